@@ -9,9 +9,11 @@ import (
 )
 
 type dumpEnvoyConfigOptions struct {
-	configFile    string
-	mockConfig    string
-	outputMapping bool
+	configFile      string
+	mockConfig      string
+	outputMapping   bool
+	accessLog       string
+	accessLogFormat string
 }
 
 var dumpEnvoyConfigOpts = &dumpEnvoyConfigOptions{}
@@ -52,6 +54,16 @@ func init() {
 		"output-mapping", false,
 		"Envoy設定の代わりにポートフォワードマッピングを出力",
 	)
+	dumpEnvoyConfigCmd.Flags().StringVar(
+		&dumpEnvoyConfigOpts.accessLog,
+		"access-log", "",
+		"全サービスのアクセスログの出力先（ファイルパスまたはstdout）",
+	)
+	dumpEnvoyConfigCmd.Flags().StringVar(
+		&dumpEnvoyConfigOpts.accessLogFormat,
+		"access-log-format", "text",
+		"アクセスログの形式: text|json",
+	)
 }
 
 func runDumpEnvoyConfig(cmd *cobra.Command, args []string) error {
@@ -63,6 +75,11 @@ func runDumpEnvoyConfig(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("config file required: use -f or provide as argument")
 	}
 
+	accessLog, err := buildAccessLog(dumpEnvoyConfigOpts.accessLog, dumpEnvoyConfigOpts.accessLogFormat)
+	if err != nil {
+		return err
+	}
+
 	cfg, err := config.Load(dumpEnvoyConfigOpts.configFile)
 	if err != nil {
 		return fmt.Errorf("failed to load config: %w", err)
@@ -73,6 +90,7 @@ func runDumpEnvoyConfig(cmd *cobra.Command, args []string) error {
 	opts := dump.DumpOptions{
 		MockConfigPath: dumpEnvoyConfigOpts.mockConfig,
 		OutputMapping:  dumpEnvoyConfigOpts.outputMapping,
+		AccessLog:      accessLog,
 	}
 
 	return dump.DumpEnvoyConfigWithOptions(ctx, cfg, opts)
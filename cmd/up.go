@@ -8,12 +8,18 @@ import (
 
 	"github.com/spf13/cobra"
 	"github.com/usadamasa/kubectl-localmesh/internal/config"
+	"github.com/usadamasa/kubectl-localmesh/internal/envoy"
 	"github.com/usadamasa/kubectl-localmesh/internal/run"
 )
 
 type upOptions struct {
-	configFile  string
-	noEditHosts bool
+	configFile      string
+	noEditHosts     bool
+	accessLog       string
+	accessLogFormat string
+	watch           bool
+	proxy           string
+	envoyRuntime    string
 }
 
 var upOpts = &upOptions{}
@@ -22,12 +28,17 @@ var upCmd = &cobra.Command{
 	Use:   "up [config-file]",
 	Short: "Start the local service mesh",
 	Long: `Start kubectl port-forward processes for all configured services
-and run a local Envoy proxy for host-based routing.
+and run a local Envoy proxy (or the built-in Go proxy with --proxy builtin)
+for host-based routing.
 
 Examples:
   kubectl-localmesh up -f services.yaml
   kubectl-localmesh up services.yaml
-  kubectl-localmesh up -f services.yaml --no-edit-hosts`,
+  kubectl-localmesh up -f services.yaml --no-edit-hosts
+  kubectl-localmesh up -f services.yaml --access-log stdout --access-log-format json
+  kubectl-localmesh up -f services.yaml --watch
+  kubectl-localmesh up -f services.yaml --proxy builtin
+  kubectl-localmesh up -f services.yaml --envoy-runtime docker`,
 	RunE: runUp,
 }
 
@@ -36,6 +47,23 @@ func init() {
 
 	upCmd.Flags().StringVarP(&upOpts.configFile, "config", "f", "", "config yaml path")
 	upCmd.Flags().BoolVar(&upOpts.noEditHosts, "no-edit-hosts", false, "skip updating /etc/hosts")
+	upCmd.Flags().StringVar(&upOpts.accessLog, "access-log", "", "write access logs for all services to a file path or 'stdout'")
+	upCmd.Flags().StringVar(&upOpts.accessLogFormat, "access-log-format", "text", "access log format: text|json")
+	upCmd.Flags().BoolVar(&upOpts.watch, "watch", false, "watch the config file and the files it references, and apply changes without restarting")
+	upCmd.Flags().StringVar(&upOpts.proxy, "proxy", run.ProxyEnvoy, "proxy runtime: envoy|builtin (builtin needs no envoy binary)")
+	upCmd.Flags().StringVar(&upOpts.envoyRuntime, "envoy-runtime", run.EnvoyRuntimeHost, "how to run envoy: host|docker|podman (docker/podman run the "+run.EnvoyImage+" image)")
+}
+
+// buildAccessLog は--access-log/--access-log-formatからアクセスログ設定を生成
+// --access-logが未指定の場合はnilを返す
+func buildAccessLog(path, format string) (*envoy.AccessLog, error) {
+	if err := config.ValidateAccessLogFormat(format); err != nil {
+		return nil, fmt.Errorf("--access-log-format %w", err)
+	}
+	if path == "" {
+		return nil, nil
+	}
+	return &envoy.AccessLog{Path: path, Format: format}, nil
 }
 
 func runUp(cmd *cobra.Command, args []string) error {
@@ -48,6 +76,20 @@ func runUp(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("config file required: use -f or provide as argument")
 	}
 
+	accessLog, err := buildAccessLog(upOpts.accessLog, upOpts.accessLogFormat)
+	if err != nil {
+		return err
+	}
+	if err := run.ValidateProxy(upOpts.proxy); err != nil {
+		return fmt.Errorf("--proxy %w", err)
+	}
+	if err := run.ValidateEnvoyRuntime(upOpts.envoyRuntime); err != nil {
+		return fmt.Errorf("--envoy-runtime %w", err)
+	}
+	if upOpts.proxy == run.ProxyBuiltin && upOpts.envoyRuntime != run.EnvoyRuntimeHost {
+		return fmt.Errorf("--envoy-runtime cannot be used with --proxy %s", run.ProxyBuiltin)
+	}
+
 	// 設定ファイルの読み込み
 	cfg, err := config.Load(upOpts.configFile)
 	if err != nil {
@@ -63,5 +105,17 @@ func runUp(cmd *cobra.Command, args []string) error {
 	// 論理反転: noEditHosts=false → updateHosts=true
 	updateHosts := !upOpts.noEditHosts
 
-	return run.Run(ctx, cfg, globalLogLevel, updateHosts)
+	var watchPath string
+	if upOpts.watch {
+		watchPath = upOpts.configFile
+	}
+
+	return run.RunWithOptions(ctx, cfg, run.RunOptions{
+		LogLevel:     globalLogLevel,
+		UpdateHosts:  updateHosts,
+		AccessLog:    accessLog,
+		WatchPath:    watchPath,
+		Proxy:        upOpts.proxy,
+		EnvoyRuntime: upOpts.envoyRuntime,
+	})
 }
@@ -138,6 +138,26 @@ func TestUpCommand_NoEditHosts(t *testing.T) {
 	}
 }
 
+func TestBuildAccessLog(t *testing.T) {
+	// --access-log未指定の場合は出力しない
+	got, err := buildAccessLog("", "text")
+	if err != nil || got != nil {
+		t.Errorf("expected nil access log, got %+v, %v", got, err)
+	}
+
+	got, err = buildAccessLog("stdout", "json")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got.Path != "stdout" || got.Format != "json" {
+		t.Errorf("expected stdout/json, got %+v", got)
+	}
+
+	if _, err := buildAccessLog("stdout", "xml"); err == nil || !strings.Contains(err.Error(), "--access-log-format") {
+		t.Errorf("expected --access-log-format error, got %v", err)
+	}
+}
+
 func TestMain(m *testing.M) {
 	// テスト実行
 	code := m.Run()
@@ -5,12 +5,14 @@ import (
 
 	"github.com/spf13/cobra"
 	"github.com/usadamasa/kubectl-localmesh/internal/config"
+	"github.com/usadamasa/kubectl-localmesh/internal/dump"
 	"github.com/usadamasa/kubectl-localmesh/internal/validate"
 )
 
 type validateOptions struct {
 	configFile string
 	strict     bool
+	mockConfig string
 }
 
 var validateOpts = &validateOptions{}
@@ -22,11 +24,14 @@ var validateCmd = &cobra.Command{
 
 By default, runs Go-level validation (same as 'up' command).
 With --strict, additionally validates against JSON Schema (detects typos, unknown fields).
+envoy_overrides are applied to the generated Envoy config without connecting to the cluster.
+Use --mock-config when patches target clusters or listeners of services using port_name.
 
 Examples:
   kubectl-localmesh validate -f services.yaml
   kubectl-localmesh validate services.yaml
-  kubectl-localmesh validate -f services.yaml --strict`,
+  kubectl-localmesh validate -f services.yaml --strict
+  kubectl-localmesh validate -f services.yaml --mock-config mocks.yaml`,
 	RunE: runValidate,
 }
 
@@ -35,6 +40,7 @@ func init() {
 
 	validateCmd.Flags().StringVarP(&validateOpts.configFile, "config", "f", "", "config yaml path")
 	validateCmd.Flags().BoolVar(&validateOpts.strict, "strict", false, "additionally validate against JSON Schema (detects typos, unknown fields)")
+	validateCmd.Flags().StringVar(&validateOpts.mockConfig, "mock-config", "", "mock config file path used to resolve service ports when applying envoy_overrides")
 }
 
 func runValidate(cmd *cobra.Command, args []string) error {
@@ -48,11 +54,16 @@ func runValidate(cmd *cobra.Command, args []string) error {
 	}
 
 	// Go-level validation (config.Load)
-	_, err := config.Load(validateOpts.configFile)
+	cfg, err := config.Load(validateOpts.configFile)
 	if err != nil {
 		return fmt.Errorf("validation failed: %w", err)
 	}
 
+	// envoy_overridesが生成したEnvoy設定に適用できること
+	if err := dump.ValidateOverrides(cmd.Context(), cfg, validateOpts.mockConfig); err != nil {
+		return fmt.Errorf("validation failed: %w", err)
+	}
+
 	// JSON Schema validation (optional)
 	if validateOpts.strict {
 		result, err := validate.ValidateSchemaFile(validateOpts.configFile)
@@ -4,12 +4,14 @@ import (
 	"bytes"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 )
 
 func resetValidateOpts() {
 	validateOpts.configFile = ""
 	validateOpts.strict = false
+	validateOpts.mockConfig = ""
 }
 
 func TestValidateCmd_ValidConfig(t *testing.T) {
@@ -231,3 +233,94 @@ listener_port: 80
 		t.Error("expected error for config with no services")
 	}
 }
+
+func TestValidateCmd_EnvoyOverrides(t *testing.T) {
+	tests := []struct {
+		name      string
+		overrides string
+		wantErr   string
+	}{
+		{
+			name: "適用できるパッチ",
+			overrides: `
+envoy_overrides:
+  - patch:
+      stats_flush_interval: 10s
+  - listener: listener_http
+    patch:
+      - op: add
+        path: /per_connection_buffer_limit_bytes
+        value: 32768
+  - cluster: test_test_svc_8080
+    patch:
+      connect_timeout: 5s
+`,
+		},
+		{
+			name: "存在しないクラスタ",
+			overrides: `
+envoy_overrides:
+  - cluster: missing
+    patch:
+      connect_timeout: 5s
+`,
+			wantErr: `envoy_overrides[0]: cluster "missing" not found`,
+		},
+		{
+			name: "適用できないJSON Patch",
+			overrides: `
+envoy_overrides:
+  - patch:
+      - op: remove
+        path: /missing_field
+`,
+			wantErr: "envoy_overrides[0]: failed to apply JSON patch",
+		},
+		{
+			name: "Envoyの設定として不正なフィールド",
+			overrides: `
+envoy_overrides:
+  - listener: listener_http
+    patch:
+      unknown_field: true
+`,
+			wantErr: "envoy_overrides[0]: patched config is not a valid envoy bootstrap",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetValidateOpts()
+			content := `
+listener_port: 80
+services:
+  - kind: kubernetes
+    host: test.localhost
+    namespace: test
+    service: test-svc
+    port: 8080
+    protocol: http
+` + tt.overrides
+			configPath := filepath.Join(t.TempDir(), "config.yaml")
+			if err := os.WriteFile(configPath, []byte(content), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			cmd := rootCmd
+			cmd.SetArgs([]string{"validate", "-f", configPath})
+			cmd.SetOut(new(bytes.Buffer))
+			cmd.SetErr(new(bytes.Buffer))
+
+			err := cmd.Execute()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("expected no error, got: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got: %v", tt.wantErr, err)
+			}
+		})
+	}
+}
@@ -1,9 +1,17 @@
 package config
 
 import (
+	"encoding/json"
 	"fmt"
+	"maps"
+	"net"
 	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
 
 	"gopkg.in/yaml.v3"
 
@@ -11,10 +19,179 @@ import (
 )
 
 type Config struct {
-	ListenerPort port.ListenerPort      `yaml:"listener_port"`
-	Cluster      string                 `yaml:"cluster,omitempty"`
-	SSHBastions  map[string]*SSHBastion `yaml:"ssh_bastions,omitempty"`
-	Services     []ServiceDefinition    `yaml:"services"`
+	ListenerPort  port.ListenerPort            `yaml:"listener_port"`
+	Listeners     map[string]port.ListenerPort `yaml:"listeners,omitempty"`    // 複数のホストで共有する名前付きHTTPリスナー（名前 -> ポート）
+	BindAddress   string                       `yaml:"bind_address,omitempty"` // HTTPリスナーのバインドアドレス（省略時はloopbackのみ）
+	Cluster       string                       `yaml:"cluster,omitempty"`
+	GRPCAggregate *GRPCAggregate               `yaml:"grpc_aggregate,omitempty"`
+	Dashboard     *Dashboard                   `yaml:"dashboard,omitempty"`     // サービス一覧のダッシュボード（省略時は無効）
+	RateLimit     *RateLimit                   `yaml:"rate_limit,omitempty"`    // リスナー単位のレート制限（全ホスト共通）
+	Tracing       *Tracing                     `yaml:"tracing,omitempty"`       // OpenTelemetryトレーシング
+	ListenerAuth  *ListenerAuth                `yaml:"listener_auth,omitempty"` // リスナーの認証・送信元IP制限
+	SSHBastions   map[string]*SSHBastion       `yaml:"ssh_bastions,omitempty"`
+	Services      []ServiceDefinition          `yaml:"services"`
+
+	EnvoyOverrides []*EnvoyOverride `yaml:"envoy_overrides,omitempty"` // 生成したEnvoy設定へのパッチ
+}
+
+// DefaultListenerName はサービスのlistenersでlistener_portの共通HTTPリスナーを指す名前
+const DefaultListenerName = "default"
+
+// listenerNamePattern は名前付きリスナーの名前として受け付ける文字列（Envoyのリスナー名に使用する）
+var listenerNamePattern = regexp.MustCompile(`^[a-z0-9]([a-z0-9_-]*[a-z0-9])?$`)
+
+// DefaultBindAddresses はbind_address省略時のHTTPリスナーのバインドアドレス
+// 同じLAN上の他のホストから到達できないようloopbackのみにバインドする（::1は利用できる場合のみ）
+var DefaultBindAddresses = []string{"127.0.0.1", "::1"}
+
+// EffectiveBindAddresses はHTTPリスナーのバインドアドレスを返す（省略時はDefaultBindAddresses）
+func (c *Config) EffectiveBindAddresses() []string {
+	if c.BindAddress != "" {
+		return []string{c.BindAddress}
+	}
+	return DefaultBindAddresses
+}
+
+// ListenerAuth はメッシュをlocalhost以外に公開する場合のリスナーの認証・送信元IP制限
+// loopbackからの接続は常に許可する
+type ListenerAuth struct {
+	Basic        *BasicAuth `yaml:"basic,omitempty"`         // HTTPリスナーのBasic認証
+	BearerToken  *Secret    `yaml:"bearer_token,omitempty"`  // HTTPリスナーで要求するBearerトークン
+	AllowedCIDRs []string   `yaml:"allowed_cidrs,omitempty"` // 接続を許可する送信元（HTTP・TCPリスナー、IPアドレスも可）
+}
+
+// BasicAuth はBasic認証のユーザー名とパスワード
+type BasicAuth struct {
+	Username string `yaml:"username"`
+	Password Secret `yaml:"password"`
+}
+
+// Secret は環境変数またはファイルから読み込む秘密の値（設定ファイルに直接書かない）
+type Secret struct {
+	Env  string `yaml:"env,omitempty"`  // 環境変数名
+	File string `yaml:"file,omitempty"` // ファイルのパス（設定ファイルからの相対パス、前後の空白は除く）
+
+	value string // loadで読み込んだ値
+}
+
+// GRPCAggregate はprotocol: grpcのサービスを1つのホストに集約する設定
+// /pkg.Service/Method 形式のパスをリフレクションで解決したバックエンドへルーティングする
+type GRPCAggregate struct {
+	Host string `yaml:"host"` // 集約ホスト名（例: grpc.localhost）
+}
+
+// DefaultDashboardHost はダッシュボードのデフォルトのホスト名
+const DefaultDashboardHost = "localmesh.localhost"
+
+// Dashboard はメインのHTTPリスナーで配信するサービス一覧のダッシュボード設定
+type Dashboard struct {
+	Enabled *bool  `yaml:"enabled,omitempty"` // falseの場合は無効（dashboardを指定した場合の省略時はtrue）
+	Host    string `yaml:"host,omitempty"`    // ダッシュボードのホスト名（省略時はlocalmesh.localhost）
+}
+
+// DashboardHost はダッシュボードのホスト名を返す（無効な場合は空文字列）
+// 既存の設定で新たにリスナーを開かないよう、dashboardを指定した場合のみ有効にする
+func (c *Config) DashboardHost() string {
+	switch {
+	case c.Dashboard == nil:
+		return ""
+	case c.Dashboard.Enabled != nil && !*c.Dashboard.Enabled:
+		return ""
+	case c.Dashboard.Host != "":
+		return c.Dashboard.Host
+	default:
+		return DefaultDashboardHost
+	}
+}
+
+// HTTPListenerPorts はメッシュで開くHTTPリスナーのポートを返す（validate済みであることを前提とする）
+// listener_portと名前付きリスナーは、接続するホストがある場合のみ含める
+func (c *Config) HTTPListenerPorts() []port.ListenerPort {
+	var ports []port.ListenerPort
+	add := func(p port.ListenerPort) {
+		if !slices.Contains(ports, p) {
+			ports = append(ports, p)
+		}
+	}
+	if c.GRPCAggregate != nil || c.DashboardHost() != "" {
+		add(c.ListenerPort)
+	}
+	for _, svcDef := range c.Services {
+		k, ok := svcDef.Get().(*KubernetesService)
+		if !ok {
+			continue
+		}
+		switch {
+		case k.ListenerPort != 0:
+			add(k.ListenerPort)
+		case len(k.Listeners) == 0:
+			add(c.ListenerPort)
+		default:
+			for _, name := range k.Listeners {
+				if name == DefaultListenerName {
+					add(c.ListenerPort)
+				} else {
+					add(c.Listeners[name])
+				}
+			}
+		}
+	}
+	return ports
+}
+
+// ReferencedFiles は設定が読み込むファイル（lua_file・listener_authの秘密・envoy_overridesのfile）のパスを返す
+// Load済みであることを前提とする（パスは設定ファイルのディレクトリで解決済み）
+func (c *Config) ReferencedFiles() []string {
+	var files []string
+	add := func(f string) {
+		if f != "" && !slices.Contains(files, f) {
+			files = append(files, f)
+		}
+	}
+	if a := c.ListenerAuth; a != nil {
+		if a.Basic != nil {
+			add(a.Basic.Password.File)
+		}
+		if a.BearerToken != nil {
+			add(a.BearerToken.File)
+		}
+	}
+	for _, svcDef := range c.Services {
+		if k, ok := svcDef.Get().(*KubernetesService); ok {
+			add(k.LuaFile)
+		}
+	}
+	for _, o := range c.EnvoyOverrides {
+		add(o.File)
+	}
+	return files
+}
+
+// Tracing はOpenTelemetry（OTLP/gRPC）コレクターへのトレーシング設定
+type Tracing struct {
+	Endpoint    string   `yaml:"endpoint"`               // コレクターのhost:port（例: localhost:4317）
+	Sampling    *float64 `yaml:"sampling,omitempty"`     // サンプリングするリクエストの割合（0-100、省略時は100）
+	ServiceName string   `yaml:"service_name,omitempty"` // スパンのサービス名（省略時はkubectl-localmesh）
+}
+
+// RateLimit はトークンバケット方式のレート制限設定
+// fill_intervalごとにrequests個のトークンを補充し、最大burst個まで貯める
+type RateLimit struct {
+	Requests     int    `yaml:"requests"`                // fill_intervalあたりに許可するリクエスト数
+	FillInterval string `yaml:"fill_interval,omitempty"` // トークン補充間隔（例: 1s、省略時は1s）
+	Burst        int    `yaml:"burst,omitempty"`         // バケットの最大トークン数（省略時はrequestsと同じ）
+}
+
+// EnvoyOverride は生成したEnvoy設定に適用するパッチ
+// patch（インラインYAML）またはfile（YAML/JSONファイル）のどちらかを指定し、
+// マッピングはJSON Merge Patch（RFC 7396）、リストはJSON Patch（RFC 6902）として適用する
+type EnvoyOverride struct {
+	Listener string `yaml:"listener,omitempty"` // 対象のリスナー名（省略時はbootstrap全体）
+	Cluster  string `yaml:"cluster,omitempty"`  // 対象のクラスタ名
+	Patch    any    `yaml:"patch,omitempty"`    // インラインのパッチ
+	File     string `yaml:"file,omitempty"`     // パッチファイルのパス（設定ファイルからの相対パス）
+
+	patchJSON []byte // JSONに変換したパッチ
 }
 
 type SSHBastion struct {
@@ -38,23 +215,181 @@ type ServiceDefinition struct {
 
 // KubernetesService はKubernetes Service（HTTP/gRPC）を表現
 type KubernetesService struct {
-	Host         string            `yaml:"host"`
-	Namespace    string            `yaml:"namespace"`
-	Service      string            `yaml:"service"`
-	PortName     string            `yaml:"port_name,omitempty"`
-	Port         port.ServicePort  `yaml:"port,omitempty"`
-	Protocol     string            `yaml:"protocol"`                // http|http2|grpc
-	ListenerPort port.ListenerPort `yaml:"listener_port,omitempty"` // 個別リスナーポート（指定時はHTTPリスナーを上書き）
-	Cluster      string            `yaml:"cluster,omitempty"`       // kubeconfig cluster name（オーバーライド用）
+	Host          string            `yaml:"host"`
+	Namespace     string            `yaml:"namespace"`
+	Service       string            `yaml:"service"`
+	PortName      string            `yaml:"port_name,omitempty"`
+	Port          port.ServicePort  `yaml:"port,omitempty"`
+	Protocol      string            `yaml:"protocol"`                 // http|http2|grpc
+	Aliases       []string          `yaml:"aliases,omitempty"`        // hostと同じルーティングを行う別名
+	Listeners     []string          `yaml:"listeners,omitempty"`      // 接続する名前付きリスナー（defaultはlistener_port、省略時はlistener_portのみ）
+	ListenerPort  port.ListenerPort `yaml:"listener_port,omitempty"`  // 個別リスナーポート（指定時はHTTPリスナーを上書き）
+	BindAddress   string            `yaml:"bind_address,omitempty"`   // 個別リスナーのバインドアドレス（省略時はグローバル設定、listener_portが必要）
+	Cluster       string            `yaml:"cluster,omitempty"`        // kubeconfig cluster name（オーバーライド用）
+	Clusters      []string          `yaml:"clusters,omitempty"`       // フェイルオーバー用のcluster name（優先順）
+	LocalOverride *LocalOverride    `yaml:"local_override,omitempty"` // ローカルで起動したプロセスを優先するフォールバック設定
+	HeaderRoutes  []HeaderRoute     `yaml:"header_routes,omitempty"`  // ヘッダーで振り分ける代替バックエンド
+	Mirror        *Mirror           `yaml:"mirror,omitempty"`         // トラフィックミラーリング先
+	Weight        *int              `yaml:"weight,omitempty"`         // 重み付き分散時のこのサービスの重み（split指定時は必須）
+	Split         []WeightedBackend `yaml:"split,omitempty"`          // 重み付き分散の追加バックエンド
+	RateLimit     *RateLimit        `yaml:"rate_limit,omitempty"`     // ホスト単位のレート制限（グローバル設定より優先）
+	AccessLog     *AccessLog        `yaml:"access_log,omitempty"`     // このホストへのリクエストのアクセスログ
+	HealthCheck   *HealthCheck      `yaml:"health_check,omitempty"`   // バックエンドのアクティブヘルスチェック
+	Lua           string            `yaml:"lua,omitempty"`            // このホストに適用するLuaスクリプト（インライン）
+	LuaFile       string            `yaml:"lua_file,omitempty"`       // このホストに適用するLuaスクリプトのパス（設定ファイルからの相対パス）
+	// RewriteRedirects はLocationヘッダーのクラスタ側ホスト名をローカルのホストに置換する
+	RewriteRedirects *HostRewrite `yaml:"rewrite_redirects,omitempty"`
+	// CookieDomainRewrite はSet-CookieのDomain属性のクラスタ側ホスト名をローカルのホストに置換する
+	CookieDomainRewrite *HostRewrite `yaml:"cookie_domain_rewrite,omitempty"`
+	// ClusterDNSAliases がtrueの場合はクラスタ内のDNS名（<service>.<namespace>[.svc[.cluster.local]]）を別名に加える
+	ClusterDNSAliases bool `yaml:"cluster_dns_aliases,omitempty"`
+
+	luaScript string // validateで読み込んだLuaスクリプト（lua/lua_fileのいずれか）
+}
+
+// KubernetesBackend はメインのバックエンド以外に接続するKubernetes Service
+// namespace・clusterは省略時に親サービスの値を引き継ぐ
+type KubernetesBackend struct {
+	Namespace string           `yaml:"namespace,omitempty"`
+	Service   string           `yaml:"service"`
+	PortName  string           `yaml:"port_name,omitempty"`
+	Port      port.ServicePort `yaml:"port,omitempty"`
+	Cluster   string           `yaml:"cluster,omitempty"` // kubeconfig cluster name（オーバーライド用）
+}
+
+// HostRewrite はレスポンスに含まれるクラスタ側のホスト名をローカルのホストに置換する設定
+// YAMLではtrue（クラスタDNS名のみ）または追加で置換するホスト名のリストを指定する
+type HostRewrite struct {
+	Enabled bool
+	Hosts   []string // クラスタDNS名に加えて置換するホスト名（公開ドメインなど）
+}
+
+// UnmarshalYAML はboolまたはホスト名のリストをHostRewriteに変換
+func (h *HostRewrite) UnmarshalYAML(node *yaml.Node) error {
+	switch node.Kind {
+	case yaml.ScalarNode:
+		if err := node.Decode(&h.Enabled); err == nil {
+			return nil
+		}
+	case yaml.SequenceNode:
+		h.Enabled = true
+		return node.Decode(&h.Hosts)
+	}
+	return fmt.Errorf("line %d: must be a boolean or a list of host names", node.Line)
+}
+
+// Mirror はリクエストを別バックエンドへ複製するトラフィックミラーリング設定
+// ミラー先の応答は破棄される（fire-and-forget）
+type Mirror struct {
+	KubernetesBackend `yaml:",inline"`
+	Percent           *float64 `yaml:"percent,omitempty"` // ミラーするリクエストの割合（0-100、省略時は100）
 }
 
 // TCPService はGCP SSH Bastion経由のTCP接続を表現
 type TCPService struct {
-	Host       string       `yaml:"host"`
-	SSHBastion string       `yaml:"ssh_bastion"`
-	TargetHost string       `yaml:"target_host"`
-	TargetPort port.TCPPort `yaml:"target_port"`
-	ListenPort port.TCPPort `yaml:"listen_port,omitempty"` // 省略時はTargetPortと同じ
+	Host        string       `yaml:"host"`
+	SSHBastion  string       `yaml:"ssh_bastion"`
+	TargetHost  string       `yaml:"target_host"`
+	TargetPort  port.TCPPort `yaml:"target_port"`
+	ListenPort  port.TCPPort `yaml:"listen_port,omitempty"`  // 省略時はTargetPortと同じ
+	Aliases     []string     `yaml:"aliases,omitempty"`      // /etc/hostsでhostと同じIPに割り当てる別名
+	AccessLog   *AccessLog   `yaml:"access_log,omitempty"`   // このサービスへの接続のアクセスログ
+	HealthCheck *HealthCheck `yaml:"health_check,omitempty"` // SSH tunnelのアクティブヘルスチェック（tcpのみ）
+	// SNI がtrueの場合はloopback IPを割り当てず、127.0.0.1のlisten_portを他のsniサービスと共有し、
+	// TLSのClientHelloのSNIで振り分ける
+	SNI         bool     `yaml:"sni,omitempty"`
+	ServerNames []string `yaml:"server_names,omitempty"` // SNIで一致させるサーバー名（省略時はhost）
+	// Protocol はデータベースのプロトコル（postgres|mysql、省略時は解釈しないTCP）
+	// 指定した場合はEnvoyのpostgres_proxy・mysql_proxyでクエリ統計を収集する
+	Protocol string `yaml:"protocol,omitempty"`
+}
+
+// EffectiveServerNames はSNIで一致させるサーバー名を返す（省略時はhost、小文字）
+func (t *TCPService) EffectiveServerNames() []string {
+	if len(t.ServerNames) == 0 {
+		return []string{strings.ToLower(t.Host)}
+	}
+	names := make([]string, len(t.ServerNames))
+	for i, n := range t.ServerNames {
+		names[i] = strings.ToLower(n)
+	}
+	return names
+}
+
+// HostAliases はhostの別名を返す（validate済みであることを前提とする）
+func (t *TCPService) HostAliases() []string {
+	return t.Aliases
+}
+
+// HostAliases はhostの別名を返す（aliasesの後にクラスタ内のDNS名、validate済みであることを前提とする）
+// クラスタ内のDNS名は/etc/hostsに書き込むため、namespaceを含まない<service>のみの名前は含めない
+func (k *KubernetesService) HostAliases() []string {
+	if !k.ClusterDNSAliases {
+		return k.Aliases
+	}
+	aliases := append([]string{}, k.Aliases...)
+	for _, name := range clusterDNSNames(k.Namespace, k.Service)[1:] {
+		if !slices.Contains(aliases, name) {
+			aliases = append(aliases, name)
+		}
+	}
+	return aliases
+}
+
+// normalizeAliases は別名を検証し、小文字に正規化する
+func normalizeAliases(aliases []string, host string) error {
+	for i, alias := range aliases {
+		alias = strings.ToLower(strings.TrimSpace(alias))
+		if alias == "" {
+			return fmt.Errorf("aliases[%d] must not be empty", i)
+		}
+		if !hostNamePattern.MatchString(alias) {
+			return fmt.Errorf("aliases[%d] must be a host name, got '%s'", i, aliases[i])
+		}
+		if alias == strings.ToLower(host) {
+			return fmt.Errorf("aliases[%d] '%s' is the same as host", i, aliases[i])
+		}
+		if slices.Contains(aliases[:i], alias) {
+			return fmt.Errorf("aliases[%d] '%s' is duplicated", i, aliases[i])
+		}
+		aliases[i] = alias
+	}
+	return nil
+}
+
+// validateAliases はサービスの別名が他のサービスのホスト名・別名と重複しないことを検証し、
+// 別名をhostsに追加する（集約gRPCホスト・ダッシュボードとの重複チェックにも使うため）
+func validateAliases(services []ServiceDefinition, hosts map[string]bool) error {
+	owners := make(map[string]string)
+	for _, svcDef := range services {
+		var aliases []string
+		switch s := svcDef.Get().(type) {
+		case *KubernetesService:
+			aliases = s.HostAliases()
+		case *TCPService:
+			aliases = s.HostAliases()
+		}
+		host := svcDef.Get().GetHost()
+		for _, alias := range aliases {
+			if hosts[alias] {
+				return fmt.Errorf("alias '%s' of service '%s' conflicts with a service host", alias, host)
+			}
+			if owner, ok := owners[alias]; ok {
+				return fmt.Errorf("alias '%s' is used by both services '%s' and '%s'", alias, owner, host)
+			}
+			owners[alias] = host
+		}
+	}
+	for alias := range owners {
+		hosts[alias] = true
+	}
+	return nil
+}
+
+// AccessLog はサービス単位のアクセスログ設定
+type AccessLog struct {
+	Path   string `yaml:"path"`             // 出力先のファイルパス、または stdout
+	Format string `yaml:"format,omitempty"` // text|json（省略時はtext）
 }
 
 // インターフェース実装
@@ -171,6 +506,9 @@ func (k *KubernetesService) Validate(cfg *Config) error {
 	if k.Protocol != "http" && k.Protocol != "http2" && k.Protocol != "grpc" {
 		return fmt.Errorf("protocol must be 'http', 'http2', or 'grpc' for kubernetes service '%s', got '%s'", k.Host, k.Protocol)
 	}
+	if err := normalizeAliases(k.Aliases, k.Host); err != nil {
+		return fmt.Errorf("%w for kubernetes service '%s'", err, k.Host)
+	}
 
 	// ListenerPortのバリデーション（共通関数使用）
 	if k.ListenerPort != 0 {
@@ -179,10 +517,696 @@ func (k *KubernetesService) Validate(cfg *Config) error {
 		}
 		port.WarnPrivilegedPort(k.ListenerPort, "listener_port", k.Host)
 	}
+	if len(k.Listeners) > 0 {
+		if k.ListenerPort != 0 {
+			return fmt.Errorf("listeners and listener_port are mutually exclusive for kubernetes service '%s'", k.Host)
+		}
+		for i, name := range k.Listeners {
+			if _, ok := cfg.Listeners[name]; !ok && name != DefaultListenerName {
+				return fmt.Errorf("listener '%s' not found for kubernetes service '%s'", name, k.Host)
+			}
+			if slices.Contains(k.Listeners[:i], name) {
+				return fmt.Errorf("listeners[%d] '%s' is duplicated for kubernetes service '%s'", i, name, k.Host)
+			}
+		}
+	}
+	if k.BindAddress != "" {
+		// 共通HTTPリスナーはすべてのホストで共有するため、個別リスナーのみアドレスを変更できる
+		if k.ListenerPort == 0 {
+			return fmt.Errorf("bind_address requires listener_port for kubernetes service '%s'", k.Host)
+		}
+		if err := validateBindAddress(k.BindAddress); err != nil {
+			return fmt.Errorf("%w for kubernetes service '%s'", err, k.Host)
+		}
+	}
+
+	if err := k.validateClusters(); err != nil {
+		return err
+	}
+
+	if k.LocalOverride != nil {
+		if err := k.LocalOverride.validate(k); err != nil {
+			return err
+		}
+	}
+
+	if k.Mirror != nil {
+		if err := k.Mirror.validate(k); err != nil {
+			return err
+		}
+	}
+
+	if err := k.validateSplit(); err != nil {
+		return err
+	}
 
+	if err := k.validateHeaderRoutes(); err != nil {
+		return err
+	}
+
+	if k.RateLimit != nil {
+		if err := k.RateLimit.validate(); err != nil {
+			return fmt.Errorf("%w for kubernetes service '%s'", err, k.Host)
+		}
+	}
+
+	if k.AccessLog != nil {
+		if err := k.AccessLog.validate(); err != nil {
+			return fmt.Errorf("%w for kubernetes service '%s'", err, k.Host)
+		}
+	}
+
+	if k.HealthCheck != nil {
+		if k.LocalOverride != nil {
+			return fmt.Errorf("health_check and local_override cannot be used together for kubernetes service '%s'", k.Host)
+		}
+		if err := k.HealthCheck.validate(k.Protocol); err != nil {
+			return fmt.Errorf("%w for kubernetes service '%s'", err, k.Host)
+		}
+	}
+
+	if err := k.validateLua(); err != nil {
+		return err
+	}
+
+	if err := k.RewriteRedirects.validate("rewrite_redirects"); err != nil {
+		return fmt.Errorf("%w for kubernetes service '%s'", err, k.Host)
+	}
+	if err := k.CookieDomainRewrite.validate("cookie_domain_rewrite"); err != nil {
+		return fmt.Errorf("%w for kubernetes service '%s'", err, k.Host)
+	}
+
+	return nil
+}
+
+// validateLua はLuaスクリプトの設定を検証し、lua_fileの場合はファイルを読み込む
+func (k *KubernetesService) validateLua() error {
+	if k.Lua == "" && k.LuaFile == "" {
+		return nil
+	}
+	if k.Lua != "" && k.LuaFile != "" {
+		return fmt.Errorf("lua and lua_file cannot be used together for kubernetes service '%s'", k.Host)
+	}
+
+	field, script := "lua", k.Lua
+	if k.LuaFile != "" {
+		b, err := os.ReadFile(k.LuaFile)
+		if err != nil {
+			return fmt.Errorf("failed to read lua_file for kubernetes service '%s': %w", k.Host, err)
+		}
+		field, script = "lua_file", string(b)
+	}
+
+	// Envoyはエントリポイントの関数が無いスクリプトを何もせず受け付けるため、ここで検出する
+	if !strings.Contains(script, "envoy_on_request") && !strings.Contains(script, "envoy_on_response") {
+		return fmt.Errorf("%s must define envoy_on_request or envoy_on_response for kubernetes service '%s'", field, k.Host)
+	}
+	k.luaScript = script
+	return nil
+}
+
+// LuaScript はこのホストに適用するLuaスクリプトを返す（未設定の場合は空文字列）
+// validate済みであることを前提とする
+func (k *KubernetesService) LuaScript() string {
+	return k.luaScript
+}
+
+// validate は置換対象のホスト名を検証し、小文字・先頭のドットなしに正規化
+func (h *HostRewrite) validate(field string) error {
+	if h == nil {
+		return nil
+	}
+	for i, host := range h.Hosts {
+		host = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(host), "."))
+		if host == "" {
+			return fmt.Errorf("%s[%d] must not be empty", field, i)
+		}
+		if !hostNamePattern.MatchString(host) {
+			return fmt.Errorf("%s[%d] must be a host name, got '%s'", field, i, h.Hosts[i])
+		}
+		h.Hosts[i] = host
+	}
+	return nil
+}
+
+// hostNamePattern は置換対象として受け付けるホスト名
+var hostNamePattern = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]*[a-z0-9])?(\.[a-z0-9]([a-z0-9-]*[a-z0-9])?)*$`)
+
+// RedirectHosts はLocationヘッダーで置換するホスト名を返す（無効な場合はnil）
+func (k *KubernetesService) RedirectHosts() []string {
+	return k.rewriteHosts(k.RewriteRedirects)
+}
+
+// CookieDomains はSet-CookieのDomain属性で置換するホスト名を返す（無効な場合はnil）
+func (k *KubernetesService) CookieDomains() []string {
+	return k.rewriteHosts(k.CookieDomainRewrite)
+}
+
+// rewriteHosts はバックエンドのクラスタDNS名と設定されたホスト名を重複なく返す
+// 重み付き分散・ヘッダールーティングの応答も同じホストに返るため、それらのバックエンドも対象にする
+func (k *KubernetesService) rewriteHosts(h *HostRewrite) []string {
+	if h == nil || !h.Enabled {
+		return nil
+	}
+	var hosts []string
+	seen := map[string]bool{}
+	add := func(host string) {
+		if !seen[host] {
+			seen[host] = true
+			hosts = append(hosts, host)
+		}
+	}
+	addService := func(namespace, service string) {
+		for _, host := range clusterDNSNames(namespace, service) {
+			add(host)
+		}
+	}
+
+	addService(k.Namespace, k.Service)
+	for _, b := range k.Split {
+		addService(b.Namespace, b.Service)
+	}
+	for _, r := range k.HeaderRoutes {
+		if r.Service != "" {
+			addService(r.Namespace, r.Service)
+		}
+	}
+	for _, host := range h.Hosts {
+		add(host)
+	}
+	return hosts
+}
+
+// clusterDNSNames はServiceをクラスタ内から参照する際のDNS名を返す
+func clusterDNSNames(namespace, service string) []string {
+	service = strings.ToLower(service)
+	namespace = strings.ToLower(namespace)
+	return []string{
+		service,
+		service + "." + namespace,
+		service + "." + namespace + ".svc",
+		service + "." + namespace + ".svc.cluster.local",
+	}
+}
+
+// validateClusters はクラスタ間フェイルオーバーの設定を検証
+func (k *KubernetesService) validateClusters() error {
+	if len(k.Clusters) == 0 {
+		return nil
+	}
+	if k.Cluster != "" {
+		return fmt.Errorf("cluster and clusters cannot be used together for kubernetes service '%s'", k.Host)
+	}
+	if len(k.Clusters) < 2 {
+		return fmt.Errorf("clusters requires at least 2 entries for kubernetes service '%s'", k.Host)
+	}
+	seen := make(map[string]bool, len(k.Clusters))
+	for i, c := range k.Clusters {
+		if c == "" {
+			return fmt.Errorf("clusters[%d] must not be empty for kubernetes service '%s'", i, k.Host)
+		}
+		if seen[c] {
+			return fmt.Errorf("clusters contains duplicate cluster '%s' for kubernetes service '%s'", c, k.Host)
+		}
+		seen[c] = true
+	}
 	return nil
 }
 
+// PrimaryCluster はメインのバックエンドのcluster nameを返す
+// clustersが指定されている場合は最も優先度の高いclusterを返す
+func (k *KubernetesService) PrimaryCluster() string {
+	if len(k.Clusters) > 0 {
+		return k.Clusters[0]
+	}
+	return k.Cluster
+}
+
+// validate はバックエンド設定を検証し、省略されたnamespace・clusterを親サービスから補完する
+func (b *KubernetesBackend) validate(parent *KubernetesService, field string) error {
+	if b.Service == "" {
+		return fmt.Errorf("%s.service is required for kubernetes service '%s'", field, parent.Host)
+	}
+	if b.Namespace == "" {
+		b.Namespace = parent.Namespace
+	}
+	if b.Cluster == "" {
+		b.Cluster = parent.PrimaryCluster()
+	}
+	return nil
+}
+
+// validate はミラーリング設定を検証
+func (m *Mirror) validate(parent *KubernetesService) error {
+	if err := m.KubernetesBackend.validate(parent, "mirror"); err != nil {
+		return err
+	}
+	if m.Percent != nil && (*m.Percent <= 0 || *m.Percent > 100) {
+		return fmt.Errorf("mirror.percent must be greater than 0 and at most 100 for kubernetes service '%s', got %v", parent.Host, *m.Percent)
+	}
+	return nil
+}
+
+// LocalOverride はローカルのプロセスを優先し、応答しない間はクラスタのServiceへフォールバックする設定
+type LocalOverride struct {
+	Address    string `yaml:"address"`               // ローカルプロセスのアドレス（host:port、hostはIPまたはlocalhost）
+	HealthPath string `yaml:"health_path,omitempty"` // HTTPヘルスチェックのパス（省略時はTCP接続のみで判定）
+}
+
+// validate はローカルオーバーライド設定を検証
+func (l *LocalOverride) validate(parent *KubernetesService) error {
+	if len(parent.Clusters) > 0 {
+		return fmt.Errorf("local_override and clusters cannot be used together for kubernetes service '%s'", parent.Host)
+	}
+	if l.Address == "" {
+		return fmt.Errorf("local_override.address is required for kubernetes service '%s'", parent.Host)
+	}
+	if _, _, err := parseLocalAddress(l.Address); err != nil {
+		return fmt.Errorf("local_override.address %w for kubernetes service '%s'", err, parent.Host)
+	}
+	if l.HealthPath != "" && !strings.HasPrefix(l.HealthPath, "/") {
+		return fmt.Errorf("local_override.health_path must start with '/' for kubernetes service '%s', got '%s'", parent.Host, l.HealthPath)
+	}
+	return nil
+}
+
+// Endpoint はローカルプロセスのIPアドレスとポートを返す（localhostは127.0.0.1に変換）
+// validate済みであることを前提とする
+func (l *LocalOverride) Endpoint() (string, int) {
+	host, p, _ := parseLocalAddress(l.Address)
+	return host, p
+}
+
+// parseLocalAddress はローカルプロセスのアドレス（host:port）を解析する
+// hostはIPアドレスまたはlocalhostのみ許可し、localhostは127.0.0.1に変換する
+func parseLocalAddress(address string) (string, int, error) {
+	host, portStr, err := net.SplitHostPort(address)
+	if err != nil {
+		return "", 0, fmt.Errorf("must be host:port: %w", err)
+	}
+	if host == "localhost" {
+		host = "127.0.0.1"
+	} else if net.ParseIP(host) == nil {
+		return "", 0, fmt.Errorf("host must be an IP address or 'localhost', got '%s'", host)
+	}
+	p, err := strconv.Atoi(portStr)
+	if err != nil || p < 1 || p > 65535 {
+		return "", 0, fmt.Errorf("port must be between 1 and 65535, got '%s'", portStr)
+	}
+	return host, p, nil
+}
+
+// HeaderRoute はリクエストヘッダーに一致した場合のみ代替バックエンドへ振り分けるルート
+// バックエンドはKubernetes Service（namespace/service）またはローカルプロセス（address）のいずれか
+type HeaderRoute struct {
+	Header            string `yaml:"header"`          // ヘッダー名（例: x-localmesh-route）
+	Value             string `yaml:"value,omitempty"` // 完全一致する値（省略時はヘッダーの存在のみで判定）
+	KubernetesBackend `yaml:",inline"`
+	Address           string `yaml:"address,omitempty"` // ローカルプロセスのアドレス（host:port）
+}
+
+// validateHeaderRoutes はヘッダールーティングの設定を検証
+func (k *KubernetesService) validateHeaderRoutes() error {
+	for i := range k.HeaderRoutes {
+		r := &k.HeaderRoutes[i]
+		field := fmt.Sprintf("header_routes[%d]", i)
+		if r.Header == "" {
+			return fmt.Errorf("%s.header is required for kubernetes service '%s'", field, k.Host)
+		}
+		if r.Address != "" {
+			if r.KubernetesBackend != (KubernetesBackend{}) {
+				return fmt.Errorf("%s cannot set both address and service fields for kubernetes service '%s'", field, k.Host)
+			}
+			if _, _, err := parseLocalAddress(r.Address); err != nil {
+				return fmt.Errorf("%s.address %w for kubernetes service '%s'", field, err, k.Host)
+			}
+			continue
+		}
+		if err := r.KubernetesBackend.validate(k, field); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Endpoint はローカルプロセスのIPアドレスとポートを返す（localhostは127.0.0.1に変換）
+// addressが指定され、validate済みであることを前提とする
+func (r *HeaderRoute) Endpoint() (string, int) {
+	host, p, _ := parseLocalAddress(r.Address)
+	return host, p
+}
+
+// WeightedBackend は重み付き分散の追加バックエンド
+type WeightedBackend struct {
+	KubernetesBackend `yaml:",inline"`
+	Weight            int `yaml:"weight"` // 重み（1以上）
+}
+
+// validateSplit は重み付き分散の設定を検証
+func (k *KubernetesService) validateSplit() error {
+	if len(k.Split) == 0 {
+		if k.Weight != nil {
+			return fmt.Errorf("weight requires split for kubernetes service '%s'", k.Host)
+		}
+		return nil
+	}
+
+	if k.Weight == nil {
+		return fmt.Errorf("weight is required when split is set for kubernetes service '%s'", k.Host)
+	}
+	if *k.Weight < 0 {
+		return fmt.Errorf("weight must be 0 or greater for kubernetes service '%s', got %d", k.Host, *k.Weight)
+	}
+	for i := range k.Split {
+		b := &k.Split[i]
+		field := fmt.Sprintf("split[%d]", i)
+		if err := b.KubernetesBackend.validate(k, field); err != nil {
+			return err
+		}
+		if b.Weight < 1 {
+			return fmt.Errorf("%s.weight must be 1 or greater for kubernetes service '%s', got %d", field, k.Host, b.Weight)
+		}
+	}
+	return nil
+}
+
+// EffectivePercent はミラーする割合を返す（省略時は100）
+func (m *Mirror) EffectivePercent() float64 {
+	if m.Percent == nil {
+		return 100
+	}
+	return *m.Percent
+}
+
+// validate はトレーシング設定を検証し、省略されたフィールドにデフォルト値を設定
+func (t *Tracing) validate() error {
+	t.Endpoint = strings.TrimSpace(t.Endpoint)
+	t.ServiceName = strings.TrimSpace(t.ServiceName)
+	if t.Endpoint == "" {
+		return fmt.Errorf("tracing.endpoint is required")
+	}
+	host, portStr, err := net.SplitHostPort(t.Endpoint)
+	if err != nil {
+		return fmt.Errorf("tracing.endpoint must be host:port: %w", err)
+	}
+	if host == "" {
+		return fmt.Errorf("tracing.endpoint host must not be empty, got '%s'", t.Endpoint)
+	}
+	if p, err := strconv.Atoi(portStr); err != nil || p < 1 || p > 65535 {
+		return fmt.Errorf("tracing.endpoint port must be between 1 and 65535, got '%s'", portStr)
+	}
+	if t.Sampling != nil && (*t.Sampling < 0 || *t.Sampling > 100) {
+		return fmt.Errorf("tracing.sampling must be between 0 and 100, got %v", *t.Sampling)
+	}
+	if t.ServiceName == "" {
+		t.ServiceName = "kubectl-localmesh"
+	}
+	return nil
+}
+
+// Collector はコレクターのホストとポートを返す（localhostは127.0.0.1に変換）
+// validate済みであることを前提とする
+func (t *Tracing) Collector() (string, int) {
+	host, portStr, _ := net.SplitHostPort(t.Endpoint)
+	if host == "localhost" {
+		host = "127.0.0.1"
+	}
+	p, _ := strconv.Atoi(portStr)
+	return host, p
+}
+
+// EffectiveSampling はサンプリングする割合を返す（省略時は100）
+func (t *Tracing) EffectiveSampling() float64 {
+	if t.Sampling == nil {
+		return 100
+	}
+	return *t.Sampling
+}
+
+// load はリスナー認証の設定を検証し、秘密の値を読み込む
+// allowed_cidrsのIPアドレスはCIDR表記（/32・/128）に変換する
+func (a *ListenerAuth) load(baseDir string) error {
+	if a.Basic == nil && a.BearerToken == nil && len(a.AllowedCIDRs) == 0 {
+		return fmt.Errorf("listener_auth requires basic, bearer_token or allowed_cidrs")
+	}
+	if a.Basic != nil {
+		a.Basic.Username = strings.TrimSpace(a.Basic.Username)
+		if a.Basic.Username == "" {
+			return fmt.Errorf("listener_auth.basic.username is required")
+		}
+		if strings.Contains(a.Basic.Username, ":") {
+			return fmt.Errorf("listener_auth.basic.username must not contain ':', got '%s'", a.Basic.Username)
+		}
+		if err := a.Basic.Password.load(baseDir); err != nil {
+			return fmt.Errorf("listener_auth.basic.password %w", err)
+		}
+	}
+	if a.BearerToken != nil {
+		if err := a.BearerToken.load(baseDir); err != nil {
+			return fmt.Errorf("listener_auth.bearer_token %w", err)
+		}
+	}
+	for i, c := range a.AllowedCIDRs {
+		c = strings.TrimSpace(c)
+		if ip := net.ParseIP(c); ip != nil {
+			if ip.To4() != nil {
+				c += "/32"
+			} else {
+				c += "/128"
+			}
+		}
+		if _, _, err := net.ParseCIDR(c); err != nil {
+			return fmt.Errorf("listener_auth.allowed_cidrs[%d] must be a CIDR or an IP address, got '%s'", i, a.AllowedCIDRs[i])
+		}
+		a.AllowedCIDRs[i] = c
+	}
+	return nil
+}
+
+// load はenvまたはfileから秘密の値を読み込む
+func (s *Secret) load(baseDir string) error {
+	s.Env = strings.TrimSpace(s.Env)
+	s.File = strings.TrimSpace(s.File)
+	if (s.Env == "") == (s.File == "") {
+		return fmt.Errorf("requires exactly one of env or file")
+	}
+	if s.Env != "" {
+		s.value = os.Getenv(s.Env)
+		if s.value == "" {
+			return fmt.Errorf("environment variable '%s' is not set", s.Env)
+		}
+		return nil
+	}
+	if !filepath.IsAbs(s.File) {
+		s.File = filepath.Join(baseDir, s.File)
+	}
+	b, err := os.ReadFile(s.File)
+	if err != nil {
+		return fmt.Errorf("failed to read file: %w", err)
+	}
+	s.value = strings.TrimSpace(string(b))
+	if s.value == "" {
+		return fmt.Errorf("file %s is empty", s.File)
+	}
+	return nil
+}
+
+// Value は読み込んだ秘密の値を返す
+// load済みであることを前提とする
+func (s *Secret) Value() string {
+	return s.value
+}
+
+// load はパッチ設定を検証し、fileの場合はファイルを読み込んでJSONに変換する
+func (o *EnvoyOverride) load(baseDir string) error {
+	o.Listener = strings.TrimSpace(o.Listener)
+	o.Cluster = strings.TrimSpace(o.Cluster)
+	o.File = strings.TrimSpace(o.File)
+
+	if o.Listener != "" && o.Cluster != "" {
+		return fmt.Errorf("listener and cluster cannot be used together")
+	}
+	if o.Patch != nil && o.File != "" {
+		return fmt.Errorf("patch and file cannot be used together")
+	}
+	if o.Patch == nil && o.File == "" {
+		return fmt.Errorf("patch or file is required")
+	}
+
+	patch := o.Patch
+	if o.File != "" {
+		if !filepath.IsAbs(o.File) {
+			o.File = filepath.Join(baseDir, o.File)
+		}
+		b, err := os.ReadFile(o.File)
+		if err != nil {
+			return fmt.Errorf("failed to read file: %w", err)
+		}
+		// JSONはYAMLとして読み込める
+		if err := yaml.Unmarshal(b, &patch); err != nil {
+			return fmt.Errorf("failed to parse file %s: %w", o.File, err)
+		}
+	}
+
+	switch patch.(type) {
+	case map[string]any, []any:
+	default:
+		return fmt.Errorf("patch must be a mapping (JSON merge patch) or a list (JSON patch)")
+	}
+	b, err := json.Marshal(patch)
+	if err != nil {
+		return fmt.Errorf("patch cannot be converted to JSON: %w", err)
+	}
+	o.patchJSON = b
+	return nil
+}
+
+// PatchJSON はJSONに変換したパッチを返す
+// load済みであることを前提とする
+func (o *EnvoyOverride) PatchJSON() []byte {
+	return o.patchJSON
+}
+
+// validate はレート制限設定を検証
+func (r *RateLimit) validate() error {
+	if r.Requests < 1 {
+		return fmt.Errorf("rate_limit.requests must be at least 1, got %d", r.Requests)
+	}
+	if r.FillInterval != "" {
+		d, err := time.ParseDuration(r.FillInterval)
+		if err != nil {
+			return fmt.Errorf("rate_limit.fill_interval is invalid: %w", err)
+		}
+		// Envoyのlocal_ratelimitは50ms未満の補充間隔を受け付けない
+		if d < 50*time.Millisecond {
+			return fmt.Errorf("rate_limit.fill_interval must be at least 50ms, got '%s'", r.FillInterval)
+		}
+	}
+	if r.Burst != 0 && r.Burst < r.Requests {
+		return fmt.Errorf("rate_limit.burst must be greater than or equal to requests (%d), got %d", r.Requests, r.Burst)
+	}
+	return nil
+}
+
+// validate はアクセスログ設定を検証し、formatの省略時はtextを設定
+func (a *AccessLog) validate() error {
+	if a.Path == "" {
+		return fmt.Errorf("access_log.path is required")
+	}
+	if a.Format == "" {
+		a.Format = "text"
+	}
+	if err := ValidateAccessLogFormat(a.Format); err != nil {
+		return fmt.Errorf("access_log.format %w", err)
+	}
+	return nil
+}
+
+// HealthCheck はバックエンドのアクティブヘルスチェック設定
+// Envoyのヘルスチェックとして設定し、失敗が続いた場合はport-forward・SSH tunnelを再接続する
+type HealthCheck struct {
+	Type               string `yaml:"type"`                          // http|grpc|tcp
+	Path               string `yaml:"path,omitempty"`                // HTTPヘルスチェックのパス（type: httpのみ）
+	Service            string `yaml:"service,omitempty"`             // gRPC Health Checking Protocolのサービス名（type: grpcのみ、省略時はサーバー全体）
+	Interval           string `yaml:"interval,omitempty"`            // チェック間隔（省略時は5s）
+	UnhealthyThreshold int    `yaml:"unhealthy_threshold,omitempty"` // unhealthyとする連続失敗回数（省略時は3）
+}
+
+// validate はヘルスチェック設定を検証
+// protocolはサービスのプロトコル（tcpサービスの場合はtcp）
+func (h *HealthCheck) validate(protocol string) error {
+	switch h.Type {
+	case "http":
+		if protocol == "tcp" {
+			return fmt.Errorf("health_check.type must be 'tcp' for tcp services, got '%s'", h.Type)
+		}
+		if !strings.HasPrefix(h.Path, "/") {
+			return fmt.Errorf("health_check.path must start with '/', got '%s'", h.Path)
+		}
+	case "grpc":
+		if protocol != "grpc" {
+			return fmt.Errorf("health_check.type 'grpc' requires protocol 'grpc', got '%s'", protocol)
+		}
+	case "tcp":
+	default:
+		return fmt.Errorf("health_check.type must be 'http', 'grpc', or 'tcp', got '%s'", h.Type)
+	}
+	if h.Path != "" && h.Type != "http" {
+		return fmt.Errorf("health_check.path can only be used with type 'http'")
+	}
+	if h.Service != "" && h.Type != "grpc" {
+		return fmt.Errorf("health_check.service can only be used with type 'grpc'")
+	}
+	if h.Interval != "" {
+		d, err := time.ParseDuration(h.Interval)
+		if err != nil {
+			return fmt.Errorf("health_check.interval is invalid: %w", err)
+		}
+		if d < time.Second {
+			return fmt.Errorf("health_check.interval must be at least 1s, got '%s'", h.Interval)
+		}
+	}
+	if h.UnhealthyThreshold < 0 {
+		return fmt.Errorf("health_check.unhealthy_threshold must be at least 1, got %d", h.UnhealthyThreshold)
+	}
+	return nil
+}
+
+// EffectiveInterval はチェック間隔を返す（省略時は5秒）
+// validate済みであることを前提とする
+func (h *HealthCheck) EffectiveInterval() time.Duration {
+	if h.Interval == "" {
+		return 5 * time.Second
+	}
+	d, _ := time.ParseDuration(h.Interval)
+	return d
+}
+
+// EffectiveUnhealthyThreshold はunhealthyとする連続失敗回数を返す（省略時は3）
+func (h *HealthCheck) EffectiveUnhealthyThreshold() int {
+	if h.UnhealthyThreshold == 0 {
+		return 3
+	}
+	return h.UnhealthyThreshold
+}
+
+// trim は文字列フィールドをトリム（未設定の場合は何もしない）
+func (h *HealthCheck) trim() {
+	if h == nil {
+		return
+	}
+	h.Type = strings.TrimSpace(h.Type)
+	h.Path = strings.TrimSpace(h.Path)
+	h.Service = strings.TrimSpace(h.Service)
+	h.Interval = strings.TrimSpace(h.Interval)
+}
+
+// ValidateAccessLogFormat はアクセスログの形式（text|json）を検証
+func ValidateAccessLogFormat(format string) error {
+	if format != "text" && format != "json" {
+		return fmt.Errorf("must be 'text' or 'json', got '%s'", format)
+	}
+	return nil
+}
+
+// EffectiveFillInterval はトークン補充間隔を返す（省略時は1秒）
+// validate済みであることを前提とする
+func (r *RateLimit) EffectiveFillInterval() time.Duration {
+	if r.FillInterval == "" {
+		return time.Second
+	}
+	d, _ := time.ParseDuration(r.FillInterval)
+	return d
+}
+
+// EffectiveBurst はバケットの最大トークン数を返す（省略時はrequestsと同じ）
+func (r *RateLimit) EffectiveBurst() int {
+	if r.Burst == 0 {
+		return r.Requests
+	}
+	return r.Burst
+}
+
 func (t *TCPService) Validate(cfg *Config) error {
 	if t.Host == "" {
 		return fmt.Errorf("host is required for tcp service")
@@ -207,6 +1231,44 @@ func (t *TCPService) Validate(cfg *Config) error {
 		t.ListenPort = t.TargetPort
 	}
 
+	if t.AccessLog != nil {
+		if err := t.AccessLog.validate(); err != nil {
+			return fmt.Errorf("%w for tcp service '%s'", err, t.Host)
+		}
+	}
+
+	if t.HealthCheck != nil {
+		if err := t.HealthCheck.validate("tcp"); err != nil {
+			return fmt.Errorf("%w for tcp service '%s'", err, t.Host)
+		}
+	}
+
+	if len(t.ServerNames) > 0 && !t.SNI {
+		return fmt.Errorf("server_names requires sni: true for tcp service '%s'", t.Host)
+	}
+	for i, n := range t.ServerNames {
+		if n == "" {
+			return fmt.Errorf("server_names[%d] is empty for tcp service '%s'", i, t.Host)
+		}
+		if strings.Contains(strings.TrimPrefix(n, "*."), "*") {
+			return fmt.Errorf("server_names[%d] may only use a leading '*.' wildcard, got '%s' for tcp service '%s'", i, n, t.Host)
+		}
+	}
+
+	if err := normalizeAliases(t.Aliases, t.Host); err != nil {
+		return fmt.Errorf("%w for tcp service '%s'", err, t.Host)
+	}
+
+	switch t.Protocol {
+	case "", "postgres", "mysql":
+	default:
+		return fmt.Errorf("protocol must be 'postgres' or 'mysql' for tcp service '%s', got '%s'", t.Host, t.Protocol)
+	}
+	// SNIで振り分ける接続はTLSで暗号化されているため、プロトコルを解釈できない
+	if t.Protocol != "" && t.SNI {
+		return fmt.Errorf("protocol cannot be combined with sni for tcp service '%s'", t.Host)
+	}
+
 	// 特権ポート警告
 	port.WarnPrivilegedPort(t.ListenPort, "listen_port", t.Host)
 
@@ -228,6 +1290,7 @@ func Load(path string) (*Config, error) {
 
 	// グローバルClusterのトリム
 	cfg.Cluster = strings.TrimSpace(cfg.Cluster)
+	cfg.BindAddress = strings.TrimSpace(cfg.BindAddress)
 
 	// デフォルト値設定
 	if cfg.ListenerPort == 0 {
@@ -245,7 +1308,46 @@ func Load(path string) (*Config, error) {
 		return nil, fmt.Errorf("no services configured in %s", path)
 	}
 
+	if cfg.BindAddress != "" {
+		if err := validateBindAddress(cfg.BindAddress); err != nil {
+			return nil, err
+		}
+	}
+
+	if cfg.RateLimit != nil {
+		cfg.RateLimit.FillInterval = strings.TrimSpace(cfg.RateLimit.FillInterval)
+		if err := cfg.RateLimit.validate(); err != nil {
+			return nil, err
+		}
+	}
+
+	if cfg.Tracing != nil {
+		if err := cfg.Tracing.validate(); err != nil {
+			return nil, err
+		}
+	}
+
+	if cfg.ListenerAuth != nil {
+		if err := cfg.ListenerAuth.load(filepath.Dir(path)); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := validateListeners(&cfg); err != nil {
+		return nil, err
+	}
+
+	for i, o := range cfg.EnvoyOverrides {
+		if o == nil {
+			return nil, fmt.Errorf("invalid envoy_overrides[%d]: entry is empty", i)
+		}
+		if err := o.load(filepath.Dir(path)); err != nil {
+			return nil, fmt.Errorf("invalid envoy_overrides[%d]: %w", i, err)
+		}
+	}
+
 	// バリデーション
+	hosts := make(map[string]bool, len(cfg.Services))
 	for i, svcDef := range cfg.Services {
 		svc := svcDef.Get()
 		if svc == nil {
@@ -254,36 +1356,152 @@ func Load(path string) (*Config, error) {
 
 		// 文字列フィールドのトリム（各サービス型で実施）
 		trimServiceFields(svc)
+		resolveServicePaths(svc, filepath.Dir(path))
 
 		// 各サービスのバリデーション
 		if err := svc.Validate(&cfg); err != nil {
 			return nil, fmt.Errorf("invalid service entry at index %d: %w", i, err)
 		}
+		hosts[svc.GetHost()] = true
+	}
+
+	if err := validateSNI(cfg.Services); err != nil {
+		return nil, err
 	}
 
-	// ポート競合チェック
-	// 注意: TCPサービスはここではチェックしない
+	if err := validateAliases(cfg.Services, hosts); err != nil {
+		return nil, err
+	}
+
+	// 集約gRPCホストのバリデーション
+	if cfg.GRPCAggregate != nil {
+		if err := cfg.GRPCAggregate.validate(&cfg, hosts); err != nil {
+			return nil, err
+		}
+	}
+
+	// ダッシュボードのホスト名のバリデーション
+	if cfg.Dashboard != nil {
+		cfg.Dashboard.Host = strings.TrimSpace(cfg.Dashboard.Host)
+	}
+	if host := cfg.DashboardHost(); host != "" {
+		if hosts[host] || (cfg.GRPCAggregate != nil && cfg.GRPCAggregate.Host == host) {
+			return nil, fmt.Errorf("dashboard host '%s' conflicts with a service host or alias (set dashboard.host or dashboard.enabled: false)", host)
+		}
+	}
+
+	// ポート競合チェック（HTTPリスナーは実際にバインドするアドレスで登録する）
+	// 注意: SNI以外のTCPサービスはここではチェックしない
 	// TCPサービスは実行時にloopback IPが割り当てられるため、
 	// 同じポートでも異なるIPにバインドされ競合しない
 	// （visitor.goでIP割り当て後にチェックする）
 	checker := port.NewPortConflictChecker()
-	port.RegisterPort(checker, cfg.ListenerPort, "listener_port")
+	for _, addr := range cfg.EffectiveBindAddresses() {
+		checker.RegisterWithAddr(addr, int(cfg.ListenerPort), "listener_port")
+		for _, name := range slices.Sorted(maps.Keys(cfg.Listeners)) {
+			checker.RegisterWithAddr(addr, int(cfg.Listeners[name]), "listeners."+name)
+		}
+	}
+	sniPorts := make(map[port.TCPPort]bool)
 
 	for _, svcDef := range cfg.Services {
 		svc := svcDef.Get()
 		switch s := svc.(type) {
 		case *KubernetesService:
 			if s.ListenerPort != 0 {
-				port.RegisterPort(checker, s.ListenerPort, s.Host)
+				for _, addr := range s.EffectiveBindAddresses(&cfg) {
+					checker.RegisterWithAddr(addr, int(s.ListenerPort), s.Host)
+				}
+			}
+		case *TCPService:
+			// SNIで振り分けるサービスは127.0.0.1のlisten_portを共有するため、ポートごとに1回だけ登録する
+			if s.SNI && !sniPorts[s.ListenPort] {
+				sniPorts[s.ListenPort] = true
+				checker.RegisterWithAddr("127.0.0.1", int(s.ListenPort), s.Host)
 			}
-			// TCPService は実行時にloopback IPが割り当てられてから
-			// visitor.go でチェックするため、ここではスキップ
 		}
 	}
 
 	return &cfg, nil
 }
 
+// validateListeners は名前付きリスナーの名前とポートを検証
+// 同じアドレスにバインドするため、listener_portや他の名前付きリスナーとポートを共有できない
+func validateListeners(cfg *Config) error {
+	ports := map[port.ListenerPort]string{cfg.ListenerPort: "listener_port"}
+	for _, name := range slices.Sorted(maps.Keys(cfg.Listeners)) {
+		p := cfg.Listeners[name]
+		if name == DefaultListenerName {
+			return fmt.Errorf("listener name '%s' is reserved for listener_port", name)
+		}
+		if !listenerNamePattern.MatchString(name) {
+			return fmt.Errorf("invalid listener name '%s': must consist of lowercase letters, digits, '-' and '_'", name)
+		}
+		if err := port.ValidateRequiredPort(p, "listeners."+name, "config"); err != nil {
+			return err
+		}
+		if owner, ok := ports[p]; ok {
+			return fmt.Errorf("listener '%s' uses port %d which is already used by %s", name, p, owner)
+		}
+		ports[p] = fmt.Sprintf("listener '%s'", name)
+		port.WarnPrivilegedPort(p, "listeners."+name, "config")
+	}
+	return nil
+}
+
+// validateBindAddress はbind_addressがIPアドレスであることを検証
+func validateBindAddress(addr string) error {
+	if net.ParseIP(addr) == nil {
+		return fmt.Errorf("bind_address must be an IP address, got '%s'", addr)
+	}
+	return nil
+}
+
+// EffectiveBindAddresses は個別リスナーのバインドアドレスを返す（省略時はグローバル設定）
+func (k *KubernetesService) EffectiveBindAddresses(cfg *Config) []string {
+	if k.BindAddress != "" {
+		return []string{k.BindAddress}
+	}
+	return cfg.EffectiveBindAddresses()
+}
+
+// validateSNI はlisten_portを共有するsniサービス間でサーバー名が重複していないことを検証
+func validateSNI(services []ServiceDefinition) error {
+	owners := make(map[string]string) // "port/server name" -> host
+	for _, svcDef := range services {
+		t, ok := svcDef.AsTCP()
+		if !ok || !t.SNI {
+			continue
+		}
+		for _, name := range t.EffectiveServerNames() {
+			key := fmt.Sprintf("%d/%s", t.ListenPort, name)
+			if owner, ok := owners[key]; ok && owner != t.Host {
+				return fmt.Errorf("server name '%s' on listen_port %d is used by both tcp services '%s' and '%s'", name, t.ListenPort, owner, t.Host)
+			}
+			owners[key] = t.Host
+		}
+	}
+	return nil
+}
+
+// validate は集約gRPCホスト設定を検証
+func (g *GRPCAggregate) validate(cfg *Config, hosts map[string]bool) error {
+	g.Host = strings.TrimSpace(g.Host)
+	if g.Host == "" {
+		return fmt.Errorf("host is required for grpc_aggregate")
+	}
+	if hosts[g.Host] {
+		return fmt.Errorf("grpc_aggregate host '%s' conflicts with a service host", g.Host)
+	}
+
+	for _, svcDef := range cfg.Services {
+		if k8s, ok := svcDef.AsKubernetes(); ok && k8s.Protocol == "grpc" {
+			return nil
+		}
+	}
+	return fmt.Errorf("grpc_aggregate requires at least one kubernetes service with protocol 'grpc'")
+}
+
 // trimServiceFields は文字列フィールドをトリム
 func trimServiceFields(svc Service) {
 	switch s := svc.(type) {
@@ -293,14 +1511,73 @@ func trimServiceFields(svc Service) {
 		s.Service = strings.TrimSpace(s.Service)
 		s.PortName = strings.TrimSpace(s.PortName)
 		s.Protocol = strings.TrimSpace(s.Protocol)
+		s.BindAddress = strings.TrimSpace(s.BindAddress)
+		for i := range s.Listeners {
+			s.Listeners[i] = strings.TrimSpace(s.Listeners[i])
+		}
 		s.Cluster = strings.TrimSpace(s.Cluster)
+		for i := range s.Clusters {
+			s.Clusters[i] = strings.TrimSpace(s.Clusters[i])
+		}
+		if s.LocalOverride != nil {
+			s.LocalOverride.Address = strings.TrimSpace(s.LocalOverride.Address)
+			s.LocalOverride.HealthPath = strings.TrimSpace(s.LocalOverride.HealthPath)
+		}
+		if s.Mirror != nil {
+			s.Mirror.trim()
+		}
+		for i := range s.Split {
+			s.Split[i].trim()
+		}
+		for i := range s.HeaderRoutes {
+			r := &s.HeaderRoutes[i]
+			r.Header = strings.TrimSpace(r.Header)
+			r.Address = strings.TrimSpace(r.Address)
+			r.trim()
+		}
+		if s.RateLimit != nil {
+			s.RateLimit.FillInterval = strings.TrimSpace(s.RateLimit.FillInterval)
+		}
+		s.AccessLog.trim()
+		s.HealthCheck.trim()
+		s.LuaFile = strings.TrimSpace(s.LuaFile)
 	case *TCPService:
 		s.Host = strings.TrimSpace(s.Host)
 		s.SSHBastion = strings.TrimSpace(s.SSHBastion)
 		s.TargetHost = strings.TrimSpace(s.TargetHost)
+		s.Protocol = strings.TrimSpace(s.Protocol)
+		s.AccessLog.trim()
+		s.HealthCheck.trim()
+		for i := range s.ServerNames {
+			s.ServerNames[i] = strings.TrimSpace(s.ServerNames[i])
+		}
+	}
+}
+
+// resolveServicePaths はサービスが参照するファイルの相対パスを設定ファイルのディレクトリ基準に解決
+func resolveServicePaths(svc Service, baseDir string) {
+	if s, ok := svc.(*KubernetesService); ok && s.LuaFile != "" && !filepath.IsAbs(s.LuaFile) {
+		s.LuaFile = filepath.Join(baseDir, s.LuaFile)
 	}
 }
 
+// trim は文字列フィールドをトリム（未設定の場合は何もしない）
+func (a *AccessLog) trim() {
+	if a == nil {
+		return
+	}
+	a.Path = strings.TrimSpace(a.Path)
+	a.Format = strings.TrimSpace(a.Format)
+}
+
+// trim は文字列フィールドをトリム
+func (b *KubernetesBackend) trim() {
+	b.Namespace = strings.TrimSpace(b.Namespace)
+	b.Service = strings.TrimSpace(b.Service)
+	b.PortName = strings.TrimSpace(b.PortName)
+	b.Cluster = strings.TrimSpace(b.Cluster)
+}
+
 type MockConfig struct {
 	Mocks []MockService `yaml:"mocks"`
 }
@@ -310,6 +1587,7 @@ type MockService struct {
 	Service      string           `yaml:"service"`
 	PortName     string           `yaml:"port_name"`
 	ResolvedPort port.ServicePort `yaml:"resolved_port"`
+	GRPCServices []string         `yaml:"grpc_services,omitempty"` // リフレクションで得られるgRPCサービス名（grpc_aggregate用）
 }
 
 func LoadMockConfig(path string) (*MockConfig, error) {
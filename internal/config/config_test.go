@@ -4,7 +4,10 @@ import (
 	"bytes"
 	"os"
 	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/usadamasa/kubectl-localmesh/internal/port"
 )
@@ -1391,3 +1394,2123 @@ services:
 		t.Errorf("expected listener_port 0, got %d", grpc3.ListenerPort)
 	}
 }
+
+// ========== grpc_aggregate関連テスト ==========
+
+// loadContent は設定内容を一時ファイルに書き出してLoadする
+func loadContent(t *testing.T, content string) (*Config, error) {
+	t.Helper()
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "config.yaml")
+	if err := os.WriteFile(configPath, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return Load(configPath)
+}
+
+func TestLoad_GRPCAggregate(t *testing.T) {
+	cfg, err := loadContent(t, `
+grpc_aggregate:
+  host: " grpc.localhost "
+services:
+  - kind: kubernetes
+    host: users.localhost
+    namespace: users
+    service: users-api
+    protocol: grpc
+`)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if cfg.GRPCAggregate == nil {
+		t.Fatal("expected grpc_aggregate to be loaded")
+	}
+	if cfg.GRPCAggregate.Host != "grpc.localhost" {
+		t.Errorf("expected trimmed host 'grpc.localhost', got '%s'", cfg.GRPCAggregate.Host)
+	}
+}
+
+func TestLoad_GRPCAggregate_Invalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{
+			name: "host未指定",
+			content: `
+grpc_aggregate: {}
+services:
+  - kind: kubernetes
+    host: users.localhost
+    namespace: users
+    service: users-api
+    protocol: grpc
+`,
+			wantErr: "host is required for grpc_aggregate",
+		},
+		{
+			name: "サービスのホストと重複",
+			content: `
+grpc_aggregate:
+  host: users.localhost
+services:
+  - kind: kubernetes
+    host: users.localhost
+    namespace: users
+    service: users-api
+    protocol: grpc
+`,
+			wantErr: "conflicts with a service host",
+		},
+		{
+			name: "gRPCサービスなし",
+			content: `
+grpc_aggregate:
+  host: grpc.localhost
+services:
+  - kind: kubernetes
+    host: web.localhost
+    namespace: web
+    service: web
+    protocol: http
+`,
+			wantErr: "requires at least one kubernetes service with protocol 'grpc'",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := loadContent(t, tt.content)
+			if err == nil {
+				t.Fatal("expected error, got nil")
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got %q", tt.wantErr, err.Error())
+			}
+		})
+	}
+}
+
+func TestLoad_KubernetesService_Mirror(t *testing.T) {
+	cfg, err := loadContent(t, `
+services:
+  - kind: kubernetes
+    host: users.localhost
+    namespace: users
+    service: users-api
+    protocol: http
+    cluster: dev-cluster
+    mirror:
+      service: " users-api-canary "
+      percent: 12.5
+  - kind: kubernetes
+    host: billing.localhost
+    namespace: billing
+    service: billing-api
+    protocol: http
+    mirror:
+      namespace: billing-canary
+      service: billing-api
+      cluster: canary-cluster
+`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	users, _ := cfg.Services[0].AsKubernetes()
+	if users.Mirror == nil {
+		t.Fatal("expected mirror to be set")
+	}
+	// namespace・clusterは親サービスから引き継ぐ
+	if users.Mirror.Namespace != "users" {
+		t.Errorf("expected mirror namespace 'users', got %q", users.Mirror.Namespace)
+	}
+	if users.Mirror.Service != "users-api-canary" {
+		t.Errorf("expected mirror service 'users-api-canary', got %q", users.Mirror.Service)
+	}
+	if users.Mirror.Cluster != "dev-cluster" {
+		t.Errorf("expected mirror cluster 'dev-cluster', got %q", users.Mirror.Cluster)
+	}
+	if users.Mirror.EffectivePercent() != 12.5 {
+		t.Errorf("expected mirror percent 12.5, got %v", users.Mirror.EffectivePercent())
+	}
+
+	billing, _ := cfg.Services[1].AsKubernetes()
+	if billing.Mirror.Namespace != "billing-canary" {
+		t.Errorf("expected mirror namespace 'billing-canary', got %q", billing.Mirror.Namespace)
+	}
+	if billing.Mirror.Cluster != "canary-cluster" {
+		t.Errorf("expected mirror cluster 'canary-cluster', got %q", billing.Mirror.Cluster)
+	}
+	// percent省略時は100%
+	if billing.Mirror.EffectivePercent() != 100 {
+		t.Errorf("expected mirror percent 100, got %v", billing.Mirror.EffectivePercent())
+	}
+}
+
+func TestLoad_KubernetesService_Mirror_Invalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		mirror  string
+		wantErr string
+	}{
+		{
+			name:    "service未指定",
+			mirror:  "{namespace: users-canary}",
+			wantErr: "mirror.service is required",
+		},
+		{
+			name:    "percentが0",
+			mirror:  "{service: users-api, percent: 0}",
+			wantErr: "mirror.percent must be greater than 0 and at most 100",
+		},
+		{
+			name:    "percentが100超",
+			mirror:  "{service: users-api, percent: 150}",
+			wantErr: "mirror.percent must be greater than 0 and at most 100",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := loadContent(t, `
+services:
+  - kind: kubernetes
+    host: users.localhost
+    namespace: users
+    service: users-api
+    protocol: http
+    mirror: `+tt.mirror+`
+`)
+			if err == nil {
+				t.Fatal("expected error, got nil")
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got %q", tt.wantErr, err.Error())
+			}
+		})
+	}
+}
+
+func TestLoad_KubernetesService_Split(t *testing.T) {
+	cfg, err := loadContent(t, `
+services:
+  - kind: kubernetes
+    host: users.localhost
+    namespace: users
+    service: users-api
+    protocol: http
+    weight: 90
+    split:
+      - namespace: users-canary
+        service: users-api
+        weight: 10
+      - service: users-api
+        cluster: other-cluster
+        weight: 5
+`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	svc, _ := cfg.Services[0].AsKubernetes()
+	if svc.Weight == nil || *svc.Weight != 90 {
+		t.Fatalf("expected weight 90, got %v", svc.Weight)
+	}
+	if len(svc.Split) != 2 {
+		t.Fatalf("expected 2 split backends, got %d", len(svc.Split))
+	}
+	if svc.Split[0].Namespace != "users-canary" || svc.Split[0].Weight != 10 {
+		t.Errorf("unexpected split[0]: %+v", svc.Split[0])
+	}
+	// namespaceは親サービスから引き継ぐ
+	if svc.Split[1].Namespace != "users" || svc.Split[1].Cluster != "other-cluster" {
+		t.Errorf("unexpected split[1]: %+v", svc.Split[1])
+	}
+}
+
+func TestLoad_KubernetesService_Split_Invalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		fields  string
+		wantErr string
+	}{
+		{
+			name:    "split指定時にweight未指定",
+			fields:  "split: [{service: users-api, weight: 10}]",
+			wantErr: "weight is required when split is set",
+		},
+		{
+			name:    "splitなしでweight指定",
+			fields:  "weight: 90",
+			wantErr: "weight requires split",
+		},
+		{
+			name:    "weightが負",
+			fields:  "weight: -1\n    split: [{service: users-api, weight: 10}]",
+			wantErr: "weight must be 0 or greater",
+		},
+		{
+			name:    "splitのweightが0",
+			fields:  "weight: 90\n    split: [{service: users-api, weight: 0}]",
+			wantErr: "split[0].weight must be 1 or greater",
+		},
+		{
+			name:    "splitのservice未指定",
+			fields:  "weight: 90\n    split: [{namespace: users-canary, weight: 10}]",
+			wantErr: "split[0].service is required",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := loadContent(t, `
+services:
+  - kind: kubernetes
+    host: users.localhost
+    namespace: users
+    service: users-api
+    protocol: http
+    `+tt.fields+`
+`)
+			if err == nil {
+				t.Fatal("expected error, got nil")
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got %q", tt.wantErr, err.Error())
+			}
+		})
+	}
+}
+
+func TestLoad_KubernetesService_Clusters(t *testing.T) {
+	cfg, err := loadContent(t, `
+services:
+  - kind: kubernetes
+    host: users.localhost
+    namespace: users
+    service: users-api
+    protocol: http
+    clusters: [" tokyo ", osaka]
+    mirror:
+      service: users-api-canary
+`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	svc, _ := cfg.Services[0].AsKubernetes()
+	if len(svc.Clusters) != 2 || svc.Clusters[0] != "tokyo" || svc.Clusters[1] != "osaka" {
+		t.Errorf("unexpected clusters: %v", svc.Clusters)
+	}
+	if svc.PrimaryCluster() != "tokyo" {
+		t.Errorf("expected primary cluster 'tokyo', got %q", svc.PrimaryCluster())
+	}
+	// ミラー先のclusterは最優先のclusterを引き継ぐ
+	if svc.Mirror.Cluster != "tokyo" {
+		t.Errorf("expected mirror cluster 'tokyo', got %q", svc.Mirror.Cluster)
+	}
+}
+
+func TestLoad_KubernetesService_Clusters_Invalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		fields  string
+		wantErr string
+	}{
+		{
+			name:    "clusterと併用",
+			fields:  "cluster: tokyo\n    clusters: [tokyo, osaka]",
+			wantErr: "cluster and clusters cannot be used together",
+		},
+		{
+			name:    "1つのみ",
+			fields:  "clusters: [tokyo]",
+			wantErr: "clusters requires at least 2 entries",
+		},
+		{
+			name:    "空文字",
+			fields:  "clusters: [tokyo, \" \"]",
+			wantErr: "clusters[1] must not be empty",
+		},
+		{
+			name:    "重複",
+			fields:  "clusters: [tokyo, tokyo]",
+			wantErr: "clusters contains duplicate cluster 'tokyo'",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := loadContent(t, `
+services:
+  - kind: kubernetes
+    host: users.localhost
+    namespace: users
+    service: users-api
+    protocol: http
+    `+tt.fields+`
+`)
+			if err == nil {
+				t.Fatal("expected error, got nil")
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got %q", tt.wantErr, err.Error())
+			}
+		})
+	}
+}
+
+func TestLoad_KubernetesService_LocalOverride(t *testing.T) {
+	cfg, err := loadContent(t, `
+services:
+  - kind: kubernetes
+    host: users.localhost
+    namespace: users
+    service: users-api
+    protocol: http
+    local_override:
+      address: " localhost:8080 "
+      health_path: /healthz
+`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	svc, _ := cfg.Services[0].AsKubernetes()
+	if svc.LocalOverride == nil {
+		t.Fatal("expected local_override to be set")
+	}
+	address, p := svc.LocalOverride.Endpoint()
+	// localhostは127.0.0.1に変換される
+	if address != "127.0.0.1" || p != 8080 {
+		t.Errorf("expected 127.0.0.1:8080, got %s:%d", address, p)
+	}
+	if svc.LocalOverride.HealthPath != "/healthz" {
+		t.Errorf("expected health_path '/healthz', got %q", svc.LocalOverride.HealthPath)
+	}
+}
+
+func TestLoad_KubernetesService_LocalOverride_Invalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		fields  string
+		wantErr string
+	}{
+		{
+			name:    "address未指定",
+			fields:  "local_override: {health_path: /healthz}",
+			wantErr: "local_override.address is required",
+		},
+		{
+			name:    "ポートなし",
+			fields:  "local_override: {address: localhost}",
+			wantErr: "local_override.address must be host:port",
+		},
+		{
+			name:    "ホスト名",
+			fields:  "local_override: {address: 'dev.example.com:8080'}",
+			wantErr: "local_override.address host must be an IP address or 'localhost'",
+		},
+		{
+			name:    "ポート範囲外",
+			fields:  "local_override: {address: 'localhost:70000'}",
+			wantErr: "local_override.address port must be between 1 and 65535",
+		},
+		{
+			name:    "health_pathが/で始まらない",
+			fields:  "local_override: {address: 'localhost:8080', health_path: healthz}",
+			wantErr: "local_override.health_path must start with '/'",
+		},
+		{
+			name:    "clustersと併用",
+			fields:  "clusters: [tokyo, osaka]\n    local_override: {address: 'localhost:8080'}",
+			wantErr: "local_override and clusters cannot be used together",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := loadContent(t, `
+services:
+  - kind: kubernetes
+    host: users.localhost
+    namespace: users
+    service: users-api
+    protocol: http
+    `+tt.fields+`
+`)
+			if err == nil {
+				t.Fatal("expected error, got nil")
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got %q", tt.wantErr, err.Error())
+			}
+		})
+	}
+}
+
+func TestLoad_KubernetesService_HeaderRoutes(t *testing.T) {
+	cfg, err := loadContent(t, `
+services:
+  - kind: kubernetes
+    host: users.localhost
+    namespace: users
+    service: users-api
+    protocol: http
+    header_routes:
+      - header: x-localmesh-route
+        value: alice
+        namespace: alice-dev
+        service: users-api
+      - header: x-localmesh-route
+        value: bob
+        address: localhost:8081
+`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	svc, _ := cfg.Services[0].AsKubernetes()
+	if len(svc.HeaderRoutes) != 2 {
+		t.Fatalf("expected 2 header routes, got %d", len(svc.HeaderRoutes))
+	}
+	alice := svc.HeaderRoutes[0]
+	if alice.Namespace != "alice-dev" || alice.Service != "users-api" || alice.Value != "alice" {
+		t.Errorf("unexpected header_routes[0]: %+v", alice)
+	}
+	address, p := svc.HeaderRoutes[1].Endpoint()
+	if address != "127.0.0.1" || p != 8081 {
+		t.Errorf("expected 127.0.0.1:8081, got %s:%d", address, p)
+	}
+}
+
+func TestLoad_KubernetesService_HeaderRoutes_Invalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		route   string
+		wantErr string
+	}{
+		{
+			name:    "header未指定",
+			route:   "{value: alice, service: users-api}",
+			wantErr: "header_routes[0].header is required",
+		},
+		{
+			name:    "バックエンド未指定",
+			route:   "{header: x-localmesh-route, value: alice}",
+			wantErr: "header_routes[0].service is required",
+		},
+		{
+			name:    "addressとserviceを両方指定",
+			route:   "{header: x-localmesh-route, service: users-api, address: 'localhost:8081'}",
+			wantErr: "header_routes[0] cannot set both address and service fields",
+		},
+		{
+			name:    "不正なaddress",
+			route:   "{header: x-localmesh-route, address: 'dev.example.com:8081'}",
+			wantErr: "header_routes[0].address host must be an IP address or 'localhost'",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := loadContent(t, `
+services:
+  - kind: kubernetes
+    host: users.localhost
+    namespace: users
+    service: users-api
+    protocol: http
+    header_routes: [`+tt.route+`]
+`)
+			if err == nil {
+				t.Fatal("expected error, got nil")
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got %q", tt.wantErr, err.Error())
+			}
+		})
+	}
+}
+
+func TestLoad_RateLimit(t *testing.T) {
+	cfg, err := loadContent(t, `
+rate_limit:
+  requests: 100
+services:
+  - kind: kubernetes
+    host: users.localhost
+    namespace: users
+    service: users-api
+    protocol: http
+    rate_limit:
+      requests: 5
+      fill_interval: 500ms
+      burst: 20
+`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// 省略時はfill_interval=1s、burst=requests
+	if cfg.RateLimit.EffectiveFillInterval() != time.Second {
+		t.Errorf("expected default fill_interval 1s, got %v", cfg.RateLimit.EffectiveFillInterval())
+	}
+	if cfg.RateLimit.EffectiveBurst() != 100 {
+		t.Errorf("expected default burst 100, got %d", cfg.RateLimit.EffectiveBurst())
+	}
+
+	svc, _ := cfg.Services[0].AsKubernetes()
+	if svc.RateLimit.EffectiveFillInterval() != 500*time.Millisecond {
+		t.Errorf("expected fill_interval 500ms, got %v", svc.RateLimit.EffectiveFillInterval())
+	}
+	if svc.RateLimit.EffectiveBurst() != 20 {
+		t.Errorf("expected burst 20, got %d", svc.RateLimit.EffectiveBurst())
+	}
+}
+
+func TestLoad_RateLimit_Invalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		limit   string
+		wantErr string
+	}{
+		{
+			name:    "requests未指定",
+			limit:   "{burst: 10}",
+			wantErr: "rate_limit.requests must be at least 1",
+		},
+		{
+			name:    "不正なfill_interval",
+			limit:   "{requests: 10, fill_interval: soon}",
+			wantErr: "rate_limit.fill_interval is invalid",
+		},
+		{
+			name:    "fill_intervalが短すぎる",
+			limit:   "{requests: 10, fill_interval: 10ms}",
+			wantErr: "rate_limit.fill_interval must be at least 50ms",
+		},
+		{
+			name:    "burstがrequests未満",
+			limit:   "{requests: 10, burst: 5}",
+			wantErr: "rate_limit.burst must be greater than or equal to requests (10), got 5",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := loadContent(t, `
+services:
+  - kind: kubernetes
+    host: users.localhost
+    namespace: users
+    service: users-api
+    protocol: http
+    rate_limit: `+tt.limit+`
+`)
+			if err == nil {
+				t.Fatal("expected error, got nil")
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got %q", tt.wantErr, err.Error())
+			}
+		})
+	}
+
+	// グローバル設定も同様に検証
+	_, err := loadContent(t, `
+rate_limit:
+  requests: 0
+services:
+  - kind: kubernetes
+    host: users.localhost
+    namespace: users
+    service: users-api
+    protocol: http
+`)
+	if err == nil || !strings.Contains(err.Error(), "rate_limit.requests must be at least 1") {
+		t.Errorf("expected global rate_limit error, got %v", err)
+	}
+}
+
+func TestLoad_AccessLog(t *testing.T) {
+	cfg, err := loadContent(t, `
+ssh_bastions:
+  primary:
+    instance: bastion-1
+    zone: asia-northeast1-a
+services:
+  - kind: kubernetes
+    host: users.localhost
+    namespace: users
+    service: users-api
+    protocol: http
+    access_log:
+      path: /tmp/users.log
+      format: json
+  - kind: tcp
+    host: db.localdomain
+    ssh_bastion: primary
+    target_host: 10.0.0.1
+    target_port: 5432
+    access_log:
+      path: stdout
+`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	k8s, _ := cfg.Services[0].AsKubernetes()
+	if k8s.AccessLog.Path != "/tmp/users.log" || k8s.AccessLog.Format != "json" {
+		t.Errorf("unexpected access_log: %+v", k8s.AccessLog)
+	}
+	// formatの省略時はtext
+	tcp, _ := cfg.Services[1].AsTCP()
+	if tcp.AccessLog.Path != "stdout" || tcp.AccessLog.Format != "text" {
+		t.Errorf("expected stdout/text, got %+v", tcp.AccessLog)
+	}
+}
+
+func TestLoad_AccessLog_Invalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		log     string
+		wantErr string
+	}{
+		{
+			name:    "path未指定",
+			log:     "{format: json}",
+			wantErr: "access_log.path is required for kubernetes service 'users.localhost'",
+		},
+		{
+			name:    "未知の形式",
+			log:     "{path: stdout, format: xml}",
+			wantErr: "access_log.format must be 'text' or 'json', got 'xml'",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := loadContent(t, `
+services:
+  - kind: kubernetes
+    host: users.localhost
+    namespace: users
+    service: users-api
+    protocol: http
+    access_log: `+tt.log+`
+`)
+			if err == nil {
+				t.Fatal("expected error, got nil")
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got %q", tt.wantErr, err.Error())
+			}
+		})
+	}
+}
+
+func TestLoad_HealthCheck(t *testing.T) {
+	cfg, err := loadContent(t, `
+ssh_bastions:
+  primary:
+    instance: bastion-1
+    zone: asia-northeast1-a
+services:
+  - kind: kubernetes
+    host: users.localhost
+    namespace: users
+    service: users-api
+    protocol: http
+    health_check:
+      type: " http "
+      path: /healthz
+      interval: 10s
+      unhealthy_threshold: 2
+  - kind: kubernetes
+    host: billing.localhost
+    namespace: billing
+    service: billing-api
+    protocol: grpc
+    health_check:
+      type: grpc
+      service: billing.v1.BillingService
+  - kind: tcp
+    host: db.localdomain
+    ssh_bastion: primary
+    target_host: 10.0.0.1
+    target_port: 5432
+    health_check:
+      type: tcp
+`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	users, _ := cfg.Services[0].AsKubernetes()
+	if users.HealthCheck.Type != "http" || users.HealthCheck.Path != "/healthz" {
+		t.Errorf("unexpected health_check: %+v", users.HealthCheck)
+	}
+	if got := users.HealthCheck.EffectiveInterval(); got != 10*time.Second {
+		t.Errorf("expected interval 10s, got %v", got)
+	}
+	if got := users.HealthCheck.EffectiveUnhealthyThreshold(); got != 2 {
+		t.Errorf("expected threshold 2, got %d", got)
+	}
+
+	billing, _ := cfg.Services[1].AsKubernetes()
+	if billing.HealthCheck.Service != "billing.v1.BillingService" {
+		t.Errorf("unexpected health_check: %+v", billing.HealthCheck)
+	}
+
+	// interval・unhealthy_thresholdの省略時は5s・3回
+	tcp, _ := cfg.Services[2].AsTCP()
+	if got := tcp.HealthCheck.EffectiveInterval(); got != 5*time.Second {
+		t.Errorf("expected default interval 5s, got %v", got)
+	}
+	if got := tcp.HealthCheck.EffectiveUnhealthyThreshold(); got != 3 {
+		t.Errorf("expected default threshold 3, got %d", got)
+	}
+}
+
+func TestLoad_HealthCheck_Invalid(t *testing.T) {
+	tests := []struct {
+		name     string
+		protocol string
+		check    string
+		extra    string
+		wantErr  string
+	}{
+		{
+			name:     "未知のtype",
+			protocol: "http",
+			check:    "{type: redis}",
+			wantErr:  "health_check.type must be 'http', 'grpc', or 'tcp', got 'redis' for kubernetes service 'users.localhost'",
+		},
+		{
+			name:     "httpでpath未指定",
+			protocol: "http",
+			check:    "{type: http}",
+			wantErr:  "health_check.path must start with '/', got ''",
+		},
+		{
+			name:     "http以外のprotocolでgrpc",
+			protocol: "http",
+			check:    "{type: grpc}",
+			wantErr:  "health_check.type 'grpc' requires protocol 'grpc', got 'http'",
+		},
+		{
+			name:     "tcpでpath指定",
+			protocol: "http",
+			check:    "{type: tcp, path: /healthz}",
+			wantErr:  "health_check.path can only be used with type 'http'",
+		},
+		{
+			name:     "httpでservice指定",
+			protocol: "grpc",
+			check:    "{type: http, path: /healthz, service: foo}",
+			wantErr:  "health_check.service can only be used with type 'grpc'",
+		},
+		{
+			name:     "不正なinterval",
+			protocol: "http",
+			check:    "{type: tcp, interval: soon}",
+			wantErr:  "health_check.interval is invalid",
+		},
+		{
+			name:     "短すぎるinterval",
+			protocol: "http",
+			check:    "{type: tcp, interval: 100ms}",
+			wantErr:  "health_check.interval must be at least 1s, got '100ms'",
+		},
+		{
+			name:     "負のunhealthy_threshold",
+			protocol: "http",
+			check:    "{type: tcp, unhealthy_threshold: -1}",
+			wantErr:  "health_check.unhealthy_threshold must be at least 1, got -1",
+		},
+		{
+			name:     "local_overrideとの併用",
+			protocol: "http",
+			check:    "{type: tcp}",
+			extra:    "\n    local_override: {address: \"localhost:3000\"}",
+			wantErr:  "health_check and local_override cannot be used together for kubernetes service 'users.localhost'",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := loadContent(t, `
+services:
+  - kind: kubernetes
+    host: users.localhost
+    namespace: users
+    service: users-api
+    protocol: `+tt.protocol+`
+    health_check: `+tt.check+tt.extra+`
+`)
+			if err == nil {
+				t.Fatal("expected error, got nil")
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got %q", tt.wantErr, err.Error())
+			}
+		})
+	}
+
+	t.Run("tcpサービスでhttp", func(t *testing.T) {
+		_, err := loadContent(t, `
+ssh_bastions:
+  primary:
+    instance: bastion-1
+    zone: asia-northeast1-a
+services:
+  - kind: tcp
+    host: db.localdomain
+    ssh_bastion: primary
+    target_host: 10.0.0.1
+    target_port: 5432
+    health_check: {type: http, path: /healthz}
+`)
+		want := "health_check.type must be 'tcp' for tcp services, got 'http' for tcp service 'db.localdomain'"
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("expected error containing %q, got %v", want, err)
+		}
+	})
+}
+
+func TestLoad_TCPService_SNI(t *testing.T) {
+	cfg, err := loadContent(t, `
+ssh_bastions:
+  primary:
+    instance: bastion-1
+    zone: asia-northeast1-a
+services:
+  - kind: tcp
+    host: Orders-DB.localdomain
+    ssh_bastion: primary
+    target_host: 10.0.0.1
+    target_port: 5432
+    sni: true
+  - kind: tcp
+    host: users-db.localdomain
+    ssh_bastion: primary
+    target_host: 10.0.0.2
+    target_port: 5432
+    sni: true
+    server_names: [" users-db.localdomain ", "*.users.example.com"]
+`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// server_namesの省略時はhost（小文字）
+	orders, _ := cfg.Services[0].AsTCP()
+	if got := orders.EffectiveServerNames(); !reflect.DeepEqual(got, []string{"orders-db.localdomain"}) {
+		t.Errorf("expected [orders-db.localdomain], got %v", got)
+	}
+	users, _ := cfg.Services[1].AsTCP()
+	if got := users.EffectiveServerNames(); !reflect.DeepEqual(got, []string{"users-db.localdomain", "*.users.example.com"}) {
+		t.Errorf("unexpected server names: %v", got)
+	}
+}
+
+func TestLoad_TCPService_SNI_Invalid(t *testing.T) {
+	tests := []struct {
+		name     string
+		services string
+		wantErr  string
+	}{
+		{
+			name: "sniなしでserver_names",
+			services: `
+  - kind: tcp
+    host: db.localdomain
+    ssh_bastion: primary
+    target_host: 10.0.0.1
+    target_port: 5432
+    server_names: [db.localdomain]`,
+			wantErr: "server_names requires sni: true for tcp service 'db.localdomain'",
+		},
+		{
+			name: "先頭以外のワイルドカード",
+			services: `
+  - kind: tcp
+    host: db.localdomain
+    ssh_bastion: primary
+    target_host: 10.0.0.1
+    target_port: 5432
+    sni: true
+    server_names: ["db.*.example.com"]`,
+			wantErr: "server_names[0] may only use a leading '*.' wildcard, got 'db.*.example.com'",
+		},
+		{
+			name: "同じlisten_portでサーバー名が重複",
+			services: `
+  - kind: tcp
+    host: db.localdomain
+    ssh_bastion: primary
+    target_host: 10.0.0.1
+    target_port: 5432
+    sni: true
+  - kind: tcp
+    host: db2.localdomain
+    ssh_bastion: primary
+    target_host: 10.0.0.2
+    target_port: 5432
+    sni: true
+    server_names: [db.localdomain]`,
+			wantErr: "server name 'db.localdomain' on listen_port 5432 is used by both tcp services 'db.localdomain' and 'db2.localdomain'",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := loadContent(t, `
+ssh_bastions:
+  primary:
+    instance: bastion-1
+    zone: asia-northeast1-a
+services:`+tt.services+`
+`)
+			if err == nil {
+				t.Fatal("expected error, got nil")
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got %q", tt.wantErr, err.Error())
+			}
+		})
+	}
+
+	// listen_portが異なれば同じサーバー名を使える
+	_, err := loadContent(t, `
+ssh_bastions:
+  primary:
+    instance: bastion-1
+    zone: asia-northeast1-a
+services:
+  - kind: tcp
+    host: db.localdomain
+    ssh_bastion: primary
+    target_host: 10.0.0.1
+    target_port: 5432
+    sni: true
+  - kind: tcp
+    host: db2.localdomain
+    ssh_bastion: primary
+    target_host: 10.0.0.2
+    target_port: 3306
+    sni: true
+    server_names: [db.localdomain]
+`)
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestLoad_TCPService_Protocol(t *testing.T) {
+	cfg, err := loadContent(t, `
+ssh_bastions:
+  primary:
+    instance: bastion-1
+    zone: asia-northeast1-a
+services:
+  - kind: tcp
+    host: orders-db.localdomain
+    ssh_bastion: primary
+    target_host: 10.0.0.1
+    target_port: 5432
+    protocol: " postgres "
+  - kind: tcp
+    host: users-db.localdomain
+    ssh_bastion: primary
+    target_host: 10.0.0.2
+    target_port: 3306
+    protocol: mysql
+`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	orders, _ := cfg.Services[0].AsTCP()
+	if orders.Protocol != "postgres" {
+		t.Errorf("expected protocol postgres, got %q", orders.Protocol)
+	}
+	users, _ := cfg.Services[1].AsTCP()
+	if users.Protocol != "mysql" {
+		t.Errorf("expected protocol mysql, got %q", users.Protocol)
+	}
+}
+
+func TestLoad_TCPService_Protocol_Invalid(t *testing.T) {
+	tests := []struct {
+		name     string
+		services string
+		wantErr  string
+	}{
+		{
+			name: "未対応のプロトコル",
+			services: `
+  - kind: tcp
+    host: db.localdomain
+    ssh_bastion: primary
+    target_host: 10.0.0.1
+    target_port: 6379
+    protocol: redis`,
+			wantErr: "protocol must be 'postgres' or 'mysql' for tcp service 'db.localdomain', got 'redis'",
+		},
+		{
+			name: "sniとの併用",
+			services: `
+  - kind: tcp
+    host: db.localdomain
+    ssh_bastion: primary
+    target_host: 10.0.0.1
+    target_port: 5432
+    protocol: postgres
+    sni: true`,
+			wantErr: "protocol cannot be combined with sni for tcp service 'db.localdomain'",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := loadContent(t, `
+ssh_bastions:
+  primary:
+    instance: bastion-1
+    zone: asia-northeast1-a
+services:`+tt.services+`
+`)
+			if err == nil {
+				t.Fatal("expected error, got nil")
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got %q", tt.wantErr, err.Error())
+			}
+		})
+	}
+}
+
+func TestLoad_BindAddress(t *testing.T) {
+	const services = `
+services:
+  - kind: kubernetes
+    host: users.localhost
+    namespace: users
+    service: users-api
+    protocol: http
+  - kind: kubernetes
+    host: admin.localhost
+    namespace: admin
+    service: admin-web
+    protocol: http
+    listener_port: 8081
+    bind_address: " 0.0.0.0 "
+`
+	cfg, err := loadContent(t, services)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// 省略時はloopbackのみ
+	if got := cfg.EffectiveBindAddresses(); !reflect.DeepEqual(got, []string{"127.0.0.1", "::1"}) {
+		t.Errorf("expected [127.0.0.1 ::1], got %v", got)
+	}
+	users, _ := cfg.Services[0].AsKubernetes()
+	if got := users.EffectiveBindAddresses(cfg); !reflect.DeepEqual(got, []string{"127.0.0.1", "::1"}) {
+		t.Errorf("expected users to use the global addresses, got %v", got)
+	}
+	admin, _ := cfg.Services[1].AsKubernetes()
+	if got := admin.EffectiveBindAddresses(cfg); !reflect.DeepEqual(got, []string{"0.0.0.0"}) {
+		t.Errorf("expected admin to bind 0.0.0.0, got %v", got)
+	}
+
+	cfg, err = loadContent(t, "bind_address: \"::\"\n"+services)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := cfg.EffectiveBindAddresses(); !reflect.DeepEqual(got, []string{"::"}) {
+		t.Errorf("expected [::], got %v", got)
+	}
+}
+
+func TestLoad_BindAddress_Invalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{
+			name: "グローバルがIPアドレスでない",
+			content: `
+bind_address: localhost
+services:
+  - kind: kubernetes
+    host: users.localhost
+    namespace: users
+    service: users-api
+    protocol: http`,
+			wantErr: "bind_address must be an IP address, got 'localhost'",
+		},
+		{
+			name: "listener_portなしで個別指定",
+			content: `
+services:
+  - kind: kubernetes
+    host: users.localhost
+    namespace: users
+    service: users-api
+    protocol: http
+    bind_address: 0.0.0.0`,
+			wantErr: "bind_address requires listener_port for kubernetes service 'users.localhost'",
+		},
+		{
+			name: "個別指定がIPアドレスでない",
+			content: `
+services:
+  - kind: kubernetes
+    host: users.localhost
+    namespace: users
+    service: users-api
+    protocol: http
+    listener_port: 8081
+    bind_address: 0.0.0.0:8081`,
+			wantErr: "bind_address must be an IP address, got '0.0.0.0:8081' for kubernetes service 'users.localhost'",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := loadContent(t, tt.content)
+			if err == nil {
+				t.Fatal("expected error, got nil")
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got %q", tt.wantErr, err.Error())
+			}
+		})
+	}
+}
+
+func TestLoad_ListenerAuth(t *testing.T) {
+	t.Setenv("LOCALMESH_TEST_TOKEN", "s3cret-token")
+	tmpDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(tmpDir, "password"), []byte("hunter2\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	configPath := filepath.Join(tmpDir, "config.yaml")
+	content := `
+listener_auth:
+  basic:
+    username: " alice "
+    password:
+      file: password
+  bearer_token:
+    env: LOCALMESH_TEST_TOKEN
+  allowed_cidrs:
+    - 192.168.1.0/24
+    - " 10.0.0.5 "
+    - fd00::1
+services:
+  - kind: kubernetes
+    host: users.localhost
+    namespace: users
+    service: users-api
+    protocol: http
+`
+	if err := os.WriteFile(configPath, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := Load(configPath)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	auth := cfg.ListenerAuth
+	if auth.Basic.Username != "alice" {
+		t.Errorf("expected username alice, got %q", auth.Basic.Username)
+	}
+	// ファイルは設定ファイルからの相対パスで読み込み、末尾の改行を除く
+	if got := auth.Basic.Password.Value(); got != "hunter2" {
+		t.Errorf("expected password from file, got %q", got)
+	}
+	if got := auth.BearerToken.Value(); got != "s3cret-token" {
+		t.Errorf("expected token from env, got %q", got)
+	}
+	// IPアドレスはCIDR表記に変換する
+	if want := []string{"192.168.1.0/24", "10.0.0.5/32", "fd00::1/128"}; !reflect.DeepEqual(auth.AllowedCIDRs, want) {
+		t.Errorf("expected allowed_cidrs %v, got %v", want, auth.AllowedCIDRs)
+	}
+}
+
+func TestLoad_ListenerAuth_Invalid(t *testing.T) {
+	t.Setenv("LOCALMESH_TEST_EMPTY", "")
+	tests := []struct {
+		name    string
+		auth    string
+		wantErr string
+	}{
+		{
+			name:    "設定なし",
+			auth:    "listener_auth: {}",
+			wantErr: "listener_auth requires basic, bearer_token or allowed_cidrs",
+		},
+		{
+			name: "usernameなし",
+			auth: `listener_auth:
+  basic:
+    password:
+      env: HOME`,
+			wantErr: "listener_auth.basic.username is required",
+		},
+		{
+			name: "usernameにコロン",
+			auth: `listener_auth:
+  basic:
+    username: "a:b"
+    password:
+      env: HOME`,
+			wantErr: "listener_auth.basic.username must not contain ':', got 'a:b'",
+		},
+		{
+			name: "envとfileの両方",
+			auth: `listener_auth:
+  bearer_token:
+    env: HOME
+    file: token`,
+			wantErr: "listener_auth.bearer_token requires exactly one of env or file",
+		},
+		{
+			name: "環境変数が空",
+			auth: `listener_auth:
+  bearer_token:
+    env: LOCALMESH_TEST_EMPTY`,
+			wantErr: "listener_auth.bearer_token environment variable 'LOCALMESH_TEST_EMPTY' is not set",
+		},
+		{
+			name: "ファイルが存在しない",
+			auth: `listener_auth:
+  basic:
+    username: alice
+    password:
+      file: missing`,
+			wantErr: "listener_auth.basic.password failed to read file",
+		},
+		{
+			name: "不正なCIDR",
+			auth: `listener_auth:
+  allowed_cidrs:
+    - 192.168.1.0/33`,
+			wantErr: "listener_auth.allowed_cidrs[0] must be a CIDR or an IP address, got '192.168.1.0/33'",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := loadContent(t, tt.auth+`
+services:
+  - kind: kubernetes
+    host: users.localhost
+    namespace: users
+    service: users-api
+    protocol: http
+`)
+			if err == nil {
+				t.Fatal("expected error, got nil")
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got %q", tt.wantErr, err.Error())
+			}
+		})
+	}
+}
+
+func TestLoad_Tracing(t *testing.T) {
+	cfg, err := loadContent(t, `
+tracing:
+  endpoint: localhost:4317
+services:
+  - kind: kubernetes
+    host: users.localhost
+    namespace: users
+    service: users-api
+    protocol: http
+`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	address, p := cfg.Tracing.Collector()
+	if address != "127.0.0.1" || p != 4317 {
+		t.Errorf("expected 127.0.0.1:4317, got %s:%d", address, p)
+	}
+	// 省略時のデフォルト値
+	if cfg.Tracing.EffectiveSampling() != 100 {
+		t.Errorf("expected default sampling 100, got %v", cfg.Tracing.EffectiveSampling())
+	}
+	if cfg.Tracing.ServiceName != "kubectl-localmesh" {
+		t.Errorf("expected default service_name 'kubectl-localmesh', got %q", cfg.Tracing.ServiceName)
+	}
+}
+
+func TestLoad_Tracing_Invalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		tracing string
+		wantErr string
+	}{
+		{
+			name:    "endpoint未指定",
+			tracing: "{sampling: 10}",
+			wantErr: "tracing.endpoint is required",
+		},
+		{
+			name:    "ポートなし",
+			tracing: "{endpoint: jaeger}",
+			wantErr: "tracing.endpoint must be host:port",
+		},
+		{
+			name:    "不正なポート",
+			tracing: "{endpoint: 'jaeger:0'}",
+			wantErr: "tracing.endpoint port must be between 1 and 65535, got '0'",
+		},
+		{
+			name:    "samplingが範囲外",
+			tracing: "{endpoint: 'jaeger:4317', sampling: 150}",
+			wantErr: "tracing.sampling must be between 0 and 100, got 150",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := loadContent(t, `
+tracing: `+tt.tracing+`
+services:
+  - kind: kubernetes
+    host: users.localhost
+    namespace: users
+    service: users-api
+    protocol: http
+`)
+			if err == nil {
+				t.Fatal("expected error, got nil")
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got %q", tt.wantErr, err.Error())
+			}
+		})
+	}
+}
+
+func TestLoad_KubernetesService_Lua(t *testing.T) {
+	tmpDir := t.TempDir()
+	script := "function envoy_on_request(request_handle)\n  request_handle:headers():remove(\"cookie\")\nend\n"
+	if err := os.MkdirAll(filepath.Join(tmpDir, "lua"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(tmpDir, "lua", "strip.lua"), []byte(script), 0644); err != nil {
+		t.Fatal(err)
+	}
+	configPath := filepath.Join(tmpDir, "config.yaml")
+	content := `
+services:
+  - kind: kubernetes
+    host: users.localhost
+    namespace: users
+    service: users-api
+    protocol: http
+    lua_file: lua/strip.lua
+  - kind: kubernetes
+    host: billing.localhost
+    namespace: billing
+    service: billing-api
+    protocol: http
+    lua: |
+      function envoy_on_response(response_handle)
+      end
+`
+	if err := os.WriteFile(configPath, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := Load(configPath)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// lua_fileは設定ファイルのディレクトリ基準で解決して読み込む
+	users, _ := cfg.Services[0].AsKubernetes()
+	if users.LuaFile != filepath.Join(tmpDir, "lua", "strip.lua") {
+		t.Errorf("expected lua_file resolved relative to config, got %q", users.LuaFile)
+	}
+	if users.LuaScript() != script {
+		t.Errorf("expected script from lua_file, got %q", users.LuaScript())
+	}
+
+	billing, _ := cfg.Services[1].AsKubernetes()
+	if !strings.Contains(billing.LuaScript(), "envoy_on_response") {
+		t.Errorf("expected inline script, got %q", billing.LuaScript())
+	}
+}
+
+func TestLoad_KubernetesService_Lua_Invalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		fields  string
+		wantErr string
+	}{
+		{
+			name:    "luaとlua_fileを両方指定",
+			fields:  "lua: 'function envoy_on_request(h) end'\n    lua_file: filter.lua",
+			wantErr: "lua and lua_file cannot be used together",
+		},
+		{
+			name:    "lua_fileが存在しない",
+			fields:  "lua_file: missing.lua",
+			wantErr: "failed to read lua_file for kubernetes service 'users.localhost'",
+		},
+		{
+			name:    "エントリポイントがない",
+			fields:  "lua: 'local x = 1'",
+			wantErr: "lua must define envoy_on_request or envoy_on_response",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := loadContent(t, `
+services:
+  - kind: kubernetes
+    host: users.localhost
+    namespace: users
+    service: users-api
+    protocol: http
+    `+tt.fields+`
+`)
+			if err == nil {
+				t.Fatal("expected error, got nil")
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got %q", tt.wantErr, err.Error())
+			}
+		})
+	}
+}
+
+func TestLoad_KubernetesService_HostRewrite(t *testing.T) {
+	cfg, err := loadContent(t, `
+services:
+  - kind: kubernetes
+    host: users.localhost
+    namespace: users
+    service: users-api
+    protocol: http
+    rewrite_redirects: true
+    cookie_domain_rewrite:
+      - .Auth.Example.com
+      - users.internal
+  - kind: kubernetes
+    host: billing.localhost
+    namespace: billing
+    service: billing-api
+    protocol: http
+    rewrite_redirects: false
+`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	users, _ := cfg.Services[0].AsKubernetes()
+	// trueの場合はクラスタDNS名のみ
+	wantRedirect := []string{"users-api", "users-api.users", "users-api.users.svc", "users-api.users.svc.cluster.local"}
+	if got := users.RedirectHosts(); !reflect.DeepEqual(got, wantRedirect) {
+		t.Errorf("expected redirect hosts %v, got %v", wantRedirect, got)
+	}
+	// リスト指定の場合はクラスタDNS名に加えて、正規化したホスト名を置換対象にする
+	wantCookie := append(append([]string{}, wantRedirect...), "auth.example.com", "users.internal")
+	if got := users.CookieDomains(); !reflect.DeepEqual(got, wantCookie) {
+		t.Errorf("expected cookie domains %v, got %v", wantCookie, got)
+	}
+
+	billing, _ := cfg.Services[1].AsKubernetes()
+	if got := billing.RedirectHosts(); got != nil {
+		t.Errorf("expected no redirect hosts when disabled, got %v", got)
+	}
+	if got := billing.CookieDomains(); got != nil {
+		t.Errorf("expected no cookie domains when unset, got %v", got)
+	}
+}
+
+func TestLoad_KubernetesService_HostRewrite_Invalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		fields  string
+		wantErr string
+	}{
+		{
+			name:    "文字列を指定",
+			fields:  "rewrite_redirects: users.internal",
+			wantErr: "must be a boolean or a list of host names",
+		},
+		{
+			name:    "不正なホスト名",
+			fields:  "cookie_domain_rewrite: ['bad host']",
+			wantErr: "cookie_domain_rewrite",
+		},
+		{
+			name:    "空のホスト名",
+			fields:  "rewrite_redirects: ['']",
+			wantErr: "rewrite_redirects",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := loadContent(t, `
+services:
+  - kind: kubernetes
+    host: users.localhost
+    namespace: users
+    service: users-api
+    protocol: http
+    `+tt.fields+`
+`)
+			if err == nil {
+				t.Fatal("expected error, got nil")
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got %q", tt.wantErr, err.Error())
+			}
+		})
+	}
+}
+
+func TestLoad_EnvoyOverrides(t *testing.T) {
+	tmpDir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(tmpDir, "envoy"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	// JSON PatchのファイルはJSONでも記述できる
+	if err := os.WriteFile(filepath.Join(tmpDir, "envoy", "listener.json"), []byte(`[{"op": "add", "path": "/per_connection_buffer_limit_bytes", "value": 32768}]`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	configPath := filepath.Join(tmpDir, "config.yaml")
+	content := `
+services:
+  - kind: kubernetes
+    host: users.localhost
+    namespace: users
+    service: users-api
+    protocol: http
+envoy_overrides:
+  - patch:
+      stats_flush_interval: 10s
+  - listener: ' listener_http '
+    file: envoy/listener.json
+  - cluster: users_users_api_8080
+    patch:
+      - op: replace
+        path: /connect_timeout
+        value: 5s
+`
+	if err := os.WriteFile(configPath, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := Load(configPath)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(cfg.EnvoyOverrides) != 3 {
+		t.Fatalf("expected 3 overrides, got %d", len(cfg.EnvoyOverrides))
+	}
+
+	tests := []struct {
+		listener string
+		cluster  string
+		patch    string
+	}{
+		{patch: `{"stats_flush_interval":"10s"}`},
+		{listener: "listener_http", patch: `[{"op":"add","path":"/per_connection_buffer_limit_bytes","value":32768}]`},
+		{cluster: "users_users_api_8080", patch: `[{"op":"replace","path":"/connect_timeout","value":"5s"}]`},
+	}
+	for i, tt := range tests {
+		o := cfg.EnvoyOverrides[i]
+		if o.Listener != tt.listener || o.Cluster != tt.cluster {
+			t.Errorf("override[%d]: expected target listener=%q cluster=%q, got listener=%q cluster=%q", i, tt.listener, tt.cluster, o.Listener, o.Cluster)
+		}
+		if string(o.PatchJSON()) != tt.patch {
+			t.Errorf("override[%d]: expected patch %s, got %s", i, tt.patch, o.PatchJSON())
+		}
+	}
+
+	// fileは設定ファイルのディレクトリ基準で解決する
+	if cfg.EnvoyOverrides[1].File != filepath.Join(tmpDir, "envoy", "listener.json") {
+		t.Errorf("expected file resolved relative to config, got %q", cfg.EnvoyOverrides[1].File)
+	}
+}
+
+func TestLoad_EnvoyOverrides_Invalid(t *testing.T) {
+	tests := []struct {
+		name      string
+		overrides string
+		wantErr   string
+	}{
+		{
+			name:      "patchとfileを両方指定",
+			overrides: "- patch: {admin: {}}\n    file: patch.yaml",
+			wantErr:   "invalid envoy_overrides[0]: patch and file cannot be used together",
+		},
+		{
+			name:      "patchとfileが未指定",
+			overrides: "- listener: listener_http",
+			wantErr:   "invalid envoy_overrides[0]: patch or file is required",
+		},
+		{
+			name:      "listenerとclusterを両方指定",
+			overrides: "- listener: listener_http\n    cluster: users\n    patch: {}",
+			wantErr:   "invalid envoy_overrides[0]: listener and cluster cannot be used together",
+		},
+		{
+			name:      "patchがスカラー",
+			overrides: "- patch: stats",
+			wantErr:   "invalid envoy_overrides[0]: patch must be a mapping (JSON merge patch) or a list (JSON patch)",
+		},
+		{
+			name:      "fileが存在しない",
+			overrides: "- file: missing.yaml",
+			wantErr:   "invalid envoy_overrides[0]: failed to read file",
+		},
+		{
+			name:      "空のエントリ",
+			overrides: "- ",
+			wantErr:   "invalid envoy_overrides[0]: entry is empty",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := loadContent(t, `
+services:
+  - kind: kubernetes
+    host: users.localhost
+    namespace: users
+    service: users-api
+    protocol: http
+envoy_overrides:
+  `+tt.overrides+`
+`)
+			if err == nil {
+				t.Fatal("expected error, got nil")
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got %q", tt.wantErr, err.Error())
+			}
+		})
+	}
+}
+
+func TestLoad_Dashboard(t *testing.T) {
+	const services = `
+services:
+  - kind: kubernetes
+    host: users.localhost
+    namespace: users
+    service: users-api
+    protocol: http
+`
+	tests := []struct {
+		name      string
+		dashboard string
+		want      string
+	}{
+		{name: "省略時は無効", want: ""},
+		{name: "ホスト省略時はデフォルトのホスト", dashboard: "dashboard: {}\n", want: DefaultDashboardHost},
+		{name: "ホスト指定", dashboard: "dashboard:\n  host: \" mesh.localhost \"\n", want: "mesh.localhost"},
+		{name: "無効化", dashboard: "dashboard:\n  enabled: false\n  host: mesh.localhost\n", want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := loadContent(t, tt.dashboard+services)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := cfg.DashboardHost(); got != tt.want {
+				t.Errorf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestLoad_Dashboard_HostConflict(t *testing.T) {
+	const services = `
+services:
+  - kind: kubernetes
+    host: localmesh.localhost
+    namespace: users
+    service: users-api
+    protocol: http
+`
+	_, err := loadContent(t, "dashboard: {}\n"+services)
+	if err == nil || !strings.Contains(err.Error(), "dashboard host 'localmesh.localhost' conflicts with a service host") {
+		t.Fatalf("expected conflict error, got %v", err)
+	}
+
+	// ダッシュボードを有効にしない場合、または無効にすればサービスで使える
+	for _, dashboard := range []string{"", "dashboard:\n  enabled: false\n"} {
+		if _, err := loadContent(t, dashboard+services); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	}
+}
+
+func TestLoad_Aliases(t *testing.T) {
+	cfg, err := loadContent(t, `
+ssh_bastions:
+  primary:
+    instance: bastion-1
+    zone: asia-northeast1-a
+services:
+  - kind: kubernetes
+    host: users.localhost
+    namespace: users
+    service: users-api
+    protocol: http
+    aliases:
+      - " Users.Internal "
+      - users-api.users
+    cluster_dns_aliases: true
+  - kind: tcp
+    host: db.localhost
+    ssh_bastion: primary
+    target_host: 10.0.0.1
+    target_port: 5432
+    aliases: [orders-db.internal]
+`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// aliasesの後にクラスタ内のDNS名（重複は除く）
+	users, _ := cfg.Services[0].AsKubernetes()
+	want := []string{"users.internal", "users-api.users", "users-api.users.svc", "users-api.users.svc.cluster.local"}
+	if got := users.HostAliases(); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+	db, _ := cfg.Services[1].AsTCP()
+	if got := db.HostAliases(); !reflect.DeepEqual(got, []string{"orders-db.internal"}) {
+		t.Errorf("expected [orders-db.internal], got %v", got)
+	}
+}
+
+func TestLoad_Aliases_Invalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		extra   string
+		wantErr string
+	}{
+		{
+			name:    "空の別名",
+			extra:   `    aliases: [""]`,
+			wantErr: "aliases[0] must not be empty",
+		},
+		{
+			name:    "ホスト名でない",
+			extra:   `    aliases: ["users/api"]`,
+			wantErr: "aliases[0] must be a host name",
+		},
+		{
+			name:    "hostと同じ",
+			extra:   `    aliases: [Users.Localhost]`,
+			wantErr: "aliases[0] 'Users.Localhost' is the same as host",
+		},
+		{
+			name:    "重複",
+			extra:   `    aliases: [users.internal, users.internal]`,
+			wantErr: "aliases[1] 'users.internal' is duplicated",
+		},
+		{
+			name:    "他のサービスのホスト",
+			extra:   `    aliases: [billing.localhost]`,
+			wantErr: "alias 'billing.localhost' of service 'users.localhost' conflicts with a service host",
+		},
+		{
+			name:    "他のサービスの別名",
+			extra:   `    aliases: [shared.internal]`,
+			wantErr: "alias 'shared.internal' is used by both services 'billing.localhost' and 'users.localhost'",
+		},
+		{
+			name:    "ダッシュボードのホスト",
+			extra:   `    aliases: [localmesh.localhost]`,
+			wantErr: "dashboard host 'localmesh.localhost' conflicts with a service host or alias",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := loadContent(t, `
+dashboard: {}
+services:
+  - kind: kubernetes
+    host: billing.localhost
+    namespace: billing
+    service: billing-api
+    protocol: http
+    aliases: [shared.internal]
+  - kind: kubernetes
+    host: users.localhost
+    namespace: users
+    service: users-api
+    protocol: http
+`+tt.extra+"\n")
+			if err == nil {
+				t.Fatal("expected error, got nil")
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got %q", tt.wantErr, err.Error())
+			}
+		})
+	}
+}
+
+func TestLoad_Listeners(t *testing.T) {
+	cfg, err := loadContent(t, `
+listener_port: 8080
+listeners:
+  web: 8081
+  api: 8082
+services:
+  - kind: kubernetes
+    host: frontend.localhost
+    namespace: web
+    service: frontend
+    protocol: http
+    listeners: [" web "]
+  - kind: kubernetes
+    host: users.localhost
+    namespace: users
+    service: users-api
+    protocol: http
+    listeners: [default, web, api]
+`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := map[string]port.ListenerPort{"web": 8081, "api": 8082}
+	if !reflect.DeepEqual(cfg.Listeners, want) {
+		t.Errorf("expected %v, got %v", want, cfg.Listeners)
+	}
+	frontend, _ := cfg.Services[0].AsKubernetes()
+	if !reflect.DeepEqual(frontend.Listeners, []string{"web"}) {
+		t.Errorf("expected [web], got %v", frontend.Listeners)
+	}
+	users, _ := cfg.Services[1].AsKubernetes()
+	if !reflect.DeepEqual(users.Listeners, []string{"default", "web", "api"}) {
+		t.Errorf("expected [default web api], got %v", users.Listeners)
+	}
+}
+
+func TestConfig_HTTPListenerPorts(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []port.ListenerPort
+	}{
+		{
+			name: "listener_portのみ",
+			content: `
+services:
+  - kind: kubernetes
+    host: users.localhost
+    namespace: users
+    service: users-api
+    protocol: http
+`,
+			want: []port.ListenerPort{80},
+		},
+		{
+			// 接続するホストがないlistener_port・名前付きリスナーは開かない
+			name: "名前付きリスナーと個別リスナー",
+			content: `
+listeners:
+  web: 8081
+  api: 8082
+services:
+  - kind: kubernetes
+    host: frontend.localhost
+    namespace: web
+    service: frontend
+    protocol: http
+    listeners: [web]
+  - kind: kubernetes
+    host: admin.localhost
+    namespace: admin
+    service: admin-api
+    protocol: http
+    listener_port: 9000
+`,
+			want: []port.ListenerPort{8081, 9000},
+		},
+		{
+			name: "ダッシュボードはlistener_portで配信する",
+			content: `
+dashboard: {}
+services:
+  - kind: kubernetes
+    host: admin.localhost
+    namespace: admin
+    service: admin-api
+    protocol: http
+    listener_port: 9000
+`,
+			want: []port.ListenerPort{80, 9000},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := loadContent(t, tt.content)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := cfg.HTTPListenerPorts(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestConfig_ReferencedFiles(t *testing.T) {
+	tmpDir := t.TempDir()
+	files := map[string]string{
+		"password":     "s3cret\n",
+		"token":        "t0ken\n",
+		"strip.lua":    "function envoy_on_request(handle) end\n",
+		"cluster.yaml": "connect_timeout: 5s\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(tmpDir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	configPath := filepath.Join(tmpDir, "config.yaml")
+	content := `
+listener_auth:
+  basic:
+    username: admin
+    password:
+      file: password
+  bearer_token:
+    file: token
+envoy_overrides:
+  - cluster: users-api
+    file: cluster.yaml
+services:
+  - kind: kubernetes
+    host: users.localhost
+    namespace: users
+    service: users-api
+    protocol: http
+    lua_file: strip.lua
+  - kind: kubernetes
+    host: admin.localhost
+    namespace: admin
+    service: admin-api
+    protocol: http
+    lua_file: strip.lua
+`
+	if err := os.WriteFile(configPath, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := Load(configPath)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// パスは設定ファイルのディレクトリで解決し、重複は除く
+	want := []string{
+		filepath.Join(tmpDir, "password"),
+		filepath.Join(tmpDir, "token"),
+		filepath.Join(tmpDir, "strip.lua"),
+		filepath.Join(tmpDir, "cluster.yaml"),
+	}
+	if got := cfg.ReferencedFiles(); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+}
+
+func TestLoad_Listeners_Invalid(t *testing.T) {
+	tests := []struct {
+		name      string
+		listeners string
+		extra     string
+		wantErr   string
+	}{
+		{
+			name:      "defaultは予約済み",
+			listeners: "listeners:\n  default: 8081\n",
+			wantErr:   "listener name 'default' is reserved for listener_port",
+		},
+		{
+			name:      "不正な名前",
+			listeners: "listeners:\n  web.api: 8081\n",
+			wantErr:   "invalid listener name 'web.api'",
+		},
+		{
+			name:      "ポートなし",
+			listeners: "listeners:\n  web: 0\n",
+			wantErr:   "listeners.web is required",
+		},
+		{
+			name:      "listener_portと同じポート",
+			listeners: "listeners:\n  web: 80\n",
+			wantErr:   "listener 'web' uses port 80 which is already used by listener_port",
+		},
+		{
+			name:      "名前付きリスナー同士で同じポート",
+			listeners: "listeners:\n  api: 8081\n  web: 8081\n",
+			wantErr:   "listener 'web' uses port 8081 which is already used by listener 'api'",
+		},
+		{
+			name:      "未定義のリスナー",
+			listeners: "listeners:\n  web: 8081\n",
+			extra:     "    listeners: [api]\n",
+			wantErr:   "listener 'api' not found for kubernetes service 'users.localhost'",
+		},
+		{
+			name:      "リスナーの重複",
+			listeners: "listeners:\n  web: 8081\n",
+			extra:     "    listeners: [web, web]\n",
+			wantErr:   "listeners[1] 'web' is duplicated",
+		},
+		{
+			name:      "listener_portと併用",
+			listeners: "listeners:\n  web: 8081\n",
+			extra:     "    listeners: [web]\n    listener_port: 9000\n",
+			wantErr:   "listeners and listener_port are mutually exclusive",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := loadContent(t, tt.listeners+`
+services:
+  - kind: kubernetes
+    host: users.localhost
+    namespace: users
+    service: users-api
+    protocol: http
+`+tt.extra)
+			if err == nil {
+				t.Fatal("expected error, got nil")
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got %q", tt.wantErr, err.Error())
+			}
+		})
+	}
+}
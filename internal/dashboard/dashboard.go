@@ -0,0 +1,173 @@
+// Package dashboard はメインのリスナーで提供するサービス一覧とライブステータスのページを提供します。
+// 各サービスのURL・バックエンド・トンネルの接続状態とEnvoyのupstream統計（データベースはクエリ統計も）をHTMLとJSONで返します。
+package dashboard
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// statsTimeout はEnvoyの統計の取得を待つ時間
+const statsTimeout = 2 * time.Second
+
+// port-forward・SSH tunnelの接続状態
+const (
+	StateConnecting   = "connecting"   // 一度も確立していない
+	StateReady        = "ready"        // 確立済み
+	StateReconnecting = "reconnecting" // 切断され、再接続中
+)
+
+// Service はダッシュボードに表示するサービス
+type Service struct {
+	Host     string         `json:"host"`
+	URL      string         `json:"url,omitempty"` // ブラウザで開けるHTTPサービスのみ
+	Address  string         `json:"address"`       // 接続先（host:port）
+	Protocol string         `json:"protocol"`
+	Backend  string         `json:"backend"`
+	Details  []string       `json:"details,omitempty"`
+	Tunnels  []Tunnel       `json:"tunnels,omitempty"`
+	Clusters []string       `json:"clusters"` // 統計を集計するEnvoyクラスタ
+	Upstream *UpstreamStats `json:"upstream,omitempty"`
+
+	// MirrorClusters はミラー先のEnvoyクラスタ（同じリクエストを重複して数えないようUpstreamとは別に集計する）
+	MirrorClusters []string       `json:"mirror_clusters,omitempty"`
+	Mirror         *UpstreamStats `json:"mirror,omitempty"`
+
+	// QueryStatPrefix はpostgres_proxy・mysql_proxyの統計のプレフィックス（"<protocol>.<stat_prefix>"）
+	// 空の場合はクエリ統計を表示しない
+	QueryStatPrefix string      `json:"query_stat_prefix,omitempty"`
+	Queries         *QueryStats `json:"queries,omitempty"`
+}
+
+// Tunnel はport-forward・SSH tunnelの接続状態
+type Tunnel struct {
+	Target     string    `json:"target"`
+	State      string    `json:"state"`
+	Pod        string    `json:"pod,omitempty"` // port-forwardの接続先Pod
+	Since      time.Time `json:"since"`         // 現在の状態になった時刻
+	Reconnects int       `json:"reconnects"`
+}
+
+// UpstreamStats はEnvoyのupstreamクラスタの統計
+type UpstreamStats struct {
+	ActiveConnections uint64 `json:"active_connections"`
+	ActiveRequests    uint64 `json:"active_requests"`
+	TotalRequests     uint64 `json:"total_requests"`
+	Errors5xx         uint64 `json:"errors_5xx"`
+	ConnectFailures   uint64 `json:"connect_failures"`
+	HealthyEndpoints  uint64 `json:"healthy_endpoints"`
+	TotalEndpoints    uint64 `json:"total_endpoints"`
+}
+
+// QueryStats はpostgres_proxy・mysql_proxyのクエリ統計
+// プロトコルにない統計（MySQLのトランザクション、PostgreSQLのログイン失敗）は0
+type QueryStats struct {
+	Sessions      uint64 `json:"sessions"`
+	Queries       uint64 `json:"queries"`      // PostgreSQL: statements、MySQL: queries_parsed
+	Transactions  uint64 `json:"transactions"` // PostgreSQLのみ
+	Errors        uint64 `json:"errors"`       // PostgreSQL: errors、MySQL: protocol_errors
+	ParseErrors   uint64 `json:"parse_errors"`
+	LoginFailures uint64 `json:"login_failures"` // MySQLのみ
+}
+
+// Stats はEnvoyから取得した統計
+type Stats struct {
+	Clusters  map[string]UpstreamStats // キーはクラスタ名
+	Databases map[string]QueryStats    // キーは"<protocol>.<stat_prefix>"
+}
+
+// add は統計を合算する
+func (s *UpstreamStats) add(o UpstreamStats) {
+	s.ActiveConnections += o.ActiveConnections
+	s.ActiveRequests += o.ActiveRequests
+	s.TotalRequests += o.TotalRequests
+	s.Errors5xx += o.Errors5xx
+	s.ConnectFailures += o.ConnectFailures
+	s.HealthyEndpoints += o.HealthyEndpoints
+	s.TotalEndpoints += o.TotalEndpoints
+}
+
+// Source は現在のサービスを返す
+type Source func() []Service
+
+// StatsSource はEnvoyクラスタ・データベースごとの統計を返す
+type StatsSource func(ctx context.Context) (Stats, error)
+
+// Status はダッシュボードのJSONレスポンス
+type Status struct {
+	GeneratedAt time.Time `json:"generated_at"`
+	Services    []Service `json:"services"`
+	StatsError  string    `json:"stats_error,omitempty"` // 統計を取得できなかった場合の理由
+}
+
+// NewHandler はダッシュボードのHTTPハンドラーを生成
+// "/"でHTMLのページ、"/api/services"でJSONを返す
+// statsがnilの場合（組み込みプロキシなど）はupstreamの統計を表示しない
+func NewHandler(services Source, stats StatsSource) http.Handler {
+	h := &handler{services: services, stats: stats}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", h.serveHTML)
+	mux.HandleFunc("GET /api/services", h.serveJSON)
+	return mux
+}
+
+type handler struct {
+	services Source
+	stats    StatsSource
+}
+
+// status は現在のサービスに統計を付与する
+func (h *handler) status(ctx context.Context) Status {
+	st := Status{GeneratedAt: time.Now(), Services: h.services()}
+	if h.stats == nil {
+		return st
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, statsTimeout)
+	defer cancel()
+	stats, err := h.stats(ctx)
+	if err != nil {
+		st.StatsError = err.Error()
+		return st
+	}
+	for i := range st.Services {
+		st.Services[i].Upstream = sumClusters(stats.Clusters, st.Services[i].Clusters)
+		if mirrors := st.Services[i].MirrorClusters; len(mirrors) > 0 {
+			st.Services[i].Mirror = sumClusters(stats.Clusters, mirrors)
+		}
+		if prefix := st.Services[i].QueryStatPrefix; prefix != "" {
+			q := stats.Databases[prefix]
+			st.Services[i].Queries = &q
+		}
+	}
+	return st
+}
+
+// sumClusters はクラスタの統計を合算する（統計のないクラスタは0）
+func sumClusters(stats map[string]UpstreamStats, clusters []string) *UpstreamStats {
+	var sum UpstreamStats
+	for _, c := range clusters {
+		sum.add(stats[c])
+	}
+	return &sum
+}
+
+func (h *handler) serveJSON(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	_ = enc.Encode(h.status(r.Context()))
+}
+
+func (h *handler) serveHTML(w http.ResponseWriter, r *http.Request) {
+	var buf bytes.Buffer
+	if err := pageTemplate.Execute(&buf, h.status(r.Context())); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_, _ = buf.WriteTo(w)
+}
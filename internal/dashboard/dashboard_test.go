@@ -0,0 +1,214 @@
+package dashboard
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func testServices() []Service {
+	return []Service{
+		{
+			Host:           "users.localhost",
+			URL:            "http://users.localhost",
+			Address:        "users.localhost:80",
+			Protocol:       "http",
+			Backend:        "default/users:8080",
+			Tunnels:        []Tunnel{{Target: "default/users:8080", State: StateReady, Pod: "users-7d9f-abcde"}},
+			Clusters:       []string{"default_users_8080", "default_users_8080_canary"},
+			MirrorClusters: []string{"default_users_8080_mirror"},
+		},
+		{
+			Host:     "db.localhost",
+			Address:  "db.localhost:5432",
+			Protocol: "tcp",
+			Backend:  "primary @ 10.0.0.1:5432",
+			Tunnels:  []Tunnel{{Target: "primary @ 10.0.0.1:5432", State: StateReconnecting, Reconnects: 2}},
+			Clusters: []string{"tcp_primary_10_0_0_1_5432"},
+		},
+		{
+			Host:            "orders-db.localdomain",
+			Address:         "orders-db.localdomain:5432",
+			Protocol:        "tcp",
+			Backend:         "primary @ 10.0.0.2:5432",
+			Clusters:        []string{"tcp_primary_10_0_0_2_5432"},
+			QueryStatPrefix: "postgres.orders_db_localdomain",
+		},
+	}
+}
+
+func TestParseStats(t *testing.T) {
+	body := `{"stats":[
+		{"name":"cluster.default_users_8080.upstream_cx_active","value":2},
+		{"name":"cluster.default_users_8080.upstream_rq_total","value":120},
+		{"name":"cluster.default_users_8080.upstream_rq_5xx","value":3},
+		{"name":"cluster.default_users_8080.membership_healthy","value":1},
+		{"name":"cluster.default_users_8080.membership_total","value":1},
+		{"name":"cluster.default_users_8080.outlier_detection.ejections_active","value":0},
+		{"name":"cluster_manager.active_clusters","value":4},
+		{"name":"postgres.orders_db_localdomain.sessions","value":3},
+		{"name":"postgres.orders_db_localdomain.statements","value":42},
+		{"name":"postgres.orders_db_localdomain.statements_select","value":40},
+		{"name":"postgres.orders_db_localdomain.transactions","value":7},
+		{"name":"postgres.orders_db_localdomain.errors","value":1},
+		{"name":"mysql.users_db_localdomain.sessions","value":2},
+		{"name":"mysql.users_db_localdomain.queries_parsed","value":9},
+		{"name":"mysql.users_db_localdomain.login_failures","value":1},
+		{"name":"mysql.users_db_localdomain.users.select","value":9},
+		{"histograms":{"supported_quantiles":[50]}}
+	]}`
+
+	stats, err := parseStats(strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(stats.Clusters) != 1 {
+		t.Fatalf("expected 1 cluster, got %d: %v", len(stats.Clusters), stats.Clusters)
+	}
+	want := UpstreamStats{ActiveConnections: 2, TotalRequests: 120, Errors5xx: 3, HealthyEndpoints: 1, TotalEndpoints: 1}
+	if got := stats.Clusters["default_users_8080"]; got != want {
+		t.Errorf("expected %+v, got %+v", want, got)
+	}
+
+	if len(stats.Databases) != 2 {
+		t.Fatalf("expected 2 databases, got %d: %v", len(stats.Databases), stats.Databases)
+	}
+	wantPostgres := QueryStats{Sessions: 3, Queries: 42, Transactions: 7, Errors: 1}
+	if got := stats.Databases["postgres.orders_db_localdomain"]; got != wantPostgres {
+		t.Errorf("expected %+v, got %+v", wantPostgres, got)
+	}
+	// テーブル単位の統計は含めない
+	wantMySQL := QueryStats{Sessions: 2, Queries: 9, LoginFailures: 1}
+	if got := stats.Databases["mysql.users_db_localdomain"]; got != wantMySQL {
+		t.Errorf("expected %+v, got %+v", wantMySQL, got)
+	}
+
+	if _, err := parseStats(strings.NewReader("not json")); err == nil {
+		t.Error("expected error for invalid json")
+	}
+}
+
+func TestHandler_JSON(t *testing.T) {
+	stats := func(context.Context) (Stats, error) {
+		return Stats{
+			Clusters: map[string]UpstreamStats{
+				"default_users_8080":        {TotalRequests: 10, HealthyEndpoints: 1, TotalEndpoints: 1},
+				"default_users_8080_canary": {TotalRequests: 5, HealthyEndpoints: 1, TotalEndpoints: 1},
+				"default_users_8080_mirror": {TotalRequests: 15, Errors5xx: 2, HealthyEndpoints: 1, TotalEndpoints: 1},
+			},
+			Databases: map[string]QueryStats{
+				"postgres.orders_db_localdomain": {Sessions: 1, Queries: 12},
+			},
+		}, nil
+	}
+	srv := httptest.NewServer(NewHandler(testServices, stats))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/api/services")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if ct := resp.Header.Get("Content-Type"); ct != "application/json" {
+		t.Errorf("unexpected content type: %s", ct)
+	}
+
+	var st Status
+	if err := json.NewDecoder(resp.Body).Decode(&st); err != nil {
+		t.Fatalf("failed to decode: %v", err)
+	}
+	if len(st.Services) != 3 {
+		t.Fatalf("expected 3 services, got %d", len(st.Services))
+	}
+	// サービスの統計はクラスタを合算する
+	if got := st.Services[0].Upstream; got == nil || got.TotalRequests != 15 || got.TotalEndpoints != 2 {
+		t.Errorf("unexpected upstream stats: %+v", got)
+	}
+	// ミラー先の統計は合算せず別に返す
+	if got := st.Services[0].Mirror; got == nil || got.TotalRequests != 15 || got.Errors5xx != 2 {
+		t.Errorf("unexpected mirror stats: %+v", got)
+	}
+	if st.Services[1].Mirror != nil {
+		t.Errorf("expected no mirror stats, got %+v", st.Services[1].Mirror)
+	}
+	// 統計のないクラスタは0
+	if got := st.Services[1].Upstream; got == nil || got.TotalRequests != 0 {
+		t.Errorf("unexpected upstream stats: %+v", got)
+	}
+	// クエリ統計はprotocolを指定したサービスのみ
+	if st.Services[0].Queries != nil || st.Services[1].Queries != nil {
+		t.Error("expected no query stats for services without protocol")
+	}
+	if got := st.Services[2].Queries; got == nil || got.Sessions != 1 || got.Queries != 12 {
+		t.Errorf("unexpected query stats: %+v", got)
+	}
+	if got := st.Services[0].Tunnels[0]; got.State != StateReady || got.Pod != "users-7d9f-abcde" {
+		t.Errorf("unexpected tunnel: %+v", got)
+	}
+}
+
+func TestHandler_HTML(t *testing.T) {
+	stats := func(context.Context) (Stats, error) {
+		return Stats{}, errors.New("connection refused")
+	}
+	srv := httptest.NewServer(NewHandler(testServices, stats))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	b, _ := io.ReadAll(resp.Body)
+	page := string(b)
+
+	for _, want := range []string{
+		`<a href="http://users.localhost">users.localhost:80</a>`,
+		"users-7d9f-abcde",
+		"db.localhost:5432",
+		`<span class="reconnecting">reconnecting</span>`,
+		"2 reconnects",
+		"envoy stats unavailable: connection refused",
+	} {
+		if !strings.Contains(page, want) {
+			t.Errorf("expected page to contain %q", want)
+		}
+	}
+
+	resp, err = http.Get(srv.URL + "/unknown")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("expected 404, got %d", resp.StatusCode)
+	}
+}
+
+func TestHandler_HTMLQueryStats(t *testing.T) {
+	stats := func(context.Context) (Stats, error) {
+		return Stats{Databases: map[string]QueryStats{
+			"postgres.orders_db_localdomain": {Sessions: 1, Queries: 12, Transactions: 4},
+		}}, nil
+	}
+	srv := httptest.NewServer(NewHandler(testServices, stats))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	b, _ := io.ReadAll(resp.Body)
+	if want := "1 sessions, 12 queries, 4 transactions, 0 errors, 0 parse errors"; !strings.Contains(string(b), want) {
+		t.Errorf("expected page to contain %q", want)
+	}
+	if n := strings.Count(string(b), `class="queries"`); n != 1 {
+		t.Errorf("expected query stats for 1 service, got %d", n)
+	}
+}
@@ -0,0 +1,56 @@
+package dashboard
+
+import (
+	"html/template"
+	"time"
+)
+
+// refreshSeconds はページを自動で再読み込みする間隔
+const refreshSeconds = 5
+
+var pageTemplate = template.Must(template.New("dashboard").Funcs(template.FuncMap{
+	"ago": func(t time.Time) string {
+		if t.IsZero() {
+			return ""
+		}
+		return time.Since(t).Truncate(time.Second).String()
+	},
+	"refresh": func() int { return refreshSeconds },
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta http-equiv="refresh" content="{{refresh}}">
+<title>kubectl-localmesh</title>
+<style>
+body { font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", sans-serif; margin: 2em; color: #222; }
+table { border-collapse: collapse; width: 100%; }
+th, td { text-align: left; vertical-align: top; padding: .5em .75em; border-bottom: 1px solid #ddd; }
+th { background: #f5f5f5; }
+.queries { margin-top: .25em; }
+.details { color: #666; font-size: .85em; margin: .25em 0 0; padding-left: 1.2em; }
+.ready { color: #1a7f37; }
+.connecting, .reconnecting { color: #bf8700; }
+.muted { color: #888; }
+.error { color: #cf222e; }
+</style>
+</head>
+<body>
+<h1>kubectl-localmesh</h1>
+<p class="muted">{{len .Services}} services &middot; refreshed every {{refresh}}s &middot; <a href="/api/services">JSON</a></p>
+{{if .StatsError}}<p class="error">envoy stats unavailable: {{.StatsError}}</p>{{end}}
+<table>
+<tr><th>Host</th><th>Protocol</th><th>Backend</th><th>Tunnel</th><th>Upstream</th></tr>
+{{range .Services}}
+<tr>
+<td>{{if .URL}}<a href="{{.URL}}">{{.Address}}</a>{{else}}{{.Address}}{{end}}</td>
+<td>{{.Protocol}}</td>
+<td>{{.Backend}}{{if .Details}}<ul class="details">{{range .Details}}<li>{{.}}</li>{{end}}</ul>{{end}}</td>
+<td>{{range .Tunnels}}<div><span class="{{.State}}">{{.State}}</span> {{.Target}}{{if .Pod}} ({{.Pod}}){{end}} <span class="muted">{{ago .Since}}{{if .Reconnects}}, {{.Reconnects}} reconnects{{end}}</span></div>{{else}}<span class="muted">-</span>{{end}}</td>
+<td>{{with .Upstream}}{{.ActiveConnections}} cx, {{.ActiveRequests}} active rq, {{.TotalRequests}} rq total, {{.Errors5xx}} 5xx, {{.ConnectFailures}} connect failures<br><span class="muted">{{.HealthyEndpoints}}/{{.TotalEndpoints}} endpoints healthy</span>{{else}}<span class="muted">-</span>{{end}}{{with .Mirror}}<div class="muted">mirror: {{.TotalRequests}} rq total, {{.Errors5xx}} 5xx, {{.ConnectFailures}} connect failures</div>{{end}}{{with .Queries}}<div class="queries">{{.Sessions}} sessions, {{.Queries}} queries{{if .Transactions}}, {{.Transactions}} transactions{{end}}, {{.Errors}} errors, {{.ParseErrors}} parse errors{{if .LoginFailures}}, {{.LoginFailures}} login failures{{end}}</div>{{end}}</td>
+</tr>
+{{end}}
+</table>
+</body>
+</html>
+`))
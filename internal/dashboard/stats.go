@@ -0,0 +1,125 @@
+package dashboard
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// statsPath はEnvoyの管理インターフェースでupstreamクラスタとデータベースの統計を取得するパス
+const statsPath = `/stats?format=json&filter=^(cluster|postgres|mysql)\.`
+
+// EnvoyStats はEnvoyの管理インターフェース（unixソケット）から統計を取得するStatsSourceを生成
+func EnvoyStats(adminSocket string) StatsSource {
+	client := &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, "unix", adminSocket)
+			},
+		},
+	}
+	return func(ctx context.Context) (Stats, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://envoy"+statsPath, nil)
+		if err != nil {
+			return Stats{}, err
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return Stats{}, err
+		}
+		defer func() { _ = resp.Body.Close() }()
+		if resp.StatusCode != http.StatusOK {
+			return Stats{}, fmt.Errorf("envoy admin returned %s", resp.Status)
+		}
+		return parseStats(resp.Body)
+	}
+}
+
+// parseStats はEnvoyのJSON形式の統計（/stats?format=json）をクラスタ・データベースごとに集計する
+// 統計名は"cluster.<クラスタ名>.<統計>"・"<protocol>.<stat_prefix>.<統計>"の形式（名前は"."を含まない）
+func parseStats(r io.Reader) (Stats, error) {
+	var body struct {
+		Stats []struct {
+			Name  string  `json:"name"`
+			Value float64 `json:"value"`
+		} `json:"stats"`
+	}
+	if err := json.NewDecoder(r).Decode(&body); err != nil {
+		return Stats{}, fmt.Errorf("failed to parse envoy stats: %w", err)
+	}
+
+	result := Stats{Clusters: make(map[string]UpstreamStats), Databases: make(map[string]QueryStats)}
+	for _, s := range body.Stats {
+		protocol, rest, ok := strings.Cut(s.Name, ".")
+		if !ok {
+			continue
+		}
+		name, stat, ok := strings.Cut(rest, ".")
+		if !ok {
+			continue
+		}
+		v := uint64(s.Value)
+		switch protocol {
+		case "cluster":
+			if st, ok := addClusterStat(result.Clusters[name], stat, v); ok {
+				result.Clusters[name] = st
+			}
+		case "postgres", "mysql":
+			key := protocol + "." + name
+			if st, ok := addQueryStat(result.Databases[key], protocol, stat, v); ok {
+				result.Databases[key] = st
+			}
+		}
+	}
+	return result, nil
+}
+
+// addClusterStat はupstreamクラスタの統計を設定する（表示しない統計の場合はfalse）
+func addClusterStat(st UpstreamStats, stat string, v uint64) (UpstreamStats, bool) {
+	switch stat {
+	case "upstream_cx_active":
+		st.ActiveConnections = v
+	case "upstream_rq_active":
+		st.ActiveRequests = v
+	case "upstream_rq_total":
+		st.TotalRequests = v
+	case "upstream_rq_5xx":
+		st.Errors5xx = v
+	case "upstream_cx_connect_fail":
+		st.ConnectFailures = v
+	case "membership_healthy":
+		st.HealthyEndpoints = v
+	case "membership_total":
+		st.TotalEndpoints = v
+	default:
+		return st, false
+	}
+	return st, true
+}
+
+// addQueryStat はpostgres_proxy・mysql_proxyの統計を設定する（表示しない統計の場合はfalse）
+// MySQLのテーブル単位の統計（"<table>.<operation>"）は含めない
+func addQueryStat(st QueryStats, protocol, stat string, v uint64) (QueryStats, bool) {
+	switch protocol + "." + stat {
+	case "postgres.sessions", "mysql.sessions":
+		st.Sessions = v
+	case "postgres.statements", "mysql.queries_parsed":
+		st.Queries = v
+	case "postgres.transactions":
+		st.Transactions = v
+	case "postgres.errors", "mysql.protocol_errors":
+		st.Errors = v
+	case "postgres.statements_parse_error", "mysql.queries_parse_error":
+		st.ParseErrors = v
+	case "mysql.login_failures":
+		st.LoginFailures = v
+	default:
+		return st, false
+	}
+	return st, true
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 
+	bootstrapv3 "github.com/envoyproxy/go-control-plane/envoy/config/bootstrap/v3"
 	"gopkg.in/yaml.v3"
 
 	"github.com/usadamasa/kubectl-localmesh/internal/config"
@@ -15,6 +16,7 @@ import (
 type DumpOptions struct {
 	MockConfigPath string
 	OutputMapping  bool
+	AccessLog      *envoy.AccessLog // すべてのリスナーに設定するアクセスログ（nilの場合は出力しない）
 }
 
 func DumpEnvoyConfig(ctx context.Context, cfg *config.Config, mockConfigPath string) error {
@@ -35,14 +37,8 @@ func DumpEnvoyConfigWithOptions(ctx context.Context, cfg *config.Config, opts Du
 
 	// Visitor の生成（Kubernetes clientはサービスごとにlazy初期化）
 	visitor := NewDumpVisitor(ctx, cfg.Cluster, mockCfg)
-
-	// Visitorパターンで各サービスを処理
-	for i, svcDef := range cfg.Services {
-		visitor.SetIndex(i)
-		svc := svcDef.Get()
-		if err := svc.Accept(visitor); err != nil {
-			return err
-		}
+	if err := visitServices(cfg, visitor); err != nil {
+		return err
 	}
 
 	serviceConfigs := visitor.GetServiceConfigs()
@@ -59,9 +55,12 @@ func DumpEnvoyConfigWithOptions(ctx context.Context, cfg *config.Config, opts Du
 	}
 
 	// Envoy設定生成（デフォルト）
-	envoyCfg := envoy.BuildConfig(cfg.ListenerPort, serviceConfigs)
+	envoyCfg, err := buildEnvoyConfig(cfg, serviceConfigs, opts.AccessLog)
+	if err != nil {
+		return err
+	}
 
-	b, err := yaml.Marshal(envoyCfg)
+	b, err := envoy.MarshalYAML(envoyCfg)
 	if err != nil {
 		return err
 	}
@@ -69,3 +68,68 @@ func DumpEnvoyConfigWithOptions(ctx context.Context, cfg *config.Config, opts Du
 	fmt.Print(string(b)) //nolint:forbidigo // CLIダンプ出力として意図的に使用
 	return nil
 }
+
+// ValidateOverrides はenvoy_overridesを生成したEnvoy設定に適用できるか検証する
+// モック設定が指定されない場合はクラスタに接続せず、サービスのportをそのまま解決後のポートとして扱う
+// （port_nameのみの場合は0。クラスタ名に解決後のポートが含まれるため、対象の指定には--mock-configを使用する）
+func ValidateOverrides(ctx context.Context, cfg *config.Config, mockConfigPath string) error {
+	if len(cfg.EnvoyOverrides) == 0 {
+		return nil
+	}
+
+	mockCfg, err := config.LoadMockConfig(mockConfigPath)
+	if err != nil {
+		return fmt.Errorf("failed to load mock config: %w", err)
+	}
+
+	visitor := NewDumpVisitor(ctx, cfg.Cluster, mockCfg)
+	visitor.offline = mockCfg == nil
+	if err := visitServices(cfg, visitor); err != nil {
+		return err
+	}
+
+	_, err = buildEnvoyConfig(cfg, visitor.GetServiceConfigs(), nil)
+	return err
+}
+
+// visitServices はVisitorで各サービス・集約gRPCホスト・ダッシュボードを処理する
+func visitServices(cfg *config.Config, visitor *DumpVisitor) error {
+	visitor.SetListeners(cfg.Listeners)
+	visitor.SetHTTPListenerPorts(cfg.HTTPListenerPorts())
+	for i, svcDef := range cfg.Services {
+		visitor.SetIndex(i)
+		svc := svcDef.Get()
+		if err := svc.Accept(visitor); err != nil {
+			return err
+		}
+	}
+
+	// 集約gRPCホスト
+	if cfg.GRPCAggregate != nil {
+		visitor.SetIndex(len(cfg.Services))
+		visitor.AddGRPCAggregate(cfg.GRPCAggregate)
+	}
+
+	// ダッシュボード
+	if host := cfg.DashboardHost(); host != "" {
+		visitor.SetIndex(len(cfg.Services) + 1)
+		visitor.AddDashboard(host)
+	}
+	return nil
+}
+
+// buildEnvoyConfig はEnvoy設定を生成し、envoy_overridesを適用する
+func buildEnvoyConfig(cfg *config.Config, serviceConfigs []envoy.ServiceConfig, accessLog *envoy.AccessLog) (*bootstrapv3.Bootstrap, error) {
+	envoyCfg, err := envoy.BuildConfigWithOptions(cfg.ListenerPort, serviceConfigs, envoy.BuildOptions{
+		RateLimit: toEnvoyRateLimit(cfg.RateLimit),
+		AccessLog: accessLog,
+		Tracing:   toEnvoyTracing(cfg.Tracing),
+		// ::1を利用できるかは実行環境に依存するため、ダンプでは既定のアドレスをそのまま使う
+		BindAddresses: cfg.EffectiveBindAddresses(),
+		ListenerAuth:  toEnvoyListenerAuth(cfg.ListenerAuth),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return envoy.ApplyOverrides(envoyCfg, toEnvoyOverrides(cfg.EnvoyOverrides))
+}
@@ -3,11 +3,13 @@ package dump
 import (
 	"context"
 	"fmt"
+	"slices"
 
 	"k8s.io/client-go/kubernetes"
 
 	"github.com/usadamasa/kubectl-localmesh/internal/config"
 	"github.com/usadamasa/kubectl-localmesh/internal/envoy"
+	"github.com/usadamasa/kubectl-localmesh/internal/grpcagg"
 	"github.com/usadamasa/kubectl-localmesh/internal/k8s"
 	"github.com/usadamasa/kubectl-localmesh/internal/loopback"
 	"github.com/usadamasa/kubectl-localmesh/internal/port"
@@ -24,6 +26,8 @@ type DumpVisitor struct {
 	defaultCluster string
 	mockCfg        *config.MockConfig
 	idx            int
+	upstreamIdx    int  // ミラー先など追加バックエンドのダミーポート用インデックス
+	offline        bool // モック設定がない場合にクラスタへ接続せず、portをそのまま解決後のポートとして扱う
 
 	// cluster名 → clientset のキャッシュ
 	clients map[string]*k8sClientEntry
@@ -31,6 +35,15 @@ type DumpVisitor struct {
 	// loopback IPアロケータ（TCPサービス用）
 	ipAllocator *loopback.IPAllocator
 
+	// 名前付きリスナーのポート（SetListenersで設定）
+	listeners map[string]port.ListenerPort
+
+	// メッシュのHTTPリスナーのポート（SetHTTPListenerPortsで設定）
+	httpListenerPorts []port.ListenerPort
+
+	// モック設定から得たgRPCサービス一覧（grpc_aggregate用）
+	grpcBackends []grpcagg.BackendServices
+
 	// 結果
 	serviceConfigs []envoy.ServiceConfig
 }
@@ -72,33 +85,9 @@ func (v *DumpVisitor) getOrCreateClient(serviceCluster string) (*kubernetes.Clie
 
 // VisitKubernetes は Kubernetes Service の処理（ダンプ用）
 func (v *DumpVisitor) VisitKubernetes(s *config.KubernetesService) error {
-	var remotePort port.ServicePort
-	var err error
-
-	// モック設定がある場合はモックから取得
-	if v.mockCfg != nil {
-		remotePort, err = findMockPort(v.mockCfg, s.Namespace, s.Service, s.PortName)
-		if err != nil {
-			return err
-		}
-	} else {
-		// サービスに対応するKubernetes clientを取得
-		clientset, clientErr := v.getOrCreateClient(s.Cluster)
-		if clientErr != nil {
-			return fmt.Errorf("failed to create kubernetes client for service '%s': %w", s.Host, clientErr)
-		}
-
-		remotePort, err = k8s.ResolveServicePort(
-			v.ctx,
-			clientset,
-			s.Namespace,
-			s.Service,
-			s.PortName,
-			s.Port,
-		)
-		if err != nil {
-			return err
-		}
+	remotePort, mock, err := v.resolveRemotePort(s.PrimaryCluster(), s.Namespace, s.Service, s.PortName, s.Port)
+	if err != nil {
+		return err
 	}
 
 	// ダミーのローカルポート
@@ -106,8 +95,95 @@ func (v *DumpVisitor) VisitKubernetes(s *config.KubernetesService) error {
 	clusterName := sanitize(fmt.Sprintf("%s_%s_%d", s.Namespace, s.Service, remotePort))
 
 	builder := envoy.NewKubernetesServiceBuilder(
-		s.Host, s.Protocol, s.Namespace, s.Service, s.PortName, s.Port, s.ListenerPort, s.Cluster,
+		s.Host, s.Protocol, s.Namespace, s.Service, s.PortName, s.Port, s.ListenerPort, s.PrimaryCluster(),
 	)
+	if s.BindAddress != "" {
+		builder.BindAddresses = []string{s.BindAddress}
+	}
+	if len(s.Listeners) > 0 {
+		builder.Listeners = v.sharedListeners(s.Listeners)
+	}
+	if aliases := s.HostAliases(); len(aliases) > 0 {
+		builder.Aliases = aliases
+		// クラスタ内のURL（host:サービスのポート）は、そのポートにHTTPリスナーがある場合のみ一致させる
+		if slices.Contains(v.httpListenerPorts, port.ListenerPort(remotePort)) {
+			builder.AliasPort = remotePort
+		}
+	}
+
+	// フェイルオーバー先のcluster（同じEnvoyクラスタに下位の優先度で追加）
+	if len(s.Clusters) > 1 {
+		for _, c := range s.Clusters[1:] {
+			backend := &config.KubernetesBackend{
+				Namespace: s.Namespace,
+				Service:   s.Service,
+				PortName:  s.PortName,
+				Port:      s.Port,
+				Cluster:   c,
+			}
+			upstream, err := v.buildUpstream(backend, clusterName)
+			if err != nil {
+				return fmt.Errorf("failed to set up failover cluster '%s' for service '%s': %w", c, s.Host, err)
+			}
+			builder.Failover = append(builder.Failover, upstream)
+		}
+	}
+
+	// ローカルプロセスを優先し、port-forwardをフォールバック先にする
+	if s.LocalOverride != nil {
+		address, p := s.LocalOverride.Endpoint()
+		builder.LocalOverride = &envoy.LocalOverride{
+			Address:    address,
+			Port:       p,
+			HealthPath: s.LocalOverride.HealthPath,
+		}
+	}
+
+	for i, r := range s.HeaderRoutes {
+		route := envoy.HeaderRoute{Header: r.Header, Value: r.Value}
+		routeClusterName := fmt.Sprintf("%s_header_%d", clusterName, i+1)
+		if r.Address != "" {
+			address, p := r.Endpoint()
+			route.Upstream = envoy.Upstream{ClusterName: routeClusterName, LocalPort: port.LocalPort(p)}
+			route.Address = address
+		} else {
+			upstream, err := v.buildUpstream(&r.KubernetesBackend, routeClusterName)
+			if err != nil {
+				return fmt.Errorf("failed to set up header_routes[%d] for service '%s': %w", i, s.Host, err)
+			}
+			route.Upstream = upstream
+		}
+		builder.HeaderRoutes = append(builder.HeaderRoutes, route)
+	}
+
+	if len(s.Split) > 0 {
+		builder.Weight = *s.Weight
+		for i, b := range s.Split {
+			upstream, err := v.buildUpstream(&b.KubernetesBackend, fmt.Sprintf("%s_split_%d", clusterName, i+1))
+			if err != nil {
+				return fmt.Errorf("failed to set up split[%d] for service '%s': %w", i, s.Host, err)
+			}
+			builder.Splits = append(builder.Splits, envoy.WeightedUpstream{Upstream: upstream, Weight: b.Weight})
+		}
+	}
+
+	if s.Mirror != nil {
+		upstream, err := v.buildUpstream(&s.Mirror.KubernetesBackend, clusterName+"_mirror")
+		if err != nil {
+			return fmt.Errorf("failed to set up mirror for service '%s': %w", s.Host, err)
+		}
+		builder.Mirror = &envoy.MirrorUpstream{
+			Upstream: upstream,
+			Percent:  s.Mirror.EffectivePercent(),
+		}
+	}
+
+	builder.RateLimit = toEnvoyRateLimit(s.RateLimit)
+	builder.AccessLog = toEnvoyAccessLog(s.AccessLog)
+	builder.HealthCheck = toEnvoyHealthCheck(s.HealthCheck)
+	builder.Lua = s.LuaScript()
+	builder.RedirectHosts = s.RedirectHosts()
+	builder.CookieDomains = s.CookieDomains()
 
 	v.serviceConfigs = append(v.serviceConfigs, envoy.ServiceConfig{
 		Builder:            builder,
@@ -116,9 +192,88 @@ func (v *DumpVisitor) VisitKubernetes(s *config.KubernetesService) error {
 		ResolvedRemotePort: remotePort,
 	})
 
+	if s.Protocol == "grpc" {
+		backend := grpcagg.BackendServices{
+			Backend: grpcagg.Backend{Host: s.Host, ClusterName: clusterName},
+		}
+		if mock != nil {
+			backend.Services = mock.GRPCServices
+		}
+		v.grpcBackends = append(v.grpcBackends, backend)
+	}
+
 	return nil
 }
 
+// resolveRemotePort はモック設定またはKubernetes APIからリモートポートを解決する
+// モック設定を使用した場合は該当するモックも返す
+func (v *DumpVisitor) resolveRemotePort(cluster, namespace, service, portName string, p port.ServicePort) (port.ServicePort, *config.MockService, error) {
+	if v.mockCfg != nil {
+		mock, err := findMock(v.mockCfg, namespace, service, portName)
+		if err != nil {
+			return 0, nil, err
+		}
+		return mock.ResolvedPort, mock, nil
+	}
+	if v.offline {
+		return p, nil, nil
+	}
+
+	// サービスに対応するKubernetes clientを取得
+	clientset, err := v.getOrCreateClient(cluster)
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to create kubernetes client for %s/%s: %w", namespace, service, err)
+	}
+
+	remotePort, err := k8s.ResolveServicePort(v.ctx, clientset, namespace, service, portName, p)
+	if err != nil {
+		return 0, nil, err
+	}
+	return remotePort, nil, nil
+}
+
+// buildUpstream はメイン以外のバックエンドを生成（ダンプ用）
+// ダミーのローカルポートはサービス用（10000番台）と重ならないよう20000番台を使用する
+func (v *DumpVisitor) buildUpstream(b *config.KubernetesBackend, clusterName string) (envoy.Upstream, error) {
+	remotePort, _, err := v.resolveRemotePort(b.Cluster, b.Namespace, b.Service, b.PortName, b.Port)
+	if err != nil {
+		return envoy.Upstream{}, err
+	}
+
+	dummyLocalPort := port.LocalPort(20000 + v.upstreamIdx)
+	v.upstreamIdx++
+
+	return envoy.Upstream{
+		ClusterName:        clusterName,
+		LocalPort:          dummyLocalPort,
+		ResolvedRemotePort: remotePort,
+		Namespace:          b.Namespace,
+		ServiceName:        b.Service,
+		PortName:           b.PortName,
+		Port:               b.Port,
+		Cluster:            b.Cluster,
+	}, nil
+}
+
+// AddGRPCAggregate は集約gRPCホストの設定を追加（ダンプ用）
+// ダンプではリフレクションを実行できないため、モック設定のgrpc_servicesからルーティングを生成する
+func (v *DumpVisitor) AddGRPCAggregate(agg *config.GRPCAggregate) {
+	v.serviceConfigs = append(v.serviceConfigs, envoy.ServiceConfig{
+		Builder:     envoy.NewGRPCAggregateBuilder(agg.Host, grpcagg.Routes(v.grpcBackends)),
+		ClusterName: envoy.GRPCReflectionClusterName,
+		LocalPort:   port.LocalPort(10000 + v.idx),
+	})
+}
+
+// AddDashboard はダッシュボードの設定を追加（ダンプ用）
+func (v *DumpVisitor) AddDashboard(host string) {
+	v.serviceConfigs = append(v.serviceConfigs, envoy.ServiceConfig{
+		Builder:     envoy.NewDashboardBuilder(host),
+		ClusterName: envoy.DashboardClusterName,
+		LocalPort:   port.LocalPort(10000 + v.idx),
+	})
+}
+
 // VisitTCP は TCP Service の処理（ダンプ用）
 func (v *DumpVisitor) VisitTCP(s *config.TCPService) error {
 	// ダミーのローカルポート
@@ -126,12 +281,24 @@ func (v *DumpVisitor) VisitTCP(s *config.TCPService) error {
 	clusterName := sanitize(fmt.Sprintf("tcp_%s_%s_%d", s.SSHBastion, s.TargetHost, s.TargetPort))
 
 	// loopback IP割り当て（ダンプ用でも同一ポート重複を回避）
-	listenAddr, err := v.ipAllocator.Allocate()
-	if err != nil {
-		return fmt.Errorf("failed to allocate loopback IP for service '%s': %w", s.Host, err)
+	// SNIで振り分けるサービスは127.0.0.1のリスナーを共有する
+	listenAddr := "127.0.0.1"
+	if !s.SNI {
+		var err error
+		listenAddr, err = v.ipAllocator.Allocate()
+		if err != nil {
+			return fmt.Errorf("failed to allocate loopback IP for service '%s': %w", s.Host, err)
+		}
 	}
 
 	builder := envoy.NewTCPServiceBuilder(s.Host, s.ListenPort, listenAddr, s.SSHBastion, s.TargetHost, s.TargetPort)
+	builder.AccessLog = toEnvoyAccessLog(s.AccessLog)
+	builder.HealthCheck = toEnvoyHealthCheck(s.HealthCheck)
+	builder.Protocol = s.Protocol
+	builder.Aliases = s.HostAliases()
+	if s.SNI {
+		builder.ServerNames = s.EffectiveServerNames()
+	}
 
 	v.serviceConfigs = append(v.serviceConfigs, envoy.ServiceConfig{
 		Builder:     builder,
@@ -147,18 +314,42 @@ func (v *DumpVisitor) SetIndex(idx int) {
 	v.idx = idx
 }
 
+// SetListeners は名前付きリスナーのポートを設定
+func (v *DumpVisitor) SetListeners(listeners map[string]port.ListenerPort) {
+	v.listeners = listeners
+}
+
+// SetHTTPListenerPorts はメッシュのHTTPリスナーのポートを設定
+func (v *DumpVisitor) SetHTTPListenerPorts(ports []port.ListenerPort) {
+	v.httpListenerPorts = ports
+}
+
+// sharedListeners はサービスが接続する名前付きリスナーをEnvoyの共有HTTPリスナーに変換する
+// defaultはlistener_portの共通HTTPリスナー（名前なし）とする
+func (v *DumpVisitor) sharedListeners(names []string) []envoy.NamedListener {
+	listeners := make([]envoy.NamedListener, 0, len(names))
+	for _, name := range names {
+		if name == config.DefaultListenerName {
+			listeners = append(listeners, envoy.NamedListener{})
+			continue
+		}
+		listeners = append(listeners, envoy.NamedListener{Name: name, Port: v.listeners[name]})
+	}
+	return listeners
+}
+
 // GetServiceConfigs は収集した ServiceConfig を返す
 func (v *DumpVisitor) GetServiceConfigs() []envoy.ServiceConfig {
 	return v.serviceConfigs
 }
 
-func findMockPort(mockCfg *config.MockConfig, namespace, service, portName string) (port.ServicePort, error) {
-	for _, m := range mockCfg.Mocks {
+func findMock(mockCfg *config.MockConfig, namespace, service, portName string) (*config.MockService, error) {
+	for i, m := range mockCfg.Mocks {
 		if m.Namespace == namespace && m.Service == service && m.PortName == portName {
-			return m.ResolvedPort, nil
+			return &mockCfg.Mocks[i], nil
 		}
 	}
-	return 0, fmt.Errorf("mock config not found for %s/%s (port_name=%s)", namespace, service, portName)
+	return nil, fmt.Errorf("mock config not found for %s/%s (port_name=%s)", namespace, service, portName)
 }
 
 func sanitize(s string) string {
@@ -175,3 +366,77 @@ func sanitize(s string) string {
 	}
 	return string(out)
 }
+
+// toEnvoyRateLimit はレート制限設定をEnvoy用に変換（未設定の場合はnil）
+func toEnvoyRateLimit(r *config.RateLimit) *envoy.RateLimit {
+	if r == nil {
+		return nil
+	}
+	return &envoy.RateLimit{
+		Requests:     r.Requests,
+		Burst:        r.EffectiveBurst(),
+		FillInterval: r.EffectiveFillInterval(),
+	}
+}
+
+// toEnvoyHealthCheck はヘルスチェック設定をEnvoy用に変換（未設定の場合はnil）
+func toEnvoyHealthCheck(hc *config.HealthCheck) *envoy.HealthCheck {
+	if hc == nil {
+		return nil
+	}
+	return &envoy.HealthCheck{
+		Type:               hc.Type,
+		Path:               hc.Path,
+		Service:            hc.Service,
+		Interval:           hc.EffectiveInterval(),
+		UnhealthyThreshold: hc.EffectiveUnhealthyThreshold(),
+	}
+}
+
+// toEnvoyAccessLog はアクセスログ設定をEnvoy用に変換（未設定の場合はnil）
+func toEnvoyAccessLog(a *config.AccessLog) *envoy.AccessLog {
+	if a == nil {
+		return nil
+	}
+	return &envoy.AccessLog{Path: a.Path, Format: a.Format}
+}
+
+// toEnvoyTracing はトレーシング設定をEnvoy用に変換（未設定の場合はnil）
+func toEnvoyTracing(t *config.Tracing) *envoy.Tracing {
+	if t == nil {
+		return nil
+	}
+	address, p := t.Collector()
+	return &envoy.Tracing{
+		Address:         address,
+		Port:            p,
+		SamplingPercent: t.EffectiveSampling(),
+		ServiceName:     t.ServiceName,
+	}
+}
+
+// toEnvoyListenerAuth はリスナー認証の設定をEnvoy用に変換（未設定の場合はnil）
+// ダンプは共有・保存されるため、認証情報は<redacted>に置き換える
+func toEnvoyListenerAuth(a *config.ListenerAuth) *envoy.ListenerAuth {
+	if a == nil {
+		return nil
+	}
+	auth := &envoy.ListenerAuth{AllowedCIDRs: a.AllowedCIDRs, Redacted: true}
+	if a.Basic != nil {
+		auth.BasicUsername = a.Basic.Username
+		auth.BasicPassword = a.Basic.Password.Value()
+	}
+	if a.BearerToken != nil {
+		auth.BearerToken = a.BearerToken.Value()
+	}
+	return auth
+}
+
+// toEnvoyOverrides はenvoy_overridesをEnvoy用に変換
+func toEnvoyOverrides(overrides []*config.EnvoyOverride) []envoy.Override {
+	var result []envoy.Override
+	for _, o := range overrides {
+		result = append(result, envoy.Override{Listener: o.Listener, Cluster: o.Cluster, Patch: o.PatchJSON()})
+	}
+	return result
+}
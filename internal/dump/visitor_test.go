@@ -5,6 +5,7 @@ import (
 	"testing"
 
 	"github.com/usadamasa/kubectl-localmesh/internal/config"
+	"github.com/usadamasa/kubectl-localmesh/internal/envoy"
 )
 
 func TestDumpVisitor_Creation(t *testing.T) {
@@ -52,3 +53,62 @@ func TestDumpVisitor_VisitTCP(t *testing.T) {
 		t.Errorf("expected localPort 10000, got %d", configs[0].LocalPort)
 	}
 }
+
+func TestDumpVisitor_VisitKubernetes_Mirror(t *testing.T) {
+	ctx := context.Background()
+
+	mockCfg := &config.MockConfig{
+		Mocks: []config.MockService{
+			{Namespace: "users", Service: "users-api", PortName: "http", ResolvedPort: 8080},
+			{Namespace: "users-canary", Service: "users-api", PortName: "http", ResolvedPort: 8081},
+		},
+	}
+	visitor := NewDumpVisitor(ctx, "", mockCfg)
+	visitor.SetIndex(0)
+
+	percent := 10.0
+	svc := &config.KubernetesService{
+		Host:      "users.localhost",
+		Namespace: "users",
+		Service:   "users-api",
+		PortName:  "http",
+		Protocol:  "http",
+		Mirror: &config.Mirror{
+			KubernetesBackend: config.KubernetesBackend{
+				Namespace: "users-canary",
+				Service:   "users-api",
+				PortName:  "http",
+			},
+			Percent: &percent,
+		},
+	}
+
+	if err := visitor.VisitKubernetes(svc); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	configs := visitor.GetServiceConfigs()
+	if len(configs) != 1 {
+		t.Fatalf("expected 1 config, got %d", len(configs))
+	}
+
+	builder, ok := configs[0].Builder.(*envoy.KubernetesServiceBuilder)
+	if !ok {
+		t.Fatalf("expected *envoy.KubernetesServiceBuilder, got %T", configs[0].Builder)
+	}
+	if builder.Mirror == nil {
+		t.Fatal("expected mirror to be set")
+	}
+	if builder.Mirror.ClusterName != "users_users_api_8080_mirror" {
+		t.Errorf("expected mirror cluster 'users_users_api_8080_mirror', got %s", builder.Mirror.ClusterName)
+	}
+	if builder.Mirror.LocalPort != 20000 {
+		t.Errorf("expected mirror localPort 20000, got %d", builder.Mirror.LocalPort)
+	}
+	if builder.Mirror.ResolvedRemotePort != 8081 {
+		t.Errorf("expected mirror remote port 8081, got %d", builder.Mirror.ResolvedRemotePort)
+	}
+	if builder.Mirror.Percent != 10 {
+		t.Errorf("expected mirror percent 10, got %v", builder.Mirror.Percent)
+	}
+}
@@ -0,0 +1,185 @@
+package envoy
+
+import (
+	"regexp"
+
+	accesslogv3 "github.com/envoyproxy/go-control-plane/envoy/config/accesslog/v3"
+	clusterv3 "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
+	corev3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	routev3 "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	filev3 "github.com/envoyproxy/go-control-plane/envoy/extensions/access_loggers/file/v3"
+	streamv3 "github.com/envoyproxy/go-control-plane/envoy/extensions/access_loggers/stream/v3"
+	matcherv3 "github.com/envoyproxy/go-control-plane/envoy/type/matcher/v3"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+const (
+	// AccessLogStdout はアクセスログを標準出力に書き出す場合のパス指定
+	AccessLogStdout = "stdout"
+
+	// backendMetadataNamespace はクラスタのmetadataに接続先（namespace/service）を記録するnamespace
+	backendMetadataNamespace = "localmesh"
+
+	httpAccessLogText = "[%START_TIME%] %REQ(:AUTHORITY)% \"%REQ(:METHOD)% %REQ(X-ENVOY-ORIGINAL-PATH?:PATH)% %PROTOCOL%\" " +
+		"%RESPONSE_CODE% %RESPONSE_FLAGS% %DURATION%ms rx=%BYTES_RECEIVED% tx=%BYTES_SENT% " +
+		"upstream=%UPSTREAM_CLUSTER% backend=%CLUSTER_METADATA(localmesh:backend)%\n"
+	// tcpAccessLogText は先頭の "[%START_TIME%] <host>" に続く部分
+	tcpAccessLogText = " tcp %RESPONSE_FLAGS% %DURATION%ms rx=%BYTES_RECEIVED% tx=%BYTES_SENT% " +
+		"upstream=%UPSTREAM_CLUSTER% backend=%CLUSTER_METADATA(localmesh:backend)%\n"
+)
+
+// AccessLog はアクセスログの出力先と形式
+type AccessLog struct {
+	Path   string // ファイルパス、またはAccessLogStdout
+	Format string // text|json
+}
+
+// httpAccessLog はHTTP connection manager用のアクセスロガーを生成
+// filterがnilでない場合は一致したリクエストのみを記録する
+func httpAccessLog(l *AccessLog, filter *accesslogv3.AccessLogFilter) (*accesslogv3.AccessLog, error) {
+	var format *corev3.SubstitutionFormatString
+	if l.Format == "json" {
+		format = jsonLogFormat(map[string]string{
+			"start_time":       "%START_TIME%",
+			"host":             "%REQ(:AUTHORITY)%",
+			"method":           "%REQ(:METHOD)%",
+			"path":             "%REQ(X-ENVOY-ORIGINAL-PATH?:PATH)%",
+			"protocol":         "%PROTOCOL%",
+			"status":           "%RESPONSE_CODE%",
+			"response_flags":   "%RESPONSE_FLAGS%",
+			"duration_ms":      "%DURATION%",
+			"bytes_received":   "%BYTES_RECEIVED%",
+			"bytes_sent":       "%BYTES_SENT%",
+			"upstream_cluster": "%UPSTREAM_CLUSTER%",
+			"backend":          "%CLUSTER_METADATA(localmesh:backend)%",
+		})
+	} else {
+		format = textLogFormat(httpAccessLogText)
+	}
+
+	logger, err := accessLogger(l.Path, format)
+	if err != nil {
+		return nil, err
+	}
+	logger.Filter = filter
+	return logger, nil
+}
+
+// tcpAccessLog はtcp_proxy用のアクセスロガーを生成
+// TCPにはリクエストヘッダーがないため、ホスト名は設定値を埋め込む
+func tcpAccessLog(l *AccessLog, host string) (*accesslogv3.AccessLog, error) {
+	if l.Format == "json" {
+		return accessLogger(l.Path, jsonLogFormat(map[string]string{
+			"start_time":       "%START_TIME%",
+			"host":             host,
+			"protocol":         "tcp",
+			"response_flags":   "%RESPONSE_FLAGS%",
+			"duration_ms":      "%DURATION%",
+			"bytes_received":   "%BYTES_RECEIVED%",
+			"bytes_sent":       "%BYTES_SENT%",
+			"upstream_cluster": "%UPSTREAM_CLUSTER%",
+			"backend":          "%CLUSTER_METADATA(localmesh:backend)%",
+		}))
+	}
+	return accessLogger(l.Path, textLogFormat("[%START_TIME%] "+host+tcpAccessLogText))
+}
+
+// accessLogger は出力先に応じたアクセスロガーを生成
+func accessLogger(path string, format *corev3.SubstitutionFormatString) (*accesslogv3.AccessLog, error) {
+	name := "envoy.access_loggers.file"
+	var config proto.Message = &filev3.FileAccessLog{
+		Path:            path,
+		AccessLogFormat: &filev3.FileAccessLog_LogFormat{LogFormat: format},
+	}
+	if path == AccessLogStdout {
+		name = "envoy.access_loggers.stdout"
+		config = &streamv3.StdoutAccessLog{
+			AccessLogFormat: &streamv3.StdoutAccessLog_LogFormat{LogFormat: format},
+		}
+	}
+	tc, err := typedConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	return &accesslogv3.AccessLog{
+		Name:       name,
+		ConfigType: &accesslogv3.AccessLog_TypedConfig{TypedConfig: tc},
+	}, nil
+}
+
+// textLogFormat はテキスト形式のlog_formatを生成
+func textLogFormat(format string) *corev3.SubstitutionFormatString {
+	return &corev3.SubstitutionFormatString{
+		Format: &corev3.SubstitutionFormatString_TextFormatSource{
+			TextFormatSource: inlineString(format),
+		},
+	}
+}
+
+// jsonLogFormat はJSON形式のlog_formatを生成
+func jsonLogFormat(fields map[string]string) *corev3.SubstitutionFormatString {
+	return &corev3.SubstitutionFormatString{
+		Format: &corev3.SubstitutionFormatString_JsonFormat{
+			JsonFormat: stringStruct(fields),
+		},
+	}
+}
+
+// inlineString は文字列を埋め込んだDataSourceを生成
+func inlineString(s string) *corev3.DataSource {
+	return &corev3.DataSource{
+		Specifier: &corev3.DataSource_InlineString{InlineString: s},
+	}
+}
+
+// authorityFilter は:authorityヘッダーがホスト名（ポート付きを含む）に一致するリクエストのみを記録するフィルタを生成
+// 共通HTTPリスナーでサービス単位のアクセスログを振り分けるために使用する
+func authorityFilter(host string) *accesslogv3.AccessLogFilter {
+	return &accesslogv3.AccessLogFilter{
+		FilterSpecifier: &accesslogv3.AccessLogFilter_HeaderFilter{
+			HeaderFilter: &accesslogv3.HeaderFilter{
+				Header: &routev3.HeaderMatcher{
+					Name: ":authority",
+					HeaderMatchSpecifier: &routev3.HeaderMatcher_StringMatch{
+						StringMatch: &matcherv3.StringMatcher{
+							MatchPattern: &matcherv3.StringMatcher_SafeRegex{
+								SafeRegex: &matcherv3.RegexMatcher{
+									Regex: "^" + regexp.QuoteMeta(host) + "(:[0-9]+)?$",
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+// backendMetadata はアクセスログで参照する接続先をクラスタのmetadataとして生成
+func backendMetadata(backend string) *corev3.Metadata {
+	return &corev3.Metadata{
+		FilterMetadata: map[string]*structpb.Struct{
+			backendMetadataNamespace: stringStruct(map[string]string{
+				"backend": backend,
+			}),
+		},
+	}
+}
+
+// setBackendMetadata はクラスタに接続先のmetadataを設定（backendが空の場合は何もしない）
+func setBackendMetadata(cluster *clusterv3.Cluster, backend string) {
+	if backend == "" {
+		return
+	}
+	cluster.Metadata = backendMetadata(backend)
+}
+
+// stringStruct は文字列の値のみを持つmapをStructに変換
+func stringStruct(fields map[string]string) *structpb.Struct {
+	s := &structpb.Struct{Fields: make(map[string]*structpb.Value, len(fields))}
+	for k, v := range fields {
+		s.Fields[k] = structpb.NewStringValue(v)
+	}
+	return s
+}
@@ -0,0 +1,177 @@
+package envoy
+
+import (
+	"encoding/base64"
+	"net"
+
+	accesslogv3 "github.com/envoyproxy/go-control-plane/envoy/config/accesslog/v3"
+	corev3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	listenerv3 "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
+	rbacv3 "github.com/envoyproxy/go-control-plane/envoy/config/rbac/v3"
+	luav3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/lua/v3"
+	rbachttpv3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/rbac/v3"
+	hcmv3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
+	rbacnetworkv3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/rbac/v3"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+const (
+	httpRBACFilterName    = "envoy.filters.http.rbac"
+	networkRBACFilterName = "envoy.filters.network.rbac"
+	listenerAuthPolicy    = "listener_auth"
+	listenerAuthRealm     = "kubectl-localmesh"
+
+	// UnauthorizedBody は認証情報がないリクエストに返すレスポンスボディ
+	UnauthorizedBody = "unauthorized by kubectl-localmesh: credentials required (see listener_auth in your localmesh config)\n"
+)
+
+// loopbackCIDRs はlistener_authで常に許可する送信元
+var loopbackCIDRs = []string{"127.0.0.0/8", "::1/128"}
+
+// ListenerAuth はリスナーの認証・送信元IP制限
+// loopbackからの接続は常に許可する
+type ListenerAuth struct {
+	BasicUsername string
+	BasicPassword string   // BasicUsernameが空の場合はBasic認証を使わない
+	BearerToken   string   // 空の場合はBearerトークンを使わない
+	AllowedCIDRs  []string // 空の場合は送信元IPを制限しない
+	Redacted      bool     // trueの場合は設定に認証情報を書き出さない（dump-envoy-config用）
+}
+
+// redactedCredential は認証情報の代わりに書き出す値
+const redactedCredential = "<redacted>"
+
+// hasCredentials はHTTPリスナーで認証情報を要求する場合にtrueを返す
+func (a *ListenerAuth) hasCredentials() bool {
+	return a != nil && (a.BasicUsername != "" || a.BearerToken != "")
+}
+
+// authorizationValues は許可するAuthorizationヘッダーの値を返す
+// Redactedの場合はスキームのみを残し、値を<redacted>に置き換える
+func (a *ListenerAuth) authorizationValues() []string {
+	var values []string
+	if a.BasicUsername != "" {
+		credential := base64.StdEncoding.EncodeToString([]byte(a.BasicUsername + ":" + a.BasicPassword))
+		if a.Redacted {
+			credential = redactedCredential
+		}
+		values = append(values, "Basic "+credential)
+	}
+	if a.BearerToken != "" {
+		token := a.BearerToken
+		if a.Redacted {
+			token = redactedCredential
+		}
+		values = append(values, "Bearer "+token)
+	}
+	return values
+}
+
+// authHTTPFilter はloopbackまたは認証情報を持つリクエストのみを許可するRBACフィルタを生成
+func authHTTPFilter(a *ListenerAuth) (*hcmv3.HttpFilter, error) {
+	principals := loopbackPrincipals()
+	for _, v := range a.authorizationValues() {
+		principals = append(principals, &rbacv3.Principal{
+			Identifier: &rbacv3.Principal_Header{Header: headerMatcher("authorization", v)},
+		})
+	}
+	return httpFilter(httpRBACFilterName, &rbachttpv3.RBAC{Rules: allowRules(principals)})
+}
+
+// authStripFilter はRBACフィルタの後でメッシュの認証情報を取り除くLuaフィルタを生成
+func authStripFilter(a *ListenerAuth) (*hcmv3.HttpFilter, error) {
+	return httpFilter(authStripFilterName, &luav3.Lua{
+		DefaultSourceCode: inlineString(authStripScript(a.authorizationValues())),
+	})
+}
+
+// authLocalReplyMapper はRBACフィルタの403をWWW-Authenticateヘッダー付きの401に差し替えるマッパーを生成
+// local_reply_configはEnvoy自身が生成した応答にのみ適用されるため、バックエンドの403はそのまま返る
+func authLocalReplyMapper(a *ListenerAuth) *hcmv3.ResponseMapper {
+	scheme := "Bearer"
+	if a.BasicUsername != "" {
+		scheme = "Basic"
+	}
+	return &hcmv3.ResponseMapper{
+		Filter: &accesslogv3.AccessLogFilter{
+			FilterSpecifier: &accesslogv3.AccessLogFilter_StatusCodeFilter{
+				StatusCodeFilter: &accesslogv3.StatusCodeFilter{
+					Comparison: &accesslogv3.ComparisonFilter{
+						Op: accesslogv3.ComparisonFilter_EQ,
+						Value: &corev3.RuntimeUInt32{
+							DefaultValue: 403,
+							RuntimeKey:   "listener_auth_status_code",
+						},
+					},
+				},
+			},
+		},
+		StatusCode: wrapperspb.UInt32(401),
+		HeadersToAdd: []*corev3.HeaderValueOption{
+			{
+				Header: &corev3.HeaderValue{Key: "www-authenticate", Value: scheme + ` realm="` + listenerAuthRealm + `"`},
+			},
+		},
+		Body: inlineString(UnauthorizedBody),
+	}
+}
+
+// restrictSourceIPs はリスナーのすべてのフィルタチェーンの先頭に、送信元IPを制限するRBACフィルタを挿入する
+func restrictSourceIPs(l *listenerv3.Listener, cidrs []string) error {
+	principals := loopbackPrincipals()
+	for _, c := range cidrs {
+		principals = append(principals, directRemoteIP(c))
+	}
+	rbac, err := typedConfig(&rbacnetworkv3.RBAC{
+		StatPrefix: listenerAuthPolicy,
+		Rules:      allowRules(principals),
+	})
+	if err != nil {
+		return err
+	}
+	filter := &listenerv3.Filter{
+		Name:       networkRBACFilterName,
+		ConfigType: &listenerv3.Filter_TypedConfig{TypedConfig: rbac},
+	}
+	for _, chain := range l.FilterChains {
+		chain.Filters = append([]*listenerv3.Filter{filter}, chain.Filters...)
+	}
+	return nil
+}
+
+// allowRules はいずれかのprincipalに一致する接続・リクエストのみを許可するルールを生成
+func allowRules(principals []*rbacv3.Principal) *rbacv3.RBAC {
+	return &rbacv3.RBAC{
+		Action: rbacv3.RBAC_ALLOW,
+		Policies: map[string]*rbacv3.Policy{
+			listenerAuthPolicy: {
+				Permissions: []*rbacv3.Permission{{Rule: &rbacv3.Permission_Any{Any: true}}},
+				Principals:  principals,
+			},
+		},
+	}
+}
+
+// loopbackPrincipals はloopbackからの接続に一致するprincipalを生成
+func loopbackPrincipals() []*rbacv3.Principal {
+	var principals []*rbacv3.Principal
+	for _, c := range loopbackCIDRs {
+		principals = append(principals, directRemoteIP(c))
+	}
+	return principals
+}
+
+// directRemoteIP は送信元IPがCIDRに含まれる接続に一致するprincipalを生成
+// CIDRは検証済みであることを前提とする
+func directRemoteIP(cidr string) *rbacv3.Principal {
+	_, network, _ := net.ParseCIDR(cidr)
+	prefixLen, _ := network.Mask.Size()
+	return &rbacv3.Principal{
+		Identifier: &rbacv3.Principal_DirectRemoteIp{
+			DirectRemoteIp: &corev3.CidrRange{
+				AddressPrefix: network.IP.String(),
+				PrefixLen:     wrapperspb.UInt32(uint32(prefixLen)),
+			},
+		},
+	}
+}
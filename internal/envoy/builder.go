@@ -1,20 +1,36 @@
 package envoy
 
+import (
+	clusterv3 "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
+	listenerv3 "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
+	routev3 "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+)
+
 // HTTPComponents はHTTPサービス用のEnvoy設定コンポーネント
 type HTTPComponents struct {
-	Cluster map[string]any
-	Route   map[string]any
+	Cluster            *clusterv3.Cluster
+	Route              *routev3.VirtualHost
+	AdditionalClusters []*clusterv3.Cluster // ミラー先など追加のバックエンド用クラスタ
 }
 
 // TCPComponents はTCPサービス用のEnvoy設定コンポーネント
 type TCPComponents struct {
-	Cluster  map[string]any
-	Listener map[string]any
+	Cluster  *clusterv3.Cluster
+	Listener *listenerv3.Listener
 }
 
 // IndividualListenerComponents は個別リスナーを持つサービス用のEnvoy設定コンポーネント
 // OverwriteListenPortsが指定された場合に使用（HTTP/HTTP2/gRPC問わず）
 type IndividualListenerComponents struct {
-	Cluster   map[string]any
-	Listeners []map[string]any // 各OverwriteListenPortに対応するリスナー
+	Cluster            *clusterv3.Cluster
+	Listeners          []*listenerv3.Listener // 各OverwriteListenPortに対応するリスナー
+	AdditionalClusters []*clusterv3.Cluster   // ミラー先など追加のバックエンド用クラスタ
+}
+
+// SharedListenerComponents は名前付きの共有HTTPリスナーに接続するサービス用のEnvoy設定コンポーネント
+// Listenersが指定された場合に使用（Routes[i]はListeners[i]に追加するvirtual host）
+type SharedListenerComponents struct {
+	Cluster            *clusterv3.Cluster
+	Routes             []*routev3.VirtualHost
+	AdditionalClusters []*clusterv3.Cluster // ミラー先など追加のバックエンド用クラスタ
 }
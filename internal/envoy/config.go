@@ -1,116 +1,359 @@
 package envoy
 
-import "github.com/usadamasa/kubectl-localmesh/internal/port"
+import (
+	"time"
+
+	accesslogv3 "github.com/envoyproxy/go-control-plane/envoy/config/accesslog/v3"
+	bootstrapv3 "github.com/envoyproxy/go-control-plane/envoy/config/bootstrap/v3"
+	clusterv3 "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
+	corev3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	listenerv3 "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
+	overloadv3 "github.com/envoyproxy/go-control-plane/envoy/config/overload/v3"
+	routev3 "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	routerv3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/router/v3"
+	hcmv3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
+	downstreamv3 "github.com/envoyproxy/go-control-plane/envoy/extensions/resource_monitors/downstream_connections/v3"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	"github.com/usadamasa/kubectl-localmesh/internal/port"
+)
 
 // ServiceConfig はビルダーとメタデータを保持
 type ServiceConfig struct {
-	Builder            interface{} // *KubernetesServiceBuilder または *TCPServiceBuilder
+	Builder            interface{} // *KubernetesServiceBuilder, *TCPServiceBuilder, *GRPCAggregateBuilder または *DashboardBuilder
 	ClusterName        string
 	LocalPort          port.LocalPort
 	ResolvedRemotePort port.ServicePort // Kubernetesサービスの解決済みリモートポート（マッピング出力用）
 }
 
+// BuildOptions はサービスに依存しないEnvoy設定全体のオプション
+type BuildOptions struct {
+	// RateLimit はHTTPリスナーごとに適用するレート制限（nilの場合は制限しない）
+	RateLimit *RateLimit
+	// AccessLog はすべてのリスナーに設定するアクセスログ（nilの場合は出力しない）
+	AccessLog *AccessLog
+	// Tracing はすべてのHTTPリスナーに設定するトレーシング（nilの場合は無効）
+	Tracing *Tracing
+	// BindAddresses はHTTPリスナーのバインドアドレス（空の場合はDefaultBindAddress）
+	// 2つ目以降のアドレスはリスナーのadditional_addressesにする
+	BindAddresses []string
+	// ListenerAuth はすべてのリスナーに適用する認証・送信元IP制限（nilの場合は制限しない）
+	ListenerAuth *ListenerAuth
+}
+
+// DefaultBindAddress はバインドアドレスを指定しない場合のHTTPリスナーのアドレス
+const DefaultBindAddress = "127.0.0.1"
+
+// hostFilters は共通HTTPリスナー上のホストが必要とするvirtual host単位のHTTPフィルタ
+type hostFilters struct {
+	rateLimit bool // ホスト単位のレート制限があるか
+	lua       bool // Luaスクリプトを持つホストがあるか
+	rewrite   bool // Location・Set-Cookieのホスト名を置換するホストがあるか
+}
+
+// httpFilters はHTTP connection managerのフィルタチェーンを生成
+// listenerLimitはリスナー全体のトークンバケット、hostはvirtual host単位の設定を持つホストの有無
+// いずれも無い場合はrouterのみを返す
+func httpFilters(listenerLimit *RateLimit, host hostFilters) ([]*hcmv3.HttpFilter, error) {
+	var filters []*hcmv3.HttpFilter
+	add := func(f *hcmv3.HttpFilter, err error) error {
+		if err != nil {
+			return err
+		}
+		filters = append(filters, f)
+		return nil
+	}
+	if listenerLimit != nil || host.rateLimit {
+		// token_bucketを持たないフィルタは何も制限せず、virtual host単位の設定のみが有効になる
+		if err := add(httpFilter(localRateLimitFilterName, localRateLimitConfig(listenerLimit))); err != nil {
+			return nil, err
+		}
+	}
+	if host.lua {
+		if err := add(luaFilter(luaFilterName)); err != nil {
+			return nil, err
+		}
+	}
+	// レスポンスは逆順に処理されるため、ユーザーのLuaスクリプトは置換後のヘッダーを受け取る
+	if host.rewrite {
+		if err := add(luaFilter(hostRewriteFilterName)); err != nil {
+			return nil, err
+		}
+	}
+	if err := add(httpFilter("envoy.filters.http.router", &routerv3.Router{})); err != nil {
+		return nil, err
+	}
+	return filters, nil
+}
+
+// httpFilter はtyped_configを持つHTTPフィルタを生成
+func httpFilter(name string, config proto.Message) (*hcmv3.HttpFilter, error) {
+	tc, err := typedConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	return &hcmv3.HttpFilter{
+		Name:       name,
+		ConfigType: &hcmv3.HttpFilter_TypedConfig{TypedConfig: tc},
+	}, nil
+}
+
+// httpConnectionManager はHTTP connection managerの共通部分を生成
+// フィルタチェーン・アクセスログ・トレーシングなどは呼び出し側で設定する
+func httpConnectionManager(statPrefix, routeName string, virtualHosts []*routev3.VirtualHost) *hcmv3.HttpConnectionManager {
+	return &hcmv3.HttpConnectionManager{
+		StatPrefix: statPrefix,
+		CodecType:  hcmv3.HttpConnectionManager_AUTO,
+		RouteSpecifier: &hcmv3.HttpConnectionManager_RouteConfig{
+			RouteConfig: &routev3.RouteConfiguration{
+				Name:         routeName,
+				VirtualHosts: virtualHosts,
+			},
+		},
+	}
+}
+
+// applyListenerOptions はBuildOptionsの認証・レート制限・アクセスログ・トレーシングをHTTP connection managerに設定
+// hostLogsはグローバルのアクセスログに続けて追加するサービス単位のアクセスログ
+func applyListenerOptions(hcm *hcmv3.HttpConnectionManager, opts BuildOptions, host hostFilters, hostLogs []*accesslogv3.AccessLog) error {
+	filters, err := httpFilters(opts.RateLimit, host)
+	if err != nil {
+		return err
+	}
+	hcm.HttpFilters = filters
+	if opts.RateLimit != nil || host.rateLimit {
+		hcm.LocalReplyConfig = rateLimitLocalReplyConfig()
+	}
+	// 認証されていないリクエストはレート制限のトークンを消費する前に拒否する
+	if opts.ListenerAuth.hasCredentials() {
+		auth, err := authHTTPFilter(opts.ListenerAuth)
+		if err != nil {
+			return err
+		}
+		// メッシュの認証情報はバックエンドに転送しない（一致しないAuthorizationはそのまま転送する）
+		strip, err := authStripFilter(opts.ListenerAuth)
+		if err != nil {
+			return err
+		}
+		hcm.HttpFilters = append([]*hcmv3.HttpFilter{auth, strip}, hcm.HttpFilters...)
+		if hcm.LocalReplyConfig == nil {
+			hcm.LocalReplyConfig = &hcmv3.LocalReplyConfig{}
+		}
+		hcm.LocalReplyConfig.Mappers = append(hcm.LocalReplyConfig.Mappers, authLocalReplyMapper(opts.ListenerAuth))
+	}
+	if opts.AccessLog != nil {
+		log, err := httpAccessLog(opts.AccessLog, nil)
+		if err != nil {
+			return err
+		}
+		hcm.AccessLog = append(hcm.AccessLog, log)
+	}
+	hcm.AccessLog = append(hcm.AccessLog, hostLogs...)
+	if opts.Tracing != nil {
+		tracing, err := httpTracing(opts.Tracing)
+		if err != nil {
+			return err
+		}
+		hcm.Tracing = tracing
+	}
+	return nil
+}
+
+// listener はアドレス・ポートにバインドし、単一のネットワークフィルタを持つリスナーを生成
+func listener(name, address string, p int, filterName string, config proto.Message) (*listenerv3.Listener, error) {
+	tc, err := typedConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	return &listenerv3.Listener{
+		Name:            name,
+		Address:         socketAddress(address, p),
+		EnableReusePort: wrapperspb.Bool(false),
+		FilterChains: []*listenerv3.FilterChain{
+			{
+				Filters: []*listenerv3.Filter{
+					{
+						Name:       filterName,
+						ConfigType: &listenerv3.Filter_TypedConfig{TypedConfig: tc},
+					},
+				},
+			},
+		},
+	}, nil
+}
+
+// httpListener はHTTP connection managerを持つリスナーを生成
+// addrsの先頭をaddress、2つ目以降をadditional_addressesとして同じポートにバインドする
+func httpListener(name string, addrs []string, p int, hcm *hcmv3.HttpConnectionManager) (*listenerv3.Listener, error) {
+	if len(addrs) == 0 {
+		addrs = []string{DefaultBindAddress}
+	}
+	l, err := listener(name, addrs[0], p, "envoy.filters.network.http_connection_manager", hcm)
+	if err != nil {
+		return nil, err
+	}
+	for _, addr := range addrs[1:] {
+		l.AdditionalAddresses = append(l.AdditionalAddresses, &listenerv3.AdditionalAddress{Address: socketAddress(addr, p)})
+	}
+	return l, nil
+}
+
+// socketAddress はTCPのソケットアドレスを生成
+func socketAddress(address string, p int) *corev3.Address {
+	return &corev3.Address{
+		Address: &corev3.Address_SocketAddress{
+			SocketAddress: &corev3.SocketAddress{
+				Address:       address,
+				PortSpecifier: &corev3.SocketAddress_PortValue{PortValue: uint32(p)},
+			},
+		},
+	}
+}
+
 // BuildConfig は ServiceConfig のリストから Envoy 設定を生成
-func BuildConfig(listenerPort port.ListenerPort, configs []ServiceConfig) map[string]any {
-	var clusters []any
-	var httpRoutes []any
-	var tcpListeners []any
+func BuildConfig(listenerPort port.ListenerPort, configs []ServiceConfig) (*bootstrapv3.Bootstrap, error) {
+	return BuildConfigWithOptions(listenerPort, configs, BuildOptions{})
+}
 
-	var individualListeners []any
+// BuildConfigWithOptions はグローバルなオプションを反映して Envoy 設定を生成
+// 書き出す前にMarshalYAMLで検証する
+func BuildConfigWithOptions(listenerPort port.ListenerPort, configs []ServiceConfig, opts BuildOptions) (*bootstrapv3.Bootstrap, error) {
+	var clusters []*clusterv3.Cluster
+	main := &sharedHTTPListener{port: int(listenerPort)} // 共通HTTPリスナー
+	var named namedListeners                             // 名前付きの共有HTTPリスナー
+	var tcpListeners []*listenerv3.Listener
+	var sni sniListeners // SNIで振り分けるTCPサービスの共有リスナー
+
+	var individualListeners []*listenerv3.Listener
 
 	for _, cfg := range configs {
 		// type switchで各ビルダーを処理
 		switch builder := cfg.Builder.(type) {
 		case *KubernetesServiceBuilder:
-			result := builder.Build(cfg.ClusterName, int(cfg.LocalPort), int(listenerPort))
+			result, err := builder.build(cfg.ClusterName, int(cfg.LocalPort), int(listenerPort), opts)
+			if err != nil {
+				return nil, err
+			}
 			// 戻り値の型によって処理を分岐
 			switch components := result.(type) {
 			case HTTPComponents:
 				clusters = append(clusters, components.Cluster)
-				httpRoutes = append(httpRoutes, components.Route)
-			case IndividualListenerComponents:
+				clusters = append(clusters, components.AdditionalClusters...)
+				if err := main.addHost(builder, components.Route); err != nil {
+					return nil, err
+				}
+			case SharedListenerComponents:
 				clusters = append(clusters, components.Cluster)
-				for _, listener := range components.Listeners {
-					individualListeners = append(individualListeners, listener)
+				clusters = append(clusters, components.AdditionalClusters...)
+				for i, l := range builder.Listeners {
+					shared := main
+					if l.Name != "" {
+						shared = named.get(l)
+					}
+					if err := shared.addHost(builder, components.Routes[i]); err != nil {
+						return nil, err
+					}
 				}
+			case IndividualListenerComponents:
+				clusters = append(clusters, components.Cluster)
+				clusters = append(clusters, components.AdditionalClusters...)
+				individualListeners = append(individualListeners, components.Listeners...)
+			}
+
+		case *GRPCAggregateBuilder:
+			components, err := builder.Build(cfg.ClusterName, int(cfg.LocalPort), int(listenerPort))
+			if err != nil {
+				return nil, err
+			}
+			clusters = append(clusters, components.Cluster)
+			main.routes = append(main.routes, components.Route)
+
+		case *DashboardBuilder:
+			components, err := builder.Build(cfg.ClusterName, int(cfg.LocalPort), int(listenerPort))
+			if err != nil {
+				return nil, err
 			}
+			clusters = append(clusters, components.Cluster)
+			main.routes = append(main.routes, components.Route)
 
 		case *TCPServiceBuilder:
-			components := builder.Build(cfg.ClusterName, int(cfg.LocalPort))
+			components, err := builder.build(cfg.ClusterName, int(cfg.LocalPort), opts)
+			if err != nil {
+				return nil, err
+			}
 			clusters = append(clusters, components.Cluster)
-			tcpListeners = append(tcpListeners, components.Listener)
+			if len(builder.ServerNames) > 0 {
+				sni.add(components.Listener)
+			} else {
+				tcpListeners = append(tcpListeners, components.Listener)
+			}
+		}
+	}
+
+	// トレーシングのコレクター（HTTPリスナーがある場合のみ）
+	if opts.Tracing != nil && (len(main.routes) > 0 || len(named.order) > 0 || len(individualListeners) > 0) {
+		collector, err := tracingCollectorCluster(opts.Tracing)
+		if err != nil {
+			return nil, err
 		}
+		clusters = append(clusters, collector)
 	}
 
-	var listeners []any
+	var listeners []*listenerv3.Listener
 
 	// HTTPリスナー（HTTPルートがある場合のみ）
-	if len(httpRoutes) > 0 {
-		httpListener := map[string]any{
-			"name": "listener_http",
-			"address": map[string]any{
-				"socket_address": map[string]any{
-					"address":    "0.0.0.0",
-					"port_value": int(listenerPort),
-				},
-			},
-			"enable_reuse_port": map[string]any{"value": false},
-			"filter_chains": []any{
-				map[string]any{
-					"filters": []any{
-						map[string]any{
-							"name": "envoy.filters.network.http_connection_manager",
-							"typed_config": map[string]any{
-								"@type":                  "type.googleapis.com/envoy.extensions.filters.network.http_connection_manager.v3.HttpConnectionManager",
-								"stat_prefix":            "ingress_http",
-								"codec_type":             "AUTO",
-								"http2_protocol_options": map[string]any{},
-								"route_config": map[string]any{
-									"name":          "local_route",
-									"virtual_hosts": httpRoutes,
-								},
-								"http_filters": []any{
-									map[string]any{
-										"name": "envoy.filters.http.router",
-										"typed_config": map[string]any{
-											"@type": "type.googleapis.com/envoy.extensions.filters.http.router.v3.Router",
-										},
-									},
-								},
-							},
-						},
-					},
-				},
-			},
+	if len(main.routes) > 0 {
+		l, err := main.build(opts)
+		if err != nil {
+			return nil, err
 		}
-		listeners = append(listeners, httpListener)
+		listeners = append(listeners, l)
 	}
 
+	// 名前付きの共有HTTPリスナー（接続したサービスがあるもののみ）
+	namedAll, err := named.all(opts)
+	if err != nil {
+		return nil, err
+	}
+	listeners = append(listeners, namedAll...)
+
 	// 個別リスナーを追加（OverwriteListenPortsが指定されたサービス用）
 	listeners = append(listeners, individualListeners...)
 
 	// TCPリスナーを追加
 	listeners = append(listeners, tcpListeners...)
+	listeners = append(listeners, sni.all()...)
+
+	if opts.ListenerAuth != nil && len(opts.ListenerAuth.AllowedCIDRs) > 0 {
+		for _, l := range listeners {
+			if err := restrictSourceIPs(l, opts.ListenerAuth.AllowedCIDRs); err != nil {
+				return nil, err
+			}
+		}
+	}
 
-	return map[string]any{
-		"static_resources": map[string]any{
-			"listeners": listeners,
-			"clusters":  clusters,
+	overload, err := typedConfig(&downstreamv3.DownstreamConnectionsConfig{
+		MaxActiveDownstreamConnections: 5000,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &bootstrapv3.Bootstrap{
+		StaticResources: &bootstrapv3.Bootstrap_StaticResources{
+			Listeners: listeners,
+			Clusters:  clusters,
 		},
-		"overload_manager": map[string]any{
-			"refresh_interval": map[string]any{
-				"seconds": 0,
-				"nanos":   250000000,
-			},
-			"resource_monitors": []any{
-				map[string]any{
-					"name": "envoy.resource_monitors.global_downstream_max_connections",
-					"typed_config": map[string]any{
-						"@type":                             "type.googleapis.com/envoy.extensions.resource_monitors.downstream_connections.v3.DownstreamConnectionsConfig",
-						"max_active_downstream_connections": 5000,
-					},
+		OverloadManager: &overloadv3.OverloadManager{
+			RefreshInterval: durationpb.New(250 * time.Millisecond),
+			ResourceMonitors: []*overloadv3.ResourceMonitor{
+				{
+					Name:       "envoy.resource_monitors.global_downstream_max_connections",
+					ConfigType: &overloadv3.ResourceMonitor_TypedConfig{TypedConfig: overload},
 				},
 			},
 		},
-	}
+	}, nil
 }
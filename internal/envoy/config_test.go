@@ -1,9 +1,72 @@
 package envoy
 
 import (
+	"fmt"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
+
+	bootstrapv3 "github.com/envoyproxy/go-control-plane/envoy/config/bootstrap/v3"
+	clusterv3 "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
+	listenerv3 "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
+	tracev3 "github.com/envoyproxy/go-control-plane/envoy/config/trace/v3"
+	filev3 "github.com/envoyproxy/go-control-plane/envoy/extensions/access_loggers/file/v3"
+	localratelimitv3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/local_ratelimit/v3"
+	luav3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/lua/v3"
+	rbachttpv3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/rbac/v3"
+	hcmv3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
+	rbacnetworkv3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/rbac/v3"
+	tcpproxyv3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/tcp_proxy/v3"
+	upstreamhttpv3 "github.com/envoyproxy/go-control-plane/envoy/extensions/upstreams/http/v3"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+
+	"github.com/usadamasa/kubectl-localmesh/internal/port"
 )
 
+// unpack はtyped_configを指定した型に展開
+func unpack[T proto.Message](t *testing.T, a *anypb.Any) T {
+	t.Helper()
+	m, err := a.UnmarshalNew()
+	if err != nil {
+		t.Fatalf("failed to unpack %s: %v", a.GetTypeUrl(), err)
+	}
+	typed, ok := m.(T)
+	if !ok {
+		t.Fatalf("unexpected typed_config %s", a.GetTypeUrl())
+	}
+	return typed
+}
+
+// listenerHCM はリスナーのHTTP connection managerを取り出す
+func listenerHCM(t *testing.T, l *listenerv3.Listener) *hcmv3.HttpConnectionManager {
+	t.Helper()
+	return unpack[*hcmv3.HttpConnectionManager](t, l.GetFilterChains()[0].GetFilters()[0].GetTypedConfig())
+}
+
+// explicitHTTPConfig はクラスタのexplicit_http_configを取り出す
+func explicitHTTPConfig(t *testing.T, c *clusterv3.Cluster) *upstreamhttpv3.HttpProtocolOptions_ExplicitHttpConfig {
+	t.Helper()
+	opts, ok := c.GetTypedExtensionProtocolOptions()["envoy.extensions.upstreams.http.v3.HttpProtocolOptions"]
+	if !ok {
+		t.Fatal("HttpProtocolOptions not found")
+	}
+	explicit := unpack[*upstreamhttpv3.HttpProtocolOptions](t, opts).GetExplicitHttpConfig()
+	if explicit == nil {
+		t.Fatal("explicit_http_config not found")
+	}
+	return explicit
+}
+
+// endpointAddress はクラスタのpriority番目のエンドポイントのアドレスとポートを取り出す
+func endpointAddress(c *clusterv3.Cluster, priority int) (string, uint32) {
+	addr := c.GetLoadAssignment().GetEndpoints()[priority].GetLbEndpoints()[0].GetEndpoint().GetAddress().GetSocketAddress()
+	return addr.GetAddress(), addr.GetPortValue()
+}
+
 func TestBuildConfig_HTTPOnly(t *testing.T) {
 	// HTTP/gRPCのみの設定（既存の動作確認）
 	builder := NewKubernetesServiceBuilder(
@@ -20,32 +83,27 @@ func TestBuildConfig_HTTPOnly(t *testing.T) {
 		},
 	}
 
-	cfg := BuildConfig(80, configs)
+	cfg := buildConfig(t, 80, configs, BuildOptions{})
 
 	// static_resourcesの存在確認
-	staticRes, ok := cfg["static_resources"].(map[string]any)
-	if !ok {
+	staticRes := cfg.GetStaticResources()
+	if staticRes == nil {
 		t.Fatal("static_resources not found")
 	}
 
-	// listenersの確認
-	listeners, ok := staticRes["listeners"].([]any)
-	if !ok {
-		t.Fatal("listeners not found")
-	}
-
 	// HTTPリスナーが1つ存在することを確認
-	if len(listeners) != 1 {
-		t.Errorf("expected 1 listener (HTTP), got %d", len(listeners))
+	if len(staticRes.GetListeners()) != 1 {
+		t.Errorf("expected 1 listener (HTTP), got %d", len(staticRes.GetListeners()))
 	}
 
 	// clustersの確認
-	clusters, ok := staticRes["clusters"].([]any)
-	if !ok {
-		t.Fatal("clusters not found")
+	if len(staticRes.GetClusters()) != 1 {
+		t.Errorf("expected 1 cluster, got %d", len(staticRes.GetClusters()))
 	}
-	if len(clusters) != 1 {
-		t.Errorf("expected 1 cluster, got %d", len(clusters))
+
+	// 生成した設定は検証を通る
+	if err := Validate(cfg); err != nil {
+		t.Errorf("expected valid config, got %v", err)
 	}
 }
 
@@ -64,39 +122,24 @@ func TestBuildConfig_TCPOnly(t *testing.T) {
 		},
 	}
 
-	cfg := BuildConfig(80, configs)
-
-	staticRes, ok := cfg["static_resources"].(map[string]any)
-	if !ok {
-		t.Fatal("static_resources not found")
-	}
-
-	listeners, ok := staticRes["listeners"].([]any)
-	if !ok {
-		t.Fatal("listeners not found")
-	}
+	cfg := buildConfig(t, 80, configs, BuildOptions{})
+	staticRes := cfg.GetStaticResources()
 
 	// TCPリスナーが1つ存在することを確認
+	listeners := staticRes.GetListeners()
 	if len(listeners) != 1 {
-		t.Errorf("expected 1 listener (TCP), got %d", len(listeners))
+		t.Fatalf("expected 1 listener (TCP), got %d", len(listeners))
 	}
 
 	// TCPリスナーの詳細確認
-	listener := listeners[0].(map[string]any)
-	address := listener["address"].(map[string]any)
-	socketAddr := address["socket_address"].(map[string]any)
-
-	if socketAddr["port_value"] != 5432 {
-		t.Errorf("expected TCP listener port 5432, got %v", socketAddr["port_value"])
+	socketAddr := listeners[0].GetAddress().GetSocketAddress()
+	if socketAddr.GetPortValue() != 5432 {
+		t.Errorf("expected TCP listener port 5432, got %v", socketAddr.GetPortValue())
 	}
 
 	// clustersの確認
-	clusters, ok := staticRes["clusters"].([]any)
-	if !ok {
-		t.Fatal("clusters not found")
-	}
-	if len(clusters) != 1 {
-		t.Errorf("expected 1 cluster, got %d", len(clusters))
+	if len(staticRes.GetClusters()) != 1 {
+		t.Errorf("expected 1 cluster, got %d", len(staticRes.GetClusters()))
 	}
 }
 
@@ -133,30 +176,17 @@ func TestBuildConfig_MixedHTTPAndTCP(t *testing.T) {
 		},
 	}
 
-	cfg := BuildConfig(80, configs)
-
-	staticRes, ok := cfg["static_resources"].(map[string]any)
-	if !ok {
-		t.Fatal("static_resources not found")
-	}
-
-	listeners, ok := staticRes["listeners"].([]any)
-	if !ok {
-		t.Fatal("listeners not found")
-	}
+	cfg := buildConfig(t, 80, configs, BuildOptions{})
+	staticRes := cfg.GetStaticResources()
 
 	// HTTPリスナー1つ + TCPリスナー2つ = 計3つ
-	if len(listeners) != 3 {
-		t.Errorf("expected 3 listeners (1 HTTP + 2 TCP), got %d", len(listeners))
+	if len(staticRes.GetListeners()) != 3 {
+		t.Errorf("expected 3 listeners (1 HTTP + 2 TCP), got %d", len(staticRes.GetListeners()))
 	}
 
 	// clustersの確認（3つ）
-	clusters, ok := staticRes["clusters"].([]any)
-	if !ok {
-		t.Fatal("clusters not found")
-	}
-	if len(clusters) != 3 {
-		t.Errorf("expected 3 clusters, got %d", len(clusters))
+	if len(staticRes.GetClusters()) != 3 {
+		t.Errorf("expected 3 clusters, got %d", len(staticRes.GetClusters()))
 	}
 }
 
@@ -183,34 +213,79 @@ func TestBuildConfig_MultipleTCPSamePort(t *testing.T) {
 		},
 	}
 
-	cfg := BuildConfig(80, configs)
-
-	staticRes, ok := cfg["static_resources"].(map[string]any)
-	if !ok {
-		t.Fatal("static_resources not found")
-	}
-
-	listeners, ok := staticRes["listeners"].([]any)
-	if !ok {
-		t.Fatal("listeners not found")
-	}
+	cfg := buildConfig(t, 80, configs, BuildOptions{})
+	listeners := cfg.GetStaticResources().GetListeners()
 
 	// 2つのリスナーが作成される（異なるListenAddrなのでポート重複を回避）
 	if len(listeners) != 2 {
-		t.Errorf("expected 2 listeners, got %d", len(listeners))
+		t.Fatalf("expected 2 listeners, got %d", len(listeners))
 	}
 
 	// 各リスナーのアドレスが異なることを確認
-	for i, l := range listeners {
-		listener := l.(map[string]any)
-		address := listener["address"].(map[string]any)
-		socketAddr := address["socket_address"].(map[string]any)
+	for i, listener := range listeners {
+		socketAddr := listener.GetAddress().GetSocketAddress()
 		expectedAddr := "127.0.0.2"
 		if i == 1 {
 			expectedAddr = "127.0.0.3"
 		}
-		if socketAddr["address"] != expectedAddr {
-			t.Errorf("listener %d: expected address %s, got %v", i, expectedAddr, socketAddr["address"])
+		if socketAddr.GetAddress() != expectedAddr {
+			t.Errorf("listener %d: expected address %s, got %v", i, expectedAddr, socketAddr.GetAddress())
+		}
+	}
+}
+
+func TestBuildConfig_SNISharedListener(t *testing.T) {
+	// SNIで振り分けるサービスは127.0.0.1の同じポートで1つのリスナーを共有する
+	sniBuilder := func(host string, serverNames ...string) *TCPServiceBuilder {
+		b := NewTCPServiceBuilder(host, 5432, "127.0.0.1", "primary", "10.0.0.1", 5432)
+		b.ServerNames = serverNames
+		return b
+	}
+	configs := []ServiceConfig{
+		{Builder: sniBuilder("orders-db.localhost", "orders-db.localhost"), ClusterName: "orders_cluster", LocalPort: 10001},
+		{Builder: NewTCPServiceBuilder("cache.localhost", 6379, "127.0.0.2", "primary", "10.0.0.3", 6379), ClusterName: "cache_cluster", LocalPort: 10002},
+		{Builder: sniBuilder("users-db.localhost", "users-db.localhost", "*.users.example.com"), ClusterName: "users_cluster", LocalPort: 10003},
+	}
+
+	cfg := buildConfig(t, 80, configs, BuildOptions{})
+	listeners := cfg.GetStaticResources().GetListeners()
+
+	if len(listeners) != 2 {
+		t.Fatalf("expected 2 listeners, got %d", len(listeners))
+	}
+	if listeners[0].GetName() != "listener_tcp_cache_cluster" {
+		t.Errorf("expected dedicated listener first, got %v", listeners[0].GetName())
+	}
+
+	shared := listeners[1]
+	if shared.GetName() != "listener_sni_127_0_0_1_5432" {
+		t.Errorf("unexpected listener name %v", shared.GetName())
+	}
+	if addr := shared.GetAddress().GetSocketAddress(); addr.GetAddress() != "127.0.0.1" || addr.GetPortValue() != 5432 {
+		t.Errorf("expected 127.0.0.1:5432, got %v", addr)
+	}
+	if len(shared.GetListenerFilters()) != 1 || shared.GetListenerFilters()[0].GetName() != "envoy.filters.listener.tls_inspector" {
+		t.Errorf("expected tls_inspector listener filter, got %v", shared.GetListenerFilters())
+	}
+
+	chains := shared.GetFilterChains()
+	if len(chains) != 2 {
+		t.Fatalf("expected 2 filter chains, got %d", len(chains))
+	}
+	wants := []struct {
+		serverNames []string
+		cluster     string
+	}{
+		{serverNames: []string{"orders-db.localhost"}, cluster: "orders_cluster"},
+		{serverNames: []string{"users-db.localhost", "*.users.example.com"}, cluster: "users_cluster"},
+	}
+	for i, want := range wants {
+		if got := chains[i].GetFilterChainMatch().GetServerNames(); !slices.Equal(got, want.serverNames) {
+			t.Errorf("chain %d: expected server_names %v, got %v", i, want.serverNames, got)
+		}
+		tcpProxy := unpack[*tcpproxyv3.TcpProxy](t, chains[i].GetFilters()[0].GetTypedConfig())
+		if tcpProxy.GetCluster() != want.cluster {
+			t.Errorf("chain %d: expected cluster %s, got %v", i, want.cluster, tcpProxy.GetCluster())
 		}
 	}
 }
@@ -231,45 +306,22 @@ func TestBuildConfig_HTTPProtocol(t *testing.T) {
 		},
 	}
 
-	cfg := BuildConfig(80, configs)
-
-	staticRes, ok := cfg["static_resources"].(map[string]any)
-	if !ok {
-		t.Fatal("static_resources not found")
-	}
-
-	clusters, ok := staticRes["clusters"].([]any)
-	if !ok {
-		t.Fatal("clusters not found")
-	}
+	cfg := buildConfig(t, 80, configs, BuildOptions{})
 
+	clusters := cfg.GetStaticResources().GetClusters()
 	if len(clusters) != 1 {
 		t.Fatalf("expected 1 cluster, got %d", len(clusters))
 	}
 
-	cluster := clusters[0].(map[string]any)
-	protocolOpts, ok := cluster["typed_extension_protocol_options"].(map[string]any)
-	if !ok {
-		t.Fatal("typed_extension_protocol_options not found")
-	}
-
-	httpOpts, ok := protocolOpts["envoy.extensions.upstreams.http.v3.HttpProtocolOptions"].(map[string]any)
-	if !ok {
-		t.Fatal("HttpProtocolOptions not found")
-	}
-
-	explicitConfig, ok := httpOpts["explicit_http_config"].(map[string]any)
-	if !ok {
-		t.Fatal("explicit_http_config not found")
-	}
+	explicitConfig := explicitHTTPConfig(t, clusters[0])
 
 	// HTTP/1.1の設定を確認
-	if _, ok := explicitConfig["http_protocol_options"]; !ok {
+	if explicitConfig.GetHttpProtocolOptions() == nil {
 		t.Error("expected http1_protocol_options for protocol: http")
 	}
 
 	// HTTP/2の設定がないことを確認
-	if _, ok := explicitConfig["http2_protocol_options"]; ok {
+	if explicitConfig.GetHttp2ProtocolOptions() != nil {
 		t.Error("unexpected http2_protocol_options for protocol: http")
 	}
 }
@@ -290,45 +342,22 @@ func TestBuildConfig_HTTP2Protocol(t *testing.T) {
 		},
 	}
 
-	cfg := BuildConfig(80, configs)
-
-	staticRes, ok := cfg["static_resources"].(map[string]any)
-	if !ok {
-		t.Fatal("static_resources not found")
-	}
-
-	clusters, ok := staticRes["clusters"].([]any)
-	if !ok {
-		t.Fatal("clusters not found")
-	}
+	cfg := buildConfig(t, 80, configs, BuildOptions{})
 
+	clusters := cfg.GetStaticResources().GetClusters()
 	if len(clusters) != 1 {
 		t.Fatalf("expected 1 cluster, got %d", len(clusters))
 	}
 
-	cluster := clusters[0].(map[string]any)
-	protocolOpts, ok := cluster["typed_extension_protocol_options"].(map[string]any)
-	if !ok {
-		t.Fatal("typed_extension_protocol_options not found")
-	}
-
-	httpOpts, ok := protocolOpts["envoy.extensions.upstreams.http.v3.HttpProtocolOptions"].(map[string]any)
-	if !ok {
-		t.Fatal("HttpProtocolOptions not found")
-	}
-
-	explicitConfig, ok := httpOpts["explicit_http_config"].(map[string]any)
-	if !ok {
-		t.Fatal("explicit_http_config not found")
-	}
+	explicitConfig := explicitHTTPConfig(t, clusters[0])
 
 	// HTTP/2の設定を確認
-	if _, ok := explicitConfig["http2_protocol_options"]; !ok {
+	if explicitConfig.GetHttp2ProtocolOptions() == nil {
 		t.Error("expected http2_protocol_options for protocol: http2")
 	}
 
 	// HTTP/1.1の設定がないことを確認
-	if _, ok := explicitConfig["http_protocol_options"]; ok {
+	if explicitConfig.GetHttpProtocolOptions() != nil {
 		t.Error("unexpected http1_protocol_options for protocol: http2")
 	}
 }
@@ -349,45 +378,22 @@ func TestBuildConfig_gRPCProtocol(t *testing.T) {
 		},
 	}
 
-	cfg := BuildConfig(80, configs)
-
-	staticRes, ok := cfg["static_resources"].(map[string]any)
-	if !ok {
-		t.Fatal("static_resources not found")
-	}
-
-	clusters, ok := staticRes["clusters"].([]any)
-	if !ok {
-		t.Fatal("clusters not found")
-	}
+	cfg := buildConfig(t, 80, configs, BuildOptions{})
 
+	clusters := cfg.GetStaticResources().GetClusters()
 	if len(clusters) != 1 {
 		t.Fatalf("expected 1 cluster, got %d", len(clusters))
 	}
 
-	cluster := clusters[0].(map[string]any)
-	protocolOpts, ok := cluster["typed_extension_protocol_options"].(map[string]any)
-	if !ok {
-		t.Fatal("typed_extension_protocol_options not found")
-	}
-
-	httpOpts, ok := protocolOpts["envoy.extensions.upstreams.http.v3.HttpProtocolOptions"].(map[string]any)
-	if !ok {
-		t.Fatal("HttpProtocolOptions not found")
-	}
-
-	explicitConfig, ok := httpOpts["explicit_http_config"].(map[string]any)
-	if !ok {
-		t.Fatal("explicit_http_config not found")
-	}
+	explicitConfig := explicitHTTPConfig(t, clusters[0])
 
 	// HTTP/2の設定を確認（gRPCはHTTP/2必須）
-	if _, ok := explicitConfig["http2_protocol_options"]; !ok {
+	if explicitConfig.GetHttp2ProtocolOptions() == nil {
 		t.Error("expected http2_protocol_options for protocol: grpc")
 	}
 
 	// HTTP/1.1の設定がないことを確認
-	if _, ok := explicitConfig["http_protocol_options"]; ok {
+	if explicitConfig.GetHttpProtocolOptions() != nil {
 		t.Error("unexpected http1_protocol_options for protocol: grpc")
 	}
 }
@@ -427,37 +433,549 @@ func TestBuildConfig_MixedProtocols(t *testing.T) {
 		},
 	}
 
-	cfg := BuildConfig(80, configs)
-
-	staticRes, ok := cfg["static_resources"].(map[string]any)
-	if !ok {
-		t.Fatal("static_resources not found")
-	}
-
-	clusters, ok := staticRes["clusters"].([]any)
-	if !ok {
-		t.Fatal("clusters not found")
-	}
+	cfg := buildConfig(t, 80, configs, BuildOptions{})
 
+	clusters := cfg.GetStaticResources().GetClusters()
 	if len(clusters) != 3 {
 		t.Fatalf("expected 3 clusters, got %d", len(clusters))
 	}
 
 	// 各クラスタのプロトコル設定を確認
 	for i, expectedProtocol := range []string{"http1", "http2", "http2"} {
-		cluster := clusters[i].(map[string]any)
-		protocolOpts := cluster["typed_extension_protocol_options"].(map[string]any)
-		httpOpts := protocolOpts["envoy.extensions.upstreams.http.v3.HttpProtocolOptions"].(map[string]any)
-		explicitConfig := httpOpts["explicit_http_config"].(map[string]any)
+		explicitConfig := explicitHTTPConfig(t, clusters[i])
 
 		if expectedProtocol == "http1" {
-			if _, ok := explicitConfig["http_protocol_options"]; !ok {
+			if explicitConfig.GetHttpProtocolOptions() == nil {
 				t.Errorf("cluster %d: expected http1_protocol_options", i)
 			}
 		} else {
-			if _, ok := explicitConfig["http2_protocol_options"]; !ok {
+			if explicitConfig.GetHttp2ProtocolOptions() == nil {
 				t.Errorf("cluster %d: expected http2_protocol_options", i)
 			}
 		}
 	}
 }
+
+func TestBuildConfigWithOptions_RateLimit(t *testing.T) {
+	// グローバル（リスナー単位）とホスト単位のレート制限
+	limited := NewKubernetesServiceBuilder(
+		"api.localhost", "http",
+		"default", "api", "http", 8080,
+		0,
+		"",
+	)
+	limited.RateLimit = &RateLimit{Requests: 5, Burst: 10, FillInterval: 500 * time.Millisecond}
+	individual := NewKubernetesServiceBuilder(
+		"admin.localhost", "http",
+		"default", "admin", "http", 8080,
+		8081,
+		"",
+	)
+	configs := []ServiceConfig{
+		{Builder: limited, ClusterName: "api_cluster", LocalPort: 10001},
+		{Builder: individual, ClusterName: "admin_cluster", LocalPort: 10002},
+	}
+
+	cfg := buildConfig(t, 80, configs, BuildOptions{
+		RateLimit: &RateLimit{Requests: 100, Burst: 100, FillInterval: time.Second},
+	})
+
+	listeners := cfg.GetStaticResources().GetListeners()
+	if len(listeners) != 2 {
+		t.Fatalf("expected 2 listeners, got %d", len(listeners))
+	}
+
+	// 共通HTTPリスナー・個別リスナーの両方にリスナー単位のトークンバケットとlocal_reply_configが付く
+	for _, l := range listeners {
+		hcm := listenerHCM(t, l)
+		filters := hcm.GetHttpFilters()
+		if len(filters) != 2 {
+			t.Fatalf("expected 2 http filters, got %d", len(filters))
+		}
+		if filters[0].GetName() != "envoy.filters.http.local_ratelimit" {
+			t.Errorf("expected local_ratelimit before router, got %v", filters[0].GetName())
+		}
+		bucket := unpack[*localratelimitv3.LocalRateLimit](t, filters[0].GetTypedConfig()).GetTokenBucket()
+		if bucket.GetTokensPerFill().GetValue() != 100 || bucket.GetFillInterval().AsDuration() != time.Second {
+			t.Errorf("unexpected listener token bucket: %v", bucket)
+		}
+		if filters[1].GetName() != "envoy.filters.http.router" {
+			t.Errorf("expected router to be last, got %v", filters[1].GetName())
+		}
+		if hcm.GetLocalReplyConfig() == nil {
+			t.Error("expected local_reply_config for rate limited listener")
+		}
+	}
+
+	// ホスト単位の制限はvirtual hostのtyped_per_filter_configに設定
+	vhost := listenerHCM(t, listeners[0]).GetRouteConfig().GetVirtualHosts()[0]
+	perFilter, ok := vhost.GetTypedPerFilterConfig()["envoy.filters.http.local_ratelimit"]
+	if !ok {
+		t.Fatal("expected typed_per_filter_config on rate limited host")
+	}
+	bucket := unpack[*localratelimitv3.LocalRateLimit](t, perFilter).GetTokenBucket()
+	if bucket.GetMaxTokens() != 10 || bucket.GetTokensPerFill().GetValue() != 5 || bucket.GetFillInterval().AsDuration() != 500*time.Millisecond {
+		t.Errorf("unexpected host token bucket: %v", bucket)
+	}
+}
+
+func TestBuildConfig_NoRateLimit(t *testing.T) {
+	// レート制限を設定しない場合はrouterのみ
+	builder := NewKubernetesServiceBuilder(
+		"api.localhost", "http",
+		"default", "api", "http", 8080,
+		0,
+		"",
+	)
+	cfg := buildConfig(t, 80, []ServiceConfig{{Builder: builder, ClusterName: "api_cluster", LocalPort: 10001}}, BuildOptions{})
+
+	hcm := listenerHCM(t, cfg.GetStaticResources().GetListeners()[0])
+	if filters := hcm.GetHttpFilters(); len(filters) != 1 {
+		t.Errorf("expected only router filter, got %d filters", len(filters))
+	}
+	if hcm.GetLocalReplyConfig() != nil {
+		t.Error("expected no local_reply_config without rate limits")
+	}
+}
+
+func TestBuildConfigWithOptions_AccessLog(t *testing.T) {
+	users := NewKubernetesServiceBuilder(
+		"users.localhost", "http",
+		"users", "users-api", "http", 8080,
+		0,
+		"",
+	)
+	users.AccessLog = &AccessLog{Path: "/tmp/users.log", Format: "json"}
+	billing := NewKubernetesServiceBuilder(
+		"billing.localhost", "http",
+		"billing", "billing-api", "http", 8080,
+		0,
+		"",
+	)
+	db := NewTCPServiceBuilder("db.localhost", 5432, "127.0.0.2", "primary", "10.0.0.1", 5432)
+	configs := []ServiceConfig{
+		{Builder: users, ClusterName: "users_cluster", LocalPort: 10001},
+		{Builder: billing, ClusterName: "billing_cluster", LocalPort: 10002},
+		{Builder: db, ClusterName: "db_cluster", LocalPort: 10003},
+	}
+
+	cfg := buildConfig(t, 80, configs, BuildOptions{
+		AccessLog: &AccessLog{Path: AccessLogStdout, Format: "text"},
+	})
+	staticRes := cfg.GetStaticResources()
+	listeners := staticRes.GetListeners()
+
+	// 共通HTTPリスナー: グローバル（フィルタなし）→ サービス単位（:authorityで絞り込み）の順
+	logs := listenerHCM(t, listeners[0]).GetAccessLog()
+	if len(logs) != 2 {
+		t.Fatalf("expected 2 access logs, got %d", len(logs))
+	}
+	global := logs[0]
+	if global.GetName() != "envoy.access_loggers.stdout" {
+		t.Errorf("expected stdout logger, got %v", global.GetName())
+	}
+	if global.GetFilter() != nil {
+		t.Error("expected global access log without filter")
+	}
+	host := logs[1]
+	if host.GetName() != "envoy.access_loggers.file" {
+		t.Errorf("expected file logger, got %v", host.GetName())
+	}
+	regex := host.GetFilter().GetHeaderFilter().GetHeader().GetStringMatch().GetSafeRegex().GetRegex()
+	if regex != `^users\.localhost(:[0-9]+)?$` {
+		t.Errorf("unexpected authority regex: %v", regex)
+	}
+	fileLog := unpack[*filev3.FileAccessLog](t, host.GetTypedConfig())
+	if fileLog.GetPath() != "/tmp/users.log" {
+		t.Errorf("expected path /tmp/users.log, got %v", fileLog.GetPath())
+	}
+	if fileLog.GetLogFormat().GetJsonFormat() == nil {
+		t.Error("expected json_format")
+	}
+
+	// TCPリスナーにもグローバルのアクセスログが付く
+	tcpProxy := unpack[*tcpproxyv3.TcpProxy](t, listeners[1].GetFilterChains()[0].GetFilters()[0].GetTypedConfig())
+	if logs := tcpProxy.GetAccessLog(); len(logs) != 1 {
+		t.Errorf("expected 1 tcp access log, got %d", len(logs))
+	}
+
+	// クラスタには接続先のnamespace/serviceをmetadataとして記録
+	cluster := staticRes.GetClusters()[0]
+	backend := cluster.GetMetadata().GetFilterMetadata()["localmesh"].GetFields()["backend"].GetStringValue()
+	if backend != "users/users-api" {
+		t.Errorf("expected backend metadata 'users/users-api', got %v", backend)
+	}
+
+	// アクセスログがない場合はmetadataを付けない
+	plain := []ServiceConfig{
+		{Builder: NewKubernetesServiceBuilder("users.localhost", "http", "users", "users-api", "http", 8080, 0, ""), ClusterName: "users_cluster", LocalPort: 10001},
+		{Builder: NewTCPServiceBuilder("db.localhost", 5432, "127.0.0.2", "primary", "10.0.0.1", 5432), ClusterName: "db_cluster", LocalPort: 10002},
+	}
+	for _, c := range buildConfig(t, 80, plain, BuildOptions{}).GetStaticResources().GetClusters() {
+		if c.GetMetadata() != nil {
+			t.Errorf("expected no metadata on %s without access log, got %v", c.GetName(), c.GetMetadata())
+		}
+	}
+}
+
+func TestBuildConfigWithOptions_Tracing(t *testing.T) {
+	shared := NewKubernetesServiceBuilder(
+		"users.localhost", "http",
+		"users", "users-api", "http", 8080,
+		0,
+		"",
+	)
+	individual := NewKubernetesServiceBuilder(
+		"admin.localhost", "http",
+		"admin", "admin-api", "http", 8080,
+		8081,
+		"",
+	)
+	configs := []ServiceConfig{
+		{Builder: shared, ClusterName: "users_cluster", LocalPort: 10001},
+		{Builder: individual, ClusterName: "admin_cluster", LocalPort: 10002},
+	}
+
+	cfg := buildConfig(t, 80, configs, BuildOptions{
+		Tracing: &Tracing{Address: "127.0.0.1", Port: 4317, SamplingPercent: 25, ServiceName: "localmesh-test"},
+	})
+	staticRes := cfg.GetStaticResources()
+
+	// 共通HTTPリスナー・個別リスナーの両方でトレーシングを有効化
+	for _, l := range staticRes.GetListeners() {
+		tracing := listenerHCM(t, l).GetTracing()
+		if tracing == nil {
+			t.Fatalf("expected tracing on listener %v", l.GetName())
+		}
+		if tracing.GetRandomSampling().GetValue() != 25.0 {
+			t.Errorf("expected sampling 25, got %v", tracing.GetRandomSampling())
+		}
+		provider := unpack[*tracev3.OpenTelemetryConfig](t, tracing.GetProvider().GetTypedConfig())
+		if provider.GetServiceName() != "localmesh-test" {
+			t.Errorf("expected service_name 'localmesh-test', got %v", provider.GetServiceName())
+		}
+		if got := provider.GetGrpcService().GetEnvoyGrpc().GetClusterName(); got != TracingClusterName {
+			t.Errorf("expected collector cluster %s, got %v", TracingClusterName, got)
+		}
+	}
+
+	// コレクター用のクラスタ（IPアドレスの場合はSTATIC）
+	clusters := staticRes.GetClusters()
+	collector := clusters[len(clusters)-1]
+	if collector.GetName() != TracingClusterName || collector.GetType() != clusterv3.Cluster_STATIC {
+		t.Errorf("expected STATIC collector cluster, got %v (%v)", collector.GetName(), collector.GetType())
+	}
+}
+
+func TestBuildConfigWithOptions_BindAddresses(t *testing.T) {
+	newConfigs := func() ([]ServiceConfig, *KubernetesServiceBuilder) {
+		shared := NewKubernetesServiceBuilder("users.localhost", "http", "users", "users-api", "http", 8080, 0, "")
+		individual := NewKubernetesServiceBuilder("admin.localhost", "http", "admin", "admin-api", "http", 8080, 8081, "")
+		return []ServiceConfig{
+			{Builder: shared, ClusterName: "users_cluster", LocalPort: 10001},
+			{Builder: individual, ClusterName: "admin_cluster", LocalPort: 10002},
+		}, individual
+	}
+	// addresses はリスナーのaddressとadditional_addressesを"addr:port"の一覧にする
+	addresses := func(l *listenerv3.Listener) []string {
+		addrs := []string{l.GetAddress().GetSocketAddress().GetAddress()}
+		for _, a := range l.GetAdditionalAddresses() {
+			addrs = append(addrs, a.GetAddress().GetSocketAddress().GetAddress())
+		}
+		port := l.GetAddress().GetSocketAddress().GetPortValue()
+		for i, a := range addrs {
+			addrs[i] = net.JoinHostPort(a, strconv.Itoa(int(port)))
+		}
+		return addrs
+	}
+
+	t.Run("省略時はloopbackのみ", func(t *testing.T) {
+		configs, _ := newConfigs()
+		listeners := buildConfig(t, 80, configs, BuildOptions{}).GetStaticResources().GetListeners()
+		if got := addresses(listeners[0]); !slices.Equal(got, []string{"127.0.0.1:80"}) {
+			t.Errorf("expected listener_http on 127.0.0.1:80, got %v", got)
+		}
+		if got := addresses(listeners[1]); !slices.Equal(got, []string{"127.0.0.1:8081"}) {
+			t.Errorf("expected individual listener on 127.0.0.1:8081, got %v", got)
+		}
+	})
+
+	t.Run("2つ目以降はadditional_addresses", func(t *testing.T) {
+		configs, _ := newConfigs()
+		listeners := buildConfig(t, 80, configs, BuildOptions{BindAddresses: []string{"127.0.0.1", "::1"}}).GetStaticResources().GetListeners()
+		for i, want := range [][]string{{"127.0.0.1:80", "[::1]:80"}, {"127.0.0.1:8081", "[::1]:8081"}} {
+			if got := addresses(listeners[i]); !slices.Equal(got, want) {
+				t.Errorf("expected %s on %v, got %v", listeners[i].GetName(), want, got)
+			}
+		}
+	})
+
+	t.Run("個別リスナーはサービスのアドレスを優先", func(t *testing.T) {
+		configs, individual := newConfigs()
+		individual.BindAddresses = []string{"0.0.0.0"}
+		listeners := buildConfig(t, 80, configs, BuildOptions{BindAddresses: []string{"127.0.0.1", "::1"}}).GetStaticResources().GetListeners()
+		if got := addresses(listeners[0]); !slices.Equal(got, []string{"127.0.0.1:80", "[::1]:80"}) {
+			t.Errorf("expected listener_http to keep the global addresses, got %v", got)
+		}
+		if got := addresses(listeners[1]); !slices.Equal(got, []string{"0.0.0.0:8081"}) {
+			t.Errorf("expected individual listener on 0.0.0.0:8081, got %v", got)
+		}
+	})
+}
+
+func TestBuildConfigWithOptions_ListenerAuth(t *testing.T) {
+	configs := []ServiceConfig{
+		{Builder: NewKubernetesServiceBuilder("users.localhost", "http", "users", "users-api", "http", 8080, 0, ""), ClusterName: "users_cluster", LocalPort: 10001},
+		{Builder: NewTCPServiceBuilder("db.localhost", 5432, "127.0.0.2", "primary", "10.0.0.1", 5432), ClusterName: "db_cluster", LocalPort: 10002},
+	}
+	auth := &ListenerAuth{
+		BasicUsername: "alice",
+		BasicPassword: "secret",
+		BearerToken:   "token",
+		AllowedCIDRs:  []string{"192.168.1.0/24"},
+	}
+	listeners := buildConfig(t, 80, configs, BuildOptions{ListenerAuth: auth}).GetStaticResources().GetListeners()
+	if len(listeners) != 2 {
+		t.Fatalf("expected 2 listeners, got %d", len(listeners))
+	}
+
+	// すべてのリスナーの先頭で送信元IPを制限する
+	for _, l := range listeners {
+		filters := l.GetFilterChains()[0].GetFilters()
+		if filters[0].GetName() != networkRBACFilterName {
+			t.Fatalf("expected %s to start with %s, got %s", l.GetName(), networkRBACFilterName, filters[0].GetName())
+		}
+		rbac := unpack[*rbacnetworkv3.RBAC](t, filters[0].GetTypedConfig())
+		var sources []string
+		for _, p := range rbac.GetRules().GetPolicies()[listenerAuthPolicy].GetPrincipals() {
+			ip := p.GetDirectRemoteIp()
+			sources = append(sources, fmt.Sprintf("%s/%d", ip.GetAddressPrefix(), ip.GetPrefixLen().GetValue()))
+		}
+		if want := []string{"127.0.0.0/8", "::1/128", "192.168.1.0/24"}; !slices.Equal(sources, want) {
+			t.Errorf("expected %s to allow %v, got %v", l.GetName(), want, sources)
+		}
+	}
+
+	// HTTPリスナーはloopbackまたは認証情報を持つリクエストのみを許可する
+	hcm := unpack[*hcmv3.HttpConnectionManager](t, listeners[0].GetFilterChains()[0].GetFilters()[1].GetTypedConfig())
+	if hcm.GetHttpFilters()[0].GetName() != httpRBACFilterName {
+		t.Fatalf("expected the first http filter to be %s, got %s", httpRBACFilterName, hcm.GetHttpFilters()[0].GetName())
+	}
+	rbac := unpack[*rbachttpv3.RBAC](t, hcm.GetHttpFilters()[0].GetTypedConfig())
+	var credentials []string
+	for _, p := range rbac.GetRules().GetPolicies()[listenerAuthPolicy].GetPrincipals() {
+		if h := p.GetHeader(); h != nil {
+			credentials = append(credentials, h.GetStringMatch().GetExact())
+		}
+	}
+	if want := []string{"Basic YWxpY2U6c2VjcmV0", "Bearer token"}; !slices.Equal(credentials, want) {
+		t.Errorf("expected credentials %v, got %v", want, credentials)
+	}
+	mappers := hcm.GetLocalReplyConfig().GetMappers()
+	if len(mappers) != 1 || mappers[0].GetStatusCode().GetValue() != 401 {
+		t.Fatalf("expected a local reply mapper returning 401, got %v", mappers)
+	}
+	if got := mappers[0].GetHeadersToAdd()[0].GetHeader().GetValue(); got != `Basic realm="kubectl-localmesh"` {
+		t.Errorf("expected Basic challenge, got %q", got)
+	}
+
+	// メッシュの認証情報はRBACフィルタの直後に削除し、それ以外のAuthorizationはバックエンドに転送する
+	assertHTTPFilters(t, hcm.GetHttpFilters(), []string{httpRBACFilterName, authStripFilterName, "envoy.filters.http.router"})
+	if got := hcm.GetRouteConfig().GetRequestHeadersToRemove(); len(got) != 0 {
+		t.Errorf("expected authorization not to be removed unconditionally, got %v", got)
+	}
+	script := unpack[*luav3.Lua](t, hcm.GetHttpFilters()[1].GetTypedConfig()).GetDefaultSourceCode().GetInlineString()
+	for _, want := range []string{
+		`local mesh_credentials = {["Basic YWxpY2U6c2VjcmV0"] = true, ["Bearer token"] = true}`,
+		`if authorization ~= nil and mesh_credentials[authorization] then`,
+	} {
+		if !strings.Contains(script, want) {
+			t.Errorf("expected script to contain %q, got:\n%s", want, script)
+		}
+	}
+
+	t.Run("allowed_cidrsのみの場合はHTTPフィルタを追加しない", func(t *testing.T) {
+		listeners := buildConfig(t, 80, configs, BuildOptions{ListenerAuth: &ListenerAuth{AllowedCIDRs: []string{"10.0.0.0/8"}}}).GetStaticResources().GetListeners()
+		hcm := unpack[*hcmv3.HttpConnectionManager](t, listeners[0].GetFilterChains()[0].GetFilters()[1].GetTypedConfig())
+		if hcm.GetHttpFilters()[0].GetName() == httpRBACFilterName {
+			t.Error("expected no http rbac filter without credentials")
+		}
+		if hcm.GetLocalReplyConfig() != nil {
+			t.Error("expected no local reply config without credentials")
+		}
+		for _, f := range hcm.GetHttpFilters() {
+			if f.GetName() == authStripFilterName {
+				t.Error("expected authorization to be forwarded without credentials")
+			}
+		}
+	})
+
+	t.Run("Luaの文字列リテラルとして認証情報をエスケープする", func(t *testing.T) {
+		listeners := buildConfig(t, 80, configs, BuildOptions{ListenerAuth: &ListenerAuth{BearerToken: "to\"k\\en\té"}}).GetStaticResources().GetListeners()
+		filters := listenerHCM(t, listeners[0]).GetHttpFilters()
+		script := unpack[*luav3.Lua](t, filters[1].GetTypedConfig()).GetDefaultSourceCode().GetInlineString()
+		if want := `local mesh_credentials = {["Bearer to\"k\\en\009\195\169"] = true}`; !strings.Contains(script, want) {
+			t.Errorf("expected script to contain %q, got:\n%s", want, script)
+		}
+	})
+
+	t.Run("Redactedの場合は認証情報を書き出さない", func(t *testing.T) {
+		redacted := *auth
+		redacted.Redacted = true
+		b, err := MarshalYAML(buildConfig(t, 80, configs, BuildOptions{ListenerAuth: &redacted}))
+		if err != nil {
+			t.Fatalf("MarshalYAML failed: %v", err)
+		}
+		out := string(b)
+		for _, secret := range []string{"YWxpY2U6c2VjcmV0", "Bearer token"} {
+			if strings.Contains(out, secret) {
+				t.Errorf("expected %q to be redacted, got:\n%s", secret, out)
+			}
+		}
+		// RBACフィルタとLuaスクリプトの両方で置き換える
+		if got := strings.Count(out, "Basic <redacted>"); got != 2 {
+			t.Errorf("expected Basic <redacted> in rbac and lua, got %d", got)
+		}
+		if got := strings.Count(out, "Bearer <redacted>"); got != 2 {
+			t.Errorf("expected Bearer <redacted> in rbac and lua, got %d", got)
+		}
+	})
+
+	t.Run("認証情報のみの場合は送信元IPを制限しない", func(t *testing.T) {
+		listeners := buildConfig(t, 80, configs, BuildOptions{ListenerAuth: &ListenerAuth{BearerToken: "token"}}).GetStaticResources().GetListeners()
+		for _, l := range listeners {
+			if name := l.GetFilterChains()[0].GetFilters()[0].GetName(); name == networkRBACFilterName {
+				t.Errorf("expected no network rbac filter on %s", l.GetName())
+			}
+		}
+		hcm := listenerHCM(t, listeners[0])
+		if got := hcm.GetLocalReplyConfig().GetMappers()[0].GetHeadersToAdd()[0].GetHeader().GetValue(); got != `Bearer realm="kubectl-localmesh"` {
+			t.Errorf("expected Bearer challenge, got %q", got)
+		}
+	})
+
+	// 生成した設定がEnvoyの検証を通ること
+	if _, err := MarshalYAML(buildConfig(t, 80, configs, BuildOptions{ListenerAuth: auth})); err != nil {
+		t.Fatalf("MarshalYAML failed: %v", err)
+	}
+}
+
+func TestBuildConfig_Dashboard(t *testing.T) {
+	// ダッシュボードを有効にした場合はTCPサービスのみでもHTTPリスナーを生成する（有効にしない場合はServiceConfigに含まれない）
+	configs := []ServiceConfig{
+		{Builder: NewTCPServiceBuilder("db.localhost", 5432, "127.0.0.2", "primary", "10.0.0.1", 5432), ClusterName: "db_cluster", LocalPort: 10001},
+		{Builder: NewDashboardBuilder("localmesh.localhost"), ClusterName: DashboardClusterName, LocalPort: 10002},
+	}
+	resources := buildConfig(t, 80, configs, BuildOptions{}).GetStaticResources()
+
+	listeners := resources.GetListeners()
+	if len(listeners) != 2 || listeners[0].GetName() != "listener_http" {
+		t.Fatalf("expected listener_http and the tcp listener, got %d listeners", len(listeners))
+	}
+	vhosts := listenerHCM(t, listeners[0]).GetRouteConfig().GetVirtualHosts()
+	if len(vhosts) != 1 {
+		t.Fatalf("expected 1 virtual host, got %d", len(vhosts))
+	}
+	if got := vhosts[0].GetDomains(); !slices.Equal(got, []string{"localmesh.localhost", "localmesh.localhost:80"}) {
+		t.Errorf("unexpected domains: %v", got)
+	}
+	if got := vhosts[0].GetRoutes()[0].GetRoute().GetCluster(); got != DashboardClusterName {
+		t.Errorf("expected route to %s, got %s", DashboardClusterName, got)
+	}
+
+	// ダッシュボードサーバーはHTTP/1.1
+	for _, c := range resources.GetClusters() {
+		if c.GetName() != DashboardClusterName {
+			continue
+		}
+		if explicitHTTPConfig(t, c).GetHttpProtocolOptions() == nil {
+			t.Error("expected http_protocol_options for the dashboard cluster")
+		}
+		if addr, p := endpointAddress(c, 0); addr != "127.0.0.1" || p != 10002 {
+			t.Errorf("expected endpoint 127.0.0.1:10002, got %s:%d", addr, p)
+		}
+		return
+	}
+	t.Errorf("cluster %s not found", DashboardClusterName)
+}
+
+func TestBuildConfig_NamedListeners(t *testing.T) {
+	frontend := NewKubernetesServiceBuilder("frontend.localhost", "http", "web", "frontend", "", 8080, 0, "")
+	frontend.Listeners = []NamedListener{{Name: "web", Port: 8081}}
+	users := NewKubernetesServiceBuilder("users.localhost", "http", "users", "users-api", "", 8080, 0, "")
+	users.Listeners = []NamedListener{{}, {Name: "web", Port: 8081}, {Name: "api", Port: 8082}}
+	users.AccessLog = &AccessLog{Path: "stdout", Format: "text"}
+
+	configs := []ServiceConfig{
+		{Builder: frontend, ClusterName: "web_frontend_8080", LocalPort: 10001},
+		{Builder: users, ClusterName: "users_users_api_8080", LocalPort: 10002},
+	}
+	resources := buildConfig(t, 80, configs, BuildOptions{}).GetStaticResources()
+
+	listeners := resources.GetListeners()
+	var names []string
+	for _, l := range listeners {
+		names = append(names, l.GetName())
+	}
+	// 共通HTTPリスナーの後に、名前付きリスナーを最初に接続された順に並べる
+	if want := []string{"listener_http", "listener_http_web", "listener_http_api"}; !slices.Equal(names, want) {
+		t.Fatalf("expected listeners %v, got %v", want, names)
+	}
+	if len(resources.GetClusters()) != 2 {
+		t.Errorf("expected 2 clusters, got %d", len(resources.GetClusters()))
+	}
+
+	tests := []struct {
+		listener int
+		port     uint32
+		domains  [][]string
+	}{
+		{listener: 0, port: 80, domains: [][]string{{"users.localhost", "users.localhost:80"}}},
+		{listener: 1, port: 8081, domains: [][]string{{"frontend.localhost", "frontend.localhost:8081"}, {"users.localhost", "users.localhost:8081"}}},
+		{listener: 2, port: 8082, domains: [][]string{{"users.localhost", "users.localhost:8082"}}},
+	}
+	for _, tt := range tests {
+		l := listeners[tt.listener]
+		if got := l.GetAddress().GetSocketAddress().GetPortValue(); got != tt.port {
+			t.Errorf("%s: expected port %d, got %d", l.GetName(), tt.port, got)
+		}
+		hcm := listenerHCM(t, l)
+		vhosts := hcm.GetRouteConfig().GetVirtualHosts()
+		if len(vhosts) != len(tt.domains) {
+			t.Fatalf("%s: expected %d virtual hosts, got %d", l.GetName(), len(tt.domains), len(vhosts))
+		}
+		for i, vh := range vhosts {
+			if !slices.Equal(vh.GetDomains(), tt.domains[i]) {
+				t.Errorf("%s: expected domains %v, got %v", l.GetName(), tt.domains[i], vh.GetDomains())
+			}
+		}
+		// サービス単位のアクセスログは接続したリスナーごとに設定する
+		if len(hcm.GetAccessLog()) != 1 {
+			t.Errorf("%s: expected 1 access log, got %d", l.GetName(), len(hcm.GetAccessLog()))
+		}
+	}
+	if got := listenerHCM(t, listeners[1]).GetStatPrefix(); got != "ingress_http_web" {
+		t.Errorf("expected stat prefix ingress_http_web, got %s", got)
+	}
+}
+
+func TestTracingCollectorCluster_Hostname(t *testing.T) {
+	// ホスト名の場合はDNSで解決
+	cluster, err := tracingCollectorCluster(&Tracing{Address: "jaeger", Port: 4317})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cluster.GetType() != clusterv3.Cluster_STRICT_DNS {
+		t.Errorf("expected STRICT_DNS, got %v", cluster.GetType())
+	}
+}
+
+// buildConfig はBuildConfigWithOptionsで設定を生成し、エラーの場合はテストを失敗させる
+func buildConfig(t *testing.T, listenerPort port.ListenerPort, configs []ServiceConfig, opts BuildOptions) *bootstrapv3.Bootstrap {
+	t.Helper()
+	cfg, err := BuildConfigWithOptions(listenerPort, configs, opts)
+	if err != nil {
+		t.Fatalf("failed to build config: %v", err)
+	}
+	return cfg
+}
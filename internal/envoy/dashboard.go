@@ -0,0 +1,51 @@
+package envoy
+
+import (
+	"fmt"
+
+	routev3 "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+)
+
+// DashboardClusterName はダッシュボードサーバーのEnvoyクラスタ名
+const DashboardClusterName = "localmesh_dashboard"
+
+// DashboardBuilder はダッシュボード用のEnvoy設定ビルダー
+// メインのHTTPリスナーでホストへのリクエストをローカルのダッシュボードサーバーへ振り分ける
+type DashboardBuilder struct {
+	Host string
+}
+
+// NewDashboardBuilder はDashboardBuilderを生成
+func NewDashboardBuilder(host string) *DashboardBuilder {
+	return &DashboardBuilder{Host: host}
+}
+
+// Build はダッシュボードの設定コンポーネントを生成
+// clusterName/localPortはダッシュボードサーバーのクラスタ
+func (b *DashboardBuilder) Build(clusterName string, localPort int, listenerPort int) (HTTPComponents, error) {
+	cluster, err := buildLocalCluster(clusterName, localPort, "http")
+	if err != nil {
+		return HTTPComponents{}, err
+	}
+	return HTTPComponents{
+		Cluster: cluster,
+		Route: &routev3.VirtualHost{
+			Name: clusterName,
+			Domains: []string{
+				b.Host,
+				fmt.Sprintf("%s:%d", b.Host, listenerPort),
+			},
+			Routes: []*routev3.Route{
+				{
+					Match:  prefixMatch("/"),
+					Action: clusterRoute(clusterName),
+				},
+			},
+		},
+	}, nil
+}
+
+// GetHost はホスト名を取得
+func (b *DashboardBuilder) GetHost() string {
+	return b.Host
+}
@@ -0,0 +1,55 @@
+package envoy
+
+import (
+	"fmt"
+	"strings"
+
+	mysqlproxyv3 "github.com/envoyproxy/go-control-plane/contrib/envoy/extensions/filters/network/mysql_proxy/v3"
+	postgresproxyv3alpha "github.com/envoyproxy/go-control-plane/contrib/envoy/extensions/filters/network/postgres_proxy/v3alpha"
+	listenerv3 "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
+	"google.golang.org/protobuf/proto"
+)
+
+// データベースのプロトコルを解釈するネットワークフィルタ（Envoyのcontribビルドでのみ利用可能）
+const (
+	postgresProxyName = "envoy.filters.network.postgres_proxy"
+	mysqlProxyName    = "envoy.filters.network.mysql_proxy"
+)
+
+// DatabaseStatPrefix はデータベースのプロトコルフィルタの統計名のプレフィックスを返す
+// 統計は"<protocol>.<prefix>.statements"のように出力される（例: postgres.orders_db_localdomain.statements）
+func DatabaseStatPrefix(host string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' {
+			return r
+		}
+		return '_'
+	}, host)
+}
+
+// databaseFilter はtcp_proxyの前に挿入するデータベースのプロトコルフィルタを生成
+// プロトコルが未指定の場合はnilを返し（フィルタを挿入しない）、未対応のプロトコルはエラーにする
+func databaseFilter(protocol, host string) (*listenerv3.Filter, error) {
+	var name string
+	var config proto.Message
+	switch protocol {
+	case "postgres":
+		name = postgresProxyName
+		config = &postgresproxyv3alpha.PostgresProxy{StatPrefix: DatabaseStatPrefix(host)}
+	case "mysql":
+		name = mysqlProxyName
+		config = &mysqlproxyv3.MySQLProxy{StatPrefix: DatabaseStatPrefix(host)}
+	case "":
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("unsupported database protocol %q for %s", protocol, host)
+	}
+	tc, err := typedConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	return &listenerv3.Filter{
+		Name:       name,
+		ConfigType: &listenerv3.Filter_TypedConfig{TypedConfig: tc},
+	}, nil
+}
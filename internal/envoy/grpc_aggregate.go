@@ -0,0 +1,90 @@
+package envoy
+
+import (
+	"fmt"
+
+	routev3 "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+)
+
+// GRPCServiceRoute はprotoサービス名とルーティング先クラスタの対応
+type GRPCServiceRoute struct {
+	Service     string // 完全修飾サービス名（例: users.v1.UserService）
+	ClusterName string
+	Host        string // ルーティング先サービスのホスト名（ログ・診断用）
+}
+
+// GRPCReflectionClusterName は統合リフレクションサーバーのEnvoyクラスタ名
+const GRPCReflectionClusterName = "localmesh_grpc_reflection"
+
+// ReflectionServices は統合リフレクションサーバーへルーティングするサービス
+var ReflectionServices = []string{
+	"grpc.reflection.v1.ServerReflection",
+	"grpc.reflection.v1alpha.ServerReflection",
+}
+
+// IsReflectionService は統合リフレクションサーバーが処理するサービスかを判定
+func IsReflectionService(service string) bool {
+	for _, s := range ReflectionServices {
+		if s == service {
+			return true
+		}
+	}
+	return false
+}
+
+// GRPCAggregateBuilder は集約gRPCホスト用のEnvoy設定ビルダー
+// /pkg.Service/ プレフィックスで各バックエンドへ振り分け、
+// リフレクションはローカルの統合リフレクションサーバーへ振り分ける
+type GRPCAggregateBuilder struct {
+	Host   string
+	Routes []GRPCServiceRoute
+}
+
+// NewGRPCAggregateBuilder はGRPCAggregateBuilderを生成
+func NewGRPCAggregateBuilder(host string, routes []GRPCServiceRoute) *GRPCAggregateBuilder {
+	return &GRPCAggregateBuilder{
+		Host:   host,
+		Routes: routes,
+	}
+}
+
+// Build は集約ホストの設定コンポーネントを生成
+// clusterName/localPortは統合リフレクションサーバーのクラスタ
+func (b *GRPCAggregateBuilder) Build(clusterName string, localPort int, listenerPort int) (HTTPComponents, error) {
+	routes := make([]*routev3.Route, 0, len(b.Routes)+len(ReflectionServices))
+	for _, r := range b.Routes {
+		routes = append(routes, grpcServiceRoute(r.Service, r.ClusterName))
+	}
+	for _, s := range ReflectionServices {
+		routes = append(routes, grpcServiceRoute(s, clusterName))
+	}
+
+	cluster, err := buildLocalCluster(clusterName, localPort, "grpc")
+	if err != nil {
+		return HTTPComponents{}, err
+	}
+	return HTTPComponents{
+		Cluster: cluster,
+		Route: &routev3.VirtualHost{
+			Name: clusterName,
+			Domains: []string{
+				b.Host,
+				fmt.Sprintf("%s:%d", b.Host, listenerPort),
+			},
+			Routes: routes,
+		},
+	}, nil
+}
+
+// grpcServiceRoute はサービス名プレフィックスでマッチするルートを生成
+func grpcServiceRoute(service, clusterName string) *routev3.Route {
+	return &routev3.Route{
+		Match:  prefixMatch("/" + service + "/"),
+		Action: clusterRoute(clusterName),
+	}
+}
+
+// GetHost はホスト名を取得
+func (b *GRPCAggregateBuilder) GetHost() string {
+	return b.Host
+}
@@ -0,0 +1,62 @@
+package envoy
+
+import "testing"
+
+func TestGRPCAggregateBuilder_Build(t *testing.T) {
+	builder := NewGRPCAggregateBuilder("grpc.localhost", []GRPCServiceRoute{
+		{Service: "users.v1.UserService", ClusterName: "users_users_api_50051"},
+		{Service: "billing.v1.BillingService", ClusterName: "billing_billing_api_50051"},
+	})
+
+	components, err := builder.Build(GRPCReflectionClusterName, 10005, 80)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// リフレクションサーバーのクラスタはHTTP/2
+	if components.Cluster.GetName() != GRPCReflectionClusterName {
+		t.Errorf("expected cluster name %q, got %v", GRPCReflectionClusterName, components.Cluster.GetName())
+	}
+	if explicitHTTPConfig(t, components.Cluster).GetHttp2ProtocolOptions() == nil {
+		t.Error("expected http2_protocol_options for reflection cluster")
+	}
+
+	domains := components.Route.GetDomains()
+	if domains[0] != "grpc.localhost" || domains[1] != "grpc.localhost:80" {
+		t.Errorf("unexpected domains: %v", domains)
+	}
+
+	// サービスルート（設定順）の後にリフレクションルートが続く
+	routes := components.Route.GetRoutes()
+	want := []struct {
+		prefix  string
+		cluster string
+	}{
+		{"/users.v1.UserService/", "users_users_api_50051"},
+		{"/billing.v1.BillingService/", "billing_billing_api_50051"},
+		{"/grpc.reflection.v1.ServerReflection/", GRPCReflectionClusterName},
+		{"/grpc.reflection.v1alpha.ServerReflection/", GRPCReflectionClusterName},
+	}
+	if len(routes) != len(want) {
+		t.Fatalf("expected %d routes, got %d", len(want), len(routes))
+	}
+	for i, w := range want {
+		prefix := routes[i].GetMatch().GetPrefix()
+		cluster := routes[i].GetRoute().GetCluster()
+		if prefix != w.prefix || cluster != w.cluster {
+			t.Errorf("route[%d] = %v -> %v, want %s -> %s", i, prefix, cluster, w.prefix, w.cluster)
+		}
+	}
+}
+
+func TestIsReflectionService(t *testing.T) {
+	if !IsReflectionService("grpc.reflection.v1.ServerReflection") {
+		t.Error("expected v1 reflection to be a reflection service")
+	}
+	if !IsReflectionService("grpc.reflection.v1alpha.ServerReflection") {
+		t.Error("expected v1alpha reflection to be a reflection service")
+	}
+	if IsReflectionService("grpc.health.v1.Health") {
+		t.Error("expected health service not to be a reflection service")
+	}
+}
@@ -0,0 +1,65 @@
+package envoy
+
+import (
+	"time"
+
+	clusterv3 "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
+	corev3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	typev3 "github.com/envoyproxy/go-control-plane/envoy/type/v3"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// HealthCheck はバックエンド（port-forward・SSH tunnel）のアクティブヘルスチェック
+type HealthCheck struct {
+	Type               string // http|grpc|tcp
+	Path               string // HTTPヘルスチェックのパス（type: http）
+	Service            string // gRPCヘルスチェックのサービス名（type: grpc、空の場合はサーバー全体）
+	Interval           time.Duration
+	UnhealthyThreshold int
+}
+
+// applyHealthCheck はクラスタにアクティブヘルスチェックとoutlier detectionを設定
+// Envoyのクラスタに設定できるヘルスチェックは1つのため、既存のTCP接続チェックは置き換える
+func applyHealthCheck(cluster *clusterv3.Cluster, hc *HealthCheck, protocol string) {
+	cluster.HealthChecks = []*corev3.HealthCheck{activeHealthCheck(hc, protocol)}
+	cluster.OutlierDetection = outlierDetection(hc)
+}
+
+// activeHealthCheck は設定のtypeに応じたヘルスチェックを生成
+func activeHealthCheck(hc *HealthCheck, protocol string) *corev3.HealthCheck {
+	check := &corev3.HealthCheck{
+		Timeout:            durationpb.New(time.Second),
+		Interval:           durationpb.New(hc.Interval),
+		UnhealthyThreshold: wrapperspb.UInt32(uint32(hc.UnhealthyThreshold)),
+		HealthyThreshold:   wrapperspb.UInt32(1),
+	}
+
+	switch hc.Type {
+	case "http":
+		httpHealthCheck := &corev3.HealthCheck_HttpHealthCheck{Path: hc.Path}
+		if protocol == "grpc" || protocol == "http2" {
+			httpHealthCheck.CodecClientType = typev3.CodecClientType_HTTP2
+		}
+		check.HealthChecker = &corev3.HealthCheck_HttpHealthCheck_{HttpHealthCheck: httpHealthCheck}
+	case "grpc":
+		check.HealthChecker = &corev3.HealthCheck_GrpcHealthCheck_{
+			GrpcHealthCheck: &corev3.HealthCheck_GrpcHealthCheck{ServiceName: hc.Service},
+		}
+	default:
+		check.HealthChecker = &corev3.HealthCheck_TcpHealthCheck_{TcpHealthCheck: &corev3.HealthCheck_TcpHealthCheck{}}
+	}
+	return check
+}
+
+// outlierDetection は接続エラー（502・503・504）が続いたバックエンドを一時的に除外する設定を生成
+// アプリケーションの5xxでは除外せず、ヘルスチェックと同じ回数・間隔で判定する
+func outlierDetection(hc *HealthCheck) *clusterv3.OutlierDetection {
+	return &clusterv3.OutlierDetection{
+		ConsecutiveGatewayFailure:          wrapperspb.UInt32(uint32(hc.UnhealthyThreshold)),
+		EnforcingConsecutiveGatewayFailure: wrapperspb.UInt32(100),
+		EnforcingConsecutive_5Xx:           wrapperspb.UInt32(0),
+		Interval:                           durationpb.New(hc.Interval),
+		BaseEjectionTime:                   durationpb.New(hc.Interval),
+	}
+}
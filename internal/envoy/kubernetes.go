@@ -2,6 +2,24 @@ package envoy
 
 import (
 	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	accesslogv3 "github.com/envoyproxy/go-control-plane/envoy/config/accesslog/v3"
+	clusterv3 "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
+	corev3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	endpointv3 "github.com/envoyproxy/go-control-plane/envoy/config/endpoint/v3"
+	listenerv3 "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
+	routev3 "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	upstreamhttpv3 "github.com/envoyproxy/go-control-plane/envoy/extensions/upstreams/http/v3"
+	matcherv3 "github.com/envoyproxy/go-control-plane/envoy/type/matcher/v3"
+	typev3 "github.com/envoyproxy/go-control-plane/envoy/type/v3"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
 
 	"github.com/usadamasa/kubectl-localmesh/internal/port"
 )
@@ -11,12 +29,50 @@ type KubernetesServiceBuilder struct {
 	Host                string
 	Protocol            string                      // http|http2|grpc
 	OverwriteListenPort port.IndividualListenerPort // 個別リスナーポート（省略時はHTTPリスナーに統合）
-	// メタデータ（ログ・診断用、Envoy設定生成には使用しない）
+	BindAddresses       []string                    // 個別リスナーのバインドアドレス（省略時はBuildOptionsのアドレス）
+	// メタデータ（ログ・診断用、Envoy設定ではクラスタのmetadataにのみ使用）
 	Namespace   string
 	ServiceName string
 	PortName    string
 	Port        port.ServicePort
 	Cluster     string
+	// 追加のバックエンド（生成後に設定）
+	Mirror *MirrorUpstream    // トラフィックミラーリング先
+	Weight int                // 重み付き分散時のメインのバックエンドの重み
+	Splits []WeightedUpstream // 重み付き分散の追加バックエンド
+	// Failover はメインのバックエンドと同じクラスタに下位の優先度で追加するバックエンド
+	// Failover[i]の優先度はi+1（メインは0）
+	Failover []Upstream
+	// LocalOverride はメインのバックエンドより優先するローカルプロセス
+	LocalOverride *LocalOverride
+	// HealthCheck はメインのバックエンド（Failoverを含む）のアクティブヘルスチェック
+	HealthCheck *HealthCheck
+	// HeaderRoutes はデフォルトルートより前に評価するヘッダー一致ルート
+	HeaderRoutes []HeaderRoute
+	// RateLimit はこのホストに適用するレート制限（リスナー全体の設定より優先）
+	RateLimit *RateLimit
+	// AccessLog はこのホストへのリクエストのみを記録するアクセスログ
+	AccessLog *AccessLog
+	// Lua はこのホストのリクエスト・レスポンスに適用するLuaスクリプト
+	Lua string
+	// RedirectHosts はLocationヘッダーでローカルのホストに置換するクラスタ側のホスト名
+	RedirectHosts []string
+	// CookieDomains はSet-CookieのDomain属性でローカルのホストに置換するクラスタ側のホスト名
+	CookieDomains []string
+	// Aliases はHostと同じルーティングを行う別名（クラスタ内のDNS名など）
+	Aliases []string
+	// AliasPort は別名をポート付きでも一致させるServiceのポート（クラスタ内のURLのhost:port用、0の場合は追加しない）
+	AliasPort port.ServicePort
+	// Listeners は接続する共有HTTPリスナー（空の場合は共通HTTPリスナーのみ、OverwriteListenPortとは併用しない）
+	Listeners []NamedListener
+}
+
+// LocalOverride はクラスタのServiceより優先するローカルプロセス
+// ヘルスチェックに失敗している間はport-forward（優先度1）へフォールバックする
+type LocalOverride struct {
+	Address    string // IPアドレス
+	Port       int
+	HealthPath string // HTTPヘルスチェックのパス（空の場合はTCP接続のみで判定）
 }
 
 // NewKubernetesServiceBuilder はKubernetesServiceBuilderを生成
@@ -38,160 +94,469 @@ func NewKubernetesServiceBuilder(host, protocol, namespace, serviceName, portNam
 
 // Build はサービスの設定コンポーネントを生成
 // OverwriteListenPortが指定されている場合はIndividualListenerComponentsを返す
-// 指定されていない場合はHTTPComponentsを返す
+// Listenersが指定されている場合はSharedListenerComponentsを返す
+// いずれも指定されていない場合はHTTPComponentsを返す
 // listenerPortは共通HTTPリスナーのポート番号（domainsに host:port を含めるため）
-func (b *KubernetesServiceBuilder) Build(clusterName string, localPort int, listenerPort int) any {
-	// クラスタ設定
-	cluster := b.buildCluster(clusterName, localPort)
+func (b *KubernetesServiceBuilder) Build(clusterName string, localPort int, listenerPort int) (any, error) {
+	return b.build(clusterName, localPort, listenerPort, BuildOptions{})
+}
+
+// build はBuildOptionsを反映してサービスの設定コンポーネントを生成
+func (b *KubernetesServiceBuilder) build(clusterName string, localPort int, listenerPort int, opts BuildOptions) (any, error) {
+	// クラスタ設定（アクセスログがある場合のみ、ログで参照する接続先をmetadataに記録する）
+	logged := opts.AccessLog != nil || b.AccessLog != nil
+	cluster, err := b.buildCluster(clusterName, localPort, logged)
+	if err != nil {
+		return nil, err
+	}
+	additionalClusters, err := b.buildAdditionalClusters(logged)
+	if err != nil {
+		return nil, err
+	}
 
 	// OverwriteListenPortがある場合は個別リスナーを生成
 	if b.OverwriteListenPort != 0 {
-		listener := b.buildIndividualListener(clusterName, b.OverwriteListenPort, 0)
+		listener, err := b.buildIndividualListener(clusterName, b.OverwriteListenPort, opts)
+		if err != nil {
+			return nil, err
+		}
 		return IndividualListenerComponents{
-			Cluster:   cluster,
-			Listeners: []map[string]any{listener},
+			Cluster:            cluster,
+			Listeners:          []*listenerv3.Listener{listener},
+			AdditionalClusters: additionalClusters,
+		}, nil
+	}
+
+	// 共有HTTPリスナーごとに、そのポートを含むvirtual hostを生成
+	if len(b.Listeners) > 0 {
+		routes := make([]*routev3.VirtualHost, 0, len(b.Listeners))
+		for _, l := range b.Listeners {
+			vh, err := b.buildVirtualHost(clusterName, int(l.EffectivePort(port.ListenerPort(listenerPort))))
+			if err != nil {
+				return nil, err
+			}
+			routes = append(routes, vh)
 		}
+		return SharedListenerComponents{
+			Cluster:            cluster,
+			Routes:             routes,
+			AdditionalClusters: additionalClusters,
+		}, nil
 	}
 
 	// HTTPルート設定（従来動作）
-	// gRPCクライアントは:authorityヘッダーにhost:port形式で送信するため、両方のパターンを許可
-	httpRoute := map[string]any{
-		"name": clusterName,
-		"domains": []any{
-			b.Host,
-			fmt.Sprintf("%s:%d", b.Host, listenerPort),
-		},
-		"routes": []any{
-			map[string]any{
-				"match": map[string]any{"prefix": "/"},
-				"route": map[string]any{
-					"cluster": clusterName,
-					"timeout": "0s",
+	vh, err := b.buildVirtualHost(clusterName, listenerPort)
+	if err != nil {
+		return nil, err
+	}
+	return HTTPComponents{
+		Cluster:            cluster,
+		Route:              vh,
+		AdditionalClusters: additionalClusters,
+	}, nil
+}
+
+// Domains はvirtual hostのドメインを返す
+// gRPCクライアントは:authorityヘッダーにhost:port形式で送信するため、両方のパターンを許可
+// 別名はAliasPortを付けた形式（クラスタ内のURLのhost:port）も許可する
+// listenerPortはvirtual hostを持つリスナーのポート
+func (b *KubernetesServiceBuilder) Domains(listenerPort int) []string {
+	domains := []string{b.Host, fmt.Sprintf("%s:%d", b.Host, listenerPort)}
+	for _, alias := range b.Aliases {
+		domains = append(domains, alias, fmt.Sprintf("%s:%d", alias, listenerPort))
+		if b.AliasPort != 0 && int(b.AliasPort) != listenerPort {
+			domains = append(domains, fmt.Sprintf("%s:%d", alias, b.AliasPort))
+		}
+	}
+	return domains
+}
+
+// buildVirtualHost はホストのvirtual hostを生成
+// listenerPortはvirtual hostを持つリスナーのポート
+func (b *KubernetesServiceBuilder) buildVirtualHost(clusterName string, listenerPort int) (*routev3.VirtualHost, error) {
+	virtualHost := &routev3.VirtualHost{
+		Name:    clusterName,
+		Domains: b.Domains(listenerPort),
+		Routes:  b.buildRoutes(clusterName),
+	}
+	if err := b.applyPerFilterConfig(virtualHost, listenerPort); err != nil {
+		return nil, err
+	}
+	return virtualHost, nil
+}
+
+// buildRoutes はvirtual hostのルート一覧を生成
+func (b *KubernetesServiceBuilder) buildRoutes(clusterName string) []*routev3.Route {
+	action := &routev3.RouteAction{
+		Timeout: durationpb.New(0),
+	}
+
+	// 重み付き分散（メインのバックエンドと追加バックエンドをweighted_clustersで束ねる）
+	if len(b.Splits) > 0 {
+		weighted := []*routev3.WeightedCluster_ClusterWeight{
+			{Name: clusterName, Weight: wrapperspb.UInt32(uint32(b.Weight))},
+		}
+		for _, u := range b.Splits {
+			weighted = append(weighted, &routev3.WeightedCluster_ClusterWeight{Name: u.ClusterName, Weight: wrapperspb.UInt32(uint32(u.Weight))})
+		}
+		action.ClusterSpecifier = &routev3.RouteAction_WeightedClusters{
+			WeightedClusters: &routev3.WeightedCluster{Clusters: weighted},
+		}
+	} else {
+		action.ClusterSpecifier = &routev3.RouteAction_Cluster{Cluster: clusterName}
+	}
+
+	// トラフィックミラーリング（応答は破棄される）
+	if b.Mirror != nil {
+		action.RequestMirrorPolicies = []*routev3.RouteAction_RequestMirrorPolicy{
+			{
+				Cluster: b.Mirror.ClusterName,
+				RuntimeFraction: &corev3.RuntimeFractionalPercent{
+					DefaultValue: fractionalPercent(b.Mirror.Percent),
 				},
 			},
+		}
+	}
+
+	// ヘッダー一致ルートはデフォルトルートより前に置く（先に一致したルートが使われる）
+	var routes []*routev3.Route
+	for _, r := range b.HeaderRoutes {
+		match := prefixMatch("/")
+		match.Headers = []*routev3.HeaderMatcher{headerMatcher(r.Header, r.Value)}
+		routes = append(routes, &routev3.Route{
+			Match:  match,
+			Action: clusterRoute(r.ClusterName),
+		})
+	}
+
+	return append(routes, &routev3.Route{
+		Match:  prefixMatch("/"),
+		Action: &routev3.Route_Route{Route: action},
+	})
+}
+
+// prefixMatch はパスのプレフィックスで一致するルート条件を生成
+func prefixMatch(prefix string) *routev3.RouteMatch {
+	return &routev3.RouteMatch{
+		PathSpecifier: &routev3.RouteMatch_Prefix{Prefix: prefix},
+	}
+}
+
+// clusterRoute は単一のクラスタへタイムアウトなしで転送するルートアクションを生成
+func clusterRoute(clusterName string) *routev3.Route_Route {
+	return &routev3.Route_Route{
+		Route: &routev3.RouteAction{
+			ClusterSpecifier: &routev3.RouteAction_Cluster{Cluster: clusterName},
+			Timeout:          durationpb.New(0),
 		},
 	}
+}
 
-	return HTTPComponents{
-		Cluster: cluster,
-		Route:   httpRoute,
+// headerMatcher はヘッダー一致条件を生成
+// valueが空の場合はヘッダーの存在のみで判定する
+func headerMatcher(name, value string) *routev3.HeaderMatcher {
+	matcher := &routev3.HeaderMatcher{Name: strings.ToLower(name)}
+	if value == "" {
+		matcher.HeaderMatchSpecifier = &routev3.HeaderMatcher_PresentMatch{PresentMatch: true}
+	} else {
+		matcher.HeaderMatchSpecifier = &routev3.HeaderMatcher_StringMatch{
+			StringMatch: &matcherv3.StringMatcher{
+				MatchPattern: &matcherv3.StringMatcher_Exact{Exact: value},
+			},
+		}
 	}
+	return matcher
 }
 
 // buildCluster はクラスタ設定を生成
-func (b *KubernetesServiceBuilder) buildCluster(clusterName string, localPort int) map[string]any {
-	cluster := map[string]any{
-		"name":            clusterName,
-		"type":            "STATIC",
-		"connect_timeout": "1s",
-		"load_assignment": map[string]any{
-			"cluster_name": clusterName,
-			"endpoints": []any{
-				map[string]any{
-					"lb_endpoints": []any{
-						map[string]any{
-							"endpoint": map[string]any{
-								"address": map[string]any{
-									"socket_address": map[string]any{
-										"address":    "127.0.0.1",
-										"port_value": localPort,
-									},
-								},
-							},
-						},
-					},
-				},
+// LocalOverride・Failoverがある場合は優先度付きのエンドポイントとヘルスチェックを設定する
+// HealthCheckがある場合はFailover用のTCP接続チェックより優先する
+// loggedがtrueの場合はアクセスログ用の接続先のmetadataを設定する
+func (b *KubernetesServiceBuilder) buildCluster(clusterName string, localPort int, logged bool) (*clusterv3.Cluster, error) {
+	cluster, err := buildLocalCluster(clusterName, localPort, b.Protocol)
+	if err != nil {
+		return nil, err
+	}
+	if logged {
+		setBackendMetadata(cluster, b.Namespace+"/"+b.ServiceName)
+	}
+
+	switch {
+	case b.LocalOverride != nil:
+		// ローカルプロセス（優先度0）をヘルスチェックし、port-forward（優先度1）は常にhealthyとして扱う
+		cluster.LoadAssignment = &endpointv3.ClusterLoadAssignment{
+			ClusterName: clusterName,
+			Endpoints: []*endpointv3.LocalityLbEndpoints{
+				priorityEndpoints(b.LocalOverride.Address, b.LocalOverride.Port, 0, false),
+				priorityEndpoints("127.0.0.1", localPort, 1, true),
 			},
+		}
+		cluster.HealthChecks = []*corev3.HealthCheck{b.buildLocalOverrideHealthCheck()}
+
+	case len(b.Failover) > 0:
+		endpoints := []*endpointv3.LocalityLbEndpoints{priorityEndpoints("127.0.0.1", localPort, 0, false)}
+		for i, u := range b.Failover {
+			endpoints = append(endpoints, priorityEndpoints("127.0.0.1", int(u.LocalPort), i+1, false))
+		}
+		cluster.LoadAssignment = &endpointv3.ClusterLoadAssignment{
+			ClusterName: clusterName,
+			Endpoints:   endpoints,
+		}
+
+		// port-forwardはReadyなPodがない間ローカルポートを閉じるため、
+		// TCP接続のみのヘルスチェックで下位の優先度へ切り替える
+		cluster.HealthChecks = []*corev3.HealthCheck{tcpHealthCheck()}
+	}
+
+	if b.HealthCheck != nil {
+		applyHealthCheck(cluster, b.HealthCheck, b.Protocol)
+	}
+
+	return cluster, nil
+}
+
+// buildLocalOverrideHealthCheck はローカルプロセス用のヘルスチェックを生成
+// HealthPathがある場合はHTTP、ない場合はTCP接続のみで判定する
+func (b *KubernetesServiceBuilder) buildLocalOverrideHealthCheck() *corev3.HealthCheck {
+	if b.LocalOverride.HealthPath == "" {
+		return tcpHealthCheck()
+	}
+
+	httpHealthCheck := &corev3.HealthCheck_HttpHealthCheck{
+		Path: b.LocalOverride.HealthPath,
+	}
+	if b.Protocol == "grpc" || b.Protocol == "http2" {
+		httpHealthCheck.CodecClientType = typev3.CodecClientType_HTTP2
+	}
+	hc := healthCheck()
+	hc.HealthChecker = &corev3.HealthCheck_HttpHealthCheck_{HttpHealthCheck: httpHealthCheck}
+	return hc
+}
+
+// tcpHealthCheck はTCP接続のみで判定するヘルスチェックを生成
+func tcpHealthCheck() *corev3.HealthCheck {
+	hc := healthCheck()
+	hc.HealthChecker = &corev3.HealthCheck_TcpHealthCheck_{TcpHealthCheck: &corev3.HealthCheck_TcpHealthCheck{}}
+	return hc
+}
+
+// healthCheck はローカル向けの短い間隔のヘルスチェック設定を生成
+// 呼び出し側でHealthChecker（TCP・HTTPなど）を設定する
+func healthCheck() *corev3.HealthCheck {
+	return &corev3.HealthCheck{
+		Timeout:            durationpb.New(time.Second),
+		Interval:           durationpb.New(2 * time.Second),
+		UnhealthyThreshold: wrapperspb.UInt32(1),
+		HealthyThreshold:   wrapperspb.UInt32(1),
+	}
+}
+
+// priorityEndpoints は優先度付きのエンドポイントを生成
+// disableActiveHealthCheckがtrueの場合はヘルスチェック対象から外し、常にhealthyとして扱う
+func priorityEndpoints(address string, p int, priority int, disableActiveHealthCheck bool) *endpointv3.LocalityLbEndpoints {
+	endpoint := &endpointv3.Endpoint{
+		Address: socketAddress(address, p),
+	}
+	if disableActiveHealthCheck {
+		endpoint.HealthCheckConfig = &endpointv3.Endpoint_HealthCheckConfig{
+			DisableActiveHealthCheck: true,
+		}
+	}
+
+	return &endpointv3.LocalityLbEndpoints{
+		Priority: uint32(priority),
+		LbEndpoints: []*endpointv3.LbEndpoint{
+			{HostIdentifier: &endpointv3.LbEndpoint_Endpoint{Endpoint: endpoint}},
 		},
 	}
+}
+
+// buildAdditionalClusters は重み付き分散・ミラー先・ヘッダールーティングなど追加のバックエンド用クラスタを生成
+func (b *KubernetesServiceBuilder) buildAdditionalClusters(logged bool) ([]*clusterv3.Cluster, error) {
+	var upstreams []Upstream
+	for _, u := range b.Splits {
+		upstreams = append(upstreams, u.Upstream)
+	}
+	if b.Mirror != nil {
+		upstreams = append(upstreams, b.Mirror.Upstream)
+	}
+
+	var clusters []*clusterv3.Cluster
+	for _, u := range upstreams {
+		cluster, err := b.buildUpstreamCluster(u, logged)
+		if err != nil {
+			return nil, err
+		}
+		clusters = append(clusters, cluster)
+	}
+	for _, r := range b.HeaderRoutes {
+		if r.Address == "" {
+			cluster, err := b.buildUpstreamCluster(r.Upstream, logged)
+			if err != nil {
+				return nil, err
+			}
+			clusters = append(clusters, cluster)
+			continue
+		}
+		cluster, err := buildStaticCluster(r.ClusterName, r.Address, int(r.LocalPort), b.Protocol)
+		if err != nil {
+			return nil, err
+		}
+		if logged {
+			setBackendMetadata(cluster, net.JoinHostPort(r.Address, strconv.Itoa(int(r.LocalPort)))+" (local)")
+		}
+		clusters = append(clusters, cluster)
+	}
+	return clusters, nil
+}
+
+// buildUpstreamCluster はport-forward経由の追加バックエンド用クラスタを生成
+func (b *KubernetesServiceBuilder) buildUpstreamCluster(u Upstream, logged bool) (*clusterv3.Cluster, error) {
+	cluster, err := buildLocalCluster(u.ClusterName, int(u.LocalPort), b.Protocol)
+	if err != nil {
+		return nil, err
+	}
+	if logged && u.ServiceName != "" {
+		setBackendMetadata(cluster, u.Namespace+"/"+u.ServiceName)
+	}
+	return cluster, nil
+}
+
+// buildLocalCluster は127.0.0.1上のローカルポートを向くHTTPクラスタ設定を生成
+func buildLocalCluster(clusterName string, localPort int, protocol string) (*clusterv3.Cluster, error) {
+	return buildStaticCluster(clusterName, "127.0.0.1", localPort, protocol)
+}
+
+// buildStaticCluster は指定アドレスを向くHTTPクラスタ設定を生成
+func buildStaticCluster(clusterName string, address string, p int, protocol string) (*clusterv3.Cluster, error) {
+	cluster := staticCluster(clusterName, address, p)
 
 	// protocolに応じたHTTP設定を追加
-	var httpConfig map[string]any
-	if b.Protocol == "grpc" || b.Protocol == "http2" {
-		httpConfig = map[string]any{
-			"http2_protocol_options": map[string]any{},
+	explicitConfig := &upstreamhttpv3.HttpProtocolOptions_ExplicitHttpConfig{}
+	if protocol == "grpc" || protocol == "http2" {
+		explicitConfig.ProtocolConfig = &upstreamhttpv3.HttpProtocolOptions_ExplicitHttpConfig_Http2ProtocolOptions{
+			Http2ProtocolOptions: &corev3.Http2ProtocolOptions{},
 		}
 	} else {
-		httpConfig = map[string]any{
-			"http_protocol_options": map[string]any{},
+		explicitConfig.ProtocolConfig = &upstreamhttpv3.HttpProtocolOptions_ExplicitHttpConfig_HttpProtocolOptions{
+			HttpProtocolOptions: &corev3.Http1ProtocolOptions{},
 		}
 	}
 
-	cluster["typed_extension_protocol_options"] = map[string]any{
-		"envoy.extensions.upstreams.http.v3.HttpProtocolOptions": map[string]any{
-			"@type":                "type.googleapis.com/envoy.extensions.upstreams.http.v3.HttpProtocolOptions",
-			"explicit_http_config": httpConfig,
+	options, err := typedConfig(&upstreamhttpv3.HttpProtocolOptions{
+		UpstreamProtocolOptions: &upstreamhttpv3.HttpProtocolOptions_ExplicitHttpConfig_{
+			ExplicitHttpConfig: explicitConfig,
 		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	cluster.TypedExtensionProtocolOptions = map[string]*anypb.Any{
+		"envoy.extensions.upstreams.http.v3.HttpProtocolOptions": options,
 	}
 
-	return cluster
+	return cluster, nil
 }
 
-// buildIndividualListener は個別リスナーを生成
-func (b *KubernetesServiceBuilder) buildIndividualListener(clusterName string, listenPort port.IndividualListenerPort, index int) map[string]any {
-	listenerName := fmt.Sprintf("listener_%s_%d", clusterName, listenPort)
-
-	// HTTP connection manager設定
-	httpConnManager := map[string]any{
-		"@type":       "type.googleapis.com/envoy.extensions.filters.network.http_connection_manager.v3.HttpConnectionManager",
-		"stat_prefix": fmt.Sprintf("ingress_%s_%d", clusterName, listenPort),
-		"codec_type":  "AUTO",
-		"route_config": map[string]any{
-			"name": fmt.Sprintf("route_%s_%d", clusterName, listenPort),
-			"virtual_hosts": []any{
-				map[string]any{
-					"name": clusterName,
-					"domains": []any{
-						b.Host,
-						fmt.Sprintf("%s:%d", b.Host, listenPort),
-					},
-					"routes": []any{
-						map[string]any{
-							"match": map[string]any{"prefix": "/"},
-							"route": map[string]any{
-								"cluster": clusterName,
-								"timeout": "0s",
+// staticCluster は単一のエンドポイントを持つSTATICクラスタを生成（プロトコル設定なし）
+func staticCluster(clusterName string, address string, p int) *clusterv3.Cluster {
+	return &clusterv3.Cluster{
+		Name:                 clusterName,
+		ClusterDiscoveryType: &clusterv3.Cluster_Type{Type: clusterv3.Cluster_STATIC},
+		ConnectTimeout:       durationpb.New(time.Second),
+		LoadAssignment: &endpointv3.ClusterLoadAssignment{
+			ClusterName: clusterName,
+			Endpoints: []*endpointv3.LocalityLbEndpoints{
+				{
+					LbEndpoints: []*endpointv3.LbEndpoint{
+						{
+							HostIdentifier: &endpointv3.LbEndpoint_Endpoint{
+								Endpoint: &endpointv3.Endpoint{
+									Address: socketAddress(address, p),
+								},
 							},
 						},
 					},
 				},
 			},
 		},
-		"http_filters": []any{
-			map[string]any{
-				"name": "envoy.filters.http.router",
-				"typed_config": map[string]any{
-					"@type": "type.googleapis.com/envoy.extensions.filters.http.router.v3.Router",
-				},
-			},
-		},
+	}
+}
+
+// buildIndividualListener は個別リスナーを生成
+func (b *KubernetesServiceBuilder) buildIndividualListener(clusterName string, listenPort port.IndividualListenerPort, opts BuildOptions) (*listenerv3.Listener, error) {
+	listenerName := fmt.Sprintf("listener_%s_%d", clusterName, listenPort)
+
+	vh, err := b.buildVirtualHost(clusterName, int(listenPort))
+	if err != nil {
+		return nil, err
+	}
+	// HTTP connection manager設定
+	httpConnManager := httpConnectionManager(
+		fmt.Sprintf("ingress_%s_%d", clusterName, listenPort),
+		fmt.Sprintf("route_%s_%d", clusterName, listenPort),
+		[]*routev3.VirtualHost{vh},
+	)
+	// 個別リスナーはこのホスト専用のため、サービス単位のアクセスログにフィルタは不要
+	var hostLogs []*accesslogv3.AccessLog
+	if b.AccessLog != nil {
+		log, err := httpAccessLog(b.AccessLog, nil)
+		if err != nil {
+			return nil, err
+		}
+		hostLogs = append(hostLogs, log)
+	}
+	if err := applyListenerOptions(httpConnManager, opts, hostFilters{rateLimit: b.RateLimit != nil, lua: b.Lua != "", rewrite: b.rewritesHosts()}, hostLogs); err != nil {
+		return nil, err
 	}
 
 	// HTTP/2対応（gRPC/http2の場合）
 	if b.Protocol == "grpc" || b.Protocol == "http2" {
-		httpConnManager["http2_protocol_options"] = map[string]any{}
+		httpConnManager.Http2ProtocolOptions = &corev3.Http2ProtocolOptions{}
 	}
 
-	return map[string]any{
-		"name": listenerName,
-		"address": map[string]any{
-			"socket_address": map[string]any{
-				"address":    "0.0.0.0",
-				"port_value": int(listenPort),
-			},
-		},
-		"enable_reuse_port": map[string]any{"value": false},
-		"filter_chains": []any{
-			map[string]any{
-				"filters": []any{
-					map[string]any{
-						"name":         "envoy.filters.network.http_connection_manager",
-						"typed_config": httpConnManager,
-					},
-				},
-			},
-		},
+	addrs := opts.BindAddresses
+	if len(b.BindAddresses) > 0 {
+		addrs = b.BindAddresses
+	}
+	return httpListener(listenerName, addrs, int(listenPort), httpConnManager)
+}
+
+// applyPerFilterConfig はホスト単位のレート制限・Luaスクリプト・ホスト名の置換をvirtual hostに設定
+// listenerPortはvirtual hostを持つリスナーのポート（置換後のLocationヘッダーに使用）
+func (b *KubernetesServiceBuilder) applyPerFilterConfig(virtualHost *routev3.VirtualHost, listenerPort int) error {
+	configs := map[string]proto.Message{}
+	if b.RateLimit != nil {
+		configs[localRateLimitFilterName] = localRateLimitConfig(b.RateLimit)
 	}
+	if b.Lua != "" {
+		configs[luaFilterName] = luaPerRoute(b.Lua)
+	}
+	if b.rewritesHosts() {
+		authority := b.Host
+		if listenerPort != 80 {
+			authority = fmt.Sprintf("%s:%d", b.Host, listenerPort)
+		}
+		configs[hostRewriteFilterName] = luaPerRoute(hostRewriteScript(b.RedirectHosts, b.CookieDomains, authority, b.Host))
+	}
+	if len(configs) == 0 {
+		return nil
+	}
+	perFilter := make(map[string]*anypb.Any, len(configs))
+	for name, config := range configs {
+		tc, err := typedConfig(config)
+		if err != nil {
+			return err
+		}
+		perFilter[name] = tc
+	}
+	virtualHost.TypedPerFilterConfig = perFilter
+	return nil
+}
+
+// rewritesHosts はLocation・Set-Cookieのホスト名を置換するかを返す
+func (b *KubernetesServiceBuilder) rewritesHosts() bool {
+	return len(b.RedirectHosts) > 0 || len(b.CookieDomains) > 0
 }
 
 // GetHost はホスト名を取得
@@ -1,7 +1,16 @@
 package envoy
 
 import (
+	"slices"
+	"strings"
 	"testing"
+	"time"
+
+	corev3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	luav3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/lua/v3"
+	hcmv3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
+	typev3 "github.com/envoyproxy/go-control-plane/envoy/type/v3"
+	"google.golang.org/protobuf/types/known/anypb"
 
 	"github.com/usadamasa/kubectl-localmesh/internal/port"
 )
@@ -15,7 +24,10 @@ func TestKubernetesServiceBuilder_Build_HTTPRoute(t *testing.T) {
 		"",
 	)
 
-	result := builder.Build("api_cluster", 10001, 80)
+	result, err := builder.Build("api_cluster", 10001, 80)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
 
 	// HTTPComponentsを返すことを確認
 	httpComponents, ok := result.(HTTPComponents)
@@ -24,13 +36,13 @@ func TestKubernetesServiceBuilder_Build_HTTPRoute(t *testing.T) {
 	}
 
 	// クラスタ設定の確認
-	if httpComponents.Cluster["name"] != "api_cluster" {
-		t.Errorf("expected cluster name 'api_cluster', got %v", httpComponents.Cluster["name"])
+	if httpComponents.Cluster.GetName() != "api_cluster" {
+		t.Errorf("expected cluster name 'api_cluster', got %v", httpComponents.Cluster.GetName())
 	}
 
 	// ルート設定の確認
-	if httpComponents.Route["name"] != "api_cluster" {
-		t.Errorf("expected route name 'api_cluster', got %v", httpComponents.Route["name"])
+	if httpComponents.Route.GetName() != "api_cluster" {
+		t.Errorf("expected route name 'api_cluster', got %v", httpComponents.Route.GetName())
 	}
 }
 
@@ -43,7 +55,10 @@ func TestKubernetesServiceBuilder_Build_WithOverwriteListenPort(t *testing.T) {
 		"",
 	)
 
-	result := builder.Build("grpc_cluster", 10001, 80)
+	result, err := builder.Build("grpc_cluster", 10001, 80)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
 
 	// IndividualListenerComponentsを返すことを確認
 	listenerComponents, ok := result.(IndividualListenerComponents)
@@ -52,20 +67,17 @@ func TestKubernetesServiceBuilder_Build_WithOverwriteListenPort(t *testing.T) {
 	}
 
 	// クラスタ設定の確認
-	if listenerComponents.Cluster["name"] != "grpc_cluster" {
-		t.Errorf("expected cluster name 'grpc_cluster', got %v", listenerComponents.Cluster["name"])
+	if listenerComponents.Cluster.GetName() != "grpc_cluster" {
+		t.Errorf("expected cluster name 'grpc_cluster', got %v", listenerComponents.Cluster.GetName())
 	}
 
 	// リスナーが1つあることを確認
 	if len(listenerComponents.Listeners) != 1 {
-		t.Errorf("expected 1 listener, got %d", len(listenerComponents.Listeners))
+		t.Fatalf("expected 1 listener, got %d", len(listenerComponents.Listeners))
 	}
 
 	// リスナーのポート確認
-	listener := listenerComponents.Listeners[0]
-	address := listener["address"].(map[string]any)
-	socketAddr := address["socket_address"].(map[string]any)
-	portVal := socketAddr["port_value"].(int)
+	portVal := listenerComponents.Listeners[0].GetAddress().GetSocketAddress().GetPortValue()
 	if portVal != 50051 {
 		t.Errorf("expected listener port 50051, got %d", portVal)
 	}
@@ -80,7 +92,10 @@ func TestKubernetesServiceBuilder_Build_HTTP_WithOverwriteListenPort(t *testing.
 		"",
 	)
 
-	result := builder.Build("http_cluster", 10001, 80)
+	result, err := builder.Build("http_cluster", 10001, 80)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
 
 	listenerComponents, ok := result.(IndividualListenerComponents)
 	if !ok {
@@ -93,11 +108,7 @@ func TestKubernetesServiceBuilder_Build_HTTP_WithOverwriteListenPort(t *testing.
 	}
 
 	// HTTP/1.1の設定確認（クラスタ側）
-	protocolOpts := listenerComponents.Cluster["typed_extension_protocol_options"].(map[string]any)
-	httpOpts := protocolOpts["envoy.extensions.upstreams.http.v3.HttpProtocolOptions"].(map[string]any)
-	explicitConfig := httpOpts["explicit_http_config"].(map[string]any)
-
-	if _, ok := explicitConfig["http_protocol_options"]; !ok {
+	if explicitHTTPConfig(t, listenerComponents.Cluster).GetHttpProtocolOptions() == nil {
 		t.Error("expected http_protocol_options for HTTP/1.1 protocol")
 	}
 }
@@ -111,7 +122,10 @@ func TestKubernetesServiceBuilder_Build_gRPC_WithOverwriteListenPort(t *testing.
 		"",
 	)
 
-	result := builder.Build("grpc_cluster", 10001, 80)
+	result, err := builder.Build("grpc_cluster", 10001, 80)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
 
 	listenerComponents, ok := result.(IndividualListenerComponents)
 	if !ok {
@@ -119,11 +133,7 @@ func TestKubernetesServiceBuilder_Build_gRPC_WithOverwriteListenPort(t *testing.
 	}
 
 	// HTTP/2の設定確認（クラスタ側）
-	protocolOpts := listenerComponents.Cluster["typed_extension_protocol_options"].(map[string]any)
-	httpOpts := protocolOpts["envoy.extensions.upstreams.http.v3.HttpProtocolOptions"].(map[string]any)
-	explicitConfig := httpOpts["explicit_http_config"].(map[string]any)
-
-	if _, ok := explicitConfig["http2_protocol_options"]; !ok {
+	if explicitHTTPConfig(t, listenerComponents.Cluster).GetHttp2ProtocolOptions() == nil {
 		t.Error("expected http2_protocol_options for gRPC protocol")
 	}
 }
@@ -140,3 +150,552 @@ func TestKubernetesServiceBuilder_GetHost(t *testing.T) {
 		t.Errorf("expected host 'test.localhost', got '%s'", builder.GetHost())
 	}
 }
+
+func TestKubernetesServiceBuilder_Domains_WithAliases(t *testing.T) {
+	builder := NewKubernetesServiceBuilder(
+		"api.localhost", "http",
+		"default", "api", "http", 8080,
+		0,
+		"",
+	)
+	builder.Aliases = []string{"api.default", "api.default.svc.cluster.local"}
+
+	// サービスのポートにリスナーがない場合（AliasPortが0）はリスナーのポートのみ
+	if got, want := builder.Domains(80), []string{
+		"api.localhost", "api.localhost:80",
+		"api.default", "api.default:80",
+		"api.default.svc.cluster.local", "api.default.svc.cluster.local:80",
+	}; !slices.Equal(got, want) {
+		t.Errorf("expected domains %v, got %v", want, got)
+	}
+
+	// サービスのポートにリスナーがある場合はクラスタ内のURLのhost:portでも一致させる
+	builder.AliasPort = 8080
+	result, err := builder.Build("api_cluster", 10001, 80)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []string{
+		"api.localhost", "api.localhost:80",
+		"api.default", "api.default:80", "api.default:8080",
+		"api.default.svc.cluster.local", "api.default.svc.cluster.local:80", "api.default.svc.cluster.local:8080",
+	}
+	if got := result.(HTTPComponents).Route.GetDomains(); !slices.Equal(got, want) {
+		t.Errorf("expected domains %v, got %v", want, got)
+	}
+
+	// サービスのポートがリスナーのポートと同じ場合は重複させない
+	got := builder.Domains(8080)
+	want = []string{
+		"api.localhost", "api.localhost:8080",
+		"api.default", "api.default:8080",
+		"api.default.svc.cluster.local", "api.default.svc.cluster.local:8080",
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("expected domains %v, got %v", want, got)
+	}
+}
+
+func TestKubernetesServiceBuilder_Build_WithMirror(t *testing.T) {
+	builder := NewKubernetesServiceBuilder(
+		"api.localhost", "http",
+		"default", "api", "http", 8080,
+		0,
+		"",
+	)
+	builder.Mirror = &MirrorUpstream{
+		Upstream: Upstream{ClusterName: "api_cluster_mirror", LocalPort: 10002},
+		Percent:  25,
+	}
+
+	result, err := builder.Build("api_cluster", 10001, 80)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	httpComponents, ok := result.(HTTPComponents)
+	if !ok {
+		t.Fatalf("expected HTTPComponents, got %T", result)
+	}
+
+	// ミラー先クラスタが追加されることを確認
+	if len(httpComponents.AdditionalClusters) != 1 {
+		t.Fatalf("expected 1 additional cluster, got %d", len(httpComponents.AdditionalClusters))
+	}
+	if httpComponents.AdditionalClusters[0].GetName() != "api_cluster_mirror" {
+		t.Errorf("expected mirror cluster 'api_cluster_mirror', got %v", httpComponents.AdditionalClusters[0].GetName())
+	}
+
+	// ルートにrequest_mirror_policiesが設定されることを確認
+	route := httpComponents.Route.GetRoutes()[0].GetRoute()
+	policies := route.GetRequestMirrorPolicies()
+	if len(policies) != 1 {
+		t.Fatalf("expected 1 request_mirror_policy, got %v", policies)
+	}
+	if policies[0].GetCluster() != "api_cluster_mirror" {
+		t.Errorf("expected mirror policy cluster 'api_cluster_mirror', got %v", policies[0].GetCluster())
+	}
+	fraction := policies[0].GetRuntimeFraction().GetDefaultValue()
+	if fraction.GetNumerator() != 25 || fraction.GetDenominator() != typev3.FractionalPercent_HUNDRED {
+		t.Errorf("expected 25/HUNDRED, got %v", fraction)
+	}
+}
+
+func TestFractionalPercent(t *testing.T) {
+	tests := []struct {
+		percent         float64
+		wantNumerator   uint32
+		wantDenominator typev3.FractionalPercent_DenominatorType
+	}{
+		{100, 100, typev3.FractionalPercent_HUNDRED},
+		{10, 10, typev3.FractionalPercent_HUNDRED},
+		{12.5, 125000, typev3.FractionalPercent_MILLION},
+		{0.01, 100, typev3.FractionalPercent_MILLION},
+	}
+
+	for _, tt := range tests {
+		got := fractionalPercent(tt.percent)
+		if got.GetNumerator() != tt.wantNumerator || got.GetDenominator() != tt.wantDenominator {
+			t.Errorf("fractionalPercent(%v) = %v, want %d/%s", tt.percent, got, tt.wantNumerator, tt.wantDenominator)
+		}
+	}
+}
+
+func TestKubernetesServiceBuilder_Build_WithSplits(t *testing.T) {
+	builder := NewKubernetesServiceBuilder(
+		"api.localhost", "http2",
+		"default", "api", "http", 8080,
+		port.IndividualListenerPort(8080),
+		"",
+	)
+	builder.Weight = 90
+	builder.Splits = []WeightedUpstream{
+		{Upstream: Upstream{ClusterName: "api_cluster_split_1", LocalPort: 10002}, Weight: 10},
+	}
+
+	result, err := builder.Build("api_cluster", 10001, 80)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	listenerComponents, ok := result.(IndividualListenerComponents)
+	if !ok {
+		t.Fatalf("expected IndividualListenerComponents, got %T", result)
+	}
+
+	// 追加バックエンドのクラスタもメインと同じプロトコル設定になることを確認
+	if len(listenerComponents.AdditionalClusters) != 1 {
+		t.Fatalf("expected 1 additional cluster, got %d", len(listenerComponents.AdditionalClusters))
+	}
+	if explicitHTTPConfig(t, listenerComponents.AdditionalClusters[0]).GetHttp2ProtocolOptions() == nil {
+		t.Error("expected http2_protocol_options for split cluster")
+	}
+
+	// ルートがweighted_clustersになることを確認
+	vhost := listenerHCM(t, listenerComponents.Listeners[0]).GetRouteConfig().GetVirtualHosts()[0]
+	route := vhost.GetRoutes()[0].GetRoute()
+	if route.GetCluster() != "" {
+		t.Error("expected no single cluster when splits are set")
+	}
+	weighted := route.GetWeightedClusters().GetClusters()
+	if len(weighted) != 2 {
+		t.Fatalf("expected 2 weighted clusters, got %d", len(weighted))
+	}
+	if weighted[0].GetName() != "api_cluster" || weighted[0].GetWeight().GetValue() != 90 {
+		t.Errorf("expected api_cluster with weight 90, got %v", weighted[0])
+	}
+	if weighted[1].GetName() != "api_cluster_split_1" || weighted[1].GetWeight().GetValue() != 10 {
+		t.Errorf("expected api_cluster_split_1 with weight 10, got %v", weighted[1])
+	}
+}
+
+func TestKubernetesServiceBuilder_Build_WithFailover(t *testing.T) {
+	builder := NewKubernetesServiceBuilder(
+		"api.localhost", "http",
+		"default", "api", "http", 8080,
+		0,
+		"tokyo",
+	)
+	builder.Failover = []Upstream{
+		{ClusterName: "api_cluster", LocalPort: 10002, Cluster: "osaka"},
+	}
+
+	result, err := builder.Build("api_cluster", 10001, 80)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	httpComponents, ok := result.(HTTPComponents)
+	if !ok {
+		t.Fatalf("expected HTTPComponents, got %T", result)
+	}
+
+	// フェイルオーバー先は別クラスタではなく同じクラスタの下位の優先度になる
+	if len(httpComponents.AdditionalClusters) != 0 {
+		t.Errorf("expected no additional clusters, got %d", len(httpComponents.AdditionalClusters))
+	}
+
+	endpoints := httpComponents.Cluster.GetLoadAssignment().GetEndpoints()
+	if len(endpoints) != 2 {
+		t.Fatalf("expected 2 priority levels, got %d", len(endpoints))
+	}
+	for i, wantPort := range []uint32{10001, 10002} {
+		if endpoints[i].GetPriority() != uint32(i) {
+			t.Errorf("expected priority %d, got %v", i, endpoints[i].GetPriority())
+		}
+		if _, p := endpointAddress(httpComponents.Cluster, i); p != wantPort {
+			t.Errorf("expected port %d at priority %d, got %v", wantPort, i, p)
+		}
+	}
+
+	healthChecks := httpComponents.Cluster.GetHealthChecks()
+	if len(healthChecks) != 1 {
+		t.Fatalf("expected 1 health check, got %v", healthChecks)
+	}
+	if healthChecks[0].GetTcpHealthCheck() == nil {
+		t.Error("expected tcp_health_check")
+	}
+}
+
+func TestKubernetesServiceBuilder_Build_WithHealthCheck(t *testing.T) {
+	tests := []struct {
+		name     string
+		protocol string
+		check    HealthCheck
+		verify   func(t *testing.T, hc *corev3.HealthCheck)
+	}{
+		{
+			name:     "HTTP",
+			protocol: "http",
+			check:    HealthCheck{Type: "http", Path: "/healthz"},
+			verify: func(t *testing.T, hc *corev3.HealthCheck) {
+				check := hc.GetHttpHealthCheck()
+				if check.GetPath() != "/healthz" || check.GetCodecClientType() != typev3.CodecClientType_HTTP1 {
+					t.Errorf("expected HTTP/1.1 check of /healthz, got %v", hc)
+				}
+			},
+		},
+		{
+			name:     "HTTP/2バックエンドはHTTP/2でチェック",
+			protocol: "http2",
+			check:    HealthCheck{Type: "http", Path: "/healthz"},
+			verify: func(t *testing.T, hc *corev3.HealthCheck) {
+				if hc.GetHttpHealthCheck().GetCodecClientType() != typev3.CodecClientType_HTTP2 {
+					t.Errorf("expected HTTP/2 check, got %v", hc)
+				}
+			},
+		},
+		{
+			name:     "gRPC",
+			protocol: "grpc",
+			check:    HealthCheck{Type: "grpc", Service: "users.v1.UserService"},
+			verify: func(t *testing.T, hc *corev3.HealthCheck) {
+				if hc.GetGrpcHealthCheck().GetServiceName() != "users.v1.UserService" {
+					t.Errorf("expected grpc_health_check, got %v", hc)
+				}
+			},
+		},
+		{
+			name:     "TCP",
+			protocol: "http",
+			check:    HealthCheck{Type: "tcp"},
+			verify: func(t *testing.T, hc *corev3.HealthCheck) {
+				if hc.GetTcpHealthCheck() == nil {
+					t.Errorf("expected tcp_health_check, got %v", hc)
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			builder := NewKubernetesServiceBuilder(
+				"api.localhost", tt.protocol,
+				"default", "api", "http", 8080,
+				0,
+				"",
+			)
+			// Failover用のTCP接続チェックは設定したヘルスチェックで置き換える
+			builder.Failover = []Upstream{{ClusterName: "api_cluster", LocalPort: 10002}}
+			tt.check.Interval = 10 * time.Second
+			tt.check.UnhealthyThreshold = 2
+			builder.HealthCheck = &tt.check
+
+			result, err := builder.Build("api_cluster", 10001, 80)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			cluster := result.(HTTPComponents).Cluster
+
+			healthChecks := cluster.GetHealthChecks()
+			if len(healthChecks) != 1 {
+				t.Fatalf("expected 1 health check, got %v", healthChecks)
+			}
+			hc := healthChecks[0]
+			if hc.GetInterval().AsDuration() != 10*time.Second || hc.GetUnhealthyThreshold().GetValue() != 2 {
+				t.Errorf("expected interval 10s and unhealthy_threshold 2, got %v", hc)
+			}
+			tt.verify(t, hc)
+
+			// 接続エラーのみでバックエンドを除外する
+			od := cluster.GetOutlierDetection()
+			if od.GetConsecutiveGatewayFailure().GetValue() != 2 || od.GetEnforcingConsecutiveGatewayFailure().GetValue() != 100 {
+				t.Errorf("expected gateway failure ejection after 2 errors, got %v", od)
+			}
+			if od.GetEnforcingConsecutive_5Xx().GetValue() != 0 {
+				t.Errorf("expected consecutive 5xx ejection to be disabled, got %v", od)
+			}
+		})
+	}
+}
+
+func TestKubernetesServiceBuilder_Build_WithLocalOverride(t *testing.T) {
+	tests := []struct {
+		name       string
+		protocol   string
+		healthPath string
+		wantHTTP   bool
+		wantCodec  typev3.CodecClientType
+	}{
+		{name: "HTTPヘルスチェック", protocol: "http", healthPath: "/healthz", wantHTTP: true},
+		{name: "gRPCはHTTP/2でヘルスチェック", protocol: "grpc", healthPath: "/healthz", wantHTTP: true, wantCodec: typev3.CodecClientType_HTTP2},
+		{name: "health_path省略時はTCP", protocol: "http"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			builder := NewKubernetesServiceBuilder(
+				"api.localhost", tt.protocol,
+				"default", "api", "http", 8080,
+				0,
+				"",
+			)
+			builder.LocalOverride = &LocalOverride{Address: "127.0.0.1", Port: 8080, HealthPath: tt.healthPath}
+
+			result, err := builder.Build("api_cluster", 10001, 80)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			cluster := result.(HTTPComponents).Cluster
+
+			endpoints := cluster.GetLoadAssignment().GetEndpoints()
+			if len(endpoints) != 2 {
+				t.Fatalf("expected 2 priority levels, got %d", len(endpoints))
+			}
+
+			// 優先度0はローカルプロセス（ヘルスチェック対象）
+			local := endpoints[0]
+			if _, p := endpointAddress(cluster, 0); local.GetPriority() != 0 || p != 8080 {
+				t.Errorf("expected local process at priority 0, got %v", local)
+			}
+			if local.GetLbEndpoints()[0].GetEndpoint().GetHealthCheckConfig() != nil {
+				t.Error("expected local process to be health checked")
+			}
+
+			// 優先度1はport-forward（ヘルスチェック対象外）
+			fallback := endpoints[1]
+			if fallback.GetPriority() != 1 {
+				t.Errorf("expected port-forward at priority 1, got %v", fallback.GetPriority())
+			}
+			hcConfig := fallback.GetLbEndpoints()[0].GetEndpoint().GetHealthCheckConfig()
+			if !hcConfig.GetDisableActiveHealthCheck() {
+				t.Errorf("expected active health check to be disabled for port-forward, got %v", hcConfig)
+			}
+
+			healthCheck := cluster.GetHealthChecks()[0]
+			if !tt.wantHTTP {
+				if healthCheck.GetTcpHealthCheck() == nil {
+					t.Fatalf("expected tcp_health_check, got %v", healthCheck)
+				}
+				return
+			}
+			check := healthCheck.GetHttpHealthCheck()
+			if check == nil {
+				t.Fatalf("expected http_health_check, got %v", healthCheck)
+			}
+			if check.GetPath() != tt.healthPath {
+				t.Errorf("expected path %q, got %v", tt.healthPath, check.GetPath())
+			}
+			if check.GetCodecClientType() != tt.wantCodec {
+				t.Errorf("expected codec_client_type %v, got %v", tt.wantCodec, check.GetCodecClientType())
+			}
+		})
+	}
+}
+
+func TestKubernetesServiceBuilder_Build_WithHeaderRoutes(t *testing.T) {
+	builder := NewKubernetesServiceBuilder(
+		"api.localhost", "http",
+		"default", "api", "http", 8080,
+		0,
+		"",
+	)
+	builder.HeaderRoutes = []HeaderRoute{
+		{
+			Header:   "X-Localmesh-Route",
+			Value:    "alice",
+			Upstream: Upstream{ClusterName: "api_cluster_header_1", LocalPort: 10002},
+		},
+		{
+			Header:   "x-localmesh-local",
+			Upstream: Upstream{ClusterName: "api_cluster_header_2", LocalPort: 8081},
+			Address:  "127.0.0.1",
+		},
+	}
+
+	result, err := builder.Build("api_cluster", 10001, 80)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	httpComponents := result.(HTTPComponents)
+
+	// ヘッダー一致ルートがデフォルトルートより前に並ぶことを確認
+	routes := httpComponents.Route.GetRoutes()
+	if len(routes) != 3 {
+		t.Fatalf("expected 3 routes, got %d", len(routes))
+	}
+
+	matcher := routes[0].GetMatch().GetHeaders()[0]
+	if matcher.GetName() != "x-localmesh-route" {
+		t.Errorf("expected lowercased header name, got %v", matcher.GetName())
+	}
+	if matcher.GetStringMatch().GetExact() != "alice" {
+		t.Errorf("expected exact match 'alice', got %v", matcher.GetStringMatch())
+	}
+	if routes[0].GetRoute().GetCluster() != "api_cluster_header_1" {
+		t.Errorf("expected cluster 'api_cluster_header_1', got %v", routes[0].GetRoute())
+	}
+
+	// 値を省略した場合はヘッダーの存在で判定
+	presentMatcher := routes[1].GetMatch().GetHeaders()[0]
+	if !presentMatcher.GetPresentMatch() {
+		t.Errorf("expected present_match, got %v", presentMatcher)
+	}
+
+	last := routes[2]
+	if len(last.GetMatch().GetHeaders()) != 0 {
+		t.Error("expected default route without header match")
+	}
+	if last.GetRoute().GetCluster() != "api_cluster" {
+		t.Errorf("expected default route to api_cluster, got %v", last.GetRoute())
+	}
+
+	// ローカルプロセスのクラスタは指定アドレスを向く
+	if len(httpComponents.AdditionalClusters) != 2 {
+		t.Fatalf("expected 2 additional clusters, got %d", len(httpComponents.AdditionalClusters))
+	}
+	if addr, p := endpointAddress(httpComponents.AdditionalClusters[1], 0); addr != "127.0.0.1" || p != 8081 {
+		t.Errorf("expected 127.0.0.1:8081, got %s:%d", addr, p)
+	}
+}
+
+// luaScript はvirtual hostのtyped_per_filter_configからLuaスクリプトを取り出す
+func luaScript(t *testing.T, perFilter map[string]*anypb.Any, name string) string {
+	t.Helper()
+	config, ok := perFilter[name]
+	if !ok {
+		t.Fatalf("expected %s in typed_per_filter_config", name)
+	}
+	return unpack[*luav3.LuaPerRoute](t, config).GetSourceCode().GetInlineString()
+}
+
+// assertHTTPFilters はHTTPフィルタの並びを検証
+func assertHTTPFilters(t *testing.T, filters []*hcmv3.HttpFilter, want []string) {
+	t.Helper()
+	if len(filters) != len(want) {
+		t.Fatalf("expected %d filters, got %d", len(want), len(filters))
+	}
+	for i, name := range want {
+		if got := filters[i].GetName(); got != name {
+			t.Errorf("filter[%d]: expected %s, got %v", i, name, got)
+		}
+	}
+}
+
+func TestKubernetesServiceBuilder_Build_WithLua(t *testing.T) {
+	script := "function envoy_on_request(request_handle)\nend\n"
+	builder := NewKubernetesServiceBuilder(
+		"api.localhost", "http",
+		"default", "api", "http", 8080,
+		0,
+		"",
+	)
+	builder.Lua = script
+	builder.RateLimit = &RateLimit{Requests: 1, Burst: 1, FillInterval: time.Second}
+
+	result, err := builder.Build("api_cluster", 10001, 80)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	httpComponents := result.(HTTPComponents)
+
+	// スクリプトはvirtual host単位で設定し、他のホストには影響しない
+	perFilter := httpComponents.Route.GetTypedPerFilterConfig()
+	if got := luaScript(t, perFilter, "envoy.filters.http.lua"); got != script {
+		t.Errorf("expected embedded script, got %v", got)
+	}
+	if _, ok := perFilter["envoy.filters.http.local_ratelimit"]; !ok {
+		t.Error("expected rate limit alongside lua in typed_per_filter_config")
+	}
+
+	// レート制限 → Lua → routerの順
+	cfg := buildConfig(t, 80, []ServiceConfig{{Builder: builder, ClusterName: "api_cluster", LocalPort: 10001}}, BuildOptions{})
+	filters := listenerHCM(t, cfg.GetStaticResources().GetListeners()[0]).GetHttpFilters()
+	assertHTTPFilters(t, filters, []string{"envoy.filters.http.local_ratelimit", "envoy.filters.http.lua", "envoy.filters.http.router"})
+	// HCMのLuaフィルタはデフォルトのスクリプトを持たない
+	if unpack[*luav3.Lua](t, filters[1].GetTypedConfig()).GetDefaultSourceCode() != nil {
+		t.Error("expected lua filter without default_source_code")
+	}
+}
+
+func TestKubernetesServiceBuilder_Build_WithHostRewrite(t *testing.T) {
+	builder := NewKubernetesServiceBuilder(
+		"api.localhost", "http",
+		"default", "api", "http", 8080,
+		0,
+		"",
+	)
+	builder.Lua = "function envoy_on_response(response_handle)\nend\n"
+	builder.RedirectHosts = []string{"api", "api.default.svc.cluster.local"}
+	builder.CookieDomains = []string{"auth.example.com"}
+
+	result, err := builder.Build("api_cluster", 10001, 8080)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	httpComponents := result.(HTTPComponents)
+
+	// 置換スクリプトはユーザーのLuaスクリプトとは別のフィルタ名で設定する
+	perFilter := httpComponents.Route.GetTypedPerFilterConfig()
+	if _, ok := perFilter["envoy.filters.http.lua"]; !ok {
+		t.Error("expected user lua script to be kept")
+	}
+	script := luaScript(t, perFilter, "envoy.filters.http.lua.host_rewrite")
+	for _, want := range []string{
+		`local redirect_hosts = {["api"] = true, ["api.default.svc.cluster.local"] = true}`,
+		`local cookie_domains = {["auth.example.com"] = true}`,
+		`local local_authority = "api.localhost:8080"`,
+		`local local_host = "api.localhost"`,
+		"function envoy_on_response(response_handle)",
+	} {
+		if !strings.Contains(script, want) {
+			t.Errorf("expected script to contain %q, got:\n%s", want, script)
+		}
+	}
+
+	// ポート80の場合はLocationにポートを付けない
+	result80, err := builder.Build("api_cluster", 10001, 80)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	port80 := result80.(HTTPComponents)
+	script80 := luaScript(t, port80.Route.GetTypedPerFilterConfig(), "envoy.filters.http.lua.host_rewrite")
+	if !strings.Contains(script80, `local local_authority = "api.localhost"`) {
+		t.Errorf("expected authority without port, got:\n%s", script80)
+	}
+
+	// ユーザーのLua → 置換 → routerの順（レスポンスは置換後のヘッダーがユーザーのスクリプトに渡る）
+	cfg := buildConfig(t, 8080, []ServiceConfig{{Builder: builder, ClusterName: "api_cluster", LocalPort: 10001}}, BuildOptions{})
+	filters := listenerHCM(t, cfg.GetStaticResources().GetListeners()[0]).GetHttpFilters()
+	assertHTTPFilters(t, filters, []string{"envoy.filters.http.lua", "envoy.filters.http.lua.host_rewrite", "envoy.filters.http.router"})
+}
@@ -0,0 +1,138 @@
+package envoy
+
+import (
+	"fmt"
+	"strings"
+
+	luav3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/lua/v3"
+	hcmv3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
+)
+
+const luaFilterName = "envoy.filters.http.lua"
+
+// luaFilter はHTTP connection manager用のLuaフィルタを生成
+// デフォルトのスクリプトを持たないため、virtual host単位でスクリプトを設定したホストのみに作用する
+func luaFilter(name string) (*hcmv3.HttpFilter, error) {
+	return httpFilter(name, &luav3.Lua{})
+}
+
+// luaPerRoute はvirtual hostに適用するLuaスクリプトの設定を生成
+func luaPerRoute(script string) *luav3.LuaPerRoute {
+	return &luav3.LuaPerRoute{
+		Override: &luav3.LuaPerRoute_SourceCode{SourceCode: inlineString(script)},
+	}
+}
+
+// hostRewriteFilterName はクラスタ側ホスト名を置換するLuaフィルタの名前
+// ユーザーのLuaスクリプトとは別のフィルタとして登録し、同じホストで併用できるようにする
+const hostRewriteFilterName = "envoy.filters.http.lua.host_rewrite"
+
+// hostRewriteScriptBody はLocation・Set-Cookieヘッダーのホスト名を置換するLuaスクリプト本体
+// redirect_hosts・cookie_domains・local_authority・local_hostは生成時に先頭で定義する
+const hostRewriteScriptBody = `
+function envoy_on_response(response_handle)
+  local headers = response_handle:headers()
+
+  local location = headers:get("location")
+  if location ~= nil and next(redirect_hosts) ~= nil then
+    local authority, rest = string.match(location, "^[Hh][Tt][Tt][Pp][Ss]?://([^/?#]+)(.*)$")
+    if authority ~= nil then
+      local host = string.gsub(authority, ":%d+$", "")
+      if redirect_hosts[string.lower(host)] then
+        headers:replace("location", "http://" .. local_authority .. rest)
+      end
+    end
+  end
+
+  if next(cookie_domains) == nil then
+    return
+  end
+  local cookies = {}
+  local changed = false
+  for key, value in pairs(headers) do
+    if key == "set-cookie" then
+      local rewritten = string.gsub(value, "([Dd][Oo][Mm][Aa][Ii][Nn]=)%.?([^;]*)", function(attr, domain)
+        if cookie_domains[string.lower(domain)] then
+          changed = true
+          return attr .. local_host
+        end
+      end)
+      table.insert(cookies, rewritten)
+    end
+  end
+  if changed then
+    headers:remove("set-cookie")
+    for _, cookie in ipairs(cookies) do
+      headers:add("set-cookie", cookie)
+    end
+  end
+end
+`
+
+// hostRewriteScript はクラスタ側ホスト名をローカルのホストに置換するLuaスクリプトを生成
+// localAuthorityはLocationヘッダーに使うhost[:port]、localHostはCookieのDomain属性に使うホスト名
+func hostRewriteScript(redirectHosts, cookieDomains []string, localAuthority, localHost string) string {
+	var b strings.Builder
+	b.WriteString("-- kubectl-localmesh: rewrite in-cluster host names to " + localAuthority + "\n")
+	b.WriteString("local redirect_hosts = " + luaSet(redirectHosts) + "\n")
+	b.WriteString("local cookie_domains = " + luaSet(cookieDomains) + "\n")
+	b.WriteString("local local_authority = " + luaQuote(localAuthority) + "\n")
+	b.WriteString("local local_host = " + luaQuote(localHost) + "\n")
+	b.WriteString(hostRewriteScriptBody)
+	return b.String()
+}
+
+// authStripFilterName はメッシュの認証情報をバックエンドに転送しないためのLuaフィルタの名前
+const authStripFilterName = "envoy.filters.http.lua.listener_auth"
+
+// authStripScriptBody はAuthorizationヘッダーがメッシュの認証情報と一致する場合のみ削除するLuaスクリプト本体
+// mesh_credentialsは生成時に先頭で定義する
+const authStripScriptBody = `
+function envoy_on_request(request_handle)
+  local headers = request_handle:headers()
+  local authorization = headers:get("authorization")
+  if authorization ~= nil and mesh_credentials[authorization] then
+    headers:remove("authorization")
+  end
+end
+`
+
+// authStripScript はメッシュの認証情報のAuthorizationヘッダーを削除するLuaスクリプトを生成
+// それ以外の値（loopbackやallowed_cidrsからのバックエンド向けの認証情報）はそのまま転送する
+func authStripScript(credentials []string) string {
+	return "-- kubectl-localmesh: remove listener_auth credentials before forwarding\n" +
+		"local mesh_credentials = " + luaSet(credentials) + "\n" +
+		authStripScriptBody
+}
+
+// luaSet は文字列のリストをLuaのセット（{["a"] = true, ...}）に変換
+func luaSet(values []string) string {
+	if len(values) == 0 {
+		return "{}"
+	}
+	entries := make([]string, len(values))
+	for i, v := range values {
+		entries[i] = "[" + luaQuote(v) + "] = true"
+	}
+	return "{" + strings.Join(entries, ", ") + "}"
+}
+
+// luaQuote は文字列をLuaの文字列リテラルに変換
+// 印字可能なASCII以外のバイトはLua 5.1（LuaJIT）でも解釈できる10進の\dddでエスケープする
+func luaQuote(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '"' || c == '\\':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case c < 0x20 || c >= 0x7f:
+			fmt.Fprintf(&b, "\\%03d", c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
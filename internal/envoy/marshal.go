@@ -0,0 +1,135 @@
+package envoy
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	bootstrapv3 "github.com/envoyproxy/go-control-plane/envoy/config/bootstrap/v3"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/known/anypb"
+	"gopkg.in/yaml.v3"
+)
+
+// typedConfig はメッセージをtyped_config用のAnyに変換
+func typedConfig(m proto.Message) (*anypb.Any, error) {
+	a, err := anypb.New(m)
+	if err != nil {
+		return nil, fmt.Errorf("failed to pack %s: %w", m.ProtoReflect().Descriptor().FullName(), err)
+	}
+	return a, nil
+}
+
+// MarshalYAML はBootstrapを検証し、Envoyが読み込めるYAMLに変換
+// protojson（proto名のsnake_case）で出力したJSONを、キーをソートしたYAMLにする
+func MarshalYAML(bootstrap *bootstrapv3.Bootstrap) ([]byte, error) {
+	if err := Validate(bootstrap); err != nil {
+		return nil, err
+	}
+
+	b, err := protojson.MarshalOptions{UseProtoNames: true}.Marshal(bootstrap)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal envoy config: %w", err)
+	}
+	// float64に変換すると大きな整数が指数表記になるため、json.Numberで受け取る
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, fmt.Errorf("failed to marshal envoy config: %w", err)
+	}
+	return yaml.Marshal(yamlNumbers(v))
+}
+
+// yamlNumbers はjson.Numberを整数・浮動小数点数に変換する
+// yaml.v3はjson.Numberを文字列として出力するため
+func yamlNumbers(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for k, e := range v {
+			v[k] = yamlNumbers(e)
+		}
+	case []any:
+		for i, e := range v {
+			v[i] = yamlNumbers(e)
+		}
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		if f, err := v.Float64(); err == nil {
+			return f
+		}
+	}
+	return v
+}
+
+// validator はprotoc-gen-validateが生成するValidateメソッドを持つメッセージ
+type validator interface {
+	Validate() error
+}
+
+// Validate はBootstrapとtyped_configに埋め込んだ拡張の設定を検証
+// Bootstrap.Validate()はAnyの中身を検証しないため、Anyを展開して再帰的に検証する
+func Validate(bootstrap *bootstrapv3.Bootstrap) error {
+	if err := validateMessage(bootstrap); err != nil {
+		return fmt.Errorf("invalid envoy config: %w", err)
+	}
+	return nil
+}
+
+// validateMessage はメッセージのValidate()を呼び出し、含まれるAnyを展開して検証
+func validateMessage(m proto.Message) error {
+	if v, ok := m.(validator); ok {
+		if err := v.Validate(); err != nil {
+			return err
+		}
+	}
+	return validateAnyFields(m.ProtoReflect())
+}
+
+// validateAnyFields はメッセージのフィールドを辿り、Anyを展開して検証
+func validateAnyFields(m protoreflect.Message) error {
+	var err error
+	m.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		switch {
+		case fd.IsMap():
+			if fd.MapValue().Message() == nil {
+				return true
+			}
+			v.Map().Range(func(_ protoreflect.MapKey, mv protoreflect.Value) bool {
+				err = validateValue(mv.Message())
+				return err == nil
+			})
+		case fd.Message() == nil:
+			return true
+		case fd.IsList():
+			list := v.List()
+			for i := 0; i < list.Len() && err == nil; i++ {
+				err = validateValue(list.Get(i).Message())
+			}
+		default:
+			err = validateValue(v.Message())
+		}
+		return err == nil
+	})
+	return err
+}
+
+// validateValue はAnyの場合は展開して検証し、それ以外は子のフィールドを辿る
+func validateValue(m protoreflect.Message) error {
+	a, ok := m.Interface().(*anypb.Any)
+	if !ok {
+		return validateAnyFields(m)
+	}
+	inner, err := a.UnmarshalNew()
+	if err != nil {
+		return fmt.Errorf("%s: %w", a.GetTypeUrl(), err)
+	}
+	if err := validateMessage(inner); err != nil {
+		return fmt.Errorf("%s: %w", a.GetTypeUrl(), err)
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+package envoy
+
+import (
+	"strings"
+	"testing"
+
+	"google.golang.org/protobuf/types/known/wrapperspb"
+	"gopkg.in/yaml.v3"
+)
+
+func TestMarshalYAML(t *testing.T) {
+	builder := NewKubernetesServiceBuilder(
+		"api.localhost", "http",
+		"default", "api", "http", 8080,
+		0,
+		"",
+	)
+	cfg := buildConfig(t, 80, []ServiceConfig{{Builder: builder, ClusterName: "api_cluster", LocalPort: 10001}}, BuildOptions{})
+
+	b, err := MarshalYAML(cfg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Envoyが読み込むproto名（snake_case）で出力し、typed_configは@typeを持つ
+	out := string(b)
+	for _, want := range []string{
+		"static_resources:",
+		"connect_timeout: 1s",
+		"'@type': type.googleapis.com/envoy.extensions.filters.network.http_connection_manager.v3.HttpConnectionManager",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected output to contain %q, got:\n%s", want, out)
+		}
+	}
+
+	var v map[string]any
+	if err := yaml.Unmarshal(b, &v); err != nil {
+		t.Fatalf("expected valid yaml: %v", err)
+	}
+}
+
+func TestMarshalYAML_LargeInteger(t *testing.T) {
+	builder := NewKubernetesServiceBuilder("api.localhost", "http", "default", "api", "http", 8080, 0, "")
+	cfg := buildConfig(t, 80, []ServiceConfig{{Builder: builder, ClusterName: "api_cluster", LocalPort: 10001}}, BuildOptions{})
+	cfg.GetStaticResources().GetListeners()[0].PerConnectionBufferLimitBytes = wrapperspb.UInt32(1048576)
+
+	b, err := MarshalYAML(cfg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// 指数表記（1.048576e+06）にしない
+	if !strings.Contains(string(b), "per_connection_buffer_limit_bytes: 1048576\n") {
+		t.Errorf("expected integer output, got:\n%s", b)
+	}
+}
+
+func TestMarshalYAML_ProtoJSON(t *testing.T) {
+	builder := NewKubernetesServiceBuilder("api.localhost", "http", "default", "api", "http", 8080, 0, "")
+	cfg := buildConfig(t, 80, []ServiceConfig{{Builder: builder, ClusterName: "api_cluster", LocalPort: 10001}}, BuildOptions{})
+
+	b, err := MarshalYAML(cfg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// protojsonの表現（Durationは文字列、ラッパーは値のみ、int64は文字列）で出力する
+	out := string(b)
+	for _, want := range []string{
+		"refresh_interval: 0.250s\n",
+		"enable_reuse_port: false\n",
+		"max_active_downstream_connections: \"5000\"\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected output to contain %q, got:\n%s", want, out)
+		}
+	}
+	// 既定値の列挙型は出力しない
+	if strings.Contains(out, "codec_type:") {
+		t.Errorf("expected default codec_type to be omitted, got:\n%s", out)
+	}
+
+	// Envoyと同じくprotojsonで読み戻せる
+	var v map[string]any
+	if err := yaml.Unmarshal(b, &v); err != nil {
+		t.Fatalf("expected valid yaml: %v", err)
+	}
+}
+
+func TestMarshalYAML_Invalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		modify  func(*KubernetesServiceBuilder)
+		wantErr string
+	}{
+		{
+			// typed_config（Any）の中身も検証する
+			name: "トークンバケットが空",
+			modify: func(b *KubernetesServiceBuilder) {
+				b.RateLimit = &RateLimit{Requests: 1}
+			},
+			wantErr: "LocalRateLimit",
+		},
+		{
+			name: "ポート番号が範囲外",
+			modify: func(b *KubernetesServiceBuilder) {
+				b.LocalOverride = &LocalOverride{Address: "127.0.0.1", Port: 70000}
+			},
+			wantErr: "PortValue",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			builder := NewKubernetesServiceBuilder(
+				"api.localhost", "http",
+				"default", "api", "http", 8080,
+				0,
+				"",
+			)
+			tt.modify(builder)
+			cfg := buildConfig(t, 80, []ServiceConfig{{Builder: builder, ClusterName: "api_cluster", LocalPort: 10001}}, BuildOptions{})
+
+			_, err := MarshalYAML(cfg)
+			if err == nil {
+				t.Fatal("expected error, got nil")
+			}
+			if !strings.Contains(err.Error(), "invalid envoy config") || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got %q", tt.wantErr, err.Error())
+			}
+		})
+	}
+}
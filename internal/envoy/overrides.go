@@ -0,0 +1,136 @@
+package envoy
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	bootstrapv3 "github.com/envoyproxy/go-control-plane/envoy/config/bootstrap/v3"
+	"google.golang.org/protobuf/encoding/protojson"
+	jsonpatch "gopkg.in/evanphx/json-patch.v4"
+)
+
+// Override は生成したEnvoy設定に適用するユーザー定義のパッチ
+// PatchがJSONオブジェクトの場合はJSON Merge Patch（RFC 7396）、
+// 配列の場合はJSON Patch（RFC 6902）として適用する
+type Override struct {
+	Listener string // 対象のリスナー名（ListenerとClusterが空の場合はbootstrap全体）
+	Cluster  string // 対象のクラスタ名
+	Patch    []byte // パッチ（JSON）
+}
+
+// ApplyOverrides はパッチを順に適用したEnvoy設定を返す（元の設定は変更しない）
+// 各パッチの適用後に、Envoyのbootstrapとして解釈でき検証を通ることを確認する
+func ApplyOverrides(cfg *bootstrapv3.Bootstrap, overrides []Override) (*bootstrapv3.Bootstrap, error) {
+	if len(overrides) == 0 {
+		return cfg, nil
+	}
+
+	b, err := protojson.MarshalOptions{UseProtoNames: true}.Marshal(cfg)
+	if err != nil {
+		return nil, err
+	}
+	var doc map[string]any
+	if err := json.Unmarshal(b, &doc); err != nil {
+		return nil, err
+	}
+
+	patched := cfg
+	for i, o := range overrides {
+		doc, err = applyOverride(doc, o)
+		if err != nil {
+			return nil, fmt.Errorf("envoy_overrides[%d]: %w", i, err)
+		}
+		if patched, err = toBootstrap(doc); err != nil {
+			return nil, fmt.Errorf("envoy_overrides[%d]: %w", i, err)
+		}
+	}
+	return patched, nil
+}
+
+// applyOverride は対象（bootstrap全体・リスナー・クラスタ）にパッチを適用する
+func applyOverride(doc map[string]any, o Override) (map[string]any, error) {
+	switch {
+	case o.Listener != "":
+		return doc, patchResource(doc, "listeners", "listener", o.Listener, o.Patch)
+	case o.Cluster != "":
+		return doc, patchResource(doc, "clusters", "cluster", o.Cluster, o.Patch)
+	}
+
+	var patched map[string]any
+	if err := patchObject(doc, o.Patch, &patched); err != nil {
+		return nil, err
+	}
+	return patched, nil
+}
+
+// patchResource はstatic_resources内の名前が一致するリソースにパッチを適用する
+func patchResource(doc map[string]any, field, kind, name string, patch []byte) error {
+	static, _ := doc["static_resources"].(map[string]any)
+	resources, _ := static[field].([]any)
+	for i, r := range resources {
+		resource, ok := r.(map[string]any)
+		if !ok || resource["name"] != name {
+			continue
+		}
+		var patched map[string]any
+		if err := patchObject(resource, patch, &patched); err != nil {
+			return err
+		}
+		resources[i] = patched
+		return nil
+	}
+	return fmt.Errorf("%s %q not found", kind, name)
+}
+
+// patchObject はJSONオブジェクトにパッチを適用し、結果をoutに格納する
+func patchObject(obj any, patch []byte, out *map[string]any) error {
+	doc, err := json.Marshal(obj)
+	if err != nil {
+		return err
+	}
+
+	var patched []byte
+	switch p := bytes.TrimSpace(patch); {
+	case bytes.HasPrefix(p, []byte("{")):
+		patched, err = jsonpatch.MergePatch(doc, p)
+		if err != nil {
+			return fmt.Errorf("failed to apply merge patch: %w", err)
+		}
+	case bytes.HasPrefix(p, []byte("[")):
+		ops, err := jsonpatch.DecodePatch(p)
+		if err != nil {
+			return fmt.Errorf("invalid JSON patch: %w", err)
+		}
+		patched, err = ops.Apply(doc)
+		if err != nil {
+			return fmt.Errorf("failed to apply JSON patch: %w", err)
+		}
+	default:
+		return fmt.Errorf("patch must be an object (JSON merge patch) or a list (JSON patch)")
+	}
+
+	if err := json.Unmarshal(patched, out); err != nil {
+		return err
+	}
+	if *out == nil {
+		return fmt.Errorf("patch must not remove the whole object")
+	}
+	return nil
+}
+
+// toBootstrap はパッチ適用後のJSONをEnvoyのbootstrapとして解釈し、検証する
+func toBootstrap(doc map[string]any) (*bootstrapv3.Bootstrap, error) {
+	b, err := json.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+	cfg := &bootstrapv3.Bootstrap{}
+	if err := protojson.Unmarshal(b, cfg); err != nil {
+		return nil, fmt.Errorf("patched config is not a valid envoy bootstrap: %w", err)
+	}
+	if err := Validate(cfg); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
@@ -0,0 +1,152 @@
+package envoy
+
+import (
+	"strings"
+	"testing"
+
+	bufferv3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/buffer/v3"
+	"google.golang.org/protobuf/proto"
+)
+
+func overridesTestConfig() []ServiceConfig {
+	builder := NewKubernetesServiceBuilder("api.localhost", "http", "default", "api", "http", 8080, 0, "")
+	return []ServiceConfig{{Builder: builder, ClusterName: "api_cluster", LocalPort: 10001}}
+}
+
+func TestApplyOverrides(t *testing.T) {
+	cfg := buildConfig(t, 80, overridesTestConfig(), BuildOptions{})
+	original := proto.Clone(cfg)
+
+	patched, err := ApplyOverrides(cfg, []Override{
+		// bootstrap全体へのJSON Merge Patch
+		{Patch: []byte(`{"stats_flush_interval": "10s"}`)},
+		// クラスタへのJSON Merge Patch
+		{Cluster: "api_cluster", Patch: []byte(`{"connect_timeout": "5s", "per_connection_buffer_limit_bytes": 65536}`)},
+		// リスナーへのJSON Patch（生成しない拡張のtyped_configを追加）
+		{Listener: "listener_http", Patch: []byte(`[
+			{"op": "add", "path": "/per_connection_buffer_limit_bytes", "value": 32768}
+		]`)},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got := patched.GetStatsFlushInterval().AsDuration().String(); got != "10s" {
+		t.Errorf("expected stats_flush_interval 10s, got %s", got)
+	}
+	cluster := patched.GetStaticResources().GetClusters()[0]
+	if got := cluster.GetConnectTimeout().AsDuration().String(); got != "5s" {
+		t.Errorf("expected connect_timeout 5s, got %s", got)
+	}
+	if got := cluster.GetPerConnectionBufferLimitBytes().GetValue(); got != 65536 {
+		t.Errorf("expected cluster buffer limit 65536, got %d", got)
+	}
+	// Merge Patchで指定していないフィールドは保持される
+	if cluster.GetLoadAssignment() == nil {
+		t.Error("expected load_assignment to be kept")
+	}
+	if got := patched.GetStaticResources().GetListeners()[0].GetPerConnectionBufferLimitBytes().GetValue(); got != 32768 {
+		t.Errorf("expected listener buffer limit 32768, got %d", got)
+	}
+
+	if !proto.Equal(cfg, original) {
+		t.Error("expected original config to be unchanged")
+	}
+}
+
+func TestApplyOverrides_TypedConfig(t *testing.T) {
+	cfg := buildConfig(t, 80, overridesTestConfig(), BuildOptions{})
+
+	// 生成しない拡張（buffer）もroute前に追加できる
+	patched, err := ApplyOverrides(cfg, []Override{{
+		Listener: "listener_http",
+		Patch: []byte(`[{
+			"op": "add",
+			"path": "/filter_chains/0/filters/0/typed_config/http_filters/0",
+			"value": {
+				"name": "envoy.filters.http.buffer",
+				"typed_config": {
+					"@type": "type.googleapis.com/envoy.extensions.filters.http.buffer.v3.Buffer",
+					"max_request_bytes": 1048576
+				}
+			}
+		}]`),
+	}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	filters := listenerHCM(t, patched.GetStaticResources().GetListeners()[0]).GetHttpFilters()
+	assertHTTPFilters(t, filters, []string{"envoy.filters.http.buffer", "envoy.filters.http.router"})
+	buffer := unpack[*bufferv3.Buffer](t, filters[0].GetTypedConfig())
+	if buffer.GetMaxRequestBytes().GetValue() != 1048576 {
+		t.Errorf("expected max_request_bytes 1048576, got %v", buffer.GetMaxRequestBytes())
+	}
+}
+
+func TestApplyOverrides_None(t *testing.T) {
+	cfg := buildConfig(t, 80, overridesTestConfig(), BuildOptions{})
+	patched, err := ApplyOverrides(cfg, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if patched != cfg {
+		t.Error("expected config to be returned as is")
+	}
+}
+
+func TestApplyOverrides_Invalid(t *testing.T) {
+	tests := []struct {
+		name     string
+		override Override
+		wantErr  string
+	}{
+		{
+			name:     "存在しないリスナー",
+			override: Override{Listener: "missing", Patch: []byte(`{}`)},
+			wantErr:  `envoy_overrides[0]: listener "missing" not found`,
+		},
+		{
+			name:     "存在しないパスの削除",
+			override: Override{Patch: []byte(`[{"op": "remove", "path": "/missing"}]`)},
+			wantErr:  "failed to apply JSON patch",
+		},
+		{
+			name:     "不正なJSON Patch操作",
+			override: Override{Patch: []byte(`[{"op": "unknown", "path": "/admin"}]`)},
+			wantErr:  "failed to apply JSON patch",
+		},
+		{
+			name:     "パッチがオブジェクト・配列以外",
+			override: Override{Patch: []byte(`"string"`)},
+			wantErr:  "patch must be an object (JSON merge patch) or a list (JSON patch)",
+		},
+		{
+			name:     "bootstrapに存在しないフィールド",
+			override: Override{Patch: []byte(`{"unknown_field": 1}`)},
+			wantErr:  "patched config is not a valid envoy bootstrap",
+		},
+		{
+			name:     "解決できないtyped_config",
+			override: Override{Cluster: "api_cluster", Patch: []byte(`{"typed_extension_protocol_options": {"x": {"@type": "type.googleapis.com/unknown.Type"}}}`)},
+			wantErr:  "patched config is not a valid envoy bootstrap",
+		},
+		{
+			name:     "検証に失敗する値",
+			override: Override{Cluster: "api_cluster", Patch: []byte(`{"connect_timeout": "-1s"}`)},
+			wantErr:  "invalid envoy config",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ApplyOverrides(buildConfig(t, 80, overridesTestConfig(), BuildOptions{}), []Override{tt.override})
+			if err == nil {
+				t.Fatal("expected error, got nil")
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package envoy
+
+import (
+	"time"
+
+	accesslogv3 "github.com/envoyproxy/go-control-plane/envoy/config/accesslog/v3"
+	corev3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	localratelimitv3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/local_ratelimit/v3"
+	hcmv3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
+	typev3 "github.com/envoyproxy/go-control-plane/envoy/type/v3"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+const (
+	localRateLimitFilterName = "envoy.filters.http.local_ratelimit"
+	localRateLimitStatPrefix = "localmesh_rate_limit"
+
+	// RateLimitedBody はレート制限で拒否したリクエストに返すレスポンスボディ
+	RateLimitedBody = "rate limited by kubectl-localmesh: too many requests for this host (see rate_limit in your localmesh config)\n"
+)
+
+// RateLimit はトークンバケット方式のレート制限
+type RateLimit struct {
+	Requests     int // FillIntervalごとに補充するトークン数
+	Burst        int // バケットの最大トークン数
+	FillInterval time.Duration
+}
+
+// localRateLimitConfig はlocal_ratelimitの設定を生成
+// limitがnilの場合はtoken_bucketを持たず、何も制限しない
+func localRateLimitConfig(limit *RateLimit) *localratelimitv3.LocalRateLimit {
+	config := &localratelimitv3.LocalRateLimit{
+		StatPrefix: localRateLimitStatPrefix,
+	}
+	if limit == nil {
+		return config
+	}
+	config.TokenBucket = &typev3.TokenBucket{
+		MaxTokens:     uint32(limit.Burst),
+		TokensPerFill: wrapperspb.UInt32(uint32(limit.Requests)),
+		FillInterval:  durationpb.New(limit.FillInterval),
+	}
+	// filter_enabled/filter_enforcedを省略するとフィルタは何もしない
+	config.FilterEnabled = &corev3.RuntimeFractionalPercent{
+		RuntimeKey:   "local_rate_limit_enabled",
+		DefaultValue: fractionalPercent(100),
+	}
+	config.FilterEnforced = &corev3.RuntimeFractionalPercent{
+		RuntimeKey:   "local_rate_limit_enforced",
+		DefaultValue: fractionalPercent(100),
+	}
+	return config
+}
+
+// rateLimitLocalReplyConfig はレート制限による429応答のボディを差し替える設定を生成
+// local_reply_configはEnvoy自身が生成した応答にのみ適用されるため、バックエンドの429はそのまま返る
+func rateLimitLocalReplyConfig() *hcmv3.LocalReplyConfig {
+	return &hcmv3.LocalReplyConfig{
+		Mappers: []*hcmv3.ResponseMapper{
+			{
+				Filter: &accesslogv3.AccessLogFilter{
+					FilterSpecifier: &accesslogv3.AccessLogFilter_StatusCodeFilter{
+						StatusCodeFilter: &accesslogv3.StatusCodeFilter{
+							Comparison: &accesslogv3.ComparisonFilter{
+								Op: accesslogv3.ComparisonFilter_EQ,
+								Value: &corev3.RuntimeUInt32{
+									DefaultValue: 429,
+									RuntimeKey:   "local_rate_limit_status_code",
+								},
+							},
+						},
+					},
+				},
+				Body: inlineString(RateLimitedBody),
+			},
+		},
+	}
+}
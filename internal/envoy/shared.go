@@ -0,0 +1,104 @@
+package envoy
+
+import (
+	accesslogv3 "github.com/envoyproxy/go-control-plane/envoy/config/accesslog/v3"
+	corev3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	listenerv3 "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
+	routev3 "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+
+	"github.com/usadamasa/kubectl-localmesh/internal/port"
+)
+
+// NamedListener はサービスを接続する共有HTTPリスナー
+// Nameが空の場合はlistener_portの共通HTTPリスナーを表す（Portは使用しない）
+type NamedListener struct {
+	Name string
+	Port port.ListenerPort
+}
+
+// EffectivePort はリスナーのポートを返す（共通HTTPリスナーの場合はlistenerPort）
+func (l NamedListener) EffectivePort(listenerPort port.ListenerPort) port.ListenerPort {
+	if l.Name == "" {
+		return listenerPort
+	}
+	return l.Port
+}
+
+// sharedHTTPListener は複数のホストで共有するHTTPリスナーのvirtual hostとホスト単位の設定
+type sharedHTTPListener struct {
+	name       string // 空の場合は共通HTTPリスナー
+	port       int
+	routes     []*routev3.VirtualHost
+	host       hostFilters              // virtual host単位のフィルタ
+	accessLogs []*accesslogv3.AccessLog // サービス単位のアクセスログ
+}
+
+// addHost はKubernetesサービスのvirtual hostとホスト単位の設定を追加する
+func (l *sharedHTTPListener) addHost(b *KubernetesServiceBuilder, vh *routev3.VirtualHost) error {
+	l.routes = append(l.routes, vh)
+	if b.RateLimit != nil {
+		l.host.rateLimit = true
+	}
+	if b.Lua != "" {
+		l.host.lua = true
+	}
+	if b.rewritesHosts() {
+		l.host.rewrite = true
+	}
+	if b.AccessLog != nil {
+		log, err := httpAccessLog(b.AccessLog, authorityFilter(b.Host))
+		if err != nil {
+			return err
+		}
+		l.accessLogs = append(l.accessLogs, log)
+	}
+	return nil
+}
+
+// build はBuildOptionsのバインドアドレスにリスナーを生成する
+// 共通HTTPリスナーはlistener_http、名前付きリスナーはlistener_http_<名前>とする
+func (l *sharedHTTPListener) build(opts BuildOptions) (*listenerv3.Listener, error) {
+	name, statPrefix, routeName := "listener_http", "ingress_http", "local_route"
+	if l.name != "" {
+		name, statPrefix, routeName = "listener_http_"+l.name, "ingress_http_"+l.name, "route_http_"+l.name
+	}
+	hcm := httpConnectionManager(statPrefix, routeName, l.routes)
+	hcm.Http2ProtocolOptions = &corev3.Http2ProtocolOptions{}
+	if err := applyListenerOptions(hcm, opts, l.host, l.accessLogs); err != nil {
+		return nil, err
+	}
+	return httpListener(name, opts.BindAddresses, l.port, hcm)
+}
+
+// namedListeners は名前付きの共有HTTPリスナーを最初に接続された順にまとめる
+type namedListeners struct {
+	order     []string
+	listeners map[string]*sharedHTTPListener
+}
+
+// get は名前付きリスナーを返す（未登録の場合は追加する）
+func (n *namedListeners) get(l NamedListener) *sharedHTTPListener {
+	shared, ok := n.listeners[l.Name]
+	if !ok {
+		if n.listeners == nil {
+			n.listeners = make(map[string]*sharedHTTPListener)
+		}
+		shared = &sharedHTTPListener{name: l.Name, port: int(l.Port)}
+		n.listeners[l.Name] = shared
+		n.order = append(n.order, l.Name)
+	}
+	return shared
+}
+
+// all はリスナーを追加順に生成する
+func (n *namedListeners) all(opts BuildOptions) ([]*listenerv3.Listener, error) {
+	var listeners []*listenerv3.Listener
+	for _, name := range n.order {
+		l, err := n.listeners[name].build(opts)
+		if err != nil {
+			return nil, err
+		}
+		listeners = append(listeners, l)
+	}
+	return listeners, nil
+}
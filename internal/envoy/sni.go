@@ -0,0 +1,68 @@
+package envoy
+
+import (
+	"fmt"
+	"strings"
+
+	listenerv3 "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
+	tlsinspectorv3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/listener/tls_inspector/v3"
+)
+
+// tlsInspectorName はClientHelloからSNIを読み取るリスナーフィルタ
+const tlsInspectorName = "envoy.filters.listener.tls_inspector"
+
+// sniListeners はSNIで振り分けるTCPサービスのフィルタチェーンを、アドレス・ポートごとに1つのリスナーへまとめる
+// リスナーは最初に追加された順に並べる
+type sniListeners struct {
+	order     []string
+	listeners map[string]*listenerv3.Listener // キーは"address:port"
+}
+
+// add はサービスのリスナーのフィルタチェーンを、同じアドレス・ポートの共有リスナーに追加する
+func (s *sniListeners) add(l *listenerv3.Listener) {
+	addr := l.GetAddress().GetSocketAddress()
+	key := fmt.Sprintf("%s:%d", addr.GetAddress(), addr.GetPortValue())
+
+	shared, ok := s.listeners[key]
+	if !ok {
+		if s.listeners == nil {
+			s.listeners = make(map[string]*listenerv3.Listener)
+		}
+		shared = &listenerv3.Listener{
+			Name:            "listener_sni_" + strings.NewReplacer(".", "_", ":", "_").Replace(key),
+			Address:         l.GetAddress(),
+			EnableReusePort: l.GetEnableReusePort(),
+			ListenerFilters: l.GetListenerFilters(),
+		}
+		s.listeners[key] = shared
+		s.order = append(s.order, key)
+	}
+	shared.FilterChains = append(shared.FilterChains, l.GetFilterChains()...)
+}
+
+// all は共有リスナーを追加順に返す
+func (s *sniListeners) all() []*listenerv3.Listener {
+	var listeners []*listenerv3.Listener
+	for _, key := range s.order {
+		listeners = append(listeners, s.listeners[key])
+	}
+	return listeners
+}
+
+// matchServerNames はリスナーのフィルタチェーンをSNIのサーバー名で選択するよう設定する
+func matchServerNames(l *listenerv3.Listener, serverNames []string) error {
+	inspector, err := typedConfig(&tlsinspectorv3.TlsInspector{})
+	if err != nil {
+		return err
+	}
+	l.ListenerFilters = []*listenerv3.ListenerFilter{
+		{
+			Name:       tlsInspectorName,
+			ConfigType: &listenerv3.ListenerFilter_TypedConfig{TypedConfig: inspector},
+		},
+	}
+	for _, chain := range l.FilterChains {
+		chain.FilterChainMatch = &listenerv3.FilterChainMatch{ServerNames: serverNames}
+	}
+	return nil
+}
@@ -1,16 +1,35 @@
 package envoy
 
-import "github.com/usadamasa/kubectl-localmesh/internal/port"
+import (
+	"fmt"
+
+	listenerv3 "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
+	tcpproxyv3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/tcp_proxy/v3"
+
+	"github.com/usadamasa/kubectl-localmesh/internal/port"
+)
 
 // TCPServiceBuilder はTCP Service用のEnvoy設定ビルダー
 type TCPServiceBuilder struct {
 	Host       string
 	ListenPort port.TCPPort // TCPリスナーの独立ポート
 	ListenAddr string       // バインドするIPアドレス（127.0.0.x）
-	// メタデータ（ログ・診断用、Envoy設定生成には使用しない）
+	// メタデータ（ログ・診断用、Envoy設定ではクラスタのmetadataにのみ使用）
 	SSHBastion string
 	TargetHost string
 	TargetPort port.TCPPort
+	// AccessLog はこのサービスへの接続を記録するアクセスログ
+	AccessLog *AccessLog
+	// HealthCheck はSSH tunnelのアクティブヘルスチェック（TCP接続のみ）
+	HealthCheck *HealthCheck
+	// ServerNames はSNIで振り分ける場合のサーバー名
+	// 空でない場合、同じアドレス・ポートのサービスと1つのリスナーを共有する
+	ServerNames []string
+	// Protocol はデータベースのプロトコル（postgres|mysql）
+	// 指定した場合はtcp_proxyの前にpostgres_proxy・mysql_proxyを挿入してクエリ統計を収集する
+	Protocol string
+	// Aliases はHostと同じListenAddrに割り当てる別名（/etc/hostsのみで使用し、Envoy設定には影響しない）
+	Aliases []string
 }
 
 // NewTCPServiceBuilder はTCPServiceBuilderを生成
@@ -31,63 +50,59 @@ func (b *TCPServiceBuilder) GetListenAddr() string {
 }
 
 // Build はTCPサービスの設定コンポーネントを生成
-func (b *TCPServiceBuilder) Build(clusterName string, localPort int) TCPComponents {
+func (b *TCPServiceBuilder) Build(clusterName string, localPort int) (TCPComponents, error) {
+	return b.build(clusterName, localPort, BuildOptions{})
+}
+
+// build はBuildOptionsを反映してTCPサービスの設定コンポーネントを生成
+func (b *TCPServiceBuilder) build(clusterName string, localPort int, opts BuildOptions) (TCPComponents, error) {
 	// クラスタ設定（TCPクラスタはHTTPプロトコルオプション不要）
-	cluster := map[string]any{
-		"name":            clusterName,
-		"type":            "STATIC",
-		"connect_timeout": "1s",
-		"load_assignment": map[string]any{
-			"cluster_name": clusterName,
-			"endpoints": []any{
-				map[string]any{
-					"lb_endpoints": []any{
-						map[string]any{
-							"endpoint": map[string]any{
-								"address": map[string]any{
-									"socket_address": map[string]any{
-										"address":    "127.0.0.1",
-										"port_value": localPort,
-									},
-								},
-							},
-						},
-					},
-				},
-			},
-		},
+	cluster := staticCluster(clusterName, "127.0.0.1", localPort)
+	// アクセスログがある場合のみ、ログで参照する接続先をmetadataに記録する
+	if opts.AccessLog != nil || b.AccessLog != nil {
+		setBackendMetadata(cluster, fmt.Sprintf("%s @ %s:%d", b.SSHBastion, b.TargetHost, b.TargetPort))
+	}
+	if b.HealthCheck != nil {
+		applyHealthCheck(cluster, b.HealthCheck, "tcp")
 	}
 
-	// TCPリスナー設定
-	tcpListener := map[string]any{
-		"name": "listener_tcp_" + clusterName,
-		"address": map[string]any{
-			"socket_address": map[string]any{
-				"address":    b.ListenAddr,
-				"port_value": int(b.ListenPort),
-			},
-		},
-		"enable_reuse_port": map[string]any{"value": false},
-		"filter_chains": []any{
-			map[string]any{
-				"filters": []any{
-					map[string]any{
-						"name": "envoy.filters.network.tcp_proxy",
-						"typed_config": map[string]any{
-							"@type":       "type.googleapis.com/envoy.extensions.filters.network.tcp_proxy.v3.TcpProxy",
-							"stat_prefix": "tcp_" + clusterName,
-							"cluster":     clusterName,
-						},
-					},
-				},
-			},
-		},
+	tcpProxy := &tcpproxyv3.TcpProxy{
+		StatPrefix:       "tcp_" + clusterName,
+		ClusterSpecifier: &tcpproxyv3.TcpProxy_Cluster{Cluster: clusterName},
+	}
+	for _, a := range []*AccessLog{opts.AccessLog, b.AccessLog} {
+		if a == nil {
+			continue
+		}
+		log, err := tcpAccessLog(a, b.Host)
+		if err != nil {
+			return TCPComponents{}, err
+		}
+		tcpProxy.AccessLog = append(tcpProxy.AccessLog, log)
 	}
 
+	// TCPリスナー設定
+	l, err := listener("listener_tcp_"+clusterName, b.ListenAddr, int(b.ListenPort), "envoy.filters.network.tcp_proxy", tcpProxy)
+	if err != nil {
+		return TCPComponents{}, err
+	}
+	filter, err := databaseFilter(b.Protocol, b.Host)
+	if err != nil {
+		return TCPComponents{}, err
+	}
+	if filter != nil {
+		chain := l.FilterChains[0]
+		chain.Filters = append([]*listenerv3.Filter{filter}, chain.Filters...)
+	}
+	if len(b.ServerNames) > 0 {
+		if err := matchServerNames(l, b.ServerNames); err != nil {
+			return TCPComponents{}, err
+		}
+	}
 	return TCPComponents{
 		Cluster:  cluster,
-		Listener: tcpListener,
-	}
+		Listener: l,
+	}, nil
 }
 
 // GetHost はホスト名を取得
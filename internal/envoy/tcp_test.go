@@ -1,7 +1,13 @@
 package envoy
 
 import (
+	"strings"
 	"testing"
+	"time"
+
+	mysqlproxyv3 "github.com/envoyproxy/go-control-plane/contrib/envoy/extensions/filters/network/mysql_proxy/v3"
+	postgresproxyv3alpha "github.com/envoyproxy/go-control-plane/contrib/envoy/extensions/filters/network/postgres_proxy/v3alpha"
+	listenerv3 "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
 
 	"github.com/usadamasa/kubectl-localmesh/internal/port"
 )
@@ -17,25 +23,23 @@ func TestTCPServiceBuilder_Build(t *testing.T) {
 			port.TCPPort(5432),
 		)
 
-		components := builder.Build("tcp_db", 12345)
+		components, err := builder.Build("tcp_db", 12345)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
 
 		// リスナー設定を確認
-		listener := components.Listener
-		address, ok := listener["address"].(map[string]any)
-		if !ok {
-			t.Fatal("address not found in listener")
-		}
-		socketAddr, ok := address["socket_address"].(map[string]any)
-		if !ok {
-			t.Fatal("socket_address not found in address")
+		socketAddr := components.Listener.GetAddress().GetSocketAddress()
+		if socketAddr == nil {
+			t.Fatal("socket_address not found in listener")
 		}
 
 		// ListenAddrが使用されていることを確認
-		if socketAddr["address"] != "127.0.0.5" {
-			t.Errorf("expected address 127.0.0.5, got %v", socketAddr["address"])
+		if socketAddr.GetAddress() != "127.0.0.5" {
+			t.Errorf("expected address 127.0.0.5, got %v", socketAddr.GetAddress())
 		}
-		if socketAddr["port_value"] != 5432 {
-			t.Errorf("expected port_value 5432, got %v", socketAddr["port_value"])
+		if socketAddr.GetPortValue() != 5432 {
+			t.Errorf("expected port_value 5432, got %v", socketAddr.GetPortValue())
 		}
 	})
 
@@ -49,34 +53,138 @@ func TestTCPServiceBuilder_Build(t *testing.T) {
 			port.TCPPort(5432),
 		)
 
-		components := builder.Build("tcp_db", 54321)
+		components, err := builder.Build("tcp_db", 54321)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
 
 		// クラスタ設定を確認
 		cluster := components.Cluster
-		if cluster["name"] != "tcp_db" {
-			t.Errorf("expected cluster name tcp_db, got %v", cluster["name"])
+		if cluster.GetName() != "tcp_db" {
+			t.Errorf("expected cluster name tcp_db, got %v", cluster.GetName())
 		}
 
 		// ロードアサインメントを確認
-		loadAssignment, ok := cluster["load_assignment"].(map[string]any)
-		if !ok {
-			t.Fatal("load_assignment not found")
-		}
-		endpoints, ok := loadAssignment["endpoints"].([]any)
-		if !ok || len(endpoints) == 0 {
+		if len(cluster.GetLoadAssignment().GetEndpoints()) == 0 {
 			t.Fatal("endpoints not found or empty")
 		}
-		ep := endpoints[0].(map[string]any)
-		lbEndpoints := ep["lb_endpoints"].([]any)
-		endpoint := lbEndpoints[0].(map[string]any)["endpoint"].(map[string]any)
-		addr := endpoint["address"].(map[string]any)["socket_address"].(map[string]any)
+		addr, p := endpointAddress(cluster, 0)
 
 		// ローカルポートへ接続することを確認
-		if addr["address"] != "127.0.0.1" {
-			t.Errorf("expected cluster endpoint 127.0.0.1, got %v", addr["address"])
+		if addr != "127.0.0.1" {
+			t.Errorf("expected cluster endpoint 127.0.0.1, got %v", addr)
+		}
+		if p != 54321 {
+			t.Errorf("expected cluster port 54321, got %v", p)
+		}
+
+		// TCPクラスタはHTTPプロトコルオプションを持たない
+		if len(cluster.GetTypedExtensionProtocolOptions()) != 0 {
+			t.Errorf("expected no protocol options for tcp cluster, got %v", cluster.GetTypedExtensionProtocolOptions())
 		}
-		if addr["port_value"] != 54321 {
-			t.Errorf("expected cluster port 54321, got %v", addr["port_value"])
+	})
+}
+
+func TestTCPServiceBuilder_Build_WithHealthCheck(t *testing.T) {
+	builder := NewTCPServiceBuilder(
+		"db.localhost",
+		port.TCPPort(5432),
+		"127.0.0.2",
+		"primary",
+		"10.0.0.1",
+		port.TCPPort(5432),
+	)
+	builder.HealthCheck = &HealthCheck{Type: "tcp", Interval: 5 * time.Second, UnhealthyThreshold: 3}
+
+	components, err := builder.Build("tcp_db", 54321)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	cluster := components.Cluster
+
+	healthChecks := cluster.GetHealthChecks()
+	if len(healthChecks) != 1 || healthChecks[0].GetTcpHealthCheck() == nil {
+		t.Fatalf("expected tcp_health_check, got %v", healthChecks)
+	}
+	if cluster.GetOutlierDetection().GetConsecutiveGatewayFailure().GetValue() != 3 {
+		t.Errorf("expected outlier detection after 3 errors, got %v", cluster.GetOutlierDetection())
+	}
+}
+
+func TestTCPServiceBuilder_Build_WithProtocol(t *testing.T) {
+	tests := []struct {
+		protocol   string
+		filterName string
+		statPrefix func(t *testing.T, f *listenerv3.Filter) string
+	}{
+		{
+			protocol:   "postgres",
+			filterName: "envoy.filters.network.postgres_proxy",
+			statPrefix: func(t *testing.T, f *listenerv3.Filter) string {
+				return unpack[*postgresproxyv3alpha.PostgresProxy](t, f.GetTypedConfig()).GetStatPrefix()
+			},
+		},
+		{
+			protocol:   "mysql",
+			filterName: "envoy.filters.network.mysql_proxy",
+			statPrefix: func(t *testing.T, f *listenerv3.Filter) string {
+				return unpack[*mysqlproxyv3.MySQLProxy](t, f.GetTypedConfig()).GetStatPrefix()
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.protocol, func(t *testing.T) {
+			builder := NewTCPServiceBuilder(
+				"orders-db.localdomain",
+				port.TCPPort(5432),
+				"127.0.0.2",
+				"primary",
+				"10.0.0.1",
+				port.TCPPort(5432),
+			)
+			builder.Protocol = tt.protocol
+
+			components, err := builder.Build("tcp_db", 54321)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			filters := components.Listener.GetFilterChains()[0].GetFilters()
+			if len(filters) != 2 {
+				t.Fatalf("expected 2 filters, got %d", len(filters))
+			}
+			// プロトコルフィルタはtcp_proxyより前に置く
+			if filters[0].GetName() != tt.filterName {
+				t.Errorf("expected first filter %s, got %s", tt.filterName, filters[0].GetName())
+			}
+			if filters[1].GetName() != "envoy.filters.network.tcp_proxy" {
+				t.Errorf("expected tcp_proxy as the last filter, got %s", filters[1].GetName())
+			}
+			if got := tt.statPrefix(t, filters[0]); got != "orders_db_localdomain" {
+				t.Errorf("expected stat_prefix orders_db_localdomain, got %s", got)
+			}
+		})
+	}
+
+	t.Run("未指定の場合はtcp_proxyのみ", func(t *testing.T) {
+		builder := NewTCPServiceBuilder("db.localhost", port.TCPPort(5432), "127.0.0.2", "primary", "10.0.0.1", port.TCPPort(5432))
+		components, err := builder.Build("tcp_db", 54321)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		filters := components.Listener.GetFilterChains()[0].GetFilters()
+		if len(filters) != 1 || filters[0].GetName() != "envoy.filters.network.tcp_proxy" {
+			t.Errorf("expected only tcp_proxy, got %v", filters)
+		}
+	})
+
+	t.Run("未対応のプロトコルはエラー", func(t *testing.T) {
+		builder := NewTCPServiceBuilder("db.localhost", port.TCPPort(5432), "127.0.0.2", "primary", "10.0.0.1", port.TCPPort(5432))
+		builder.Protocol = "redis"
+		// 設定全体の生成でもエラーを返す
+		_, err := BuildConfig(80, []ServiceConfig{{Builder: builder, ClusterName: "tcp_db", LocalPort: 54321}})
+		if err == nil || !strings.Contains(err.Error(), `unsupported database protocol "redis"`) {
+			t.Errorf("expected unsupported protocol error, got %v", err)
 		}
 	})
 }
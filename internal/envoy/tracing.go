@@ -0,0 +1,62 @@
+package envoy
+
+import (
+	"net"
+	"time"
+
+	clusterv3 "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
+	corev3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	tracev3 "github.com/envoyproxy/go-control-plane/envoy/config/trace/v3"
+	hcmv3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
+	typev3 "github.com/envoyproxy/go-control-plane/envoy/type/v3"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// TracingClusterName はOpenTelemetryコレクター用のクラスタ名
+const TracingClusterName = "localmesh_otel_collector"
+
+// Tracing はOpenTelemetry（OTLP/gRPC）コレクターへのトレーシング設定
+type Tracing struct {
+	Address         string  // コレクターのIPアドレスまたはホスト名
+	Port            int     // コレクターのポート
+	SamplingPercent float64 // サンプリングするリクエストの割合（0-100）
+	ServiceName     string  // スパンのサービス名
+}
+
+// httpTracing はHTTP connection manager用のトレーシング設定を生成
+// OpenTelemetryトレーサーはW3C traceparentヘッダーをバックエンドへ伝播する
+func httpTracing(t *Tracing) (*hcmv3.HttpConnectionManager_Tracing, error) {
+	provider, err := typedConfig(&tracev3.OpenTelemetryConfig{
+		GrpcService: &corev3.GrpcService{
+			TargetSpecifier: &corev3.GrpcService_EnvoyGrpc_{
+				EnvoyGrpc: &corev3.GrpcService_EnvoyGrpc{ClusterName: TracingClusterName},
+			},
+			Timeout: durationpb.New(time.Second),
+		},
+		ServiceName: t.ServiceName,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &hcmv3.HttpConnectionManager_Tracing{
+		RandomSampling: &typev3.Percent{Value: t.SamplingPercent},
+		Provider: &tracev3.Tracing_Http{
+			Name:       "envoy.tracers.opentelemetry",
+			ConfigType: &tracev3.Tracing_Http_TypedConfig{TypedConfig: provider},
+		},
+	}, nil
+}
+
+// tracingCollectorCluster はコレクター用のクラスタ設定を生成
+// IPアドレスの場合はSTATIC、ホスト名の場合はSTRICT_DNSで解決する
+func tracingCollectorCluster(t *Tracing) (*clusterv3.Cluster, error) {
+	cluster, err := buildStaticCluster(TracingClusterName, t.Address, t.Port, "grpc")
+	if err != nil {
+		return nil, err
+	}
+	if net.ParseIP(t.Address) == nil {
+		cluster.ClusterDiscoveryType = &clusterv3.Cluster_Type{Type: clusterv3.Cluster_STRICT_DNS}
+		cluster.DnsLookupFamily = clusterv3.Cluster_V4_PREFERRED
+	}
+	return cluster, nil
+}
@@ -0,0 +1,60 @@
+package envoy
+
+import (
+	"math"
+
+	typev3 "github.com/envoyproxy/go-control-plane/envoy/type/v3"
+
+	"github.com/usadamasa/kubectl-localmesh/internal/port"
+)
+
+// Upstream はメインのバックエンド以外に接続するKubernetes Service（フェイルオーバー先・ミラー先など）
+// ビルダー生成後にフィールドとして設定する
+type Upstream struct {
+	ClusterName        string
+	LocalPort          port.LocalPort
+	ResolvedRemotePort port.ServicePort // 解決済みリモートポート（マッピング出力用）
+	// メタデータ（ログ・診断用、Envoy設定ではクラスタのmetadataにのみ使用）
+	Namespace   string
+	ServiceName string
+	PortName    string
+	Port        port.ServicePort
+	Cluster     string
+}
+
+// MirrorUpstream はトラフィックミラーリング先のバックエンド
+type MirrorUpstream struct {
+	Upstream
+	Percent float64 // ミラーするリクエストの割合（0-100）
+}
+
+// WeightedUpstream は重み付き分散の追加バックエンド
+type WeightedUpstream struct {
+	Upstream
+	Weight int
+}
+
+// HeaderRoute はリクエストヘッダーに一致した場合のみ振り分ける代替バックエンド
+type HeaderRoute struct {
+	Header string
+	Value  string // 空の場合はヘッダーの存在のみで判定
+	Upstream
+	// Address は直接接続するローカルプロセスのIPアドレス
+	// 空の場合はport-forward（127.0.0.1:LocalPort）に接続し、指定時はLocalPortをそのポートとして扱う
+	Address string
+}
+
+// fractionalPercent はパーセント値をEnvoyのFractionalPercentに変換
+// 整数の場合はHUNDRED、小数を含む場合はMILLIONを分母にする
+func fractionalPercent(percent float64) *typev3.FractionalPercent {
+	if percent == math.Trunc(percent) {
+		return &typev3.FractionalPercent{
+			Numerator:   uint32(percent),
+			Denominator: typev3.FractionalPercent_HUNDRED,
+		}
+	}
+	return &typev3.FractionalPercent{
+		Numerator:   uint32(math.Round(percent * 10000)),
+		Denominator: typev3.FractionalPercent_MILLION,
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"net"
 	"os"
 	"os/exec"
 	"time"
@@ -13,6 +14,17 @@ import (
 	"github.com/usadamasa/kubectl-localmesh/internal/port"
 )
 
+// SSHTunnelOptions はSSH tunnelの動作オプション
+type SSHTunnelOptions struct {
+	// Reconnect に通知すると確立中のSSH tunnelを切断して再接続する（nil可）
+	Reconnect <-chan struct{}
+	// OnStateChange はローカルポートの待ち受け開始（true）・SSH tunnelの終了（false）時に呼ばれる（nil可）
+	OnStateChange func(ready bool)
+}
+
+// listenPollInterval はSSH tunnelのローカルポートが待ち受けを開始したかを確認する間隔
+const listenPollInterval = 200 * time.Millisecond
+
 // StartGCPSSHTunnel はGCP Compute Instance経由でSSH tunnelを確立し、
 // ローカルポートからターゲットホスト:ポートへのポートフォワーディングを行います。
 // contextがキャンセルされるまで自動再接続を繰り返します。
@@ -23,6 +35,20 @@ func StartGCPSSHTunnel(
 	targetHost string,
 	targetPort port.TCPPort,
 	logger *log.Logger,
+) error {
+	return StartGCPSSHTunnelWithOptions(ctx, bastion, localPort, targetHost, targetPort, logger, SSHTunnelOptions{})
+}
+
+// StartGCPSSHTunnelWithOptions はStartGCPSSHTunnelと同様にSSH tunnelを確立し、
+// オプションに応じて再接続を行います。
+func StartGCPSSHTunnelWithOptions(
+	ctx context.Context,
+	bastion *config.SSHBastion,
+	localPort port.LocalPort,
+	targetHost string,
+	targetPort port.TCPPort,
+	logger *log.Logger,
+	opts SSHTunnelOptions,
 ) error {
 	// パラメータのバリデーション
 	if bastion == nil {
@@ -52,8 +78,29 @@ func StartGCPSSHTunnel(
 		default:
 		}
 
-		// SSH tunnel確立を試行
-		err := startSingleSSHTunnel(ctx, bastion, localPort, targetHost, targetPort, logger)
+		// SSH tunnel確立を試行（再接続の要求時はこのtunnelのみを停止する）
+		err := runWithReconnect(ctx, opts.Reconnect, func(ctx context.Context) error {
+			if opts.OnStateChange == nil {
+				return startSingleSSHTunnel(ctx, bastion, localPort, targetHost, targetPort, logger)
+			}
+			// gcloudは確立を通知しないため、ローカルポートに接続できた時点で確立とみなす
+			watchCtx, stopWatch := context.WithCancel(ctx)
+			watched := make(chan struct{})
+			go func() {
+				defer close(watched)
+				if waitListening(watchCtx, fmt.Sprintf("127.0.0.1:%d", int(localPort))) {
+					opts.OnStateChange(true)
+				}
+			}()
+			err := startSingleSSHTunnel(ctx, bastion, localPort, targetHost, targetPort, logger)
+			stopWatch()
+			<-watched
+			opts.OnStateChange(false)
+			return err
+		}, func() {
+			logger.Infof("SSH tunnel reconnecting: %s -> %s:%d (health check failed)",
+				bastion.Instance, targetHost, int(targetPort))
+		})
 
 		// contextキャンセル時は正常終了
 		if ctx.Err() != nil {
@@ -71,6 +118,52 @@ func StartGCPSSHTunnel(
 	}
 }
 
+// runWithReconnect はfnを実行し、reconnectに通知された場合はfnのcontextをキャンセルして終了を待ちます。
+// 実行前に届いていた通知は読み捨てます。
+func runWithReconnect(ctx context.Context, reconnect <-chan struct{}, fn func(ctx context.Context) error, onReconnect func()) error {
+drain:
+	for {
+		select {
+		case <-reconnect:
+		default:
+			break drain
+		}
+	}
+
+	attemptCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	done := make(chan error, 1)
+	go func() { done <- fn(attemptCtx) }()
+
+	select {
+	case err := <-done:
+		return err
+	case <-reconnect:
+		onReconnect()
+		cancel()
+		return <-done
+	}
+}
+
+// waitListening はaddrに接続できるまで待ち、接続できた場合にtrueを返します。
+// contextがキャンセルされた場合はfalseを返します。
+func waitListening(ctx context.Context, addr string) bool {
+	ticker := time.NewTicker(listenPollInterval)
+	defer ticker.Stop()
+	var d net.Dialer
+	for {
+		if conn, err := d.DialContext(ctx, "tcp", addr); err == nil {
+			_ = conn.Close()
+			return true
+		}
+		select {
+		case <-ctx.Done():
+			return false
+		case <-ticker.C:
+		}
+	}
+}
+
 // buildGcloudSSHCommand はgcloud compute sshコマンドの引数を構築します。
 // テスト可能にするため、package private関数として定義しています。
 func buildGcloudSSHCommand(
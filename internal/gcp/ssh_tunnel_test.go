@@ -2,6 +2,7 @@ package gcp
 
 import (
 	"context"
+	"net"
 	"reflect"
 	"testing"
 	"time"
@@ -162,3 +163,61 @@ func TestBuildGcloudSSHCommand(t *testing.T) {
 		})
 	}
 }
+
+func TestRunWithReconnect(t *testing.T) {
+	reconnect := make(chan struct{}, 1)
+
+	// 実行前に届いていた通知は読み捨てる
+	reconnect <- struct{}{}
+	started := make(chan struct{})
+	reconnected := false
+	done := make(chan error)
+	go func() {
+		done <- runWithReconnect(context.Background(), reconnect, func(ctx context.Context) error {
+			close(started)
+			<-ctx.Done()
+			return ctx.Err()
+		}, func() { reconnected = true })
+	}()
+
+	<-started
+	select {
+	case err := <-done:
+		t.Fatalf("expected tunnel to keep running, got %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// 実行中の通知でtunnelのcontextがキャンセルされる
+	reconnect <- struct{}{}
+	select {
+	case err := <-done:
+		if err != context.Canceled {
+			t.Errorf("expected context.Canceled, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected tunnel to stop on reconnect")
+	}
+	if !reconnected {
+		t.Error("expected onReconnect to be called")
+	}
+}
+
+func TestWaitListening(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := lis.Addr().String()
+
+	if !waitListening(t.Context(), addr) {
+		t.Error("expected true while listening")
+	}
+
+	// 待ち受けていない場合はcontextのキャンセルまで待つ
+	_ = lis.Close()
+	ctx, cancel := context.WithTimeout(t.Context(), 3*listenPollInterval)
+	defer cancel()
+	if waitListening(ctx, addr) {
+		t.Error("expected false after the listener is closed")
+	}
+}
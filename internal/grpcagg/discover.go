@@ -0,0 +1,171 @@
+// Package grpcagg は集約gRPCホストのためのサービス探索と統合リフレクションを提供します。
+// 各gRPCバックエンドへリフレクションで問い合わせ、protoサービス名からバックエンドを解決します。
+package grpcagg
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	rpb "google.golang.org/grpc/reflection/grpc_reflection_v1"
+	rpbalpha "google.golang.org/grpc/reflection/grpc_reflection_v1alpha"
+	"google.golang.org/grpc/status"
+
+	"github.com/usadamasa/kubectl-localmesh/internal/envoy"
+	"github.com/usadamasa/kubectl-localmesh/internal/log"
+)
+
+// DefaultDiscoveryTimeout はポートフォワード確立待ちを含むサービス探索のタイムアウト
+const DefaultDiscoveryTimeout = 10 * time.Second
+
+// Backend はリフレクション対象のgRPCバックエンド
+type Backend struct {
+	Host        string // サービスのホスト名（ログ用）
+	ClusterName string // Envoyクラスタ名
+	Address     string // ポートフォワードのローカルアドレス（127.0.0.1:port）
+}
+
+// BackendServices はバックエンドとリフレクションで取得したサービス一覧
+type BackendServices struct {
+	Backend
+	Services []string
+	// V1Alpha はバックエンドがgrpc.reflection.v1alphaのみに対応している場合にtrue
+	V1Alpha bool
+}
+
+// Discover は各バックエンドのサービス一覧をリフレクションで取得する
+// ポートフォワードの確立を待つため、timeoutに達するまで300ms間隔で再試行する
+// 取得できなかったバックエンドは警告を出力して結果から除外する
+func Discover(ctx context.Context, backends []Backend, timeout time.Duration, logger *log.Logger) []BackendServices {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	results := make([]*BackendServices, len(backends))
+	var wg sync.WaitGroup
+	for i, b := range backends {
+		wg.Add(1)
+		go func(i int, b Backend) {
+			defer wg.Done()
+			for {
+				services, v1alpha, err := ListServices(ctx, b.Address)
+				if err == nil {
+					logger.Debugf("grpc-aggregate: %s -> %v", b.Host, services)
+					results[i] = &BackendServices{Backend: b, Services: services, V1Alpha: v1alpha}
+					return
+				}
+				select {
+				case <-ctx.Done():
+					if ctx.Err() == context.DeadlineExceeded {
+						fmt.Fprintf(os.Stderr, "warning: grpc reflection failed for %s: %v\n", b.Host, err)
+					}
+					return
+				case <-time.After(300 * time.Millisecond):
+				}
+			}
+		}(i, b)
+	}
+	wg.Wait()
+
+	discovered := make([]BackendServices, 0, len(backends))
+	for _, r := range results {
+		if r != nil {
+			discovered = append(discovered, *r)
+		}
+	}
+	return discovered
+}
+
+// ListServices はaddrのgRPCサーバーが公開するサービス一覧をリフレクションで取得する
+// grpc.reflection.v1が未実装の場合はv1alphaで再試行し、その場合は2番目の戻り値がtrueになる
+func ListServices(ctx context.Context, addr string) ([]string, bool, error) {
+	conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return nil, false, err
+	}
+	defer func() { _ = conn.Close() }()
+
+	req := &rpb.ServerReflectionRequest{
+		MessageRequest: &rpb.ServerReflectionRequest_ListServices{ListServices: "*"},
+	}
+
+	v1alpha := false
+	resp, err := roundTrip(ctx, conn, req, false)
+	if status.Code(err) == codes.Unimplemented {
+		v1alpha = true
+		resp, err = roundTrip(ctx, conn, req, true)
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	list := resp.GetListServicesResponse()
+	if list == nil {
+		return nil, false, fmt.Errorf("unexpected reflection response from %s: %v", addr, resp.GetErrorResponse())
+	}
+	services := make([]string, 0, len(list.GetService()))
+	for _, s := range list.GetService() {
+		services = append(services, s.GetName())
+	}
+	sort.Strings(services)
+	return services, v1alpha, nil
+}
+
+// roundTrip はリフレクションストリームを開いて1往復だけ問い合わせる
+func roundTrip(ctx context.Context, conn *grpc.ClientConn, req *rpb.ServerReflectionRequest, v1alpha bool) (*rpb.ServerReflectionResponse, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	stream, err := openStream(ctx, conn, v1alpha)
+	if err != nil {
+		return nil, err
+	}
+	if err := stream.Send(req); err != nil {
+		return nil, err
+	}
+	resp, err := stream.Recv()
+	if err != nil {
+		return nil, err
+	}
+	_ = stream.CloseSend()
+	return resp, nil
+}
+
+// openStream はバックエンドへのリフレクションストリームを開く
+func openStream(ctx context.Context, conn *grpc.ClientConn, v1alpha bool) (reflectionStream, error) {
+	if v1alpha {
+		s, err := rpbalpha.NewServerReflectionClient(conn).ServerReflectionInfo(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return &v1alphaStream{stream: s}, nil
+	}
+	return rpb.NewServerReflectionClient(conn).ServerReflectionInfo(ctx)
+}
+
+// Routes はサービス探索の結果からEnvoyのルーティングを生成する
+// 同じサービスを複数のバックエンドが公開している場合は設定順で先のバックエンドを優先する
+// リフレクションサービス自体は統合リフレクションサーバーが処理するため除外する
+func Routes(results []BackendServices) []envoy.GRPCServiceRoute {
+	var routes []envoy.GRPCServiceRoute
+	seen := make(map[string]bool)
+	for _, r := range results {
+		for _, svc := range r.Services {
+			if seen[svc] || envoy.IsReflectionService(svc) {
+				continue
+			}
+			seen[svc] = true
+			routes = append(routes, envoy.GRPCServiceRoute{
+				Service:     svc,
+				ClusterName: r.ClusterName,
+				Host:        r.Host,
+			})
+		}
+	}
+	return routes
+}
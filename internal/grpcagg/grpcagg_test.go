@@ -0,0 +1,176 @@
+package grpcagg
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	channelz "google.golang.org/grpc/channelz/service"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+	rpb "google.golang.org/grpc/reflection/grpc_reflection_v1"
+
+	"github.com/usadamasa/kubectl-localmesh/internal/log"
+)
+
+// startBackend はリフレクション付きのgRPCサーバーを起動してアドレスを返す
+func startBackend(t *testing.T, register func(*grpc.Server)) string {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := grpc.NewServer()
+	register(srv)
+	reflection.Register(srv)
+	go func() { _ = srv.Serve(lis) }()
+	t.Cleanup(srv.Stop)
+	return lis.Addr().String()
+}
+
+func startTestBackends(t *testing.T) []Backend {
+	t.Helper()
+	healthAddr := startBackend(t, func(s *grpc.Server) {
+		healthpb.RegisterHealthServer(s, health.NewServer())
+	})
+	channelzAddr := startBackend(t, func(s *grpc.Server) {
+		channelz.RegisterChannelzServiceToServer(s)
+	})
+	return []Backend{
+		{Host: "health.localhost", ClusterName: "health_cluster", Address: healthAddr},
+		{Host: "channelz.localhost", ClusterName: "channelz_cluster", Address: channelzAddr},
+	}
+}
+
+func TestDiscoverAndRoutes(t *testing.T) {
+	backends := startTestBackends(t)
+
+	results := Discover(context.Background(), backends, 5*time.Second, log.New("warn"))
+	if len(results) != 2 {
+		t.Fatalf("expected 2 discovered backends, got %d", len(results))
+	}
+
+	routes := Routes(results)
+	got := make(map[string]string)
+	for _, r := range routes {
+		got[r.Service] = r.ClusterName
+	}
+
+	if got["grpc.health.v1.Health"] != "health_cluster" {
+		t.Errorf("expected health service routed to health_cluster, got %q", got["grpc.health.v1.Health"])
+	}
+	if got["grpc.channelz.v1.Channelz"] != "channelz_cluster" {
+		t.Errorf("expected channelz service routed to channelz_cluster, got %q", got["grpc.channelz.v1.Channelz"])
+	}
+	// リフレクションサービスは統合サーバーが処理するためルートに含めない
+	for svc := range got {
+		if svc == "grpc.reflection.v1.ServerReflection" || svc == "grpc.reflection.v1alpha.ServerReflection" {
+			t.Errorf("reflection service %s should not be routed to a backend", svc)
+		}
+	}
+}
+
+func TestDiscover_UnreachableBackendSkipped(t *testing.T) {
+	backends := []Backend{{Host: "down.localhost", ClusterName: "down", Address: "127.0.0.1:1"}}
+
+	results := Discover(context.Background(), backends, 500*time.Millisecond, log.New("warn"))
+	if len(results) != 0 {
+		t.Errorf("expected unreachable backend to be skipped, got %v", results)
+	}
+}
+
+func TestRoutes_FirstBackendWins(t *testing.T) {
+	routes := Routes([]BackendServices{
+		{Backend: Backend{ClusterName: "a"}, Services: []string{"grpc.health.v1.Health", "a.v1.A"}},
+		{Backend: Backend{ClusterName: "b"}, Services: []string{"grpc.health.v1.Health", "b.v1.B"}},
+	})
+
+	if len(routes) != 3 {
+		t.Fatalf("expected 3 routes, got %d: %v", len(routes), routes)
+	}
+	if routes[0].Service != "grpc.health.v1.Health" || routes[0].ClusterName != "a" {
+		t.Errorf("expected duplicated service to be routed to the first backend, got %v", routes[0])
+	}
+}
+
+func TestReflectionServer_MergesBackends(t *testing.T) {
+	backends := startTestBackends(t)
+	results := Discover(context.Background(), backends, 5*time.Second, log.New("warn"))
+
+	srv, err := NewReflectionServer(results)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() { _ = srv.Serve(ctx, lis) }()
+
+	conn, err := grpc.NewClient(lis.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = conn.Close() }()
+
+	stream, err := rpb.NewServerReflectionClient(conn).ServerReflectionInfo(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	send := func(req *rpb.ServerReflectionRequest) *rpb.ServerReflectionResponse {
+		t.Helper()
+		if err := stream.Send(req); err != nil {
+			t.Fatal(err)
+		}
+		resp, err := stream.Recv()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+
+	t.Run("list services", func(t *testing.T) {
+		resp := send(&rpb.ServerReflectionRequest{
+			MessageRequest: &rpb.ServerReflectionRequest_ListServices{ListServices: "*"},
+		})
+		names := make(map[string]bool)
+		for _, s := range resp.GetListServicesResponse().GetService() {
+			names[s.GetName()] = true
+		}
+		for _, want := range []string{"grpc.health.v1.Health", "grpc.channelz.v1.Channelz", "grpc.reflection.v1.ServerReflection"} {
+			if !names[want] {
+				t.Errorf("expected %s in merged service list, got %v", want, names)
+			}
+		}
+	})
+
+	t.Run("file containing symbol", func(t *testing.T) {
+		for _, symbol := range []string{"grpc.health.v1.Health", "grpc.channelz.v1.Channelz.GetServers"} {
+			resp := send(&rpb.ServerReflectionRequest{
+				MessageRequest: &rpb.ServerReflectionRequest_FileContainingSymbol{FileContainingSymbol: symbol},
+			})
+			if len(resp.GetFileDescriptorResponse().GetFileDescriptorProto()) == 0 {
+				t.Errorf("expected file descriptor for %s, got %v", symbol, resp)
+			}
+			if resp.GetOriginalRequest().GetFileContainingSymbol() != symbol {
+				t.Errorf("expected original request to be echoed for %s", symbol)
+			}
+		}
+	})
+
+	t.Run("unknown symbol", func(t *testing.T) {
+		resp := send(&rpb.ServerReflectionRequest{
+			MessageRequest: &rpb.ServerReflectionRequest_FileContainingSymbol{FileContainingSymbol: "no.such.Service"},
+		})
+		if resp.GetErrorResponse() == nil {
+			t.Errorf("expected error response for unknown symbol, got %v", resp)
+		}
+	})
+}
@@ -0,0 +1,276 @@
+package grpcagg
+
+import (
+	"context"
+	"net"
+	"sort"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	rpb "google.golang.org/grpc/reflection/grpc_reflection_v1"
+	rpbalpha "google.golang.org/grpc/reflection/grpc_reflection_v1alpha"
+	"google.golang.org/protobuf/proto"
+)
+
+// reflectionStream はバックエンドへのリフレクションストリーム（v1/v1alpha共通）
+type reflectionStream interface {
+	Send(*rpb.ServerReflectionRequest) error
+	Recv() (*rpb.ServerReflectionResponse, error)
+	CloseSend() error
+}
+
+// v1alphaStream はv1alphaのストリームをv1のメッセージで扱うアダプタ
+// v1とv1alphaのメッセージはワイヤ互換のため、シリアライズを経由して変換する
+type v1alphaStream struct {
+	stream grpc.BidiStreamingClient[rpbalpha.ServerReflectionRequest, rpbalpha.ServerReflectionResponse]
+}
+
+func (s *v1alphaStream) Send(req *rpb.ServerReflectionRequest) error {
+	var alphaReq rpbalpha.ServerReflectionRequest
+	if err := convert(req, &alphaReq); err != nil {
+		return err
+	}
+	return s.stream.Send(&alphaReq)
+}
+
+func (s *v1alphaStream) Recv() (*rpb.ServerReflectionResponse, error) {
+	alphaResp, err := s.stream.Recv()
+	if err != nil {
+		return nil, err
+	}
+	var resp rpb.ServerReflectionResponse
+	if err := convert(alphaResp, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+func (s *v1alphaStream) CloseSend() error {
+	return s.stream.CloseSend()
+}
+
+// convert はワイヤ互換なprotoメッセージ間で値を変換する
+func convert(from, to proto.Message) error {
+	b, err := proto.Marshal(from)
+	if err != nil {
+		return err
+	}
+	return proto.Unmarshal(b, to)
+}
+
+// ReflectionServer は複数バックエンドのリフレクションを1つに統合するgRPCリフレクションサーバー
+// ListServicesは全バックエンドの和集合を返し、それ以外の問い合わせは
+// シンボルを公開しているバックエンドへ転送する
+type ReflectionServer struct {
+	rpb.UnimplementedServerReflectionServer
+
+	backends []BackendServices
+	conns    []*grpc.ClientConn
+	owners   map[string]int // サービス名 → backendsのindex
+	services []string       // ListServicesで返すサービス一覧
+}
+
+// NewReflectionServer はサービス探索の結果からReflectionServerを生成する
+func NewReflectionServer(backends []BackendServices) (*ReflectionServer, error) {
+	s := &ReflectionServer{
+		backends: backends,
+		owners:   make(map[string]int),
+	}
+
+	for i, b := range backends {
+		conn, err := grpc.NewClient(b.Address, grpc.WithTransportCredentials(insecure.NewCredentials()))
+		if err != nil {
+			s.close()
+			return nil, err
+		}
+		s.conns = append(s.conns, conn)
+
+		for _, svc := range b.Services {
+			if _, ok := s.owners[svc]; !ok {
+				s.owners[svc] = i
+			}
+		}
+	}
+
+	for svc := range s.owners {
+		if svc != "grpc.reflection.v1alpha.ServerReflection" {
+			s.services = append(s.services, svc)
+		}
+	}
+	if _, ok := s.owners["grpc.reflection.v1.ServerReflection"]; !ok {
+		s.services = append(s.services, "grpc.reflection.v1.ServerReflection")
+	}
+	sort.Strings(s.services)
+
+	return s, nil
+}
+
+// Serve はlisでリフレクションサーバーを起動する
+// ctxがキャンセルされるとサーバーを停止してnilを返す
+func (s *ReflectionServer) Serve(ctx context.Context, lis net.Listener) error {
+	defer s.close()
+
+	srv := grpc.NewServer()
+	rpb.RegisterServerReflectionServer(srv, s)
+
+	go func() {
+		<-ctx.Done()
+		srv.Stop()
+	}()
+
+	if err := srv.Serve(lis); err != nil && ctx.Err() == nil {
+		return err
+	}
+	return nil
+}
+
+func (s *ReflectionServer) close() {
+	for _, c := range s.conns {
+		_ = c.Close()
+	}
+}
+
+// ServerReflectionInfo はリフレクションのストリームを処理する
+func (s *ReflectionServer) ServerReflectionInfo(stream grpc.BidiStreamingServer[rpb.ServerReflectionRequest, rpb.ServerReflectionResponse]) error {
+	sess := &reflectionSession{
+		server:    s,
+		ctx:       stream.Context(),
+		streams:   make(map[int]reflectionStream),
+		preferred: -1,
+	}
+	defer sess.close()
+
+	for {
+		req, err := stream.Recv()
+		if err != nil {
+			// クライアント側のストリーム終了
+			return nil
+		}
+
+		var resp *rpb.ServerReflectionResponse
+		switch r := req.MessageRequest.(type) {
+		case *rpb.ServerReflectionRequest_ListServices:
+			resp = s.listServicesResponse()
+		case *rpb.ServerReflectionRequest_FileContainingSymbol:
+			resp = sess.forward(req, s.candidates(s.ownerOf(r.FileContainingSymbol)))
+		default:
+			// ファイル名・拡張の問い合わせは直前に応答したバックエンドを優先する
+			resp = sess.forward(req, s.candidates(sess.preferred))
+		}
+
+		resp.ValidHost = req.GetHost()
+		resp.OriginalRequest = req
+		if err := stream.Send(resp); err != nil {
+			return err
+		}
+	}
+}
+
+// listServicesResponse は全バックエンドのサービス一覧を返す
+func (s *ReflectionServer) listServicesResponse() *rpb.ServerReflectionResponse {
+	list := make([]*rpb.ServiceResponse, 0, len(s.services))
+	for _, svc := range s.services {
+		list = append(list, &rpb.ServiceResponse{Name: svc})
+	}
+	return &rpb.ServerReflectionResponse{
+		MessageResponse: &rpb.ServerReflectionResponse_ListServicesResponse{
+			ListServicesResponse: &rpb.ListServiceResponse{Service: list},
+		},
+	}
+}
+
+// ownerOf はシンボルを定義しているサービスのバックエンドを返す
+// シンボルはサービス名そのもの、またはサービス名.メソッド名の形式を想定し、
+// 該当するバックエンドがない場合は-1を返す
+func (s *ReflectionServer) ownerOf(symbol string) int {
+	if idx, ok := s.owners[symbol]; ok {
+		return idx
+	}
+	best, bestLen := -1, 0
+	for svc, idx := range s.owners {
+		if strings.HasPrefix(symbol, svc+".") && len(svc) > bestLen {
+			best, bestLen = idx, len(svc)
+		}
+	}
+	return best
+}
+
+// candidates は問い合わせ先のバックエンドを優先順に返す
+func (s *ReflectionServer) candidates(first int) []int {
+	order := make([]int, 0, len(s.backends))
+	if first >= 0 {
+		order = append(order, first)
+	}
+	for i := range s.backends {
+		if i != first {
+			order = append(order, i)
+		}
+	}
+	return order
+}
+
+// reflectionSession はクライアントの1ストリームに対応するバックエンドストリームの集合
+type reflectionSession struct {
+	server    *ReflectionServer
+	ctx       context.Context
+	streams   map[int]reflectionStream
+	preferred int
+}
+
+// forward はcandidatesの順にリクエストを転送し、最初に成功した応答を返す
+func (sess *reflectionSession) forward(req *rpb.ServerReflectionRequest, candidates []int) *rpb.ServerReflectionResponse {
+	var lastErr *rpb.ErrorResponse
+	for _, idx := range candidates {
+		resp, err := sess.roundTrip(idx, req)
+		if err != nil {
+			lastErr = &rpb.ErrorResponse{ErrorCode: int32(codes.Unavailable), ErrorMessage: err.Error()}
+			continue
+		}
+		if e := resp.GetErrorResponse(); e != nil {
+			lastErr = e
+			continue
+		}
+		sess.preferred = idx
+		return resp
+	}
+
+	if lastErr == nil {
+		lastErr = &rpb.ErrorResponse{ErrorCode: int32(codes.NotFound), ErrorMessage: "no backend available"}
+	}
+	return &rpb.ServerReflectionResponse{
+		MessageResponse: &rpb.ServerReflectionResponse_ErrorResponse{ErrorResponse: lastErr},
+	}
+}
+
+// roundTrip はバックエンドidxへリクエストを1往復転送する
+// ストリームはバックエンドごとに遅延生成し、エラー時は破棄して次回作り直す
+func (sess *reflectionSession) roundTrip(idx int, req *rpb.ServerReflectionRequest) (*rpb.ServerReflectionResponse, error) {
+	stream, ok := sess.streams[idx]
+	if !ok {
+		var err error
+		stream, err = openStream(sess.ctx, sess.server.conns[idx], sess.server.backends[idx].V1Alpha)
+		if err != nil {
+			return nil, err
+		}
+		sess.streams[idx] = stream
+	}
+
+	if err := stream.Send(req); err != nil {
+		delete(sess.streams, idx)
+		return nil, err
+	}
+	resp, err := stream.Recv()
+	if err != nil {
+		delete(sess.streams, idx)
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (sess *reflectionSession) close() {
+	for _, s := range sess.streams {
+		_ = s.CloseSend()
+	}
+}
@@ -0,0 +1,175 @@
+// Package health はport-forward・SSH tunnelのローカルポートに対するアクティブヘルスチェックを提供します。
+// Envoyのヘルスチェックと同じ内容をGo側でも実行し、失敗が続いた場合にトンネルの再接続を促します。
+//
+// Envoyのヘルスチェック結果（管理インターフェースの/clusters）を使わないのは次の理由によります。
+//   - --proxy builtinではEnvoyが動いていない
+//   - EnvoyのTCPヘルスチェックは接続できればhealthyとするが、port-forward・SSH tunnelは
+//     バックエンドに接続できなくてもローカルでは接続を受け付けるため、失敗を検出できない
+//   - Envoyはローカルポートが閉じている間（再接続中）も失敗として数えるため、再接続が重複する
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// Timeout は1回のチェックのタイムアウト（Envoyのヘルスチェックと同じ）
+const Timeout = time.Second
+
+// ErrNotListening はローカルポートが開いていないことを示す
+// port-forward・SSH tunnelが再接続中のため、バックエンドの失敗としては数えない
+var ErrNotListening = errors.New("local port is not listening")
+
+// Check はヘルスチェックの設定
+type Check struct {
+	Type               string // http|grpc|tcp
+	Path               string // HTTPヘルスチェックのパス（type: http）
+	Service            string // gRPCヘルスチェックのサービス名（type: grpc）
+	HTTP2              bool   // HTTPヘルスチェックをh2cで行う（protocol: grpc・http2）
+	Interval           time.Duration
+	UnhealthyThreshold int
+}
+
+// Probe はaddr（127.0.0.1:port）に対してチェックを1回実行し、unhealthyの場合はエラーを返す
+func Probe(ctx context.Context, addr string, c Check) error {
+	ctx, cancel := context.WithTimeout(ctx, Timeout)
+	defer cancel()
+
+	switch c.Type {
+	case "http":
+		return probeHTTP(ctx, addr, c)
+	case "grpc":
+		return probeGRPC(ctx, addr, c)
+	default:
+		return probeTCP(ctx, addr)
+	}
+}
+
+// Monitor はInterval間隔でProbeを実行し、UnhealthyThreshold回連続で失敗するとonUnhealthyを呼ぶ
+// onUnhealthyの呼び出し後とローカルポートが開いていない間は失敗回数をリセットする
+// contextがキャンセルされるまでブロックする
+func Monitor(ctx context.Context, addr string, c Check, onUnhealthy func(err error)) {
+	ticker := time.NewTicker(c.Interval)
+	defer ticker.Stop()
+
+	failures := 0
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		err := Probe(ctx, addr, c)
+		switch {
+		case ctx.Err() != nil:
+			return
+		case err == nil, errors.Is(err, ErrNotListening):
+			failures = 0
+		default:
+			failures++
+			if failures >= c.UnhealthyThreshold {
+				failures = 0
+				onUnhealthy(err)
+			}
+		}
+	}
+}
+
+// dial はaddrにTCP接続し、接続を拒否された場合はErrNotListeningを返す
+func dial(ctx context.Context, addr string) (net.Conn, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	if errors.Is(err, syscall.ECONNREFUSED) {
+		return nil, ErrNotListening
+	}
+	return conn, err
+}
+
+// probeTCP は接続後、バックエンドがすぐに接続を閉じないことを確認する
+// port-forward・SSH tunnelはバックエンドに接続できなくてもローカルでは接続を受け付け、直後に閉じるため、
+// 接続できただけではhealthyとみなさない（タイムアウトまで閉じられなければhealthy）
+func probeTCP(ctx context.Context, addr string) error {
+	conn, err := dial(ctx, addr)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = conn.Close() }()
+
+	deadline, _ := ctx.Deadline()
+	_ = conn.SetReadDeadline(deadline)
+	_, err = conn.Read(make([]byte, 1))
+	if err == nil || errors.Is(err, os.ErrDeadlineExceeded) {
+		return nil
+	}
+	return fmt.Errorf("connection closed by backend: %w", err)
+}
+
+// probeHTTP はPathへのGETが200を返すことを確認する
+func probeHTTP(ctx context.Context, addr string, c Check) error {
+	transport := &http.Transport{DialContext: func(ctx context.Context, _, addr string) (net.Conn, error) {
+		return dial(ctx, addr)
+	}}
+	if c.HTTP2 {
+		transport.Protocols = new(http.Protocols)
+		transport.Protocols.SetUnencryptedHTTP2(true)
+	}
+	defer transport.CloseIdleConnections()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://"+addr+c.Path, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		return err
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s returned %d", c.Path, resp.StatusCode)
+	}
+	return nil
+}
+
+// probeGRPC はgRPC Health Checking ProtocolでSERVINGが返ることを確認する
+func probeGRPC(ctx context.Context, addr string, c Check) error {
+	var notListening atomic.Bool
+	conn, err := grpc.NewClient(
+		"passthrough:///"+addr,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithContextDialer(func(ctx context.Context, addr string) (net.Conn, error) {
+			conn, err := dial(ctx, addr)
+			if errors.Is(err, ErrNotListening) {
+				notListening.Store(true)
+			}
+			return conn, err
+		}),
+	)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = conn.Close() }()
+
+	resp, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{Service: c.Service}, grpc.WaitForReady(false))
+	if err != nil {
+		if notListening.Load() {
+			return ErrNotListening
+		}
+		return err
+	}
+	if resp.GetStatus() != healthpb.HealthCheckResponse_SERVING {
+		return fmt.Errorf("grpc health status is %s", resp.GetStatus())
+	}
+	return nil
+}
@@ -0,0 +1,154 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	grpchealth "google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// closedAddr は接続を拒否されるアドレスを返す
+func closedAddr(t *testing.T) string {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	addr := lis.Addr().String()
+	_ = lis.Close()
+	return addr
+}
+
+// tcpServer は接続を受け付け、closeImmediatelyがtrueの場合は直後に閉じるサーバーを起動
+// バックエンドに接続できないport-forwardの挙動を再現する
+func tcpServer(t *testing.T, closeImmediately bool) string {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	t.Cleanup(func() { _ = lis.Close() })
+
+	go func() {
+		for {
+			conn, err := lis.Accept()
+			if err != nil {
+				return
+			}
+			if closeImmediately {
+				_ = conn.Close()
+				continue
+			}
+			t.Cleanup(func() { _ = conn.Close() })
+		}
+	}()
+	return lis.Addr().String()
+}
+
+func TestProbe_TCP(t *testing.T) {
+	if err := Probe(context.Background(), tcpServer(t, false), Check{Type: "tcp"}); err != nil {
+		t.Errorf("expected healthy, got %v", err)
+	}
+
+	err := Probe(context.Background(), tcpServer(t, true), Check{Type: "tcp"})
+	if err == nil || !strings.Contains(err.Error(), "connection closed by backend") {
+		t.Errorf("expected connection closed error, got %v", err)
+	}
+
+	if err := Probe(context.Background(), closedAddr(t), Check{Type: "tcp"}); !errors.Is(err, ErrNotListening) {
+		t.Errorf("expected ErrNotListening, got %v", err)
+	}
+}
+
+func TestProbe_HTTP(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/healthz" {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+	addr := strings.TrimPrefix(srv.URL, "http://")
+
+	if err := Probe(context.Background(), addr, Check{Type: "http", Path: "/healthz"}); err != nil {
+		t.Errorf("expected healthy, got %v", err)
+	}
+
+	err := Probe(context.Background(), addr, Check{Type: "http", Path: "/ready"})
+	if err == nil || !strings.Contains(err.Error(), "GET /ready returned 503") {
+		t.Errorf("expected 503 error, got %v", err)
+	}
+
+	if err := Probe(context.Background(), closedAddr(t), Check{Type: "http", Path: "/healthz"}); !errors.Is(err, ErrNotListening) {
+		t.Errorf("expected ErrNotListening, got %v", err)
+	}
+}
+
+func TestProbe_GRPC(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	srv := grpc.NewServer()
+	hs := grpchealth.NewServer()
+	hs.SetServingStatus("users.v1.UserService", healthpb.HealthCheckResponse_NOT_SERVING)
+	healthpb.RegisterHealthServer(srv, hs)
+	go func() { _ = srv.Serve(lis) }()
+	defer srv.Stop()
+	addr := lis.Addr().String()
+
+	if err := Probe(context.Background(), addr, Check{Type: "grpc"}); err != nil {
+		t.Errorf("expected healthy, got %v", err)
+	}
+
+	err = Probe(context.Background(), addr, Check{Type: "grpc", Service: "users.v1.UserService"})
+	if err == nil || !strings.Contains(err.Error(), "NOT_SERVING") {
+		t.Errorf("expected NOT_SERVING error, got %v", err)
+	}
+
+	if err := Probe(context.Background(), closedAddr(t), Check{Type: "grpc"}); !errors.Is(err, ErrNotListening) {
+		t.Errorf("expected ErrNotListening, got %v", err)
+	}
+}
+
+func TestMonitor(t *testing.T) {
+	t.Run("連続失敗でonUnhealthyを呼ぶ", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		unhealthy := make(chan error, 1)
+		go Monitor(ctx, tcpServer(t, true), Check{Type: "tcp", Interval: 10 * time.Millisecond, UnhealthyThreshold: 3}, func(err error) {
+			select {
+			case unhealthy <- err:
+			default:
+			}
+		})
+
+		select {
+		case err := <-unhealthy:
+			if err == nil {
+				t.Error("expected probe error")
+			}
+		case <-ctx.Done():
+			t.Fatal("expected onUnhealthy to be called")
+		}
+	})
+
+	t.Run("ローカルポートが開いていない間は呼ばない", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(200*time.Millisecond, cancel)
+
+		Monitor(ctx, closedAddr(t), Check{Type: "tcp", Interval: 10 * time.Millisecond, UnhealthyThreshold: 1}, func(err error) {
+			t.Errorf("expected onUnhealthy not to be called while the port is closed, got %v", err)
+		})
+	})
+}
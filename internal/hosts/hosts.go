@@ -69,6 +69,14 @@ func AddEntriesWithIPs(entries []HostEntry) error {
 	return writeLinesToFile(lines)
 }
 
+// ReplaceEntries replaces the managed block in /etc/hosts with the given entries
+func ReplaceEntries(entries []HostEntry) error {
+	if err := RemoveEntries(); err != nil {
+		return err
+	}
+	return AddEntriesWithIPs(entries)
+}
+
 // AddEntries adds hostname entries to /etc/hosts
 func AddEntries(hostnames []string) error {
 	// 1. ファイル状態を検証
@@ -329,3 +329,39 @@ func TestTrimTrailingEmptyLines(t *testing.T) {
 		})
 	}
 }
+
+func TestReplaceEntries(t *testing.T) {
+	tmpDir := t.TempDir()
+	testFile := filepath.Join(tmpDir, "hosts")
+	setTestHostsFile(t, testFile)
+
+	if err := os.WriteFile(testFile, []byte("127.0.0.1 localhost\n"), 0644); err != nil {
+		t.Fatalf("failed to create test file: %v", err)
+	}
+
+	if err := AddEntriesWithIPs([]HostEntry{{Hostname: "api.localhost", IP: "127.0.0.1"}}); err != nil {
+		t.Fatalf("AddEntriesWithIPs failed: %v", err)
+	}
+	entries := []HostEntry{
+		{Hostname: "api.localhost", IP: "127.0.0.1"},
+		{Hostname: "db.localhost", IP: "127.0.0.2"},
+	}
+	if err := ReplaceEntries(entries); err != nil {
+		t.Fatalf("ReplaceEntries failed: %v", err)
+	}
+
+	content, err := os.ReadFile(testFile)
+	if err != nil {
+		t.Fatalf("failed to read test file: %v", err)
+	}
+
+	expected := "127.0.0.1 localhost\n\n" +
+		markerStart + "\n" +
+		"127.0.0.1 api.localhost\n" +
+		"127.0.0.2 db.localhost\n" +
+		markerEnd + "\n"
+
+	if string(content) != expected {
+		t.Errorf("unexpected content:\ngot:\n%q\nwant:\n%q", string(content), expected)
+	}
+}
@@ -93,6 +93,18 @@ func (f *websocketPortForwarderFactory) CreatePortForwarder(
 	return pf, readyChan, nil
 }
 
+// PortForwardOptions はポートフォワードループの動作オプション
+type PortForwardOptions struct {
+	// RequireReadyPod がtrueの場合、Ready状態のPodがなければポートフォワードを確立しない
+	// （フェイルオーバー時にローカルポートを閉じたままにしてヘルスチェックを失敗させる）
+	RequireReadyPod bool
+	// OnStateChange はポートフォワードの確立（true）・切断（false）時に接続先のPod名とともに呼ばれる（nil可）
+	OnStateChange func(ready bool, podName string)
+	// Reconnect に通知すると確立中のポートフォワードを切断し、別のPodを優先して選び直す（nil可）
+	// ヘルスチェックが失敗し続ける場合に、応答しないPodから切り替えるために使う
+	Reconnect <-chan struct{}
+}
+
 // StartPortForwardLoop starts port-forwarding with automatic reconnection.
 // It continuously forwards localPort to remotePort on the specified service,
 // retrying every 300ms on disconnection or error.
@@ -112,6 +124,24 @@ func StartPortForwardLoop(
 	)
 }
 
+// StartPortForwardLoopWithOptions starts port-forwarding with automatic reconnection
+// like StartPortForwardLoop, with additional options.
+func StartPortForwardLoopWithOptions(
+	ctx context.Context,
+	config *rest.Config,
+	clientset kubernetes.Interface,
+	namespace, serviceName string,
+	localPort port.LocalPort,
+	remotePort port.ServicePort,
+	logger *log.Logger,
+	opts PortForwardOptions,
+) error {
+	factory := NewWebSocketPortForwarderFactory(config)
+	return startPortForwardLoop(
+		ctx, factory, clientset, namespace, serviceName, localPort, remotePort, logger, opts,
+	)
+}
+
 // StartPortForwardLoopWithFactory starts port-forwarding with automatic reconnection
 // using a custom PortForwarderFactory. This function is designed for testability.
 func StartPortForwardLoopWithFactory(
@@ -123,6 +153,30 @@ func StartPortForwardLoopWithFactory(
 	remotePort port.ServicePort,
 	logger *log.Logger,
 ) error {
+	return startPortForwardLoop(
+		ctx, factory, clientset, namespace, serviceName, localPort, remotePort, logger, PortForwardOptions{},
+	)
+}
+
+func startPortForwardLoop(
+	ctx context.Context,
+	factory PortForwarderFactory,
+	clientset kubernetes.Interface,
+	namespace, serviceName string,
+	localPort port.LocalPort,
+	remotePort port.ServicePort,
+	logger *log.Logger,
+	opts PortForwardOptions,
+) error {
+	notify := func(ready bool, podName string) {
+		if opts.OnStateChange != nil {
+			opts.OnStateChange(ready, podName)
+		}
+	}
+
+	// 再接続を要求されたPod（次の選択で他のPodを優先する）
+	var avoid string
+
 	for {
 		select {
 		case <-ctx.Done():
@@ -131,16 +185,24 @@ func StartPortForwardLoopWithFactory(
 		}
 
 		// Pod名を取得
-		podName, err := selectPodForService(ctx, clientset, namespace, serviceName)
+		var podName string
+		var err error
+		if opts.RequireReadyPod {
+			podName, err = selectReadyPodForService(ctx, clientset, namespace, serviceName, avoid)
+		} else {
+			podName, err = selectPodForService(ctx, clientset, namespace, serviceName, avoid)
+		}
 		if err != nil {
 			// エラー時は0.3秒待って再試行
 			time.Sleep(300 * time.Millisecond)
 			continue
 		}
 
-		// PortForwarder作成
-		pf, readyChan, err := factory.CreatePortForwarder(ctx, namespace, podName, localPort, remotePort)
+		// PortForwarder作成（再接続の要求時はこのポートフォワードのみを停止する）
+		forwardCtx, stopForward := context.WithCancel(ctx)
+		pf, readyChan, err := factory.CreatePortForwarder(forwardCtx, namespace, podName, localPort, remotePort)
 		if err != nil {
+			stopForward()
 			// エラー時は0.3秒待って再試行
 			time.Sleep(300 * time.Millisecond)
 			continue
@@ -158,16 +220,32 @@ func StartPortForwardLoopWithFactory(
 			// 成功ログ出力（debugレベル）
 			logger.Debugf("port-forward ready: %s/%s -> pod/%s (127.0.0.1:%d -> %d)",
 				namespace, serviceName, podName, int(localPort), int(remotePort))
+			// 確立前に届いた再接続の要求は、新しいポートフォワードには適用しない
+			drain(opts.Reconnect)
+			notify(true, podName)
 		case <-ctx.Done():
+			stopForward()
 			return nil
 		case <-errChan:
+			stopForward()
 			// エラーまたは切断時は0.3秒待って再接続
 			time.Sleep(300 * time.Millisecond)
 			continue
 		}
 
-		// ForwardPortsの終了を待つ
-		<-errChan
+		// ForwardPortsの終了または再接続の要求を待つ
+		avoid = ""
+		select {
+		case <-errChan:
+		case <-opts.Reconnect:
+			logger.Infof("port-forward reconnecting: %s/%s -> pod/%s (health check failed)",
+				namespace, serviceName, podName)
+			avoid = podName
+			stopForward()
+			<-errChan
+		}
+		stopForward()
+		notify(false, podName)
 
 		// contextキャンセル時は正常終了
 		if ctx.Err() != nil {
@@ -183,13 +261,82 @@ func StartPortForwardLoopWithFactory(
 	}
 }
 
+// drain は通知済みの値を読み捨てる（chがnilの場合は何もしない）
+func drain(ch <-chan struct{}) {
+	for {
+		select {
+		case <-ch:
+		default:
+			return
+		}
+	}
+}
+
 // selectPodForService は、Serviceのselectorに基づいてReady状態のPodを選択する。
 // kubectl port-forward svc/xxxと同じロジックを実装。
+// avoidが空でない場合は、そのPod以外のReady状態のPodを優先する。
 func selectPodForService(
 	ctx context.Context,
 	clientset kubernetes.Interface,
 	namespace, serviceName string,
+	avoid string,
 ) (string, error) {
+	pods, err := listPodsForService(ctx, clientset, namespace, serviceName)
+	if err != nil {
+		return "", err
+	}
+
+	// Ready状態のPodを優先的に選択
+	if name, ok := readyPod(pods, avoid); ok {
+		return name, nil
+	}
+
+	// Ready状態のPodがない場合は最初のPodを返す（kubectlの動作と同じ）
+	return pods[0].Name, nil
+}
+
+// selectReadyPodForService は、Serviceのselectorに基づいてReady状態のPodを選択する。
+// selectPodForServiceと異なり、Ready状態のPodがない場合はエラーを返す。
+func selectReadyPodForService(
+	ctx context.Context,
+	clientset kubernetes.Interface,
+	namespace, serviceName string,
+	avoid string,
+) (string, error) {
+	pods, err := listPodsForService(ctx, clientset, namespace, serviceName)
+	if err != nil {
+		return "", err
+	}
+
+	if name, ok := readyPod(pods, avoid); ok {
+		return name, nil
+	}
+	return "", fmt.Errorf("no ready pods found for service %s/%s", namespace, serviceName)
+}
+
+// readyPod は、Ready状態のPodを選択する。
+// avoid以外のPodを優先し、他にReady状態のPodがない場合のみavoidを返す。
+func readyPod(pods []corev1.Pod, avoid string) (string, bool) {
+	fallback := ""
+	for _, pod := range pods {
+		if !isPodReady(&pod) {
+			continue
+		}
+		if pod.Name != avoid {
+			return pod.Name, true
+		}
+		fallback = pod.Name
+	}
+	return fallback, fallback != ""
+}
+
+// listPodsForService は、Serviceのselectorに一致するPodを取得する。
+// Podが1つもない場合はエラーを返す。
+func listPodsForService(
+	ctx context.Context,
+	clientset kubernetes.Interface,
+	namespace, serviceName string,
+) ([]corev1.Pod, error) {
 	// 1. Serviceを取得してselectorを取得
 	svc, err := clientset.CoreV1().Services(namespace).Get(
 		ctx,
@@ -197,12 +344,12 @@ func selectPodForService(
 		metav1.GetOptions{},
 	)
 	if err != nil {
-		return "", fmt.Errorf("failed to get service %s/%s: %w", namespace, serviceName, err)
+		return nil, fmt.Errorf("failed to get service %s/%s: %w", namespace, serviceName, err)
 	}
 
 	// 2. selectorが空の場合はエラー
 	if len(svc.Spec.Selector) == 0 {
-		return "", fmt.Errorf("service %s/%s has no selector", namespace, serviceName)
+		return nil, fmt.Errorf("service %s/%s has no selector", namespace, serviceName)
 	}
 
 	// 3. selectorをラベルセレクタに変換
@@ -216,24 +363,16 @@ func selectPodForService(
 		},
 	)
 	if err != nil {
-		return "", fmt.Errorf("failed to list pods for service %s/%s: %w", namespace, serviceName, err)
+		return nil, fmt.Errorf("failed to list pods for service %s/%s: %w", namespace, serviceName, err)
 	}
 
 	// 5. Podが見つからない場合はエラー
 	if len(pods.Items) == 0 {
-		return "", fmt.Errorf("no pods found for service %s/%s with selector %v",
+		return nil, fmt.Errorf("no pods found for service %s/%s with selector %v",
 			namespace, serviceName, svc.Spec.Selector)
 	}
 
-	// 6. Ready状態のPodを優先的に選択
-	for _, pod := range pods.Items {
-		if isPodReady(&pod) {
-			return pod.Name, nil
-		}
-	}
-
-	// 7. Ready状態のPodがない場合は最初のPodを返す（kubectlの動作と同じ）
-	return pods.Items[0].Name, nil
+	return pods.Items, nil
 }
 
 // isPodReady は、PodがReady状態かどうかを判定する。
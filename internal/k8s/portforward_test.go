@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -63,7 +64,7 @@ func TestSelectPodForService_ReadyPod(t *testing.T) {
 	}
 
 	// selectPodForService実行
-	podName, err := selectPodForService(ctx, clientset, "default", "test-svc")
+	podName, err := selectPodForService(ctx, clientset, "default", "test-svc", "")
 	if err != nil {
 		t.Fatalf("selectPodForService failed: %v", err)
 	}
@@ -122,7 +123,7 @@ func TestSelectPodForService_NoReadyPod(t *testing.T) {
 	}
 
 	// selectPodForService実行
-	podName, err := selectPodForService(ctx, clientset, "default", "test-svc")
+	podName, err := selectPodForService(ctx, clientset, "default", "test-svc", "")
 	if err != nil {
 		t.Fatalf("selectPodForService failed: %v", err)
 	}
@@ -157,7 +158,7 @@ func TestSelectPodForService_NoPods(t *testing.T) {
 	}
 
 	// selectPodForService実行
-	_, err = selectPodForService(ctx, clientset, "default", "test-svc")
+	_, err = selectPodForService(ctx, clientset, "default", "test-svc", "")
 
 	// Podが見つからない場合、エラーを返す
 	if err == nil {
@@ -186,7 +187,7 @@ func TestSelectPodForService_NoSelector(t *testing.T) {
 	}
 
 	// selectPodForService実行
-	_, err = selectPodForService(ctx, clientset, "default", "test-svc")
+	_, err = selectPodForService(ctx, clientset, "default", "test-svc", "")
 
 	// Serviceにselectorがない場合、エラーを返す
 	if err == nil {
@@ -726,3 +727,187 @@ func TestStartPortForwardLoopWithFactory_ForwardPortsError(t *testing.T) {
 		t.Errorf("expected at least 3 ForwardPorts calls, got %d", forwardCallCount)
 	}
 }
+
+func TestSelectReadyPodForService_NoReadyPod(t *testing.T) {
+	clientset := fake.NewClientset()
+	ctx := context.Background()
+
+	svc := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-svc", Namespace: "default"},
+		Spec: corev1.ServiceSpec{
+			Selector: map[string]string{"app": "test"},
+		},
+	}
+	if _, err := clientset.CoreV1().Services("default").Create(ctx, svc, metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-pod-1",
+			Namespace: "default",
+			Labels:    map[string]string{"app": "test"},
+		},
+		Status: corev1.PodStatus{Phase: corev1.PodPending},
+	}
+	if _, err := clientset.CoreV1().Pods("default").Create(ctx, pod, metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	// selectPodForServiceと異なり、Ready状態のPodがなければエラー
+	_, err := selectReadyPodForService(ctx, clientset, "default", "test-svc", "")
+	if err == nil {
+		t.Fatal("expected error when no ready pods exist")
+	}
+	if !strings.Contains(err.Error(), "no ready pods found") {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestStartPortForwardLoop_OnStateChange(t *testing.T) {
+	clientset := fake.NewClientset()
+	ctx, cancel := context.WithTimeout(t.Context(), 1*time.Second)
+	defer cancel()
+
+	setupServiceAndReadyPod(t, clientset, "default", "test-svc", "test-pod")
+
+	mockFactory := &mockPortForwarderFactory{
+		createFunc: func(ctx context.Context, namespace, podName string,
+			localPort port.LocalPort, remotePort port.ServicePort) (PortForwarder, chan struct{}, error) {
+			readyChan := make(chan struct{})
+			close(readyChan)
+			return &mockPortForwarder{
+				forwardFunc: func() error {
+					// 接続後すぐに切断
+					time.Sleep(50 * time.Millisecond)
+					return nil
+				},
+			}, readyChan, nil
+		},
+	}
+
+	var mu sync.Mutex
+	var states []bool
+	var pods []string
+	opts := PortForwardOptions{
+		RequireReadyPod: true,
+		OnStateChange: func(ready bool, podName string) {
+			mu.Lock()
+			defer mu.Unlock()
+			states = append(states, ready)
+			pods = append(pods, podName)
+			if len(states) == 2 {
+				cancel()
+			}
+		},
+	}
+
+	err := startPortForwardLoop(
+		ctx, mockFactory, clientset, "default", "test-svc", 8080, 9090, log.New("info"), opts,
+	)
+	if err != nil {
+		t.Errorf("expected nil error, got: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(states) < 2 || !states[0] || states[1] {
+		t.Errorf("expected state changes [true false], got %v", states)
+	}
+	if len(pods) < 2 || pods[0] != "test-pod" || pods[1] != "test-pod" {
+		t.Errorf("expected pod names [test-pod test-pod], got %v", pods)
+	}
+}
+
+func TestReadyPod_Avoid(t *testing.T) {
+	ready := corev1.PodStatus{
+		Phase:      corev1.PodRunning,
+		Conditions: []corev1.PodCondition{{Type: corev1.PodReady, Status: corev1.ConditionTrue}},
+	}
+	pods := []corev1.Pod{
+		{ObjectMeta: metav1.ObjectMeta{Name: "pod-a"}, Status: ready},
+		{ObjectMeta: metav1.ObjectMeta{Name: "pod-b"}, Status: ready},
+		{ObjectMeta: metav1.ObjectMeta{Name: "pod-c"}},
+	}
+
+	tests := []struct {
+		name  string
+		pods  []corev1.Pod
+		avoid string
+		want  string
+	}{
+		{"avoid未指定", pods, "", "pod-a"},
+		{"他のReady Podを優先", pods, "pod-a", "pod-b"},
+		{"他にReady Podがなければ同じPod", pods[:1], "pod-a", "pod-a"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := readyPod(tt.pods, tt.avoid)
+			if !ok || got != tt.want {
+				t.Errorf("readyPod() = %q, %v, want %q", got, ok, tt.want)
+			}
+		})
+	}
+}
+
+func TestStartPortForwardLoop_Reconnect(t *testing.T) {
+	clientset := fake.NewClientset()
+	ctx, cancel := context.WithTimeout(t.Context(), 5*time.Second)
+	defer cancel()
+
+	setupServiceAndReadyPod(t, clientset, "default", "test-svc", "pod-a")
+	second := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "pod-b", Namespace: "default", Labels: map[string]string{"app": "test"}},
+		Status: corev1.PodStatus{
+			Phase:      corev1.PodRunning,
+			Conditions: []corev1.PodCondition{{Type: corev1.PodReady, Status: corev1.ConditionTrue}},
+		},
+	}
+	if _, err := clientset.CoreV1().Pods("default").Create(t.Context(), second, metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	reconnect := make(chan struct{}, 1)
+	var mu sync.Mutex
+	var pods []string
+	mockFactory := &mockPortForwarderFactory{
+		createFunc: func(ctx context.Context, namespace, podName string,
+			localPort port.LocalPort, remotePort port.ServicePort) (PortForwarder, chan struct{}, error) {
+			mu.Lock()
+			pods = append(pods, podName)
+			if len(pods) == 2 {
+				cancel()
+			}
+			mu.Unlock()
+			readyChan := make(chan struct{})
+			close(readyChan)
+			// 実際のポートフォワードと同様、contextのキャンセルで終了する
+			return &mockPortForwarder{
+				forwardFunc: func() error {
+					<-ctx.Done()
+					return nil
+				},
+			}, readyChan, nil
+		},
+	}
+
+	opts := PortForwardOptions{
+		Reconnect: reconnect,
+		OnStateChange: func(ready bool, _ string) {
+			if ready {
+				reconnect <- struct{}{}
+			}
+		},
+	}
+	if err := startPortForwardLoop(
+		ctx, mockFactory, clientset, "default", "test-svc", 8080, 9090, log.New("error"), opts,
+	); err != nil {
+		t.Errorf("expected nil error, got: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	// 1回目で選んだPodを避け、もう一方のPodで再接続する
+	if len(pods) != 2 || pods[0] == pods[1] {
+		t.Errorf("expected reconnect to another pod, got %v", pods)
+	}
+}
@@ -19,6 +19,8 @@ type ServiceSummary struct {
 	Backend string
 	// ListenPort はリスナーポート（0の場合はデフォルトを使用）
 	ListenPort port.ListenerPort
+	// Details はサービス行の下に表示する補足情報（ミラー先など）
+	Details []string
 }
 
 // EffectiveListenPort はListenPortが0の場合はデフォルトポートを返します。
@@ -68,6 +70,7 @@ func GenerateSummary(services []ServiceSummary, listenerPort port.ListenerPort)
 			protocolLabel := formatProtocolLabel(svc.Protocol)
 			sb.WriteString(fmt.Sprintf("  • http://%s:%d (%s) -> %s\n",
 				svc.Host, p, protocolLabel, svc.Backend))
+			writeDetails(&sb, svc.Details)
 		}
 		sb.WriteString("\n")
 	}
@@ -79,6 +82,7 @@ func GenerateSummary(services []ServiceSummary, listenerPort port.ListenerPort)
 			p := svc.EffectiveListenPort(listenerPort)
 			sb.WriteString(fmt.Sprintf("  • tcp://%s:%d -> %s\n",
 				svc.Host, p, svc.Backend))
+			writeDetails(&sb, svc.Details)
 		}
 		sb.WriteString("\n")
 	}
@@ -87,3 +91,10 @@ func GenerateSummary(services []ServiceSummary, listenerPort port.ListenerPort)
 
 	return sb.String()
 }
+
+// writeDetails はサービスの補足情報をインデントして出力します。
+func writeDetails(sb *strings.Builder, details []string) {
+	for _, d := range details {
+		sb.WriteString(fmt.Sprintf("      %s\n", d))
+	}
+}
@@ -202,3 +202,23 @@ func TestServiceSummary_EffectiveListenPort(t *testing.T) {
 		})
 	}
 }
+
+func TestGenerateSummary_Details(t *testing.T) {
+	services := []ServiceSummary{
+		{
+			Host:        "users-api.localhost",
+			Protocol:    "http",
+			DisplayType: "HTTP/gRPC",
+			Backend:     "users/users-api:8080",
+			Details:     []string{"mirror -> users-canary/users-api:8080 (10%)"},
+		},
+	}
+
+	result := GenerateSummary(services, 80)
+
+	want := "  • http://users-api.localhost:80 (HTTP) -> users/users-api:8080\n" +
+		"      mirror -> users-canary/users-api:8080 (10%)\n"
+	if !strings.Contains(result, want) {
+		t.Errorf("missing details under service line in summary:\n%s", result)
+	}
+}
@@ -2,6 +2,7 @@ package loopback
 
 import (
 	"os/exec"
+	"slices"
 )
 
 // CommandExecutor はコマンド実行の抽象化（テスト用）
@@ -54,9 +55,14 @@ func (m *AliasManager) RemoveAdded() {
 }
 
 // RemoveAlias は指定されたIPをlo0のエイリアスから削除
+// 成功した場合は追跡対象からも外す
 // sudo ifconfig lo0 -alias <ip>
 func (m *AliasManager) RemoveAlias(ip string) error {
-	return m.executor("ifconfig", "lo0", "-alias", ip)
+	if err := m.executor("ifconfig", "lo0", "-alias", ip); err != nil {
+		return err
+	}
+	m.added = slices.DeleteFunc(m.added, func(added string) bool { return added == ip })
+	return nil
 }
 
 // AddAliases は複数のIPをエイリアスとして追加
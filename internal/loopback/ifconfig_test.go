@@ -225,6 +225,22 @@ func TestAliasManager_TracksAddedIPs(t *testing.T) {
 		}
 	})
 
+	t.Run("RemoveAlias成功時はaddedから外す", func(t *testing.T) {
+		mgr := &AliasManager{
+			executor: func(name string, args ...string) error { return nil },
+			added:    make([]string, 0),
+		}
+
+		_ = mgr.AddAlias("127.0.0.2")
+		_ = mgr.AddAlias("127.0.0.3")
+		_ = mgr.RemoveAlias("127.0.0.2")
+
+		added := mgr.GetAdded()
+		if len(added) != 1 || added[0] != "127.0.0.3" {
+			t.Errorf("expected [127.0.0.3], got %v", added)
+		}
+	})
+
 	t.Run("GetAddedはコピーを返す", func(t *testing.T) {
 		mgr := &AliasManager{
 			executor: func(name string, args ...string) error { return nil },
@@ -6,6 +6,7 @@ import (
 	"errors"
 	"fmt"
 	"net"
+	"slices"
 )
 
 // IPChecker はIPアドレスが使用中かを判定する関数型
@@ -59,7 +60,7 @@ func (a *IPAllocator) Allocate() (string, error) {
 	for a.nextOctet <= 254 {
 		ip := fmt.Sprintf("127.0.0.%d", a.nextOctet)
 		a.nextOctet++
-		if !a.isInUse(ip) {
+		if !slices.Contains(a.allocated, ip) && !a.isInUse(ip) {
 			a.allocated = append(a.allocated, ip)
 			return ip, nil
 		}
@@ -67,6 +68,20 @@ func (a *IPAllocator) Allocate() (string, error) {
 	return "", errors.New("loopback IP range exhausted (127.0.0.2-254)")
 }
 
+// Release は割り当て済みのIPを解放し、以降のAllocateで再利用可能にする
+func (a *IPAllocator) Release(ip string) {
+	i := slices.Index(a.allocated, ip)
+	if i < 0 {
+		return
+	}
+	a.allocated = slices.Delete(a.allocated, i, i+1)
+
+	var octet int
+	if _, err := fmt.Sscanf(ip, "127.0.0.%d", &octet); err == nil && octet < a.nextOctet {
+		a.nextOctet = octet
+	}
+}
+
 // GetAliases は追加が必要なエイリアスIPのリストを返す
 // 127.0.0.2以降を使用するため、全てがエイリアス対象
 func (a *IPAllocator) GetAliases() []string {
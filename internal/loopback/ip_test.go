@@ -165,3 +165,34 @@ func TestIPAllocator_Reset(t *testing.T) {
 		}
 	})
 }
+
+func TestIPAllocator_Release(t *testing.T) {
+	alloc := NewIPAllocatorWithChecker(func(ip string) bool { return false })
+	for i := 0; i < 3; i++ {
+		_, _ = alloc.Allocate()
+	}
+
+	alloc.Release("127.0.0.3")
+	if aliases := alloc.GetAliases(); len(aliases) != 2 {
+		t.Fatalf("expected 2 aliases after release, got %v", aliases)
+	}
+
+	// 解放したIPを再利用し、割り当て済みのIPはスキップする
+	ip, err := alloc.Allocate()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if ip != "127.0.0.3" {
+		t.Errorf("expected released 127.0.0.3 to be reused, got %s", ip)
+	}
+	ip, _ = alloc.Allocate()
+	if ip != "127.0.0.5" {
+		t.Errorf("expected 127.0.0.5, got %s", ip)
+	}
+
+	// 未割り当てのIPは無視する
+	alloc.Release("127.0.0.100")
+	if aliases := alloc.GetAliases(); len(aliases) != 4 {
+		t.Errorf("expected 4 aliases, got %v", aliases)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"io"
 	"net"
 	"os"
+	"strconv"
 )
 
 // Port はすべてのポート番号型の共通制約
@@ -109,7 +110,7 @@ func WarnLocalhostTLD(hostname, serviceName string) {
 type PortConflictChecker struct {
 	usedPorts     map[int]string    // port -> service name（IP指定なし）
 	usedAddrPorts map[string]string // "ip:port" -> service name（IP指定あり）
-	wildcardPorts map[int]string    // 0.0.0.0でバインドされたport -> service name
+	wildcardPorts map[int]string    // 0.0.0.0・::でバインドされたport -> service name
 }
 
 // NewPortConflictChecker は新しいPortConflictCheckerを生成
@@ -132,7 +133,7 @@ func (c *PortConflictChecker) Register(port int, serviceName string) {
 
 // RegisterWithAddr はIP:portの組み合わせで登録し、競合があれば警告を出力
 // addr が空の場合はポート番号のみでチェック（従来動作）
-// addr が "0.0.0.0" の場合は全インターフェースバインドとして扱う
+// addr が "0.0.0.0" または "::" の場合は全インターフェースバインドとして扱う
 func (c *PortConflictChecker) RegisterWithAddr(addr string, port int, serviceName string) {
 	// アドレス指定がない場合は従来の動作
 	if addr == "" {
@@ -140,18 +141,18 @@ func (c *PortConflictChecker) RegisterWithAddr(addr string, port int, serviceNam
 		return
 	}
 
-	// 0.0.0.0（ワイルドカード）の場合
-	if addr == "0.0.0.0" {
+	// 0.0.0.0・::（ワイルドカード）の場合
+	if addr == "0.0.0.0" || addr == "::" {
 		// 既存のワイルドカードポートと競合チェック
 		if existingService, ok := c.wildcardPorts[port]; ok {
-			_, _ = fmt.Fprintf(warnWriter, "Warning: 0.0.0.0:%d is used by both '%s' and '%s'\n",
-				port, existingService, serviceName)
+			_, _ = fmt.Fprintf(warnWriter, "Warning: %s is used by both '%s' and '%s'\n",
+				net.JoinHostPort(addr, strconv.Itoa(port)), existingService, serviceName)
 		}
 		// 既存の特定IPポートと競合チェック
 		for key, existingService := range c.usedAddrPorts {
 			existingPort := extractPort(key)
 			if existingPort == port {
-				_, _ = fmt.Fprintf(warnWriter, "Warning: port %d conflict - '%s' (0.0.0.0) binds all interfaces, conflicts with '%s' (%s)\n",
+				_, _ = fmt.Fprintf(warnWriter, "Warning: port %d conflict - '%s' binds all interfaces, conflicts with '%s' (%s)\n",
 					port, serviceName, existingService, key)
 			}
 		}
@@ -160,11 +161,11 @@ func (c *PortConflictChecker) RegisterWithAddr(addr string, port int, serviceNam
 	}
 
 	// 特定IPアドレスの場合
-	key := fmt.Sprintf("%s:%d", addr, port)
+	key := net.JoinHostPort(addr, strconv.Itoa(port))
 
 	// 既存のワイルドカードポートと競合チェック
 	if existingService, ok := c.wildcardPorts[port]; ok {
-		_, _ = fmt.Fprintf(warnWriter, "Warning: port %d conflict - '%s' (0.0.0.0) binds all interfaces, conflicts with '%s' (%s)\n",
+		_, _ = fmt.Fprintf(warnWriter, "Warning: port %d conflict - '%s' binds all interfaces, conflicts with '%s' (%s)\n",
 			port, existingService, serviceName, key)
 	}
 
@@ -192,6 +193,16 @@ func RegisterPort[T Port](c *PortConflictChecker, port T, serviceName string) {
 	c.Register(int(port), serviceName)
 }
 
+// CanBind はアドレスにバインドできるかを返す（IPv6が無効な環境で::1を除外するために使用）
+func CanBind(addr string) bool {
+	l, err := net.Listen("tcp", net.JoinHostPort(addr, "0"))
+	if err != nil {
+		return false
+	}
+	_ = l.Close()
+	return true
+}
+
 // FreeLocalPort は利用可能なローカルポートを取得
 func FreeLocalPort() (LocalPort, error) {
 	l, err := net.Listen("tcp", "127.0.0.1:0")
@@ -425,6 +425,31 @@ func TestPortConflictChecker_WithAddr(t *testing.T) {
 			t.Error("Expected warning: 0.0.0.0 binds to all interfaces and conflicts with specific IP")
 		}
 	})
+
+	t.Run(":: conflicts with any IP on same port", func(t *testing.T) {
+		var buf bytes.Buffer
+		origWriter := warnWriter
+		SetWarnWriter(&buf)
+		defer SetWarnWriter(origWriter)
+
+		checker := NewPortConflictChecker()
+		checker.RegisterWithAddr("::1", 80, "listener_port")
+		checker.RegisterWithAddr("::", 80, "users.localhost")
+
+		if !strings.Contains(buf.String(), "'users.localhost' binds all interfaces, conflicts with 'listener_port' ([::1]:80)") {
+			t.Errorf("Expected wildcard conflict warning with [::1]:80, got: %s", buf.String())
+		}
+	})
+}
+
+func TestCanBind(t *testing.T) {
+	if !CanBind("127.0.0.1") {
+		t.Error("expected 127.0.0.1 to be bindable")
+	}
+	// ループバック以外の未割り当てアドレスにはバインドできない
+	if CanBind("192.0.2.1") {
+		t.Error("expected 192.0.2.1 (TEST-NET-1) not to be bindable")
+	}
 }
 
 func TestFreeLocalPort(t *testing.T) {
@@ -0,0 +1,178 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"strings"
+)
+
+// backendContextKey はリクエストのcontextに転送先のバックエンド名を保持するキー
+type backendContextKey struct{}
+
+// handler はHTTPリスナーのリクエストをvirtual host・ルートで振り分けて転送する
+type handler struct {
+	key    string
+	server *Server
+}
+
+// ServeHTTP はHost（HTTP/2では:authority）でvirtual hostを選択して転送
+// 一致するvirtual host・ルートがない場合はEnvoyと同様に404を返す
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	l := h.server.listener(h.key)
+	if l == nil {
+		http.Error(w, "listener removed", http.StatusServiceUnavailable)
+		return
+	}
+
+	vh := l.matchVirtualHost(r.Host)
+	if vh == nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	rt := vh.matchRoute(r)
+	if rt == nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	name := rt.pickBackend()
+	ctx := context.WithValue(r.Context(), backendContextKey{}, name)
+	h.server.transport.proxy.ServeHTTP(w, r.WithContext(ctx))
+}
+
+// matchVirtualHost はHostヘッダーに一致するvirtual hostを返す（大文字小文字は区別しない）
+func (l *listenerPlan) matchVirtualHost(host string) *virtualHost {
+	host = strings.ToLower(host)
+	for _, vh := range l.virtualHosts {
+		for _, d := range vh.domains {
+			if d == host {
+				return vh
+			}
+		}
+	}
+	return nil
+}
+
+// matchRoute はパスとヘッダーの条件に最初に一致したルートを返す
+func (vh *virtualHost) matchRoute(r *http.Request) *route {
+	for i := range vh.routes {
+		rt := &vh.routes[i]
+		if !strings.HasPrefix(r.URL.Path, rt.prefix) {
+			continue
+		}
+		if rt.header != "" {
+			values := r.Header.Values(rt.header)
+			if len(values) == 0 || (rt.value != "" && values[0] != rt.value) {
+				continue
+			}
+		}
+		return rt
+	}
+	return nil
+}
+
+// pickBackend は重みに応じて転送先のバックエンドを選択
+func (rt *route) pickBackend() string {
+	if len(rt.backends) == 1 {
+		return rt.backends[0].name
+	}
+	total := 0
+	for _, b := range rt.backends {
+		total += b.weight
+	}
+	if total <= 0 {
+		return rt.backends[0].name
+	}
+	n := rand.IntN(total)
+	for _, b := range rt.backends {
+		if n < b.weight {
+			return b.name
+		}
+		n -= b.weight
+	}
+	return rt.backends[len(rt.backends)-1].name
+}
+
+// transport はバックエンドのプロトコルに応じてHTTP/1.1・h2cで転送する
+// 接続先はバックエンド名で識別し、接続時に現在のアドレス一覧を順に試す
+type transport struct {
+	lookup func(name string) *backend
+	h1     *http.Transport
+	h2     *http.Transport
+	proxy  *httputil.ReverseProxy
+}
+
+// newTransport はlookupでバックエンドを解決するtransportを生成
+func newTransport(lookup func(name string) *backend) *transport {
+	t := &transport{lookup: lookup}
+
+	t.h1 = &http.Transport{DialContext: t.dial}
+	t.h2 = &http.Transport{DialContext: t.dial, Protocols: new(http.Protocols)}
+	t.h2.Protocols.SetUnencryptedHTTP2(true)
+
+	t.proxy = &httputil.ReverseProxy{
+		// 元のHostヘッダーを維持し、接続先のみをバックエンドに置き換える
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			pr.Out.URL.Scheme = "http"
+			pr.Out.URL.Host, _ = pr.In.Context().Value(backendContextKey{}).(string)
+		},
+		Transport: t,
+		// gRPCのストリーミングのため、レスポンスは都度フラッシュする
+		FlushInterval: -1,
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			// Envoyと同様、バックエンドに接続できない場合は503を返す
+			http.Error(w, "upstream connect error: "+err.Error(), http.StatusServiceUnavailable)
+		},
+	}
+	return t
+}
+
+// RoundTrip はバックエンドのプロトコルに応じたTransportで転送
+func (t *transport) RoundTrip(r *http.Request) (*http.Response, error) {
+	b := t.lookup(r.URL.Hostname())
+	if b == nil {
+		return nil, errors.New("unknown backend " + r.URL.Hostname())
+	}
+	if b.h2 {
+		return t.h2.RoundTrip(r)
+	}
+	return t.h1.RoundTrip(r)
+}
+
+// dial はバックエンドのアドレスに先頭から順に接続し、最初に接続できたものを返す
+func (t *transport) dial(ctx context.Context, network, addr string) (net.Conn, error) {
+	name, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	b := t.lookup(name)
+	if b == nil {
+		return nil, errors.New("unknown backend " + name)
+	}
+	return dialBackend(ctx, b)
+}
+
+// closeIdleConnections は再利用待ちのバックエンド接続を閉じる
+func (t *transport) closeIdleConnections() {
+	t.h1.CloseIdleConnections()
+	t.h2.CloseIdleConnections()
+}
+
+// dialBackend はバックエンドのアドレスに先頭から順に接続する
+// すべて失敗した場合は最後のエラーを返す
+func dialBackend(ctx context.Context, b *backend) (net.Conn, error) {
+	var d net.Dialer
+	var lastErr error
+	for _, addr := range b.addrs {
+		conn, err := d.DialContext(ctx, "tcp", addr)
+		if err == nil {
+			return conn, nil
+		}
+		lastErr = err
+	}
+	return nil, lastErr
+}
@@ -0,0 +1,302 @@
+package proxy
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/usadamasa/kubectl-localmesh/internal/envoy"
+	"github.com/usadamasa/kubectl-localmesh/internal/port"
+)
+
+// plan はServiceConfigから生成したリスナー・ルート・バックエンドの一覧
+// Envoy設定のリスナー・virtual host・クラスタと同じ構成を持つ
+type plan struct {
+	listeners map[string]*listenerPlan // キーはlistenerKey
+	backends  map[string]*backend      // キーはEnvoyのクラスタ名
+}
+
+// listenerPlan は1つのリスナーのルーティング
+// HTTPリスナーはvirtualHosts、TCPリスナーはtcpBackendまたはsniBackendsを持つ
+type listenerPlan struct {
+	address      string // バインドするアドレス（host:port）
+	virtualHosts []*virtualHost
+	tcpBackend   string
+	sniBackends  map[string]string // SNIのサーバー名（小文字、"*."ワイルドカード可） -> バックエンド名
+}
+
+// isTCP はTCPリスナーの場合にtrueを返す
+func (l *listenerPlan) isTCP() bool {
+	return l.tcpBackend != "" || len(l.sniBackends) > 0
+}
+
+// virtualHost はHost・:authorityヘッダーで選択するルート一覧
+type virtualHost struct {
+	domains []string // 小文字のホスト名（host・host:port）
+	routes  []route  // 先に一致したルートが使われる
+}
+
+// route はパスのプレフィックスとヘッダーの条件、転送先のバックエンド
+type route struct {
+	prefix   string
+	header   string // 空の場合はヘッダーの条件なし
+	value    string // 空の場合はヘッダーの存在のみで判定
+	backends []weightedBackend
+}
+
+// weightedBackend は重み付き分散の転送先
+type weightedBackend struct {
+	name   string
+	weight int
+}
+
+// backend は転送先のアドレス一覧
+// 先頭から順に接続を試み、接続できたアドレスを使う（Envoyの優先度付きエンドポイントに相当）
+type backend struct {
+	addrs []string
+	h2    bool // HTTP/2（h2c）で接続する
+}
+
+// compile はServiceConfigからplanを生成
+// 組み込みプロキシで扱えない機能は警告として返す
+// bindAddrsは共通HTTPリスナーのバインドアドレス（空の場合はenvoy.DefaultBindAddress）
+func compile(listenerPort port.ListenerPort, bindAddrs []string, configs []envoy.ServiceConfig) (*plan, []string) {
+	p := &plan{
+		listeners: make(map[string]*listenerPlan),
+		backends:  make(map[string]*backend),
+	}
+	var warnings []string
+
+	for _, cfg := range configs {
+		switch b := cfg.Builder.(type) {
+		case *envoy.KubernetesServiceBuilder:
+			warnings = append(warnings, unsupportedFeatures(b)...)
+			p.addKubernetesBackends(b, cfg)
+			listenPort, addrs := int(listenerPort), bindAddrs
+			if b.OverwriteListenPort != 0 {
+				listenPort = int(b.OverwriteListenPort)
+				if len(b.BindAddresses) > 0 {
+					addrs = b.BindAddresses
+				}
+			}
+			routes := kubernetesRoutes(b, cfg.ClusterName)
+			if len(b.Listeners) == 0 {
+				vh := &virtualHost{
+					domains: lowerAll(b.Domains(listenPort)),
+					routes:  routes,
+				}
+				for _, l := range p.httpListeners(addrs, listenPort) {
+					l.virtualHosts = append(l.virtualHosts, vh)
+				}
+				break
+			}
+			// 共有HTTPリスナーごとに、そのポートを含むvirtual hostを追加する
+			for _, shared := range b.Listeners {
+				sharedPort := int(shared.EffectivePort(listenerPort))
+				vh := &virtualHost{
+					domains: lowerAll(b.Domains(sharedPort)),
+					routes:  routes,
+				}
+				for _, l := range p.httpListeners(bindAddrs, sharedPort) {
+					l.virtualHosts = append(l.virtualHosts, vh)
+				}
+			}
+
+		case *envoy.GRPCAggregateBuilder:
+			p.backends[cfg.ClusterName] = localBackend(int(cfg.LocalPort), "grpc")
+			var routes []route
+			for _, r := range b.Routes {
+				routes = append(routes, grpcServiceRoute(r.Service, r.ClusterName))
+			}
+			for _, s := range envoy.ReflectionServices {
+				routes = append(routes, grpcServiceRoute(s, cfg.ClusterName))
+			}
+			vh := &virtualHost{
+				domains: domains(b.Host, int(listenerPort)),
+				routes:  routes,
+			}
+			for _, l := range p.httpListeners(bindAddrs, int(listenerPort)) {
+				l.virtualHosts = append(l.virtualHosts, vh)
+			}
+
+		case *envoy.DashboardBuilder:
+			p.backends[cfg.ClusterName] = localBackend(int(cfg.LocalPort), "http")
+			vh := &virtualHost{
+				domains: domains(b.Host, int(listenerPort)),
+				routes:  []route{{prefix: "/", backends: []weightedBackend{{name: cfg.ClusterName, weight: 1}}}},
+			}
+			for _, l := range p.httpListeners(bindAddrs, int(listenerPort)) {
+				l.virtualHosts = append(l.virtualHosts, vh)
+			}
+
+		case *envoy.TCPServiceBuilder:
+			if b.AccessLog != nil {
+				warnings = append(warnings, fmt.Sprintf("%s: access_log is not supported by the builtin proxy", b.Host))
+			}
+			if b.Protocol != "" {
+				warnings = append(warnings, fmt.Sprintf("%s: protocol %s is not supported by the builtin proxy (proxied as plain tcp)", b.Host, b.Protocol))
+			}
+			p.backends[cfg.ClusterName] = &backend{addrs: []string{localAddress("127.0.0.1", int(cfg.LocalPort))}}
+			address := localAddress(b.ListenAddr, int(b.ListenPort))
+			if len(b.ServerNames) == 0 {
+				p.listeners[listenerKey("tcp", address)] = &listenerPlan{address: address, tcpBackend: cfg.ClusterName}
+				continue
+			}
+			// SNIで振り分けるサービスは同じアドレスのリスナーを共有する
+			key := listenerKey("tcp", address)
+			l, ok := p.listeners[key]
+			if !ok {
+				l = &listenerPlan{address: address, sniBackends: make(map[string]string)}
+				p.listeners[key] = l
+			}
+			for _, name := range b.ServerNames {
+				l.sniBackends[name] = cfg.ClusterName
+			}
+		}
+	}
+	return p, warnings
+}
+
+// httpListeners は各バインドアドレスのポートにバインドするHTTPリスナーを返す（未登録の場合は追加する）
+func (p *plan) httpListeners(addrs []string, listenPort int) []*listenerPlan {
+	if len(addrs) == 0 {
+		addrs = []string{envoy.DefaultBindAddress}
+	}
+	listeners := make([]*listenerPlan, 0, len(addrs))
+	for _, addr := range addrs {
+		address := localAddress(addr, listenPort)
+		key := listenerKey("http", address)
+		l, ok := p.listeners[key]
+		if !ok {
+			l = &listenerPlan{address: address}
+			p.listeners[key] = l
+		}
+		listeners = append(listeners, l)
+	}
+	return listeners
+}
+
+// addKubernetesBackends はKubernetes Serviceのメインと追加のバックエンドを登録
+func (p *plan) addKubernetesBackends(b *envoy.KubernetesServiceBuilder, cfg envoy.ServiceConfig) {
+	main := localBackend(int(cfg.LocalPort), b.Protocol)
+	switch {
+	case b.LocalOverride != nil:
+		// ローカルプロセスに接続できない間はport-forwardへフォールバックする
+		main.addrs = append([]string{localAddress(b.LocalOverride.Address, b.LocalOverride.Port)}, main.addrs...)
+	case len(b.Failover) > 0:
+		for _, u := range b.Failover {
+			main.addrs = append(main.addrs, localAddress("127.0.0.1", int(u.LocalPort)))
+		}
+	}
+	p.backends[cfg.ClusterName] = main
+
+	for _, u := range b.Splits {
+		p.backends[u.ClusterName] = localBackend(int(u.LocalPort), b.Protocol)
+	}
+	for _, r := range b.HeaderRoutes {
+		if r.Address == "" {
+			p.backends[r.ClusterName] = localBackend(int(r.LocalPort), b.Protocol)
+			continue
+		}
+		p.backends[r.ClusterName] = &backend{addrs: []string{localAddress(r.Address, int(r.LocalPort))}, h2: isHTTP2(b.Protocol)}
+	}
+}
+
+// kubernetesRoutes はヘッダー一致ルートとデフォルトルートを生成
+func kubernetesRoutes(b *envoy.KubernetesServiceBuilder, clusterName string) []route {
+	var routes []route
+	for _, r := range b.HeaderRoutes {
+		routes = append(routes, route{
+			prefix:   "/",
+			header:   r.Header,
+			value:    r.Value,
+			backends: []weightedBackend{{name: r.ClusterName, weight: 1}},
+		})
+	}
+
+	defaultRoute := route{prefix: "/", backends: []weightedBackend{{name: clusterName, weight: 1}}}
+	if len(b.Splits) > 0 {
+		defaultRoute.backends = []weightedBackend{{name: clusterName, weight: b.Weight}}
+		for _, u := range b.Splits {
+			defaultRoute.backends = append(defaultRoute.backends, weightedBackend{name: u.ClusterName, weight: u.Weight})
+		}
+	}
+	return append(routes, defaultRoute)
+}
+
+// grpcServiceRoute はサービス名プレフィックスでマッチするルートを生成
+func grpcServiceRoute(service, clusterName string) route {
+	return route{
+		prefix:   "/" + service + "/",
+		backends: []weightedBackend{{name: clusterName, weight: 1}},
+	}
+}
+
+// unsupportedFeatures は組み込みプロキシが無視するホスト単位の設定を返す
+func unsupportedFeatures(b *envoy.KubernetesServiceBuilder) []string {
+	var features []string
+	if b.Mirror != nil {
+		features = append(features, "mirror")
+	}
+	if b.RateLimit != nil {
+		features = append(features, "rate_limit")
+	}
+	if b.AccessLog != nil {
+		features = append(features, "access_log")
+	}
+	if b.Lua != "" {
+		features = append(features, "lua")
+	}
+	if len(b.RedirectHosts) > 0 || len(b.CookieDomains) > 0 {
+		features = append(features, "redirect_hosts/cookie_domains")
+	}
+	if b.LocalOverride != nil && b.LocalOverride.HealthPath != "" {
+		features = append(features, "local_override.health_path")
+	}
+
+	warnings := make([]string, 0, len(features))
+	for _, f := range features {
+		warnings = append(warnings, fmt.Sprintf("%s: %s is not supported by the builtin proxy", b.Host, f))
+	}
+	return warnings
+}
+
+// localBackend は127.0.0.1上のローカルポートを向くバックエンドを生成
+func localBackend(localPort int, protocol string) *backend {
+	return &backend{
+		addrs: []string{localAddress("127.0.0.1", localPort)},
+		h2:    isHTTP2(protocol),
+	}
+}
+
+// isHTTP2 はプロトコルがHTTP/2で接続するものかを判定
+func isHTTP2(protocol string) bool {
+	return protocol == "grpc" || protocol == "http2"
+}
+
+// domains はvirtual hostのドメインを生成
+// gRPCクライアントは:authorityヘッダーにhost:port形式で送信するため、両方のパターンを許可
+func domains(host string, listenPort int) []string {
+	host = strings.ToLower(host)
+	return []string{host, fmt.Sprintf("%s:%d", host, listenPort)}
+}
+
+// lowerAll はホスト名を小文字にして返す
+func lowerAll(hosts []string) []string {
+	lowered := make([]string, len(hosts))
+	for i, h := range hosts {
+		lowered[i] = strings.ToLower(h)
+	}
+	return lowered
+}
+
+// localAddress はアドレスとポートをhost:port形式に結合
+func localAddress(address string, p int) string {
+	return net.JoinHostPort(address, strconv.Itoa(p))
+}
+
+// listenerKey はリスナーを識別するキー（種類とバインドするアドレス）
+func listenerKey(kind, address string) string {
+	return kind + "/" + address
+}
@@ -0,0 +1,154 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"sync/atomic"
+
+	"github.com/usadamasa/kubectl-localmesh/internal/envoy"
+	"github.com/usadamasa/kubectl-localmesh/internal/log"
+	"github.com/usadamasa/kubectl-localmesh/internal/port"
+)
+
+// Server はEnvoyの代わりにホストベースのルーティングを行う組み込みプロキシ
+// Envoy設定と同じServiceConfigからリスナー・ルートを生成し、
+// Updateで差し替えると追加・削除されたリスナーのみを開閉する
+type Server struct {
+	logger    *log.Logger
+	plan      atomic.Pointer[plan]
+	transport *transport
+
+	mu        sync.Mutex
+	listeners map[string]*runningListener
+	version   int
+}
+
+// runningListener はバインド中のリスナー
+type runningListener struct {
+	close func()
+}
+
+// NewServer はリスナーを持たないServerを生成
+func NewServer(logger *log.Logger) *Server {
+	s := &Server{
+		logger:    logger,
+		listeners: make(map[string]*runningListener),
+	}
+	s.plan.Store(&plan{})
+	s.transport = newTransport(s.backend)
+	return s
+}
+
+// Update はServiceConfigからルーティングを生成して反映
+// bindAddrsは共通HTTPリスナーのバインドアドレス（空の場合はenvoy.DefaultBindAddress）
+// 新しいリスナーをすべてバインドできた場合のみ切り替え、失敗した場合は現在の状態を維持する
+func (s *Server) Update(listenerPort port.ListenerPort, bindAddrs []string, configs []envoy.ServiceConfig) error {
+	next, warnings := compile(listenerPort, bindAddrs, configs)
+	for _, w := range warnings {
+		fmt.Fprintf(os.Stderr, "warning: %s\n", w)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// 新しいリスナーを先にバインドし、失敗した場合はバインドした分を閉じる
+	started := make(map[string]*runningListener)
+	for key, l := range next.listeners {
+		if _, ok := s.listeners[key]; ok {
+			continue
+		}
+		rl, err := s.listen(key, l)
+		if err != nil {
+			for _, rl := range started {
+				rl.close()
+			}
+			return err
+		}
+		started[key] = rl
+	}
+
+	s.plan.Store(next)
+	for key, rl := range s.listeners {
+		if _, ok := next.listeners[key]; !ok {
+			rl.close()
+			delete(s.listeners, key)
+		}
+	}
+	for key, rl := range started {
+		s.listeners[key] = rl
+	}
+
+	// 削除・変更されたバックエンドへの接続を再利用しないようにする
+	s.transport.closeIdleConnections()
+
+	s.version++
+	s.logger.Debugf("builtin proxy config version %d applied (%d listeners)", s.version, len(s.listeners))
+	return nil
+}
+
+// Version は最後に反映した設定のバージョン（未反映の場合は0）
+func (s *Server) Version() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.version
+}
+
+// Run はcontextがキャンセルされるまでブロックし、すべてのリスナーと接続を閉じる
+func (s *Server) Run(ctx context.Context) error {
+	<-ctx.Done()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key, rl := range s.listeners {
+		rl.close()
+		delete(s.listeners, key)
+	}
+	s.transport.closeIdleConnections()
+	return nil
+}
+
+// listen はリスナーをバインドし、接続の受け付けを開始
+func (s *Server) listen(key string, l *listenerPlan) (*runningListener, error) {
+	lis, err := net.Listen("tcp", l.address)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %w", l.address, err)
+	}
+	s.logger.Debugf("builtin proxy listening on %s", l.address)
+
+	if l.isTCP() {
+		return s.serveTCP(key, lis), nil
+	}
+	return s.serveHTTP(key, lis), nil
+}
+
+// listener は現在のplanからリスナーのルーティングを返す（削除済みの場合はnil）
+func (s *Server) listener(key string) *listenerPlan {
+	return s.plan.Load().listeners[key]
+}
+
+// backend は現在のplanからバックエンドを返す（存在しない場合はnil）
+func (s *Server) backend(name string) *backend {
+	return s.plan.Load().backends[name]
+}
+
+// serveHTTP はHTTP/1.1とh2c（HTTP/2 prior knowledge）を受け付けるHTTPサーバーを起動
+func (s *Server) serveHTTP(key string, lis net.Listener) *runningListener {
+	protocols := new(http.Protocols)
+	protocols.SetHTTP1(true)
+	protocols.SetUnencryptedHTTP2(true)
+
+	srv := &http.Server{
+		Handler:   &handler{key: key, server: s},
+		Protocols: protocols,
+	}
+	go func() {
+		if err := srv.Serve(lis); err != nil && err != http.ErrServerClosed {
+			fmt.Fprintf(os.Stderr, "warning: builtin proxy listener %s stopped: %v\n", lis.Addr(), err)
+		}
+	}()
+	return &runningListener{close: func() { _ = srv.Close() }}
+}
@@ -0,0 +1,525 @@
+package proxy
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/usadamasa/kubectl-localmesh/internal/envoy"
+	"github.com/usadamasa/kubectl-localmesh/internal/log"
+	"github.com/usadamasa/kubectl-localmesh/internal/port"
+)
+
+// freePort は空いているローカルポートを返す
+func freePort(t *testing.T) int {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = lis.Close() }()
+	return lis.Addr().(*net.TCPAddr).Port
+}
+
+// backendServer はレスポンスに名前とプロトコルを返すHTTPバックエンドを起動し、ポートを返す
+// h2がtrueの場合はh2c（HTTP/2 prior knowledge）のみを受け付ける
+func backendServer(t *testing.T, name string, h2 bool) port.LocalPort {
+	t.Helper()
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Trailer", "X-Backend")
+		_, _ = fmt.Fprintf(w, "%s %s %s", name, r.Proto, r.Host)
+		w.Header().Set("X-Backend", name)
+	}))
+	if h2 {
+		srv.Config.Protocols = new(http.Protocols)
+		srv.Config.Protocols.SetUnencryptedHTTP2(true)
+	}
+	srv.Start()
+	t.Cleanup(srv.Close)
+
+	u, err := url.Parse(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, _ := strconv.Atoi(u.Port())
+	return port.LocalPort(p)
+}
+
+// httpService はKubernetes ServiceのServiceConfigを生成
+func httpService(host, protocol string, localPort port.LocalPort, listenPort port.IndividualListenerPort) envoy.ServiceConfig {
+	return envoy.ServiceConfig{
+		Builder:     envoy.NewKubernetesServiceBuilder(host, protocol, "default", host, "", 80, listenPort, ""),
+		ClusterName: "default_" + strings.ReplaceAll(host, ".", "_") + "_80",
+		LocalPort:   localPort,
+	}
+}
+
+// startServer は組み込みプロキシを起動し、テスト終了時に停止する
+func startServer(t *testing.T) *Server {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	s := NewServer(log.New("error"))
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_ = s.Run(ctx)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+	return s
+}
+
+// get はHostヘッダーを指定してリクエストし、ステータスと本文を返す
+func get(t *testing.T, client *http.Client, listenPort int, host string, header http.Header) (int, string) {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("http://127.0.0.1:%d/", listenPort), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Host = host
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("request to %s failed: %v", host, err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	body, _ := io.ReadAll(resp.Body)
+	return resp.StatusCode, string(body)
+}
+
+func TestServer_HostRouting(t *testing.T) {
+	s := startServer(t)
+	listenerPort := freePort(t)
+	users := backendServer(t, "users", false)
+	billing := backendServer(t, "billing", false)
+
+	err := s.Update(port.ListenerPort(listenerPort), nil, []envoy.ServiceConfig{
+		httpService("users.localhost", "http", users, 0),
+		httpService("billing.localhost", "http", billing, 0),
+	})
+	if err != nil {
+		t.Fatalf("failed to update: %v", err)
+	}
+
+	tests := []struct {
+		host       string
+		wantStatus int
+		wantBody   string
+	}{
+		{"users.localhost", http.StatusOK, "users HTTP/1.1 users.localhost"},
+		{fmt.Sprintf("billing.localhost:%d", listenerPort), http.StatusOK, fmt.Sprintf("billing HTTP/1.1 billing.localhost:%d", listenerPort)},
+		{"Users.Localhost", http.StatusOK, "users HTTP/1.1 Users.Localhost"},
+		{"unknown.localhost", http.StatusNotFound, ""},
+	}
+	for _, tt := range tests {
+		status, body := get(t, http.DefaultClient, listenerPort, tt.host, nil)
+		if status != tt.wantStatus || body != tt.wantBody {
+			t.Errorf("%s: expected %d %q, got %d %q", tt.host, tt.wantStatus, tt.wantBody, status, body)
+		}
+	}
+}
+
+func TestServer_H2C(t *testing.T) {
+	s := startServer(t)
+	listenerPort := freePort(t)
+	backend := backendServer(t, "greeter", true)
+
+	if err := s.Update(port.ListenerPort(listenerPort), nil, []envoy.ServiceConfig{
+		httpService("greeter.localhost", "grpc", backend, 0),
+	}); err != nil {
+		t.Fatalf("failed to update: %v", err)
+	}
+
+	// gRPCクライアントと同様にprior knowledgeのh2cで接続し、:authorityにhost:portを送る
+	h2c := &http.Transport{Protocols: new(http.Protocols)}
+	h2c.Protocols.SetUnencryptedHTTP2(true)
+	defer h2c.CloseIdleConnections()
+	client := &http.Client{Transport: h2c}
+
+	req, err := http.NewRequest(http.MethodPost, fmt.Sprintf("http://127.0.0.1:%d/greeter.v1.Greeter/Hello", listenerPort), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Host = fmt.Sprintf("greeter.localhost:%d", listenerPort)
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	body, _ := io.ReadAll(resp.Body)
+
+	if resp.ProtoMajor != 2 {
+		t.Errorf("expected HTTP/2 response, got %s", resp.Proto)
+	}
+	if want := "greeter HTTP/2.0 " + req.Host; string(body) != want {
+		t.Errorf("expected body %q, got %q", want, body)
+	}
+	// gRPCのステータスはトレーラーで返されるため、トレーラーが転送されること
+	if got := resp.Trailer.Get("X-Backend"); got != "greeter" {
+		t.Errorf("expected trailer X-Backend=greeter, got %q", got)
+	}
+}
+
+func TestServer_Aliases(t *testing.T) {
+	s := startServer(t)
+	listenerPort := freePort(t)
+	users := backendServer(t, "users", false)
+
+	svc := httpService("users.localhost", "http", users, 0)
+	b := svc.Builder.(*envoy.KubernetesServiceBuilder)
+	b.Aliases = []string{"users-api.users.svc.cluster.local"}
+	b.AliasPort = 8080
+	if err := s.Update(port.ListenerPort(listenerPort), nil, []envoy.ServiceConfig{svc}); err != nil {
+		t.Fatalf("failed to update: %v", err)
+	}
+
+	for _, host := range []string{"users-api.users.svc.cluster.local", "users-api.users.svc.cluster.local:8080"} {
+		if _, body := get(t, http.DefaultClient, listenerPort, host, nil); !strings.HasPrefix(body, "users ") {
+			t.Errorf("%s: expected users, got %q", host, body)
+		}
+	}
+}
+
+func TestServer_NamedListeners(t *testing.T) {
+	s := startServer(t)
+	listenerPort, webPort := freePort(t), freePort(t)
+	frontend := backendServer(t, "frontend", false)
+	users := backendServer(t, "users", false)
+
+	frontendSvc := httpService("frontend.localhost", "http", frontend, 0)
+	frontendSvc.Builder.(*envoy.KubernetesServiceBuilder).Listeners = []envoy.NamedListener{{Name: "web", Port: port.ListenerPort(webPort)}}
+	usersSvc := httpService("users.localhost", "http", users, 0)
+	usersSvc.Builder.(*envoy.KubernetesServiceBuilder).Listeners = []envoy.NamedListener{{}, {Name: "web", Port: port.ListenerPort(webPort)}}
+	if err := s.Update(port.ListenerPort(listenerPort), nil, []envoy.ServiceConfig{frontendSvc, usersSvc}); err != nil {
+		t.Fatalf("failed to update: %v", err)
+	}
+
+	// 共有リスナーでは接続した複数のホストを振り分ける
+	for host, want := range map[string]string{"frontend.localhost": "frontend ", "users.localhost": "users "} {
+		if _, body := get(t, http.DefaultClient, webPort, host, nil); !strings.HasPrefix(body, want) {
+			t.Errorf("%s: expected %q, got %q", host, want, body)
+		}
+	}
+	if _, body := get(t, http.DefaultClient, listenerPort, "users.localhost", nil); !strings.HasPrefix(body, "users ") {
+		t.Errorf("expected users on the main listener, got %q", body)
+	}
+	// defaultに接続していないホストは共通HTTPリスナーでは一致しない
+	if status, _ := get(t, http.DefaultClient, listenerPort, "frontend.localhost", nil); status != http.StatusNotFound {
+		t.Errorf("expected 404 for frontend on the main listener, got %d", status)
+	}
+}
+
+func TestServer_HeaderRouteAndFailover(t *testing.T) {
+	s := startServer(t)
+	listenerPort := freePort(t)
+	canary := backendServer(t, "canary", false)
+	fallback := backendServer(t, "fallback", false)
+
+	// メインのport-forwardは閉じているため、フェイルオーバー先に接続する
+	svc := httpService("users.localhost", "http", port.LocalPort(freePort(t)), 0)
+	b := svc.Builder.(*envoy.KubernetesServiceBuilder)
+	b.Failover = []envoy.Upstream{{ClusterName: "users_fallback", LocalPort: fallback}}
+	b.HeaderRoutes = []envoy.HeaderRoute{{Header: "X-Canary", Value: "true", Upstream: envoy.Upstream{ClusterName: "users_canary", LocalPort: canary}}}
+	if err := s.Update(port.ListenerPort(listenerPort), nil, []envoy.ServiceConfig{svc}); err != nil {
+		t.Fatalf("failed to update: %v", err)
+	}
+
+	if _, body := get(t, http.DefaultClient, listenerPort, "users.localhost", http.Header{"X-Canary": {"true"}}); !strings.HasPrefix(body, "canary ") {
+		t.Errorf("expected header route to canary, got %q", body)
+	}
+	if _, body := get(t, http.DefaultClient, listenerPort, "users.localhost", http.Header{"X-Canary": {"false"}}); !strings.HasPrefix(body, "fallback ") {
+		t.Errorf("expected default route to fail over, got %q", body)
+	}
+}
+
+func TestServer_Unreachable(t *testing.T) {
+	s := startServer(t)
+	listenerPort := freePort(t)
+
+	if err := s.Update(port.ListenerPort(listenerPort), nil, []envoy.ServiceConfig{
+		httpService("users.localhost", "http", port.LocalPort(freePort(t)), 0),
+	}); err != nil {
+		t.Fatalf("failed to update: %v", err)
+	}
+
+	if status, _ := get(t, http.DefaultClient, listenerPort, "users.localhost", nil); status != http.StatusServiceUnavailable {
+		t.Errorf("expected 503, got %d", status)
+	}
+}
+
+func TestServer_IndividualListener(t *testing.T) {
+	s := startServer(t)
+	listenerPort := freePort(t)
+	individualPort := freePort(t)
+	users := backendServer(t, "users", false)
+	admin := backendServer(t, "admin", false)
+
+	if err := s.Update(port.ListenerPort(listenerPort), nil, []envoy.ServiceConfig{
+		httpService("users.localhost", "http", users, 0),
+		httpService("admin.localhost", "http", admin, port.IndividualListenerPort(individualPort)),
+	}); err != nil {
+		t.Fatalf("failed to update: %v", err)
+	}
+
+	if _, body := get(t, http.DefaultClient, individualPort, "admin.localhost", nil); !strings.HasPrefix(body, "admin ") {
+		t.Errorf("expected admin on individual listener, got %q", body)
+	}
+	// 個別リスナーのホストは共通リスナーでは一致しない
+	if status, _ := get(t, http.DefaultClient, listenerPort, "admin.localhost", nil); status != http.StatusNotFound {
+		t.Errorf("expected 404 on shared listener, got %d", status)
+	}
+}
+
+func TestServer_Dashboard(t *testing.T) {
+	s := startServer(t)
+	listenerPort := freePort(t)
+	dashboard := backendServer(t, "dashboard", false)
+
+	if err := s.Update(port.ListenerPort(listenerPort), nil, []envoy.ServiceConfig{
+		{Builder: envoy.NewDashboardBuilder("localmesh.localhost"), ClusterName: envoy.DashboardClusterName, LocalPort: dashboard},
+	}); err != nil {
+		t.Fatalf("failed to update: %v", err)
+	}
+
+	if _, body := get(t, http.DefaultClient, listenerPort, "localmesh.localhost", nil); !strings.HasPrefix(body, "dashboard ") {
+		t.Errorf("expected dashboard, got %q", body)
+	}
+}
+
+func TestServer_TCP(t *testing.T) {
+	s := startServer(t)
+
+	// 受け取った行を大文字にして返すバックエンド
+	backend, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = backend.Close() }()
+	go func() {
+		for {
+			conn, err := backend.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer func() { _ = conn.Close() }()
+				line, _ := bufio.NewReader(conn).ReadString('\n')
+				_, _ = io.WriteString(conn, strings.ToUpper(line))
+			}()
+		}
+	}()
+
+	listenPort := freePort(t)
+	if err := s.Update(80, nil, []envoy.ServiceConfig{{
+		Builder:     envoy.NewTCPServiceBuilder("db.localhost", port.TCPPort(listenPort), "127.0.0.1", "bastion", "10.0.0.1", 5432),
+		ClusterName: "tcp_db_localhost",
+		LocalPort:   port.LocalPort(backend.Addr().(*net.TCPAddr).Port),
+	}}); err != nil {
+		t.Fatalf("failed to update: %v", err)
+	}
+
+	conn, err := net.DialTimeout("tcp", fmt.Sprintf("127.0.0.1:%d", listenPort), 5*time.Second)
+	if err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	defer func() { _ = conn.Close() }()
+	_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+	if _, err := io.WriteString(conn, "select 1\n"); err != nil {
+		t.Fatal(err)
+	}
+	got, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "SELECT 1\n" {
+		t.Errorf("expected echoed line, got %q", got)
+	}
+}
+
+func TestServer_SNI(t *testing.T) {
+	s := startServer(t)
+
+	// 名前を返すTLSバックエンド
+	tlsBackend := func(name string) port.LocalPort {
+		srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = io.WriteString(w, name)
+		}))
+		t.Cleanup(srv.Close)
+		return port.LocalPort(srv.Listener.Addr().(*net.TCPAddr).Port)
+	}
+	sniService := func(host string, localPort port.LocalPort, listenPort int, serverNames ...string) envoy.ServiceConfig {
+		b := envoy.NewTCPServiceBuilder(host, port.TCPPort(listenPort), "127.0.0.1", "bastion", "10.0.0.1", 5432)
+		b.ServerNames = serverNames
+		return envoy.ServiceConfig{Builder: b, ClusterName: "tcp_" + strings.ReplaceAll(host, ".", "_"), LocalPort: localPort}
+	}
+
+	listenPort := freePort(t)
+	if err := s.Update(80, nil, []envoy.ServiceConfig{
+		sniService("orders-db.localhost", tlsBackend("orders"), listenPort, "orders-db.localhost"),
+		sniService("users-db.localhost", tlsBackend("users"), listenPort, "users-db.localhost", "*.users.example.com"),
+	}); err != nil {
+		t.Fatalf("failed to update: %v", err)
+	}
+
+	tests := []struct {
+		serverName string
+		want       string
+	}{
+		{serverName: "orders-db.localhost", want: "orders"},
+		{serverName: "users-db.localhost", want: "users"},
+		{serverName: "replica.users.example.com", want: "users"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.serverName, func(t *testing.T) {
+			client := &http.Client{
+				Timeout: 5 * time.Second,
+				Transport: &http.Transport{
+					TLSClientConfig: &tls.Config{ServerName: tt.serverName, InsecureSkipVerify: true},
+				},
+			}
+			resp, err := client.Get(fmt.Sprintf("https://127.0.0.1:%d/", listenPort))
+			if err != nil {
+				t.Fatalf("request failed: %v", err)
+			}
+			defer func() { _ = resp.Body.Close() }()
+			body, _ := io.ReadAll(resp.Body)
+			if string(body) != tt.want {
+				t.Errorf("expected backend %q, got %q", tt.want, body)
+			}
+		})
+	}
+
+	// 一致するサーバー名がない場合は接続を閉じる
+	client := &http.Client{
+		Timeout:   5 * time.Second,
+		Transport: &http.Transport{TLSClientConfig: &tls.Config{ServerName: "unknown.localhost", InsecureSkipVerify: true}},
+	}
+	if _, err := client.Get(fmt.Sprintf("https://127.0.0.1:%d/", listenPort)); err == nil {
+		t.Error("expected unknown server name to be rejected")
+	}
+}
+
+func TestServer_Update(t *testing.T) {
+	s := startServer(t)
+	listenerPort := freePort(t)
+	individualPort := freePort(t)
+	users := backendServer(t, "users", false)
+
+	if err := s.Update(port.ListenerPort(listenerPort), nil, []envoy.ServiceConfig{
+		httpService("users.localhost", "http", users, port.IndividualListenerPort(individualPort)),
+	}); err != nil {
+		t.Fatalf("failed to update: %v", err)
+	}
+
+	// 個別リスナーから共通リスナーへ移すと、個別リスナーは閉じられる
+	if err := s.Update(port.ListenerPort(listenerPort), nil, []envoy.ServiceConfig{
+		httpService("users.localhost", "http", users, 0),
+	}); err != nil {
+		t.Fatalf("failed to update: %v", err)
+	}
+	if s.Version() != 2 {
+		t.Errorf("expected version 2, got %d", s.Version())
+	}
+	if _, body := get(t, http.DefaultClient, listenerPort, "users.localhost", nil); !strings.HasPrefix(body, "users ") {
+		t.Errorf("expected users on shared listener, got %q", body)
+	}
+	if conn, err := net.Dial("tcp", fmt.Sprintf("127.0.0.1:%d", individualPort)); err == nil {
+		_ = conn.Close()
+		t.Errorf("expected individual listener to be closed")
+	}
+}
+
+func TestServer_Update_BindFailure(t *testing.T) {
+	s := startServer(t)
+	listenerPort := freePort(t)
+	users := backendServer(t, "users", false)
+
+	if err := s.Update(port.ListenerPort(listenerPort), nil, []envoy.ServiceConfig{
+		httpService("users.localhost", "http", users, 0),
+	}); err != nil {
+		t.Fatalf("failed to update: %v", err)
+	}
+
+	// 使用中のポートへの個別リスナーはバインドに失敗し、現在のルーティングを維持する
+	busy, err := net.Listen("tcp", "0.0.0.0:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = busy.Close() }()
+	err = s.Update(port.ListenerPort(listenerPort), nil, []envoy.ServiceConfig{
+		httpService("admin.localhost", "http", users, port.IndividualListenerPort(busy.Addr().(*net.TCPAddr).Port)),
+	})
+	if err == nil {
+		t.Fatal("expected bind error")
+	}
+	if s.Version() != 1 {
+		t.Errorf("expected version 1, got %d", s.Version())
+	}
+	if status, _ := get(t, http.DefaultClient, listenerPort, "users.localhost", nil); status != http.StatusOK {
+		t.Errorf("expected users to keep routing, got %d", status)
+	}
+}
+
+func TestCompile_UnsupportedFeatures(t *testing.T) {
+	svc := httpService("users.localhost", "http", 10001, 0)
+	b := svc.Builder.(*envoy.KubernetesServiceBuilder)
+	b.Lua = "function envoy_on_request(h) end"
+	b.RateLimit = &envoy.RateLimit{Requests: 10, Burst: 10, FillInterval: time.Second}
+
+	_, warnings := compile(80, nil, []envoy.ServiceConfig{svc})
+	want := []string{
+		"users.localhost: rate_limit is not supported by the builtin proxy",
+		"users.localhost: lua is not supported by the builtin proxy",
+	}
+	if strings.Join(warnings, "\n") != strings.Join(want, "\n") {
+		t.Errorf("expected warnings %q, got %q", want, warnings)
+	}
+}
+
+func TestCompile_UnsupportedTCPProtocol(t *testing.T) {
+	b := envoy.NewTCPServiceBuilder("db.localhost", 15432, "127.0.0.1", "bastion", "10.0.0.1", 5432)
+	b.Protocol = "postgres"
+
+	_, warnings := compile(80, nil, []envoy.ServiceConfig{{Builder: b, ClusterName: "tcp_db", LocalPort: 10001}})
+	want := "db.localhost: protocol postgres is not supported by the builtin proxy (proxied as plain tcp)"
+	if len(warnings) != 1 || warnings[0] != want {
+		t.Errorf("expected warning %q, got %q", want, warnings)
+	}
+}
+
+func TestCompile_BindAddresses(t *testing.T) {
+	users := httpService("users.localhost", "http", 10001, 0)
+	admin := httpService("admin.localhost", "http", 10002, 8081)
+	admin.Builder.(*envoy.KubernetesServiceBuilder).BindAddresses = []string{"0.0.0.0"}
+
+	p, _ := compile(80, []string{"127.0.0.1", "::1"}, []envoy.ServiceConfig{users, admin})
+	var keys []string
+	for key := range p.listeners {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+
+	// 共通HTTPリスナーは各アドレスにバインドし、個別リスナーはサービスのアドレスを優先する
+	want := []string{"http/0.0.0.0:8081", "http/127.0.0.1:80", "http/[::1]:80"}
+	if !slices.Equal(keys, want) {
+		t.Errorf("expected listeners %q, got %q", want, keys)
+	}
+	if got := p.listeners["http/[::1]:80"].virtualHosts; len(got) != 1 || got[0].domains[0] != "users.localhost" {
+		t.Errorf("expected users.localhost on [::1]:80, got %v", got)
+	}
+}
@@ -0,0 +1,71 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+// sniPeekTimeout はClientHelloの受信を待つ時間
+const sniPeekTimeout = 5 * time.Second
+
+// errClientHelloRead はClientHelloを読み取った後にハンドシェイクを中断するためのエラー
+var errClientHelloRead = errors.New("client hello read")
+
+// matchServerName はSNIのサーバー名に一致するバックエンド名を返す
+// Envoyのserver_namesと同様に完全一致を優先し、次に最も長いサフィックスの"*."ワイルドカードを使う
+func (l *listenerPlan) matchServerName(name string) (string, bool) {
+	name = strings.ToLower(name)
+	if b, ok := l.sniBackends[name]; ok {
+		return b, true
+	}
+	for rest := name; ; {
+		i := strings.IndexByte(rest, '.')
+		if i < 0 {
+			return "", false
+		}
+		rest = rest[i+1:]
+		if b, ok := l.sniBackends["*."+rest]; ok {
+			return b, true
+		}
+	}
+}
+
+// peekServerName はTLSのClientHelloからSNIのサーバー名を読み取る
+// 読み取ったバイト列は返すreaderから再度読めるため、そのままバックエンドへ転送できる
+func peekServerName(ctx context.Context, conn net.Conn) (string, io.Reader, error) {
+	var buf bytes.Buffer
+	_ = conn.SetReadDeadline(time.Now().Add(sniPeekTimeout))
+	defer func() { _ = conn.SetReadDeadline(time.Time{}) }()
+
+	var serverName string
+	err := tls.Server(readOnlyConn{r: io.TeeReader(conn, &buf)}, &tls.Config{
+		GetConfigForClient: func(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+			serverName = hello.ServerName
+			return nil, errClientHelloRead
+		},
+	}).HandshakeContext(ctx)
+	if !errors.Is(err, errClientHelloRead) {
+		return "", nil, err
+	}
+	return serverName, io.MultiReader(&buf, conn), nil
+}
+
+// readOnlyConn はClientHelloの読み取り専用のnet.Conn（書き込みは失敗させる）
+type readOnlyConn struct {
+	r io.Reader
+}
+
+func (c readOnlyConn) Read(p []byte) (int, error)     { return c.r.Read(p) }
+func (readOnlyConn) Write([]byte) (int, error)        { return 0, io.ErrClosedPipe }
+func (readOnlyConn) Close() error                     { return nil }
+func (readOnlyConn) LocalAddr() net.Addr              { return nil }
+func (readOnlyConn) RemoteAddr() net.Addr             { return nil }
+func (readOnlyConn) SetDeadline(time.Time) error      { return nil }
+func (readOnlyConn) SetReadDeadline(time.Time) error  { return nil }
+func (readOnlyConn) SetWriteDeadline(time.Time) error { return nil }
@@ -0,0 +1,100 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sync"
+)
+
+// serveTCP はTCPリスナーの接続をバックエンドへ転送するループを起動
+// リスナーを閉じると転送中の接続も閉じる
+func (s *Server) serveTCP(key string, lis net.Listener) *runningListener {
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+
+	go func() {
+		for {
+			conn, err := lis.Accept()
+			if err != nil {
+				if !errors.Is(err, net.ErrClosed) {
+					fmt.Fprintf(os.Stderr, "warning: builtin proxy listener %s stopped: %v\n", lis.Addr(), err)
+				}
+				return
+			}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				s.proxyTCP(ctx, key, conn)
+			}()
+		}
+	}()
+
+	return &runningListener{close: func() {
+		_ = lis.Close()
+		cancel()
+		wg.Wait()
+	}}
+}
+
+// proxyTCP は接続を現在のplanのバックエンドへ双方向に転送
+func (s *Server) proxyTCP(ctx context.Context, key string, conn net.Conn) {
+	defer func() { _ = conn.Close() }()
+
+	l := s.listener(key)
+	if l == nil {
+		return
+	}
+
+	// SNIで振り分けるリスナーはClientHelloを読み取ってからバックエンドを選ぶ
+	name := l.tcpBackend
+	var src io.Reader = conn
+	if len(l.sniBackends) > 0 {
+		serverName, r, err := peekServerName(ctx, conn)
+		if err != nil {
+			s.logger.Debugf("builtin proxy: failed to read TLS client hello on %s: %v", l.address, err)
+			return
+		}
+		var ok bool
+		if name, ok = l.matchServerName(serverName); !ok {
+			s.logger.Debugf("builtin proxy: no service for server name %q on %s", serverName, l.address)
+			return
+		}
+		src = r
+	}
+
+	b := s.backend(name)
+	if b == nil {
+		return
+	}
+	upstream, err := dialBackend(ctx, b)
+	if err != nil {
+		s.logger.Debugf("builtin proxy: failed to connect to %s: %v", name, err)
+		return
+	}
+	defer func() { _ = upstream.Close() }()
+
+	// 片方向の終了は相手側へ書き込みの終了として伝え、両方向の終了かリスナーが閉じられるまで待つ
+	done := make(chan struct{}, 2)
+	go pipe(upstream, src, done)
+	go pipe(conn, upstream, done)
+	for range 2 {
+		select {
+		case <-done:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// pipe はsrcからdstへコピーし、終了後にdstの書き込みを閉じる
+func pipe(dst net.Conn, src io.Reader, done chan<- struct{}) {
+	_, _ = io.Copy(dst, src)
+	if c, ok := dst.(*net.TCPConn); ok {
+		_ = c.CloseWrite()
+	}
+	done <- struct{}{}
+}
@@ -0,0 +1,57 @@
+package run
+
+import (
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/usadamasa/kubectl-localmesh/internal/config"
+	"github.com/usadamasa/kubectl-localmesh/internal/port"
+)
+
+// bindAddresses は共通HTTPリスナーのバインドアドレスを返す
+// bind_address省略時の既定アドレスのうち、IPv6が無効な環境などでバインドできないもの（::1）は除外する
+func bindAddresses(cfg *config.Config) []string {
+	if cfg.BindAddress != "" {
+		return []string{cfg.BindAddress}
+	}
+	var addrs []string
+	for _, addr := range config.DefaultBindAddresses {
+		if port.CanBind(addr) {
+			addrs = append(addrs, addr)
+		}
+	}
+	return addrs
+}
+
+// hostsIP はバインドアドレスのリスナーにローカルから接続するための/etc/hostsのIPを返す
+// loopbackまたは全インターフェースにバインドする場合は127.0.0.1、それ以外は先頭のアドレス
+func hostsIP(addrs []string) string {
+	for _, addr := range addrs {
+		if ip := net.ParseIP(addr); ip.Equal(net.IPv4(127, 0, 0, 1)) || ip.IsUnspecified() {
+			return "127.0.0.1"
+		}
+	}
+	if len(addrs) == 0 {
+		return "127.0.0.1"
+	}
+	return addrs[0]
+}
+
+// formatBindAddresses はサマリー・ログ表示用にバインドアドレスとポートを整形する
+// loopback以外にバインドする場合は他のホストから到達できることを付記する
+func formatBindAddresses(addrs []string, p int) string {
+	formatted := make([]string, len(addrs))
+	exposed := false
+	for i, addr := range addrs {
+		formatted[i] = net.JoinHostPort(addr, strconv.Itoa(p))
+		if !net.ParseIP(addr).IsLoopback() {
+			exposed = true
+		}
+	}
+	s := strings.Join(formatted, ", ")
+	if exposed {
+		s += " (reachable from other hosts)"
+	}
+	return s
+}
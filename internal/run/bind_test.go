@@ -0,0 +1,46 @@
+package run
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestBindAddresses(t *testing.T) {
+	// 既定では127.0.0.1にバインドし、::1は利用できる場合のみ追加する
+	addrs := bindAddresses(loadTestConfig(t, meshBaseConfig))
+	if len(addrs) == 0 || addrs[0] != "127.0.0.1" {
+		t.Errorf("expected 127.0.0.1 first, got %v", addrs)
+	}
+
+	addrs = bindAddresses(loadTestConfig(t, "bind_address: 0.0.0.0\n"+meshBaseConfig))
+	if !slices.Equal(addrs, []string{"0.0.0.0"}) {
+		t.Errorf("expected [0.0.0.0], got %v", addrs)
+	}
+}
+
+func TestHostsIP(t *testing.T) {
+	tests := []struct {
+		addrs []string
+		want  string
+	}{
+		{[]string{"127.0.0.1", "::1"}, "127.0.0.1"},
+		{[]string{"0.0.0.0"}, "127.0.0.1"},
+		{[]string{"::"}, "127.0.0.1"},
+		{[]string{"::1"}, "::1"},
+		{[]string{"192.168.1.5"}, "192.168.1.5"},
+	}
+	for _, tt := range tests {
+		if got := hostsIP(tt.addrs); got != tt.want {
+			t.Errorf("hostsIP(%v) = %s, want %s", tt.addrs, got, tt.want)
+		}
+	}
+}
+
+func TestFormatBindAddresses(t *testing.T) {
+	if got := formatBindAddresses([]string{"127.0.0.1", "::1"}, 80); got != "127.0.0.1:80, [::1]:80" {
+		t.Errorf("unexpected format: %s", got)
+	}
+	if got := formatBindAddresses([]string{"0.0.0.0"}, 8081); got != "0.0.0.0:8081 (reachable from other hosts)" {
+		t.Errorf("unexpected format: %s", got)
+	}
+}
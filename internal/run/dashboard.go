@@ -0,0 +1,172 @@
+package run
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/usadamasa/kubectl-localmesh/internal/dashboard"
+	"github.com/usadamasa/kubectl-localmesh/internal/envoy"
+	"github.com/usadamasa/kubectl-localmesh/internal/port"
+)
+
+// tunnelStatus はport-forward・SSH tunnelの接続状態（ダッシュボード表示用）
+type tunnelStatus struct {
+	target string
+
+	mu         sync.Mutex
+	state      string
+	pod        string
+	since      time.Time
+	reconnects int
+}
+
+func newTunnelStatus(target string) *tunnelStatus {
+	return &tunnelStatus{target: target, state: dashboard.StateConnecting, since: time.Now()}
+}
+
+// set は確立・切断の通知で状態を更新する（同じ状態の通知は無視する）
+func (t *tunnelStatus) set(ready bool, pod string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	state := dashboard.StateReady
+	if !ready {
+		if t.state != dashboard.StateReady {
+			return
+		}
+		state = dashboard.StateReconnecting
+		t.reconnects++
+	}
+	if state == t.state && pod == t.pod {
+		return
+	}
+	t.state = state
+	t.pod = pod
+	t.since = time.Now()
+}
+
+// snapshot は現在の状態を返す
+func (t *tunnelStatus) snapshot() dashboard.Tunnel {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return dashboard.Tunnel{
+		Target:     t.target,
+		State:      t.state,
+		Pod:        t.pod,
+		Since:      t.since,
+		Reconnects: t.reconnects,
+	}
+}
+
+// meshView はダッシュボードが参照するmeshの状態
+// applyのたびに差し替え、設定の再読み込みと並行して参照できるようにする
+type meshView struct {
+	hosts        []*meshHost
+	aggregate    *aggregateHost
+	listenerPort port.ListenerPort
+}
+
+// services はダッシュボードに表示するサービスを設定ファイルの順に返す
+func (v *meshView) services() []dashboard.Service {
+	services := make([]dashboard.Service, 0, len(v.hosts)+1)
+	for _, h := range v.hosts {
+		configs := h.visitor.GetServiceConfigs()
+		summaries := h.visitor.GetServiceSummaries()
+		for i, sc := range configs {
+			svc := dashboardService(sc, v.listenerPort)
+			if i < len(summaries) {
+				svc.Backend = summaries[i].Backend
+				svc.Details = summaries[i].Details
+			}
+			for _, t := range h.visitor.tunnels {
+				svc.Tunnels = append(svc.Tunnels, t.snapshot())
+			}
+			services = append(services, svc)
+		}
+	}
+	if v.aggregate != nil {
+		svc := dashboardService(v.aggregate.config, v.listenerPort)
+		svc.Backend = v.aggregate.summary.Backend
+		services = append(services, svc)
+	}
+	return services
+}
+
+// dashboardService はServiceConfigからダッシュボードの表示内容を生成する
+// URLはブラウザで開けるHTTPサービスのみに設定する
+func dashboardService(sc envoy.ServiceConfig, listenerPort port.ListenerPort) dashboard.Service {
+	svc := dashboard.Service{Clusters: []string{sc.ClusterName}}
+	switch b := sc.Builder.(type) {
+	case *envoy.KubernetesServiceBuilder:
+		listenPort := int(listenerPort)
+		if b.OverwriteListenPort != 0 {
+			listenPort = int(b.OverwriteListenPort)
+		} else if len(b.Listeners) > 0 {
+			// 複数の共有リスナーに接続する場合は最初のリスナーのURLを表示する
+			listenPort = int(b.Listeners[0].EffectivePort(listenerPort))
+		}
+		svc.Host = b.Host
+		svc.Protocol = b.Protocol
+		svc.Address = net.JoinHostPort(b.Host, strconv.Itoa(listenPort))
+		if b.Protocol != "grpc" {
+			svc.URL = httpURL(b.Host, listenPort)
+		}
+		for _, r := range b.HeaderRoutes {
+			svc.Clusters = append(svc.Clusters, r.ClusterName)
+		}
+		for _, s := range b.Splits {
+			svc.Clusters = append(svc.Clusters, s.ClusterName)
+		}
+		if b.Mirror != nil {
+			svc.MirrorClusters = []string{b.Mirror.ClusterName}
+		}
+	case *envoy.TCPServiceBuilder:
+		svc.Host = b.Host
+		svc.Protocol = "tcp"
+		svc.Address = net.JoinHostPort(b.Host, strconv.Itoa(int(b.ListenPort)))
+		if b.Protocol != "" {
+			svc.QueryStatPrefix = b.Protocol + "." + envoy.DatabaseStatPrefix(b.Host)
+		}
+	case *envoy.GRPCAggregateBuilder:
+		svc.Host = b.Host
+		svc.Protocol = "grpc"
+		svc.Address = net.JoinHostPort(b.Host, strconv.Itoa(int(listenerPort)))
+		for _, r := range b.Routes {
+			svc.Clusters = append(svc.Clusters, r.ClusterName)
+		}
+	}
+	return svc
+}
+
+// httpURL はホストとリスナーポートからURLを生成（80番の場合はポートを省略）
+func httpURL(host string, listenPort int) string {
+	if listenPort == 80 {
+		return "http://" + host
+	}
+	return "http://" + net.JoinHostPort(host, strconv.Itoa(listenPort))
+}
+
+// startDashboard はダッシュボードサーバーを127.0.0.1の空きポートで起動する（contextキャンセル時に停止）
+func startDashboard(ctx context.Context, handler http.Handler) (port.LocalPort, error) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, err
+	}
+	srv := &http.Server{Handler: handler, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		<-ctx.Done()
+		_ = srv.Close()
+	}()
+	go func() {
+		if err := srv.Serve(lis); err != nil && err != http.ErrServerClosed {
+			fmt.Fprintf(os.Stderr, "dashboard server error: %v\n", err)
+		}
+	}()
+	return port.LocalPort(lis.Addr().(*net.TCPAddr).Port), nil
+}
@@ -0,0 +1,100 @@
+package run
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/usadamasa/kubectl-localmesh/internal/dashboard"
+	"github.com/usadamasa/kubectl-localmesh/internal/envoy"
+)
+
+func TestTunnelStatus(t *testing.T) {
+	s := newTunnelStatus("users/users-api:8080")
+	if got := s.snapshot(); got.State != dashboard.StateConnecting || got.Reconnects != 0 {
+		t.Fatalf("unexpected initial state: %+v", got)
+	}
+
+	// 確立前の切断は再接続として数えない
+	s.set(false, "")
+	if got := s.snapshot(); got.State != dashboard.StateConnecting {
+		t.Errorf("expected connecting, got %s", got.State)
+	}
+
+	s.set(true, "users-api-abc")
+	if got := s.snapshot(); got.State != dashboard.StateReady || got.Pod != "users-api-abc" {
+		t.Errorf("unexpected state: %+v", got)
+	}
+
+	// 切断の通知が重複しても1回として数える
+	s.set(false, "")
+	s.set(false, "")
+	if got := s.snapshot(); got.State != dashboard.StateReconnecting || got.Reconnects != 1 {
+		t.Errorf("unexpected state: %+v", got)
+	}
+
+	s.set(true, "users-api-def")
+	if got := s.snapshot(); got.State != dashboard.StateReady || got.Pod != "users-api-def" || got.Reconnects != 1 {
+		t.Errorf("unexpected state: %+v", got)
+	}
+}
+
+func TestDashboardService(t *testing.T) {
+	t.Run("http", func(t *testing.T) {
+		b := envoy.NewKubernetesServiceBuilder("users.localhost", "http", "users", "users-api", "", 8080, 0, "")
+		b.Mirror = &envoy.MirrorUpstream{Upstream: envoy.Upstream{ClusterName: "users_users_api_8080_mirror"}}
+		svc := dashboardService(envoy.ServiceConfig{Builder: b, ClusterName: "users_users_api_8080"}, 80)
+
+		if svc.URL != "http://users.localhost" || svc.Address != "users.localhost:80" {
+			t.Errorf("unexpected url/address: %s %s", svc.URL, svc.Address)
+		}
+		if want := []string{"users_users_api_8080"}; !slices.Equal(svc.Clusters, want) {
+			t.Errorf("expected clusters %v, got %v", want, svc.Clusters)
+		}
+		// ミラー先は本来のupstreamと別に集計する
+		if want := []string{"users_users_api_8080_mirror"}; !slices.Equal(svc.MirrorClusters, want) {
+			t.Errorf("expected mirror clusters %v, got %v", want, svc.MirrorClusters)
+		}
+	})
+
+	t.Run("individual listener", func(t *testing.T) {
+		b := envoy.NewKubernetesServiceBuilder("admin.localhost", "http", "admin", "admin", "", 8080, 8081, "")
+		svc := dashboardService(envoy.ServiceConfig{Builder: b, ClusterName: "admin_admin_8080"}, 80)
+		if svc.URL != "http://admin.localhost:8081" {
+			t.Errorf("unexpected url: %s", svc.URL)
+		}
+	})
+
+	t.Run("shared listeners", func(t *testing.T) {
+		b := envoy.NewKubernetesServiceBuilder("web.localhost", "http", "web", "frontend", "", 8080, 0, "")
+		b.Listeners = []envoy.NamedListener{{Name: "web", Port: 3000}, {}}
+		svc := dashboardService(envoy.ServiceConfig{Builder: b, ClusterName: "web_frontend_8080"}, 80)
+		if svc.URL != "http://web.localhost:3000" {
+			t.Errorf("unexpected url: %s", svc.URL)
+		}
+	})
+
+	t.Run("grpc", func(t *testing.T) {
+		b := envoy.NewKubernetesServiceBuilder("api.localhost", "grpc", "api", "api", "", 50051, 0, "")
+		svc := dashboardService(envoy.ServiceConfig{Builder: b, ClusterName: "api_api_50051"}, 8080)
+		if svc.URL != "" || svc.Address != "api.localhost:8080" {
+			t.Errorf("expected no url for grpc, got %q (%s)", svc.URL, svc.Address)
+		}
+	})
+
+	t.Run("tcp", func(t *testing.T) {
+		b := envoy.NewTCPServiceBuilder("db.localhost", 5432, "127.0.0.2", "primary", "10.0.0.1", 5432)
+		svc := dashboardService(envoy.ServiceConfig{Builder: b, ClusterName: "tcp_primary_10_0_0_1_5432"}, 80)
+		if svc.Protocol != "tcp" || svc.URL != "" || svc.Address != "db.localhost:5432" {
+			t.Errorf("unexpected service: %+v", svc)
+		}
+	})
+
+	t.Run("database", func(t *testing.T) {
+		b := envoy.NewTCPServiceBuilder("orders-db.localdomain", 5432, "127.0.0.2", "primary", "10.0.0.1", 5432)
+		b.Protocol = "postgres"
+		svc := dashboardService(envoy.ServiceConfig{Builder: b, ClusterName: "tcp_primary_10_0_0_1_5432"}, 80)
+		if svc.QueryStatPrefix != "postgres.orders_db_localdomain" {
+			t.Errorf("unexpected query stat prefix: %q", svc.QueryStatPrefix)
+		}
+	})
+}
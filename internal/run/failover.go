@@ -0,0 +1,116 @@
+package run
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/usadamasa/kubectl-localmesh/internal/k8s"
+	"github.com/usadamasa/kubectl-localmesh/internal/log"
+)
+
+// failoverWaitTimeout はサマリー出力前に最優先clusterのport-forward確立を待つ時間
+const failoverWaitTimeout = 5 * time.Second
+
+// failoverGroup はクラスタ間フェイルオーバーするサービスのport-forward状態を管理する
+// clustersは優先順で、Readyなport-forwardを持つ最も優先度の高いclusterをアクティブとする
+type failoverGroup struct {
+	host     string
+	clusters []string
+	logger   *log.Logger
+
+	mu     sync.Mutex
+	ready  []bool
+	active int // アクティブなclusterのindex（-1: なし）
+
+	primaryReady chan struct{}
+	primaryOnce  sync.Once
+}
+
+func newFailoverGroup(host string, clusters []string, logger *log.Logger) *failoverGroup {
+	return &failoverGroup{
+		host:         host,
+		clusters:     clusters,
+		logger:       logger,
+		ready:        make([]bool, len(clusters)),
+		active:       -1,
+		primaryReady: make(chan struct{}),
+	}
+}
+
+// options はpriority番目のclusterのport-forward用オプションを返す
+func (g *failoverGroup) options(priority int) k8s.PortForwardOptions {
+	return k8s.PortForwardOptions{
+		RequireReadyPod: true,
+		OnStateChange: func(ready bool, _ string) {
+			g.setReady(priority, ready)
+		},
+	}
+}
+
+// setReady はport-forwardの状態を更新し、アクティブなclusterの変化をログ出力する
+func (g *failoverGroup) setReady(priority int, ready bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.ready[priority] = ready
+	if priority == 0 && ready {
+		g.primaryOnce.Do(func() { close(g.primaryReady) })
+	}
+
+	prev := g.active
+	g.active = -1
+	for i, r := range g.ready {
+		if r {
+			g.active = i
+			break
+		}
+	}
+	if prev == g.active {
+		return
+	}
+
+	switch {
+	case prev < 0:
+		g.logger.Debugf("failover: %s active cluster -> %s", g.host, g.clusters[g.active])
+	case g.active < 0:
+		g.logger.Infof("failover: %s has no ready cluster (was %s)", g.host, g.clusters[prev])
+	default:
+		g.logger.Infof("failover: %s active cluster %s -> %s", g.host, g.clusters[prev], g.clusters[g.active])
+	}
+}
+
+// Active はアクティブなcluster名を返す（なければ空文字）
+func (g *failoverGroup) Active() string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.active < 0 {
+		return ""
+	}
+	return g.clusters[g.active]
+}
+
+// waitPrimary は最優先clusterのport-forward確立をtimeoutまで待つ
+func (g *failoverGroup) waitPrimary(ctx context.Context, timeout time.Duration) {
+	if timeout <= 0 {
+		return
+	}
+	select {
+	case <-g.primaryReady:
+	case <-ctx.Done():
+	case <-time.After(timeout):
+	}
+}
+
+// details はサマリー表示用の補足情報を返す
+func (g *failoverGroup) details() []string {
+	active := g.Active()
+	if active == "" {
+		active = "none (no ready pods)"
+	}
+	return []string{
+		"clusters (priority order): " + strings.Join(g.clusters, ", "),
+		"active cluster: " + active,
+	}
+}
@@ -0,0 +1,61 @@
+package run
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/usadamasa/kubectl-localmesh/internal/log"
+)
+
+func TestFailoverGroup_ActiveCluster(t *testing.T) {
+	var buf bytes.Buffer
+	g := newFailoverGroup("users.localhost", []string{"tokyo", "osaka"}, log.NewWithWriter("info", &buf))
+
+	if g.Active() != "" {
+		t.Errorf("expected no active cluster, got %q", g.Active())
+	}
+
+	// セカンダリのみReady
+	g.options(1).OnStateChange(true, "pod")
+	if g.Active() != "osaka" {
+		t.Errorf("expected active cluster 'osaka', got %q", g.Active())
+	}
+
+	// プライマリがReadyになれば優先度の高いプライマリに戻る
+	g.options(0).OnStateChange(true, "pod")
+	if g.Active() != "tokyo" {
+		t.Errorf("expected active cluster 'tokyo', got %q", g.Active())
+	}
+
+	// プライマリが切断されるとセカンダリへ切り替わる
+	g.options(0).OnStateChange(false, "pod")
+	if g.Active() != "osaka" {
+		t.Errorf("expected active cluster 'osaka', got %q", g.Active())
+	}
+	if !strings.Contains(buf.String(), "failover: users.localhost active cluster tokyo -> osaka") {
+		t.Errorf("expected failover log, got:\n%s", buf.String())
+	}
+
+	details := g.details()
+	if len(details) != 2 || details[1] != "active cluster: osaka" {
+		t.Errorf("unexpected details: %v", details)
+	}
+}
+
+func TestFailoverGroup_WaitPrimary(t *testing.T) {
+	g := newFailoverGroup("users.localhost", []string{"tokyo", "osaka"}, log.New("warn"))
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		g.options(0).OnStateChange(true, "pod")
+	}()
+
+	start := time.Now()
+	g.waitPrimary(context.Background(), 5*time.Second)
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("expected waitPrimary to return once primary is ready, elapsed: %v", elapsed)
+	}
+}
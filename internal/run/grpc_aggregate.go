@@ -0,0 +1,70 @@
+package run
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+
+	"github.com/usadamasa/kubectl-localmesh/internal/config"
+	"github.com/usadamasa/kubectl-localmesh/internal/envoy"
+	"github.com/usadamasa/kubectl-localmesh/internal/grpcagg"
+	"github.com/usadamasa/kubectl-localmesh/internal/log"
+	"github.com/usadamasa/kubectl-localmesh/internal/port"
+)
+
+// setupGRPCAggregate は集約gRPCホストを構築する
+// protocol: grpcの各サービスへリフレクションで問い合わせてルーティングを解決し、
+// 統合リフレクションサーバーを起動する
+func setupGRPCAggregate(
+	ctx context.Context,
+	agg *config.GRPCAggregate,
+	configs []envoy.ServiceConfig,
+	logger *log.Logger,
+) (envoy.ServiceConfig, log.ServiceSummary, error) {
+	var backends []grpcagg.Backend
+	for _, sc := range configs {
+		if b, ok := sc.Builder.(*envoy.KubernetesServiceBuilder); ok && b.Protocol == "grpc" {
+			backends = append(backends, grpcagg.Backend{
+				Host:        b.Host,
+				ClusterName: sc.ClusterName,
+				Address:     fmt.Sprintf("127.0.0.1:%d", sc.LocalPort),
+			})
+		}
+	}
+
+	results := grpcagg.Discover(ctx, backends, grpcagg.DefaultDiscoveryTimeout, logger)
+	routes := grpcagg.Routes(results)
+
+	srv, err := grpcagg.NewReflectionServer(results)
+	if err != nil {
+		return envoy.ServiceConfig{}, log.ServiceSummary{}, fmt.Errorf("failed to create grpc reflection server: %w", err)
+	}
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return envoy.ServiceConfig{}, log.ServiceSummary{}, err
+	}
+	localPort := port.LocalPort(lis.Addr().(*net.TCPAddr).Port)
+
+	go func() {
+		if err := srv.Serve(ctx, lis); err != nil {
+			fmt.Fprintf(os.Stderr, "grpc reflection server error: %v\n", err)
+		}
+	}()
+
+	logger.Debugf("grpc-aggregate: %-30s -> %d services via 127.0.0.1:%d (reflection)", agg.Host, len(routes), localPort)
+
+	sc := envoy.ServiceConfig{
+		Builder:     envoy.NewGRPCAggregateBuilder(agg.Host, routes),
+		ClusterName: envoy.GRPCReflectionClusterName,
+		LocalPort:   localPort,
+	}
+	summary := log.ServiceSummary{
+		Host:        agg.Host,
+		Protocol:    "grpc",
+		DisplayType: "gRPC aggregate",
+		Backend:     fmt.Sprintf("%d services from %d backends", len(routes), len(results)),
+	}
+	return sc, summary, nil
+}
@@ -0,0 +1,63 @@
+package run
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/usadamasa/kubectl-localmesh/internal/config"
+	"github.com/usadamasa/kubectl-localmesh/internal/envoy"
+	"github.com/usadamasa/kubectl-localmesh/internal/health"
+	"github.com/usadamasa/kubectl-localmesh/internal/port"
+)
+
+// monitorHealth はローカルポートのヘルスチェックをgoroutineで起動する
+// unhealthy_threshold回連続で失敗した場合はreconnectに通知し、port-forward・SSH tunnelを再接続させる
+// protocolはサービスのプロトコル（HTTPヘルスチェックをh2cで行うかの判定に使用）
+// Envoyのヘルスチェック結果ではなく独自のプローブを使う理由はhealthパッケージを参照
+func (v *RunVisitor) monitorHealth(host string, local port.LocalPort, hc *config.HealthCheck, protocol string, reconnect chan<- struct{}) {
+	check := health.Check{
+		Type:               hc.Type,
+		Path:               hc.Path,
+		Service:            hc.Service,
+		HTTP2:              protocol == "grpc" || protocol == "http2",
+		Interval:           hc.EffectiveInterval(),
+		UnhealthyThreshold: hc.EffectiveUnhealthyThreshold(),
+	}
+	addr := "127.0.0.1:" + strconv.Itoa(int(local))
+
+	go health.Monitor(v.ctx, addr, check, func(err error) {
+		v.logger.Infof("health check failed %d times: %s via %s: %v", check.UnhealthyThreshold, host, addr, err)
+		// 再接続中の通知は1つあれば十分なため、送信できない場合は破棄する
+		select {
+		case reconnect <- struct{}{}:
+		default:
+		}
+	})
+}
+
+// toEnvoyHealthCheck はヘルスチェック設定をEnvoy用に変換（未設定の場合はnil）
+func toEnvoyHealthCheck(hc *config.HealthCheck) *envoy.HealthCheck {
+	if hc == nil {
+		return nil
+	}
+	return &envoy.HealthCheck{
+		Type:               hc.Type,
+		Path:               hc.Path,
+		Service:            hc.Service,
+		Interval:           hc.EffectiveInterval(),
+		UnhealthyThreshold: hc.EffectiveUnhealthyThreshold(),
+	}
+}
+
+// formatHealthCheck はサマリー表示用にヘルスチェック設定を整形する
+func formatHealthCheck(hc *config.HealthCheck) string {
+	target := hc.Type
+	switch {
+	case hc.Path != "":
+		target += " " + hc.Path
+	case hc.Service != "":
+		target += " " + hc.Service
+	}
+	return fmt.Sprintf("health check: %s every %v (reconnect after %d failures)",
+		target, hc.EffectiveInterval(), hc.EffectiveUnhealthyThreshold())
+}
@@ -0,0 +1,209 @@
+package run
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+
+	"github.com/usadamasa/kubectl-localmesh/internal/config"
+	"github.com/usadamasa/kubectl-localmesh/internal/envoy"
+)
+
+// Envoyの実行環境（--envoy-runtime）
+const (
+	EnvoyRuntimeHost   = "host"   // ローカルにインストールしたenvoyコマンド（デフォルト）
+	EnvoyRuntimeDocker = "docker" // dockerで起動するEnvoyコンテナ
+	EnvoyRuntimePodman = "podman" // podmanで起動するEnvoyコンテナ
+)
+
+// EnvoyImage はコンテナで実行するEnvoyのイメージ
+// 生成するEnvoy設定（go-control-plane）のAPIバージョンに合わせて固定する
+const EnvoyImage = "envoyproxy/envoy:v1.39.0"
+
+// EnvoyContribImage はcontrib拡張（postgres_proxy・mysql_proxy）を含むEnvoyのイメージ
+const EnvoyContribImage = "envoyproxy/envoy-contrib:v1.39.0"
+
+// envoyImage は設定に必要なEnvoyのイメージを返す
+// データベースのprotocolを指定したTCPサービスがある場合はcontribのイメージを使う
+func envoyImage(cfg *config.Config) string {
+	if usesDatabaseProtocol(cfg) {
+		return EnvoyContribImage
+	}
+	return EnvoyImage
+}
+
+// usesDatabaseProtocol はprotocol（postgres|mysql）を指定したTCPサービスがあるかを返す
+func usesDatabaseProtocol(cfg *config.Config) bool {
+	for _, svcDef := range cfg.Services {
+		if s, ok := svcDef.AsTCP(); ok && s.Protocol != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateEnvoyRuntime はEnvoyの実行環境の指定を検証
+func ValidateEnvoyRuntime(name string) error {
+	switch name {
+	case EnvoyRuntimeHost, EnvoyRuntimeDocker, EnvoyRuntimePodman:
+		return nil
+	}
+	return fmt.Errorf("must be one of %s|%s|%s, got %q", EnvoyRuntimeHost, EnvoyRuntimeDocker, EnvoyRuntimePodman, name)
+}
+
+// executor は外部コマンドを実行する（テストでは記録用の実装に差し替える）
+type executor interface {
+	lookPath(file string) (string, error)
+	// run はコマンドを実行し、終了またはcontextのキャンセルまでブロックする
+	run(ctx context.Context, name string, args ...string) error
+}
+
+// osExecutor は標準出力・標準エラーを引き継いでコマンドを実行する
+type osExecutor struct{}
+
+func (osExecutor) lookPath(file string) (string, error) {
+	return exec.LookPath(file)
+}
+
+func (osExecutor) run(ctx context.Context, name string, args ...string) error {
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+// envoyLauncher は生成したbootstrapでEnvoyを起動する
+type envoyLauncher interface {
+	// check は起動に必要なコマンドがあるかを確認
+	check() error
+	// launch はEnvoyを実行し、終了またはcontextのキャンセルまでブロックする
+	launch(ctx context.Context, configPath, logLevel string) error
+}
+
+// newEnvoyLauncher は実行環境に応じたenvoyLauncherを生成
+// imageはコンテナで実行するイメージ、mountsはコンテナにマウントするホストのディレクトリ（ファイルへのアクセスログの出力先など）
+func newEnvoyLauncher(runtime string, exec executor, image string, mounts []string) envoyLauncher {
+	switch runtime {
+	case EnvoyRuntimeDocker, EnvoyRuntimePodman:
+		return &containerLauncher{
+			exec:   exec,
+			engine: runtime,
+			image:  image,
+			name:   fmt.Sprintf("kubectl-localmesh-envoy-%d", os.Getpid()),
+			mounts: mounts,
+		}
+	default:
+		return &hostLauncher{exec: exec}
+	}
+}
+
+// hostLauncher はローカルにインストールしたenvoyコマンドを実行する
+type hostLauncher struct {
+	exec executor
+}
+
+func (l *hostLauncher) check() error {
+	if _, err := l.exec.lookPath("envoy"); err != nil {
+		return fmt.Errorf("envoy not found in PATH: install envoy, use --envoy-runtime=%s|%s or --proxy=%s", EnvoyRuntimeDocker, EnvoyRuntimePodman, ProxyBuiltin)
+	}
+	return nil
+}
+
+func (l *hostLauncher) launch(ctx context.Context, configPath, logLevel string) error {
+	return l.exec.run(ctx, "envoy", "-c", configPath, "-l", logLevel)
+}
+
+// containerLauncher はEnvoyのイメージをホストネットワークのコンテナで実行する
+// bootstrapとADSサーバーのソケットがある一時ディレクトリは同じパスにマウントする
+type containerLauncher struct {
+	exec   executor
+	engine string // docker|podman
+	image  string
+	name   string // コンテナ名（終了時の削除に使用）
+	mounts []string
+}
+
+func (l *containerLauncher) check() error {
+	if _, err := l.exec.lookPath(l.engine); err != nil {
+		return fmt.Errorf("%s not found in PATH: install %s or use --envoy-runtime=%s", l.engine, l.engine, EnvoyRuntimeHost)
+	}
+	return nil
+}
+
+func (l *containerLauncher) launch(ctx context.Context, configPath, logLevel string) error {
+	err := l.exec.run(ctx, l.engine, l.runArgs(configPath, logLevel)...)
+
+	// CLIを終了してもコンテナは停止しないため、キャンセル時は明示的に削除する
+	if ctx.Err() != nil {
+		if rmErr := l.exec.run(context.WithoutCancel(ctx), l.engine, "rm", "-f", l.name); rmErr != nil {
+			fmt.Fprintf(os.Stderr, "warning: failed to remove envoy container %s: %v\n", l.name, rmErr)
+		}
+	}
+	return err
+}
+
+// runArgs はEnvoyコンテナを起動するrunコマンドの引数を生成
+func (l *containerLauncher) runArgs(configPath, logLevel string) []string {
+	args := []string{
+		"run", "--rm",
+		"--name", l.name,
+		"--network", "host",
+		// 一時ディレクトリ・マウントしたファイルをホストと同じ権限で読み書きするため、Envoyをrootで実行する
+		"-e", "ENVOY_UID=0",
+	}
+
+	dirs := []string{filepath.Dir(configPath)}
+	for _, m := range l.mounts {
+		if !slices.Contains(dirs, m) {
+			dirs = append(dirs, m)
+		}
+	}
+	for _, dir := range dirs {
+		args = append(args, "-v", dir+":"+dir)
+	}
+	// 相対パスのアクセスログをホストと同じ場所に出力するため、作業ディレクトリを合わせる
+	if wd, err := os.Getwd(); err == nil {
+		args = append(args, "-w", wd)
+	}
+
+	return append(args, l.image, "-c", configPath, "-l", logLevel)
+}
+
+// accessLogDirs はファイルへのアクセスログの出力先ディレクトリを返す（絶対パス、重複なし）
+// コンテナのEnvoyからホストのファイルに書き込めるようにマウントする
+func accessLogDirs(cfg *config.Config, global *envoy.AccessLog) []string {
+	var paths []string
+	if global != nil {
+		paths = append(paths, global.Path)
+	}
+	for _, svcDef := range cfg.Services {
+		switch s := svcDef.Get().(type) {
+		case *config.KubernetesService:
+			if s.AccessLog != nil {
+				paths = append(paths, s.AccessLog.Path)
+			}
+		case *config.TCPService:
+			if s.AccessLog != nil {
+				paths = append(paths, s.AccessLog.Path)
+			}
+		}
+	}
+
+	var dirs []string
+	for _, p := range paths {
+		if p == envoy.AccessLogStdout {
+			continue
+		}
+		abs, err := filepath.Abs(p)
+		if err != nil {
+			continue
+		}
+		if dir := filepath.Dir(abs); !slices.Contains(dirs, dir) {
+			dirs = append(dirs, dir)
+		}
+	}
+	return dirs
+}
@@ -0,0 +1,199 @@
+package run
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/usadamasa/kubectl-localmesh/internal/envoy"
+)
+
+// fakeExecutor は実行したコマンドを記録する
+// blockがtrueの場合、runはcontextがキャンセルされるまでブロックする
+type fakeExecutor struct {
+	paths    []string // lookPathで見つかるコマンド
+	block    bool
+	commands [][]string
+}
+
+func (f *fakeExecutor) lookPath(file string) (string, error) {
+	if slices.Contains(f.paths, file) {
+		return "/usr/bin/" + file, nil
+	}
+	return "", errors.New("executable file not found in $PATH")
+}
+
+func (f *fakeExecutor) run(ctx context.Context, name string, args ...string) error {
+	f.commands = append(f.commands, append([]string{name}, args...))
+	if f.block && ctx.Done() != nil {
+		<-ctx.Done()
+		return errors.New("signal: killed")
+	}
+	return nil
+}
+
+func TestValidateEnvoyRuntime(t *testing.T) {
+	for _, name := range []string{EnvoyRuntimeHost, EnvoyRuntimeDocker, EnvoyRuntimePodman} {
+		if err := ValidateEnvoyRuntime(name); err != nil {
+			t.Errorf("%s: unexpected error: %v", name, err)
+		}
+	}
+	if err := ValidateEnvoyRuntime("containerd"); err == nil {
+		t.Error("expected error for unknown runtime")
+	}
+}
+
+func TestHostLauncher(t *testing.T) {
+	exec := &fakeExecutor{paths: []string{"envoy"}}
+	l := newEnvoyLauncher(EnvoyRuntimeHost, exec, EnvoyImage, nil)
+
+	if err := l.check(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := l.launch(context.Background(), "/tmp/mesh/envoy.yaml", "warning"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := [][]string{{"envoy", "-c", "/tmp/mesh/envoy.yaml", "-l", "warning"}}
+	if !slices.EqualFunc(exec.commands, want, slices.Equal) {
+		t.Errorf("expected %q, got %q", want, exec.commands)
+	}
+}
+
+func TestEnvoyLauncher_Check(t *testing.T) {
+	tests := []struct {
+		runtime string
+		want    string
+	}{
+		{EnvoyRuntimeHost, "envoy not found in PATH"},
+		{EnvoyRuntimeDocker, "docker not found in PATH"},
+		{EnvoyRuntimePodman, "podman not found in PATH"},
+	}
+	for _, tt := range tests {
+		err := newEnvoyLauncher(tt.runtime, &fakeExecutor{}, EnvoyImage, nil).check()
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: expected %q error, got %v", tt.runtime, tt.want, err)
+		}
+	}
+}
+
+func TestContainerLauncher(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	exec := &fakeExecutor{paths: []string{"podman"}}
+	l := newEnvoyLauncher(EnvoyRuntimePodman, exec, EnvoyImage, []string{"/var/log/mesh", "/tmp/mesh"}).(*containerLauncher)
+
+	if err := l.check(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := l.launch(context.Background(), "/tmp/mesh/envoy.yaml", "info"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// 一時ディレクトリは重複せずにマウントされ、Envoyが終了した場合はコンテナを削除しない（--rmで削除される）
+	want := [][]string{{
+		"podman", "run", "--rm",
+		"--name", l.name,
+		"--network", "host",
+		"-e", "ENVOY_UID=0",
+		"-v", "/tmp/mesh:/tmp/mesh",
+		"-v", "/var/log/mesh:/var/log/mesh",
+		"-w", wd,
+		EnvoyImage, "-c", "/tmp/mesh/envoy.yaml", "-l", "info",
+	}}
+	if !slices.EqualFunc(exec.commands, want, slices.Equal) {
+		t.Errorf("expected %q, got %q", want, exec.commands)
+	}
+}
+
+func TestContainerLauncher_Cancel(t *testing.T) {
+	exec := &fakeExecutor{paths: []string{"docker"}, block: true}
+	l := newEnvoyLauncher(EnvoyRuntimeDocker, exec, EnvoyImage, nil).(*containerLauncher)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- l.launch(ctx, "/tmp/mesh/envoy.yaml", "info") }()
+	cancel()
+	<-done
+
+	// キャンセル時はCLIの終了後にコンテナを削除する
+	if len(exec.commands) != 2 {
+		t.Fatalf("expected run and rm, got %q", exec.commands)
+	}
+	if want := []string{"docker", "rm", "-f", l.name}; !slices.Equal(exec.commands[1], want) {
+		t.Errorf("expected %q, got %q", want, exec.commands[1])
+	}
+}
+
+func TestAccessLogDirs(t *testing.T) {
+	cfg := loadTestConfig(t, `
+listener_port: 80
+services:
+  - kind: kubernetes
+    host: users.localhost
+    namespace: users
+    service: users-api
+    port: 8080
+    protocol: http
+    access_log:
+      path: logs/users.log
+  - kind: kubernetes
+    host: billing.localhost
+    namespace: billing
+    service: billing-api
+    port: 8080
+    protocol: http
+    access_log:
+      path: stdout
+`)
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := accessLogDirs(cfg, &envoy.AccessLog{Path: "/var/log/mesh/access.log"})
+	want := []string{"/var/log/mesh", filepath.Join(wd, "logs")}
+	if !slices.Equal(got, want) {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
+
+func TestEnvoyImage(t *testing.T) {
+	cfg := loadTestConfig(t, `
+listener_port: 80
+services:
+  - kind: kubernetes
+    host: users.localhost
+    namespace: users
+    service: users-api
+    port: 8080
+    protocol: http
+`)
+	if got := envoyImage(cfg); got != EnvoyImage {
+		t.Errorf("expected %s, got %s", EnvoyImage, got)
+	}
+
+	// postgres_proxy・mysql_proxyはcontribのイメージにのみ含まれる
+	cfg = loadTestConfig(t, `
+listener_port: 80
+ssh_bastions:
+  primary:
+    instance: bastion-1
+    zone: asia-northeast1-a
+services:
+  - kind: tcp
+    host: orders-db.localhost
+    ssh_bastion: primary
+    target_host: 10.0.0.1
+    target_port: 5432
+    protocol: postgres
+`)
+	if got := envoyImage(cfg); got != EnvoyContribImage {
+		t.Errorf("expected %s, got %s", EnvoyContribImage, got)
+	}
+}
@@ -0,0 +1,549 @@
+package run
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"reflect"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	bootstrapv3 "github.com/envoyproxy/go-control-plane/envoy/config/bootstrap/v3"
+
+	"github.com/usadamasa/kubectl-localmesh/internal/config"
+	"github.com/usadamasa/kubectl-localmesh/internal/dashboard"
+	"github.com/usadamasa/kubectl-localmesh/internal/envoy"
+	"github.com/usadamasa/kubectl-localmesh/internal/hosts"
+	"github.com/usadamasa/kubectl-localmesh/internal/log"
+	"github.com/usadamasa/kubectl-localmesh/internal/loopback"
+	"github.com/usadamasa/kubectl-localmesh/internal/port"
+)
+
+// meshHost はホスト単位で起動したport-forward・SSHトンネルとその生成結果
+type meshHost struct {
+	cancel  context.CancelFunc
+	visitor *RunVisitor
+}
+
+// aliases はホストのTCPリスナーに割り当てたloopback IPを返す
+// SNIで振り分けるサービスは127.0.0.1を共有するため含めない
+func (h *meshHost) aliases() []string {
+	var ips []string
+	for _, sc := range h.visitor.GetServiceConfigs() {
+		if b, ok := sc.Builder.(*envoy.TCPServiceBuilder); ok && len(b.ServerNames) == 0 {
+			ips = append(ips, b.GetListenAddr())
+		}
+	}
+	return ips
+}
+
+// aggregateHost は集約gRPCホストの統合リフレクションサーバーとその生成結果
+type aggregateHost struct {
+	cancel  context.CancelFunc
+	config  envoy.ServiceConfig
+	summary log.ServiceSummary
+}
+
+// mesh は起動中のport-forward・SSHトンネル・loopbackエイリアス・/etc/hostsとEnvoy設定を管理する
+// applyで新しい設定とのホスト単位の差分だけを起動・停止し、プロキシ（ADSサーバー経由のEnvoyまたは組み込みプロキシ）へ反映する
+type mesh struct {
+	ctx      context.Context
+	logger   *log.Logger
+	opts     RunOptions
+	proxy    proxyRuntime
+	aliasMgr *loopback.AliasManager
+	stats    dashboard.StatsSource // ダッシュボードに表示するEnvoyの統計（nilの場合は表示しない）
+
+	visitor     *RunVisitor
+	cfg         *config.Config
+	hosts       map[string]*meshHost
+	aggregate   *aggregateHost
+	dashboard   *envoy.ServiceConfig // 無効な場合はnil
+	envoyCfg    *bootstrapv3.Bootstrap
+	bindAddrs   []string // 共通HTTPリスナーのバインドアドレス
+	hostEntries []hosts.HostEntry
+
+	dashboardPort port.LocalPort // ダッシュボードサーバーのポート（未起動の場合は0）
+	view          atomic.Pointer[meshView]
+}
+
+// newMesh はサービスを起動していないmeshを生成
+func newMesh(ctx context.Context, cfg *config.Config, logger *log.Logger, opts RunOptions, proxy proxyRuntime, aliasMgr *loopback.AliasManager) *mesh {
+	return &mesh{
+		ctx:      ctx,
+		logger:   logger,
+		opts:     opts,
+		proxy:    proxy,
+		aliasMgr: aliasMgr,
+		visitor:  NewRunVisitor(ctx, cfg, logger),
+		hosts:    make(map[string]*meshHost),
+	}
+}
+
+// apply は設定との差分のホストを起動・停止し、生成した設定をプロキシへ反映する
+// 追加・変更されたホストを先に起動して配信し、成功した後で削除・変更前のホストを停止する
+// 失敗した場合は起動した分を停止し、現在の状態を維持する
+func (m *mesh) apply(cfg *config.Config) (serviceDiff, error) {
+	diff := diffServices(m.cfg, cfg)
+	visitor := m.visitor.forConfig(cfg)
+
+	// 追加・変更されたホストを起動
+	// rollbackはこのapplyで起動したものだけを停止する
+	started := make(map[string]*meshHost)
+	var startedAggregate *aggregateHost
+	rollback := func() {
+		for _, h := range started {
+			m.stopHost(h)
+		}
+		if startedAggregate != nil {
+			startedAggregate.cancel()
+		}
+	}
+
+	keys := serviceKeys(cfg)
+	for i, key := range keys {
+		if !slices.Contains(diff.added, key) && !slices.Contains(diff.changed, key) {
+			continue
+		}
+		ctx, cancel := context.WithCancel(m.ctx)
+		h := &meshHost{cancel: cancel, visitor: visitor.forHost(ctx)}
+		started[key] = h
+		if err := cfg.Services[i].Get().Accept(h.visitor); err != nil {
+			rollback()
+			return diff, err
+		}
+	}
+
+	// クラスタ間フェイルオーバーのアクティブなclusterをサマリーに表示するため、
+	// 最優先clusterのport-forward確立を待つ
+	deadline := time.Now().Add(failoverWaitTimeout)
+	for _, key := range keys {
+		if h, ok := started[key]; ok {
+			h.visitor.WaitFailover(m.ctx, time.Until(deadline))
+		}
+	}
+
+	// loopback IPエイリアス追加（TCPサービス用）
+	var aliases []string
+	for _, key := range keys {
+		if h, ok := started[key]; ok {
+			aliases = append(aliases, h.aliases()...)
+		}
+	}
+	for _, ip := range aliases {
+		if err := m.aliasMgr.AddAlias(ip); err != nil {
+			rollback()
+			return diff, fmt.Errorf("failed to add loopback alias %s: %w", ip, err)
+		}
+	}
+	if len(aliases) > 0 {
+		m.logger.Debugf("loopback aliases added: %v", aliases)
+	}
+
+	next := make(map[string]*meshHost, len(keys))
+	for _, key := range keys {
+		if h, ok := started[key]; ok {
+			next[key] = h
+		} else {
+			next[key] = m.hosts[key]
+		}
+	}
+	serviceConfigs, serviceSummaries := collectResults(keys, next)
+
+	// 集約gRPCホスト（リフレクションによるルーティング解決）
+	// ホストの増減・変更があった場合はgRPCサービスを問い合わせ直す
+	aggregate := m.aggregate
+	if cfg.GRPCAggregate == nil {
+		aggregate = nil
+	} else if aggregate == nil || !diff.empty() || !reflect.DeepEqual(m.cfg.GRPCAggregate, cfg.GRPCAggregate) {
+		ctx, cancel := context.WithCancel(m.ctx)
+		sc, summary, err := setupGRPCAggregate(ctx, cfg.GRPCAggregate, serviceConfigs, m.logger)
+		if err != nil {
+			cancel()
+			rollback()
+			return diff, err
+		}
+		startedAggregate = &aggregateHost{cancel: cancel, config: sc, summary: summary}
+		aggregate = startedAggregate
+	}
+	if aggregate != nil {
+		serviceConfigs = append(serviceConfigs, aggregate.config)
+		serviceSummaries = append(serviceSummaries, aggregate.summary)
+	}
+
+	// ダッシュボード（サーバーは最初に有効になった時に起動し、以降は使い回す）
+	var dashboardCfg *envoy.ServiceConfig
+	if host := cfg.DashboardHost(); host != "" {
+		if m.dashboardPort == 0 {
+			p, err := startDashboard(m.ctx, dashboard.NewHandler(m.dashboardServices, m.stats))
+			if err != nil {
+				rollback()
+				return diff, fmt.Errorf("failed to start dashboard: %w", err)
+			}
+			m.dashboardPort = p
+		}
+		dashboardCfg = &envoy.ServiceConfig{
+			Builder:     envoy.NewDashboardBuilder(host),
+			ClusterName: envoy.DashboardClusterName,
+			LocalPort:   m.dashboardPort,
+		}
+		serviceConfigs = append(serviceConfigs, *dashboardCfg)
+	}
+
+	// Envoy設定生成とプロキシへの反映
+	bindAddrs := bindAddresses(cfg)
+	envoyCfg, err := envoy.BuildConfigWithOptions(cfg.ListenerPort, serviceConfigs, envoy.BuildOptions{
+		RateLimit:     toEnvoyRateLimit(cfg.RateLimit),
+		AccessLog:     m.opts.AccessLog,
+		Tracing:       toEnvoyTracing(cfg.Tracing),
+		BindAddresses: bindAddrs,
+		ListenerAuth:  toEnvoyListenerAuth(cfg.ListenerAuth),
+	})
+	if err == nil {
+		envoyCfg, err = envoy.ApplyOverrides(envoyCfg, toEnvoyOverrides(cfg.EnvoyOverrides))
+	}
+	if err != nil {
+		rollback()
+		return diff, err
+	}
+	if err := m.proxy.update(m.ctx, cfg.ListenerPort, bindAddrs, serviceConfigs, envoyCfg); err != nil {
+		rollback()
+		return diff, err
+	}
+
+	// 削除・変更前のホストを停止
+	for _, key := range append(append([]string{}, diff.removed...), diff.changed...) {
+		m.stopHost(m.hosts[key])
+	}
+	if m.aggregate != nil && m.aggregate != aggregate {
+		m.aggregate.cancel()
+	}
+
+	m.aggregate = aggregate
+	m.dashboard = dashboardCfg
+	m.visitor = visitor
+	m.cfg = cfg
+	m.hosts = next
+	m.envoyCfg = envoyCfg
+	m.bindAddrs = bindAddrs
+
+	view := &meshView{aggregate: aggregate, listenerPort: cfg.ListenerPort}
+	for _, key := range keys {
+		view.hosts = append(view.hosts, next[key])
+	}
+	m.view.Store(view)
+	return diff, nil
+}
+
+// dashboardServices はダッシュボードに表示する現在のサービスを返す
+// ダッシュボードサーバーのgoroutineから呼ばれるため、applyで差し替えたmeshViewのみを参照する
+func (m *mesh) dashboardServices() []dashboard.Service {
+	view := m.view.Load()
+	if view == nil {
+		return nil
+	}
+	return view.services()
+}
+
+// stopHost はホストのport-forward・SSHトンネルを停止し、loopback IPエイリアスを解放する
+func (m *mesh) stopHost(h *meshHost) {
+	h.cancel()
+	for _, ip := range h.aliases() {
+		if err := m.aliasMgr.RemoveAlias(ip); err != nil {
+			fmt.Fprintf(os.Stderr, "warning: failed to remove loopback alias %s: %v\n", ip, err)
+		}
+		m.visitor.GetIPAllocator().Release(ip)
+	}
+}
+
+// serviceConfigs は現在のEnvoy用サービス設定を設定ファイルの順に返す（集約gRPCホスト・ダッシュボードは末尾）
+func (m *mesh) serviceConfigs() []envoy.ServiceConfig {
+	configs, _ := collectResults(serviceKeys(m.cfg), m.hosts)
+	if m.aggregate != nil {
+		configs = append(configs, m.aggregate.config)
+	}
+	if m.dashboard != nil {
+		configs = append(configs, *m.dashboard)
+	}
+	return configs
+}
+
+// summaries は現在のサービスのサマリーを設定ファイルの順に返す
+func (m *mesh) summaries() []log.ServiceSummary {
+	_, summaries := collectResults(serviceKeys(m.cfg), m.hosts)
+	if m.aggregate != nil {
+		summaries = append(summaries, m.aggregate.summary)
+	}
+	return summaries
+}
+
+// hasHTTPServices はHTTPリスナーにバインドするサービスがあるかを返す
+func (m *mesh) hasHTTPServices() bool {
+	for _, sc := range m.serviceConfigs() {
+		if _, ok := sc.Builder.(*envoy.TCPServiceBuilder); !ok {
+			return true
+		}
+	}
+	return false
+}
+
+// namedListeners はサービスが接続している名前付きの共有HTTPリスナーを最初に接続された順に返す
+func (m *mesh) namedListeners() []envoy.NamedListener {
+	var listeners []envoy.NamedListener
+	for _, sc := range m.serviceConfigs() {
+		b, ok := sc.Builder.(*envoy.KubernetesServiceBuilder)
+		if !ok {
+			continue
+		}
+		for _, l := range b.Listeners {
+			if l.Name != "" && !slices.Contains(listeners, l) {
+				listeners = append(listeners, l)
+			}
+		}
+	}
+	return listeners
+}
+
+// syncHosts は/etc/hostsの管理ブロックを現在のサービスに合わせる
+// 初回は追加し、以降はエントリが変わった場合のみ書き換える
+func (m *mesh) syncHosts() error {
+	if !m.opts.UpdateHosts {
+		return nil
+	}
+
+	// ホストエントリを収集（TCPサービスは割り当てられたIP、HTTPサービスはリスナーに接続できるIPを使用）
+	// 別名はホストと同じIPに割り当てる
+	httpIP := hostsIP(m.bindAddrs)
+	var entries []hosts.HostEntry
+	for _, sc := range m.serviceConfigs() {
+		switch b := sc.Builder.(type) {
+		case *envoy.TCPServiceBuilder:
+			for _, name := range append([]string{b.GetHost()}, b.Aliases...) {
+				entries = append(entries, hosts.HostEntry{
+					Hostname: name,
+					IP:       b.GetListenAddr(),
+				})
+			}
+		case *envoy.KubernetesServiceBuilder:
+			ip := httpIP
+			if len(b.BindAddresses) > 0 {
+				ip = hostsIP(b.BindAddresses)
+			}
+			for _, name := range append([]string{b.GetHost()}, b.Aliases...) {
+				entries = append(entries, hosts.HostEntry{
+					Hostname: name,
+					IP:       ip,
+				})
+			}
+		case *envoy.GRPCAggregateBuilder:
+			entries = append(entries, hosts.HostEntry{
+				Hostname: b.GetHost(),
+				IP:       httpIP,
+			})
+		case *envoy.DashboardBuilder:
+			entries = append(entries, hosts.HostEntry{
+				Hostname: b.GetHost(),
+				IP:       httpIP,
+			})
+		}
+	}
+
+	if m.hostEntries == nil {
+		if err := hosts.AddEntriesWithIPs(entries); err != nil {
+			return fmt.Errorf("failed to update /etc/hosts: %w", err)
+		}
+	} else if !slices.Equal(m.hostEntries, entries) {
+		if err := hosts.ReplaceEntries(entries); err != nil {
+			return fmt.Errorf("failed to update /etc/hosts: %w", err)
+		}
+	} else {
+		return nil
+	}
+	m.hostEntries = entries
+	m.logger.Debug("/etc/hosts updated successfully")
+	return nil
+}
+
+// reload は再読み込みした設定を反映する
+// 反映できない変更や失敗はログに出力し、起動中のメッシュはそのまま維持する
+func (m *mesh) reload(cfg *config.Config) {
+	if cfg.ListenerPort != m.cfg.ListenerPort {
+		fmt.Fprintf(os.Stderr, "warning: listener_port cannot be changed while running (restart to apply), config change skipped\n")
+		return
+	}
+	// Envoyは既存のリスナーのアドレス変更を受け付けないため、再起動が必要
+	if bindAddressChanged(m.cfg, cfg) {
+		fmt.Fprintf(os.Stderr, "warning: bind_address cannot be changed while running (restart to apply), config change skipped\n")
+		return
+	}
+	if listenerPortChanged(m.cfg, cfg) {
+		fmt.Fprintf(os.Stderr, "warning: the port of a named listener cannot be changed while running (restart to apply), config change skipped\n")
+		return
+	}
+	if _, ok := m.proxy.(*builtinRuntime); ok && cfg.ListenerAuth != nil {
+		fmt.Fprintf(os.Stderr, "warning: %v, config change skipped\n", errListenerAuthBuiltin)
+		return
+	}
+
+	diff, err := m.apply(cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "warning: failed to apply config change, keeping the running services: %v\n", err)
+		return
+	}
+	if err := m.syncHosts(); err != nil {
+		fmt.Fprintf(os.Stderr, "warning: %v\n", err)
+	}
+
+	if diff.empty() {
+		m.logger.Infof("config reloaded (config version %d)", m.proxy.version())
+		return
+	}
+	m.logger.Infof("config reloaded: %s (config version %d)", diff, m.proxy.version())
+	m.logger.Info(log.GenerateSummary(m.summaries(), cfg.ListenerPort))
+}
+
+// bindAddressChanged は起動中のリスナーのバインドアドレスが変わるかを返す
+// 個別リスナーは同じホスト・listener_portのまま残るサービスのみを比較する
+func bindAddressChanged(prev, next *config.Config) bool {
+	if prev.BindAddress != next.BindAddress {
+		return true
+	}
+	listeners := make(map[string]*config.KubernetesService)
+	for _, svcDef := range prev.Services {
+		if k, ok := svcDef.Get().(*config.KubernetesService); ok && k.ListenerPort != 0 {
+			listeners[k.Host] = k
+		}
+	}
+	for _, svcDef := range next.Services {
+		k, ok := svcDef.Get().(*config.KubernetesService)
+		if !ok || k.ListenerPort == 0 {
+			continue
+		}
+		if p, ok := listeners[k.Host]; ok && p.ListenerPort == k.ListenerPort && p.BindAddress != k.BindAddress {
+			return true
+		}
+	}
+	return false
+}
+
+// listenerPortChanged は両方の設定にある名前付きリスナーのポートが変わるかを返す
+func listenerPortChanged(prev, next *config.Config) bool {
+	for name, p := range next.Listeners {
+		if prevPort, ok := prev.Listeners[name]; ok && prevPort != p {
+			return true
+		}
+	}
+	return false
+}
+
+// collectResults はホストのEnvoy用サービス設定とサマリーをkeysの順に集める
+func collectResults(keys []string, hosts map[string]*meshHost) ([]envoy.ServiceConfig, []log.ServiceSummary) {
+	var configs []envoy.ServiceConfig
+	var summaries []log.ServiceSummary
+	for _, key := range keys {
+		h := hosts[key]
+		configs = append(configs, h.visitor.GetServiceConfigs()...)
+		summaries = append(summaries, h.visitor.GetServiceSummaries()...)
+	}
+	return configs, summaries
+}
+
+// hostFingerprint はホストの起動内容を比較するための値
+// サービス定義に加え、参照するグローバル設定（デフォルトcluster・SSH Bastion）を含む
+type hostFingerprint struct {
+	service config.Service
+	cluster string
+	bastion *config.SSHBastion
+}
+
+// newHostFingerprint はサービスのhostFingerprintを生成
+func newHostFingerprint(cfg *config.Config, svc config.Service) hostFingerprint {
+	fp := hostFingerprint{service: svc}
+	switch s := svc.(type) {
+	case *config.KubernetesService:
+		fp.cluster = cfg.Cluster
+	case *config.TCPService:
+		fp.bastion = cfg.SSHBastions[s.SSHBastion]
+	}
+	return fp
+}
+
+// serviceKeys はサービスをホスト単位で識別するキーを設定ファイルの順に返す
+// 同じホストが複数回定義されている場合は出現順の番号を付ける
+func serviceKeys(cfg *config.Config) []string {
+	keys := make([]string, 0, len(cfg.Services))
+	seen := make(map[string]int)
+	for _, svcDef := range cfg.Services {
+		host := svcDef.Get().GetHost()
+		seen[host]++
+		if seen[host] > 1 {
+			host = fmt.Sprintf("%s#%d", host, seen[host])
+		}
+		keys = append(keys, host)
+	}
+	return keys
+}
+
+// serviceDiff は設定間のホスト単位の差分
+type serviceDiff struct {
+	added   []string
+	removed []string
+	changed []string
+}
+
+// diffServices は2つの設定をホスト単位で比較する（oldがnilの場合はすべて追加）
+func diffServices(old, next *config.Config) serviceDiff {
+	var diff serviceDiff
+
+	previous := make(map[string]hostFingerprint)
+	if old != nil {
+		for i, key := range serviceKeys(old) {
+			previous[key] = newHostFingerprint(old, old.Services[i].Get())
+		}
+	}
+
+	current := make(map[string]bool)
+	for i, key := range serviceKeys(next) {
+		current[key] = true
+		fp, ok := previous[key]
+		switch {
+		case !ok:
+			diff.added = append(diff.added, key)
+		case !reflect.DeepEqual(fp, newHostFingerprint(next, next.Services[i].Get())):
+			diff.changed = append(diff.changed, key)
+		}
+	}
+	if old != nil {
+		for _, key := range serviceKeys(old) {
+			if !current[key] {
+				diff.removed = append(diff.removed, key)
+			}
+		}
+	}
+	return diff
+}
+
+// empty は差分がない場合にtrueを返す
+func (d serviceDiff) empty() bool {
+	return len(d.added) == 0 && len(d.removed) == 0 && len(d.changed) == 0
+}
+
+// String はログ表示用に差分を整形する（例: added a.localhost, removed b.localhost）
+func (d serviceDiff) String() string {
+	var parts []string
+	for _, p := range []struct {
+		label string
+		hosts []string
+	}{
+		{"added", d.added},
+		{"removed", d.removed},
+		{"updated", d.changed},
+	} {
+		if len(p.hosts) > 0 {
+			parts = append(parts, p.label+" "+strings.Join(p.hosts, ", "))
+		}
+	}
+	return strings.Join(parts, "; ")
+}
@@ -0,0 +1,227 @@
+package run
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/usadamasa/kubectl-localmesh/internal/config"
+)
+
+const meshBaseConfig = `
+ssh_bastions:
+  primary:
+    instance: bastion-1
+    zone: asia-northeast1-a
+services:
+  - kind: kubernetes
+    host: users.localhost
+    namespace: users
+    service: users-api
+    port: 8080
+    protocol: http
+  - kind: kubernetes
+    host: billing.localhost
+    namespace: billing
+    service: billing-api
+    port: 8080
+    protocol: http
+  - kind: tcp
+    host: db.localhost
+    ssh_bastion: primary
+    target_host: 10.0.0.1
+    target_port: 5432
+`
+
+// loadTestConfig はYAMLを一時ファイルに書き出してconfig.Loadで読み込む
+func loadTestConfig(t *testing.T, content string) *config.Config {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "services.yaml")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := config.Load(path)
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+	return cfg
+}
+
+func TestDiffServices(t *testing.T) {
+	base := loadTestConfig(t, meshBaseConfig)
+
+	tests := []struct {
+		name string
+		next string
+		want serviceDiff
+	}{
+		{
+			name: "変更なし",
+			next: meshBaseConfig,
+			want: serviceDiff{},
+		},
+		{
+			name: "ホストの追加・削除・変更",
+			next: `
+ssh_bastions:
+  primary:
+    instance: bastion-1
+    zone: asia-northeast1-a
+services:
+  - kind: kubernetes
+    host: users.localhost
+    namespace: users
+    service: users-api
+    port: 9090
+    protocol: http
+  - kind: tcp
+    host: db.localhost
+    ssh_bastion: primary
+    target_host: 10.0.0.1
+    target_port: 5432
+  - kind: kubernetes
+    host: admin.localhost
+    namespace: admin
+    service: admin-web
+    port: 8080
+    protocol: http
+`,
+			want: serviceDiff{
+				added:   []string{"admin.localhost"},
+				removed: []string{"billing.localhost"},
+				changed: []string{"users.localhost"},
+			},
+		},
+		{
+			name: "参照するSSH Bastionの変更",
+			next: `
+ssh_bastions:
+  primary:
+    instance: bastion-2
+    zone: asia-northeast1-a
+services:
+  - kind: kubernetes
+    host: users.localhost
+    namespace: users
+    service: users-api
+    port: 8080
+    protocol: http
+  - kind: kubernetes
+    host: billing.localhost
+    namespace: billing
+    service: billing-api
+    port: 8080
+    protocol: http
+  - kind: tcp
+    host: db.localhost
+    ssh_bastion: primary
+    target_host: 10.0.0.1
+    target_port: 5432
+`,
+			want: serviceDiff{changed: []string{"db.localhost"}},
+		},
+		{
+			name: "デフォルトclusterの変更",
+			next: "cluster: staging\n" + meshBaseConfig,
+			want: serviceDiff{changed: []string{"users.localhost", "billing.localhost"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := diffServices(base, loadTestConfig(t, tt.next))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expected %+v, got %+v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestDiffServices_Initial(t *testing.T) {
+	got := diffServices(nil, loadTestConfig(t, meshBaseConfig))
+	want := []string{"users.localhost", "billing.localhost", "db.localhost"}
+	if !reflect.DeepEqual(got.added, want) || got.removed != nil || got.changed != nil {
+		t.Errorf("expected all hosts to be added, got %+v", got)
+	}
+}
+
+func TestServiceKeys_DuplicateHost(t *testing.T) {
+	cfg := loadTestConfig(t, meshBaseConfig+`
+  - kind: kubernetes
+    host: users.localhost
+    namespace: users
+    service: users-api-v2
+    port: 8080
+    protocol: http
+`)
+	want := []string{"users.localhost", "billing.localhost", "db.localhost", "users.localhost#2"}
+	if got := serviceKeys(cfg); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+}
+
+func TestServiceDiff_String(t *testing.T) {
+	d := serviceDiff{
+		added:   []string{"a.localhost", "b.localhost"},
+		changed: []string{"c.localhost"},
+	}
+	want := "added a.localhost, b.localhost; updated c.localhost"
+	if got := d.String(); got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+	if !(serviceDiff{}).empty() || d.empty() {
+		t.Error("unexpected empty() result")
+	}
+}
+
+func TestBindAddressChanged(t *testing.T) {
+	const individual = `
+services:
+  - kind: kubernetes
+    host: admin.localhost
+    namespace: admin
+    service: admin-web
+    port: 8080
+    protocol: http
+    listener_port: 8081
+`
+	prev := loadTestConfig(t, individual)
+
+	if bindAddressChanged(prev, loadTestConfig(t, individual)) {
+		t.Error("expected no change for the same config")
+	}
+	if !bindAddressChanged(prev, loadTestConfig(t, "bind_address: 0.0.0.0\n"+individual)) {
+		t.Error("expected change of the global bind_address")
+	}
+	if !bindAddressChanged(prev, loadTestConfig(t, individual+"    bind_address: 0.0.0.0\n")) {
+		t.Error("expected change of the individual listener's bind_address")
+	}
+	// listener_portも変わる場合は別のリスナーとして追加されるため再起動は不要
+	changedPort := strings.Replace(individual, "listener_port: 8081", "listener_port: 8082", 1) + "    bind_address: 0.0.0.0\n"
+	if bindAddressChanged(prev, loadTestConfig(t, changedPort)) {
+		t.Error("expected no restart when the listener_port changes as well")
+	}
+}
+
+func TestListenerPortChanged(t *testing.T) {
+	const services = `
+services:
+  - kind: kubernetes
+    host: web.localhost
+    namespace: web
+    service: frontend
+    port: 8080
+    protocol: http
+    listeners: [web]
+`
+	prev := loadTestConfig(t, "listeners:\n  web: 8081\n"+services)
+
+	if listenerPortChanged(prev, loadTestConfig(t, "listeners:\n  web: 8081\n  api: 8082\n"+services)) {
+		t.Error("expected no change when a listener is added")
+	}
+	if !listenerPortChanged(prev, loadTestConfig(t, "listeners:\n  web: 8082\n"+services)) {
+		t.Error("expected change of the named listener's port")
+	}
+}
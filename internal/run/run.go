@@ -2,22 +2,35 @@ package run
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
-	"os/exec"
-	"path/filepath"
 
 	"github.com/usadamasa/kubectl-localmesh/internal/config"
+	"github.com/usadamasa/kubectl-localmesh/internal/dashboard"
 	"github.com/usadamasa/kubectl-localmesh/internal/envoy"
 	"github.com/usadamasa/kubectl-localmesh/internal/hosts"
 	"github.com/usadamasa/kubectl-localmesh/internal/log"
 	"github.com/usadamasa/kubectl-localmesh/internal/loopback"
-	"gopkg.in/yaml.v3"
 )
 
+// RunOptions はupコマンドのオプション
+type RunOptions struct {
+	LogLevel     string
+	UpdateHosts  bool
+	AccessLog    *envoy.AccessLog // すべてのリスナーに設定するアクセスログ（nilの場合は出力しない）
+	WatchPath    string           // 変更を監視して反映する設定ファイル（空の場合は監視しない）
+	Proxy        string           // プロキシランタイム（ProxyEnvoy|ProxyBuiltin、空の場合はEnvoy）
+	EnvoyRuntime string           // Envoyの実行環境（EnvoyRuntimeHost|Docker|Podman、空の場合はホスト）
+}
+
 func Run(ctx context.Context, cfg *config.Config, logLevel string, updateHosts bool) error {
+	return RunWithOptions(ctx, cfg, RunOptions{LogLevel: logLevel, UpdateHosts: updateHosts})
+}
+
+func RunWithOptions(ctx context.Context, cfg *config.Config, opts RunOptions) error {
 	// Logger初期化
-	logger := log.New(logLevel)
+	logger := log.New(opts.LogLevel)
 
 	tmpDir, err := os.MkdirTemp("", "kubectl-localmesh-")
 	if err != nil {
@@ -25,18 +38,7 @@ func Run(ctx context.Context, cfg *config.Config, logLevel string, updateHosts b
 	}
 	defer func() { _ = os.RemoveAll(tmpDir) }()
 
-	// Visitor の生成（Kubernetes clientはサービスごとにlazy初期化）
-	visitor := NewRunVisitor(ctx, cfg, logger)
-
-	// Visitorパターンで各サービスを処理
-	for _, svcDef := range cfg.Services {
-		svc := svcDef.Get()
-		if err := svc.Accept(visitor); err != nil {
-			return err
-		}
-	}
-
-	// loopback IPエイリアス追加（TCPサービス用）
+	// loopback IPエイリアス（TCPサービス用）
 	// AliasManagerを先に作成し、deferを先に設定することで
 	// AddAlias途中で失敗しても追加成功した分だけ確実に削除する
 	aliasMgr := loopback.NewAliasManager()
@@ -48,45 +50,45 @@ func Run(ctx context.Context, cfg *config.Config, logLevel string, updateHosts b
 		}
 	}()
 
-	aliases := visitor.GetIPAllocator().GetAliases()
-	for _, ip := range aliases {
-		if err := aliasMgr.AddAlias(ip); err != nil {
-			return fmt.Errorf("failed to add loopback alias %s: %w", ip, err)
-		}
-	}
-	if len(aliases) > 0 {
-		logger.Debugf("loopback aliases added: %v", aliases)
+	// /etc/hosts更新が必要な場合は権限チェック
+	if opts.UpdateHosts && !hosts.HasPermission() {
+		return fmt.Errorf("need sudo: try 'sudo kubectl-localmesh ...'")
 	}
 
-	// /etc/hosts更新が必要な場合
-	if updateHosts {
-		// 権限チェック
-		if !hosts.HasPermission() {
-			return fmt.Errorf("need sudo: try 'sudo kubectl-localmesh ...'")
+	// プロキシランタイムの準備（Envoyの場合はADSサーバーを起動）
+	var proxy proxyRuntime
+	var adminSocket string
+	if opts.Proxy == ProxyBuiltin {
+		// 認証なしでメッシュを公開しないよう、警告ではなくエラーにする
+		if cfg.ListenerAuth != nil {
+			return errListenerAuthBuiltin
 		}
-
-		// ホストエントリを収集（TCPサービスは割り当てられたIPを使用）
-		var entries []hosts.HostEntry
-		for _, sc := range visitor.GetServiceConfigs() {
-			switch b := sc.Builder.(type) {
-			case *envoy.TCPServiceBuilder:
-				entries = append(entries, hosts.HostEntry{
-					Hostname: b.GetHost(),
-					IP:       b.GetListenAddr(),
-				})
-			case *envoy.KubernetesServiceBuilder:
-				entries = append(entries, hosts.HostEntry{
-					Hostname: b.GetHost(),
-					IP:       "127.0.0.1",
-				})
-			}
+		warnBuiltinUnsupported(cfg, opts)
+		proxy = newBuiltinRuntime(logger)
+	} else {
+		launcher := newEnvoyLauncher(opts.EnvoyRuntime, osExecutor{}, envoyImage(cfg), accessLogDirs(cfg, opts.AccessLog))
+		r, err := newEnvoyRuntime(ctx, logger, tmpDir, launcher)
+		if err != nil {
+			return err
 		}
+		proxy = r
+		adminSocket = r.admin
+	}
+
+	// 各サービスのport-forward・SSHトンネルを起動し、プロキシへ設定を反映
+	// （Kubernetes clientはサービスごとにlazy初期化）
+	m := newMesh(ctx, cfg, logger, opts, proxy, aliasMgr)
+	if adminSocket != "" {
+		m.stats = dashboard.EnvoyStats(adminSocket)
+	}
+	if _, err := m.apply(cfg); err != nil {
+		return err
+	}
 
-		// /etc/hostsに追加
-		if err := hosts.AddEntriesWithIPs(entries); err != nil {
-			return fmt.Errorf("failed to update /etc/hosts: %w", err)
+	if opts.UpdateHosts {
+		if err := m.syncHosts(); err != nil {
+			return err
 		}
-		logger.Debug("/etc/hosts updated successfully")
 
 		// 終了時にクリーンアップ
 		defer func() {
@@ -98,37 +100,81 @@ func Run(ctx context.Context, cfg *config.Config, logLevel string, updateHosts b
 		}()
 	}
 
-	// Envoy設定生成
-	envoyCfg := envoy.BuildConfig(cfg.ListenerPort, visitor.GetServiceConfigs())
-	envoyPath := filepath.Join(tmpDir, "envoy.yaml")
-
-	b, err := yaml.Marshal(envoyCfg)
-	if err != nil {
-		return err
+	// サマリー出力
+	summary := log.GenerateSummary(m.summaries(), cfg.ListenerPort)
+	logger.Info(summary)
+	if m.hasHTTPServices() {
+		logger.Infof("http listener: %s", formatBindAddresses(m.bindAddrs, int(cfg.ListenerPort)))
 	}
-	if err := os.WriteFile(envoyPath, b, 0644); err != nil {
-		return err
+	for _, l := range m.namedListeners() {
+		logger.Infof("http listener %s: %s", l.Name, formatBindAddresses(m.bindAddrs, int(l.Port)))
+	}
+	if host := cfg.DashboardHost(); host != "" {
+		logger.Infof("dashboard: %s", httpURL(host, int(cfg.ListenerPort)))
+	}
+	if cfg.ListenerAuth != nil {
+		logger.Infof("listener auth: %s", formatListenerAuth(cfg.ListenerAuth))
+	}
+	if cfg.RateLimit != nil {
+		logger.Infof("rate limit (per listener): %s", formatRateLimit(cfg.RateLimit))
+	}
+	if opts.AccessLog != nil {
+		logger.Infof("access log: %s (%s)", opts.AccessLog.Path, opts.AccessLog.Format)
+	}
+	if cfg.Tracing != nil {
+		logger.Infof("tracing: %s (service %s, sampling %v%%)", cfg.Tracing.Endpoint, cfg.Tracing.ServiceName, cfg.Tracing.EffectiveSampling())
+	}
+	if adminSocket != "" && usesDatabaseProtocol(cfg) {
+		if host := cfg.DashboardHost(); host != "" {
+			logger.Infof("query stats: %s/api/services", httpURL(host, int(cfg.ListenerPort)))
+		} else {
+			logger.Infof("query stats: curl -s --unix-socket %s 'http://localhost/stats?filter=^(postgres|mysql)\\.'", adminSocket)
+		}
 	}
 
-	logger.Debugf("envoy config: %s", envoyPath)
-	logger.Debugf("listen: 0.0.0.0:%d", cfg.ListenerPort)
+	// 設定ファイルの変更を監視し、差分を反映する
+	// Envoy終了後のクリーンアップより先に監視を止め、並行して状態を変更しないようにする
+	if opts.WatchPath != "" {
+		watchCtx, stopWatch := context.WithCancel(ctx)
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			watchConfig(watchCtx, opts.WatchPath, cfg.ReferencedFiles(), configPollInterval, m.reload)
+		}()
+		defer func() {
+			stopWatch()
+			<-done
+		}()
+		logger.Infof("watching %s for changes", opts.WatchPath)
+	}
 
-	// サマリー出力
-	summary := log.GenerateSummary(visitor.GetServiceSummaries(), cfg.ListenerPort)
-	logger.Info(summary)
+	// プロキシ実行（contextキャンセル時に自動終了）
+	// port-forwardのgoroutineもcontextキャンセル時に自動終了する
+	return proxy.run(ctx)
+}
 
-	envoyCmd := exec.CommandContext(
-		ctx,
-		"envoy",
-		"-c", envoyPath,
-		"-l", logger.EnvoyLevel(),
-	)
-	envoyCmd.Stdout = os.Stdout
-	envoyCmd.Stderr = os.Stderr
+// errListenerAuthBuiltin は組み込みプロキシでlistener_authを指定した場合のエラー
+var errListenerAuthBuiltin = errors.New("listener_auth is not supported by the builtin proxy (use --proxy=envoy)")
 
-	// Envoy実行（contextキャンセル時に自動終了）
-	// port-forwardのgoroutineもcontextキャンセル時に自動終了する
-	return envoyCmd.Run()
+// warnBuiltinUnsupported は組み込みプロキシが無視するグローバル設定を警告する
+// ホスト単位の設定はプロキシへの反映時に警告する
+func warnBuiltinUnsupported(cfg *config.Config, opts RunOptions) {
+	var features []string
+	if cfg.RateLimit != nil {
+		features = append(features, "rate_limit")
+	}
+	if opts.AccessLog != nil {
+		features = append(features, "--access-log")
+	}
+	if cfg.Tracing != nil {
+		features = append(features, "tracing")
+	}
+	if len(cfg.EnvoyOverrides) > 0 {
+		features = append(features, "envoy_overrides")
+	}
+	for _, f := range features {
+		fmt.Fprintf(os.Stderr, "warning: %s is not supported by the builtin proxy\n", f)
+	}
 }
 
 func sanitize(s string) string {
@@ -0,0 +1,140 @@
+package run
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	bootstrapv3 "github.com/envoyproxy/go-control-plane/envoy/config/bootstrap/v3"
+	corev3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+
+	"github.com/usadamasa/kubectl-localmesh/internal/envoy"
+	"github.com/usadamasa/kubectl-localmesh/internal/log"
+	"github.com/usadamasa/kubectl-localmesh/internal/port"
+	"github.com/usadamasa/kubectl-localmesh/internal/proxy"
+	"github.com/usadamasa/kubectl-localmesh/internal/xds"
+)
+
+// プロキシランタイム（--proxy）
+const (
+	ProxyEnvoy   = "envoy"   // Envoyバイナリ（デフォルト）
+	ProxyBuiltin = "builtin" // Goの組み込みプロキシ
+)
+
+// ValidateProxy はプロキシランタイムの指定を検証
+func ValidateProxy(name string) error {
+	switch name {
+	case ProxyEnvoy, ProxyBuiltin:
+		return nil
+	}
+	return fmt.Errorf("must be one of %s|%s, got %q", ProxyEnvoy, ProxyBuiltin, name)
+}
+
+// proxyRuntime はmeshが生成したルーティングを反映して実行するプロキシ
+type proxyRuntime interface {
+	// update はサービス設定とそこから生成したEnvoy設定を反映
+	// bindAddrsは共通HTTPリスナーのバインドアドレス
+	update(ctx context.Context, listenerPort port.ListenerPort, bindAddrs []string, configs []envoy.ServiceConfig, envoyCfg *bootstrapv3.Bootstrap) error
+	// version は最後に反映した設定のバージョン
+	version() int
+	// run はプロキシを実行し、終了またはcontextのキャンセルまでブロックする
+	run(ctx context.Context) error
+}
+
+// envoyRuntime はプロセス内のADSサーバーから設定を配信し、Envoyバイナリを実行する
+type envoyRuntime struct {
+	logger   *log.Logger
+	launcher envoyLauncher
+	tmpDir   string
+	socket   string
+	admin    string // Envoyの管理インターフェースのunixソケット（統計の参照用）
+	xds      *xds.Server
+	envoyCfg *bootstrapv3.Bootstrap
+}
+
+// newEnvoyRuntime はEnvoyの起動に必要なコマンドを確認し、ADSサーバーを起動する
+// リスナー・ルート・クラスタはADSサーバーから配信し、Envoyのbootstrapには接続先のunixソケットのみを書き出す
+func newEnvoyRuntime(ctx context.Context, logger *log.Logger, tmpDir string, launcher envoyLauncher) (*envoyRuntime, error) {
+	if err := launcher.check(); err != nil {
+		return nil, err
+	}
+
+	r := &envoyRuntime{
+		logger:   logger,
+		launcher: launcher,
+		tmpDir:   tmpDir,
+		socket:   filepath.Join(tmpDir, "xds.sock"),
+		admin:    filepath.Join(tmpDir, "admin.sock"),
+		xds:      xds.NewServer(logger),
+	}
+	if err := r.xds.Start(ctx, r.socket); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *envoyRuntime) update(ctx context.Context, _ port.ListenerPort, _ []string, _ []envoy.ServiceConfig, envoyCfg *bootstrapv3.Bootstrap) error {
+	if err := r.xds.Update(ctx, envoyCfg); err != nil {
+		return err
+	}
+	r.envoyCfg = envoyCfg
+	return nil
+}
+
+func (r *envoyRuntime) version() int {
+	return r.xds.Version()
+}
+
+// run はADSサーバーに接続するbootstrapを書き出してEnvoyを実行（contextキャンセル時に自動終了）
+func (r *envoyRuntime) run(ctx context.Context) error {
+	envoyPath := filepath.Join(r.tmpDir, "envoy.yaml")
+	bootstrap, err := xds.Bootstrap(r.envoyCfg, r.socket)
+	if err != nil {
+		return err
+	}
+	// 管理インターフェースはTCPポートを使わず一時ディレクトリのunixソケットで公開する
+	// （envoy_overridesでadminを設定した場合はそちらを優先）
+	if bootstrap.GetAdmin() == nil {
+		bootstrap.Admin = &bootstrapv3.Admin{
+			Address: &corev3.Address{Address: &corev3.Address_Pipe{Pipe: &corev3.Pipe{Path: r.admin}}},
+		}
+	}
+
+	b, err := envoy.MarshalYAML(bootstrap)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(envoyPath, b, 0644); err != nil {
+		return err
+	}
+
+	r.logger.Debugf("envoy config: %s", envoyPath)
+	r.logger.Debugf("xds: unix://%s", r.socket)
+	r.logger.Debugf("admin: unix://%s", r.admin)
+
+	return r.launcher.launch(ctx, envoyPath, r.logger.EnvoyLevel())
+}
+
+// builtinRuntime はEnvoyの代わりにGoの組み込みプロキシでルーティングする
+// Envoy設定（envoy_overridesなど）は使わず、サービス設定から直接リスナーを生成する
+type builtinRuntime struct {
+	server *proxy.Server
+}
+
+// newBuiltinRuntime はリスナーを持たない組み込みプロキシを生成
+func newBuiltinRuntime(logger *log.Logger) *builtinRuntime {
+	return &builtinRuntime{server: proxy.NewServer(logger)}
+}
+
+func (r *builtinRuntime) update(_ context.Context, listenerPort port.ListenerPort, bindAddrs []string, configs []envoy.ServiceConfig, _ *bootstrapv3.Bootstrap) error {
+	return r.server.Update(listenerPort, bindAddrs, configs)
+}
+
+func (r *builtinRuntime) version() int {
+	return r.server.Version()
+}
+
+func (r *builtinRuntime) run(ctx context.Context) error {
+	return r.server.Run(ctx)
+}
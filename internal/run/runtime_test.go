@@ -0,0 +1,14 @@
+package run
+
+import "testing"
+
+func TestValidateProxy(t *testing.T) {
+	for _, name := range []string{ProxyEnvoy, ProxyBuiltin} {
+		if err := ValidateProxy(name); err != nil {
+			t.Errorf("%s: unexpected error: %v", name, err)
+		}
+	}
+	if err := ValidateProxy("nginx"); err == nil {
+		t.Error("expected error for unknown proxy")
+	}
+}
@@ -3,7 +3,12 @@ package run
 import (
 	"context"
 	"fmt"
+	"net"
 	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
@@ -39,6 +44,12 @@ type RunVisitor struct {
 	// ポート競合チェッカー（TCPサービス用）
 	portChecker *port.PortConflictChecker
 
+	// サマリーのindex → クラスタ間フェイルオーバーの状態
+	failoverGroups map[int]*failoverGroup
+
+	// 起動したport-forward・SSH tunnelの接続状態（ダッシュボード表示用）
+	tunnels []*tunnelStatus
+
 	// 結果
 	serviceConfigs   []envoy.ServiceConfig
 	serviceSummaries []log.ServiceSummary
@@ -58,6 +69,33 @@ func NewRunVisitor(
 		clients:          make(map[string]*k8sClientEntry),
 		ipAllocator:      loopback.NewIPAllocator(),
 		portChecker:      port.NewPortConflictChecker(),
+		failoverGroups:   make(map[int]*failoverGroup),
+		serviceConfigs:   make([]envoy.ServiceConfig, 0),
+		serviceSummaries: make([]log.ServiceSummary, 0),
+	}
+}
+
+// forConfig は再読み込みした設定でサービスを処理するVisitorを生成
+// Kubernetes clientのキャッシュとIPアロケータは引き継ぎ、ポート競合チェッカーと結果は新しくする
+func (v *RunVisitor) forConfig(cfg *config.Config) *RunVisitor {
+	next := NewRunVisitor(v.ctx, cfg, v.logger)
+	next.clients = v.clients
+	next.ipAllocator = v.ipAllocator
+	return next
+}
+
+// forHost はホスト単位のcontextで結果を集めるVisitorを生成
+// contextをキャンセルすると、このVisitorで起動したport-forward・SSHトンネルだけが停止する
+func (v *RunVisitor) forHost(ctx context.Context) *RunVisitor {
+	return &RunVisitor{
+		ctx:              ctx,
+		cfg:              v.cfg,
+		defaultCluster:   v.defaultCluster,
+		logger:           v.logger,
+		clients:          v.clients,
+		ipAllocator:      v.ipAllocator,
+		portChecker:      v.portChecker,
+		failoverGroups:   make(map[int]*failoverGroup),
 		serviceConfigs:   make([]envoy.ServiceConfig, 0),
 		serviceSummaries: make([]log.ServiceSummary, 0),
 	}
@@ -85,8 +123,8 @@ func (v *RunVisitor) getOrCreateClient(serviceCluster string) (*kubernetes.Clien
 
 // VisitKubernetes は Kubernetes Service の処理
 func (v *RunVisitor) VisitKubernetes(s *config.KubernetesService) error {
-	// サービスに対応するKubernetes clientを取得
-	clientset, restConfig, err := v.getOrCreateClient(s.Cluster)
+	// サービスに対応するKubernetes clientを取得（フェイルオーバー時は最優先のcluster）
+	clientset, restConfig, err := v.getOrCreateClient(s.PrimaryCluster())
 	if err != nil {
 		return fmt.Errorf("failed to create kubernetes client for service '%s': %w", s.Host, err)
 	}
@@ -113,7 +151,7 @@ func (v *RunVisitor) VisitKubernetes(s *config.KubernetesService) error {
 
 	// ビルダー構築
 	builder := envoy.NewKubernetesServiceBuilder(
-		s.Host, s.Protocol, s.Namespace, s.Service, s.PortName, s.Port, s.ListenerPort, s.Cluster,
+		s.Host, s.Protocol, s.Namespace, s.Service, s.PortName, s.Port, s.ListenerPort, s.PrimaryCluster(),
 	)
 
 	v.logger.Debugf(
@@ -137,10 +175,274 @@ func (v *RunVisitor) VisitKubernetes(s *config.KubernetesService) error {
 		Backend:     fmt.Sprintf("%s/%s:%d", s.Namespace, s.Service, remotePort),
 		ListenPort:  listenPort,
 	})
+	if s.BindAddress != "" {
+		builder.BindAddresses = []string{s.BindAddress}
+		summary := &v.serviceSummaries[len(v.serviceSummaries)-1]
+		summary.Details = append(summary.Details, "bind: "+formatBindAddresses(builder.BindAddresses, int(s.ListenerPort)))
+	}
+	if len(s.Listeners) > 0 {
+		builder.Listeners = v.sharedListeners(s.Listeners)
+		summary := &v.serviceSummaries[len(v.serviceSummaries)-1]
+		summary.ListenPort = builder.Listeners[0].EffectivePort(v.cfg.ListenerPort)
+		summary.Details = append(summary.Details, "listeners: "+formatListeners(s.Listeners, builder.Listeners, v.cfg.ListenerPort))
+	}
+	if aliases := s.HostAliases(); len(aliases) > 0 {
+		builder.Aliases = aliases
+		summary := &v.serviceSummaries[len(v.serviceSummaries)-1]
+		summary.Details = append(summary.Details, "aliases: "+strings.Join(aliases, ", "))
+		// クラスタ内のURL（host:サービスのポート）でも一致させる（そのポートにHTTPリスナーがある場合のみ）
+		if ports := v.cfg.HTTPListenerPorts(); slices.Contains(ports, port.ListenerPort(remotePort)) {
+			builder.AliasPort = remotePort
+		} else {
+			fmt.Fprintf(os.Stderr, "warning: %s: no HTTP listener on the service port %d, aliases only match on %s\n",
+				s.Host, remotePort, formatPorts(listenPorts(builder, v.cfg.ListenerPort)))
+		}
+	}
 
 	// port-forwardをgoroutineで起動
-	go func(ns, svc string, local port.LocalPort, remote port.ServicePort, rc *rest.Config, cs *kubernetes.Clientset, logger *log.Logger) {
-		if err := k8s.StartPortForwardLoop(
+	// フェイルオーバー時はReadyなPodがある場合のみローカルポートを開く
+	var pfOpts k8s.PortForwardOptions
+	var group *failoverGroup
+	if len(s.Clusters) > 0 {
+		group = newFailoverGroup(s.Host, s.Clusters, v.logger)
+		v.failoverGroups[len(v.serviceSummaries)-1] = group
+		pfOpts = group.options(0)
+	}
+	if s.HealthCheck != nil {
+		reconnect := make(chan struct{}, 1)
+		pfOpts.Reconnect = reconnect
+		v.monitorHealth(s.Host, localPort, s.HealthCheck, s.Protocol, reconnect)
+	}
+	v.startPortForward(s.Namespace, s.Service, s.PrimaryCluster(), localPort, remotePort, restConfig, clientset, pfOpts)
+
+	// フェイルオーバー先のcluster（同じEnvoyクラスタに下位の優先度で追加）
+	if group != nil {
+		for i, c := range s.Clusters[1:] {
+			backend := &config.KubernetesBackend{
+				Namespace: s.Namespace,
+				Service:   s.Service,
+				PortName:  s.PortName,
+				Port:      s.Port,
+				Cluster:   c,
+			}
+			opts := group.options(i + 1)
+			var reconnect chan struct{}
+			if s.HealthCheck != nil {
+				reconnect = make(chan struct{}, 1)
+				opts.Reconnect = reconnect
+			}
+			upstream, err := v.setupUpstream(s.Host, backend, clusterName, opts)
+			if err != nil {
+				return fmt.Errorf("failed to set up failover cluster '%s' for service '%s': %w", c, s.Host, err)
+			}
+			if reconnect != nil {
+				v.monitorHealth(s.Host, upstream.LocalPort, s.HealthCheck, s.Protocol, reconnect)
+			}
+			builder.Failover = append(builder.Failover, upstream)
+		}
+	}
+
+	// ローカルプロセスを優先し、port-forwardをフォールバック先にする
+	if s.LocalOverride != nil {
+		address, p := s.LocalOverride.Endpoint()
+		builder.LocalOverride = &envoy.LocalOverride{
+			Address:    address,
+			Port:       p,
+			HealthPath: s.LocalOverride.HealthPath,
+		}
+		summary := &v.serviceSummaries[len(v.serviceSummaries)-1]
+		check := "tcp"
+		if s.LocalOverride.HealthPath != "" {
+			check = s.LocalOverride.HealthPath
+		}
+		summary.Details = append(summary.Details, fmt.Sprintf("local override -> %s (health check: %s, falls back to cluster)",
+			net.JoinHostPort(address, strconv.Itoa(p)), check))
+	}
+
+	// ヘッダー一致時の代替バックエンド（Kubernetes Serviceの場合は専用のport-forwardを起動）
+	for i, r := range s.HeaderRoutes {
+		route := envoy.HeaderRoute{Header: r.Header, Value: r.Value}
+		routeClusterName := fmt.Sprintf("%s_header_%d", clusterName, i+1)
+		var target string
+		if r.Address != "" {
+			address, p := r.Endpoint()
+			route.Upstream = envoy.Upstream{ClusterName: routeClusterName, LocalPort: port.LocalPort(p)}
+			route.Address = address
+			target = net.JoinHostPort(address, strconv.Itoa(p)) + " (local)"
+		} else {
+			upstream, err := v.setupUpstream(s.Host, &r.KubernetesBackend, routeClusterName, k8s.PortForwardOptions{})
+			if err != nil {
+				return fmt.Errorf("failed to set up header_routes[%d] for service '%s': %w", i, s.Host, err)
+			}
+			route.Upstream = upstream
+			target = formatUpstream(upstream, s.PrimaryCluster())
+		}
+		builder.HeaderRoutes = append(builder.HeaderRoutes, route)
+
+		summary := &v.serviceSummaries[len(v.serviceSummaries)-1]
+		summary.Details = append(summary.Details, fmt.Sprintf("header %s -> %s", formatHeaderMatch(r.Header, r.Value), target))
+	}
+
+	// 重み付き分散の追加バックエンド（それぞれ専用のport-forwardを起動）
+	if len(s.Split) > 0 {
+		summary := &v.serviceSummaries[len(v.serviceSummaries)-1]
+		builder.Weight = *s.Weight
+		summary.Backend = fmt.Sprintf("%s (weight %d)", summary.Backend, *s.Weight)
+		for i, b := range s.Split {
+			upstream, err := v.setupUpstream(s.Host, &b.KubernetesBackend, fmt.Sprintf("%s_split_%d", clusterName, i+1), k8s.PortForwardOptions{})
+			if err != nil {
+				return fmt.Errorf("failed to set up split[%d] for service '%s': %w", i, s.Host, err)
+			}
+			builder.Splits = append(builder.Splits, envoy.WeightedUpstream{Upstream: upstream, Weight: b.Weight})
+			summary.Details = append(summary.Details, fmt.Sprintf("split -> %s (weight %d)", formatUpstream(upstream, s.PrimaryCluster()), b.Weight))
+		}
+	}
+
+	// トラフィックミラーリング先（専用のport-forwardを起動）
+	if s.Mirror != nil {
+		upstream, err := v.setupUpstream(s.Host, &s.Mirror.KubernetesBackend, clusterName+"_mirror", k8s.PortForwardOptions{})
+		if err != nil {
+			return fmt.Errorf("failed to set up mirror for service '%s': %w", s.Host, err)
+		}
+		builder.Mirror = &envoy.MirrorUpstream{
+			Upstream: upstream,
+			Percent:  s.Mirror.EffectivePercent(),
+		}
+		summary := &v.serviceSummaries[len(v.serviceSummaries)-1]
+		summary.Details = append(summary.Details, fmt.Sprintf("mirror -> %s (%v%%)",
+			formatUpstream(upstream, s.PrimaryCluster()), s.Mirror.EffectivePercent()))
+	}
+
+	if s.HealthCheck != nil {
+		builder.HealthCheck = toEnvoyHealthCheck(s.HealthCheck)
+		summary := &v.serviceSummaries[len(v.serviceSummaries)-1]
+		summary.Details = append(summary.Details, formatHealthCheck(s.HealthCheck))
+	}
+
+	if s.RateLimit != nil {
+		builder.RateLimit = toEnvoyRateLimit(s.RateLimit)
+		summary := &v.serviceSummaries[len(v.serviceSummaries)-1]
+		summary.Details = append(summary.Details, "rate limit: "+formatRateLimit(s.RateLimit))
+	}
+
+	if s.AccessLog != nil {
+		builder.AccessLog = toEnvoyAccessLog(s.AccessLog)
+		summary := &v.serviceSummaries[len(v.serviceSummaries)-1]
+		summary.Details = append(summary.Details, fmt.Sprintf("access log -> %s (%s)", s.AccessLog.Path, s.AccessLog.Format))
+	}
+
+	if s.LuaScript() != "" {
+		builder.Lua = s.LuaScript()
+		source := "inline"
+		if s.LuaFile != "" {
+			source = s.LuaFile
+		}
+		summary := &v.serviceSummaries[len(v.serviceSummaries)-1]
+		summary.Details = append(summary.Details, "lua: "+source)
+	}
+
+	builder.RedirectHosts = s.RedirectHosts()
+	builder.CookieDomains = s.CookieDomains()
+	if len(builder.RedirectHosts) > 0 || len(builder.CookieDomains) > 0 {
+		var rewrites []string
+		if len(builder.RedirectHosts) > 0 {
+			rewrites = append(rewrites, "Location")
+		}
+		if len(builder.CookieDomains) > 0 {
+			rewrites = append(rewrites, "Set-Cookie domain")
+		}
+		summary := &v.serviceSummaries[len(v.serviceSummaries)-1]
+		summary.Details = append(summary.Details, fmt.Sprintf("rewrite %s: in-cluster host names -> %s", strings.Join(rewrites, ", "), s.Host))
+	}
+
+	// ServiceConfig を保存
+	v.serviceConfigs = append(v.serviceConfigs, envoy.ServiceConfig{
+		Builder:     builder,
+		ClusterName: clusterName,
+		LocalPort:   localPort,
+	})
+
+	return nil
+}
+
+// setupUpstream はメイン以外のバックエンドのポート解決とport-forward起動を行う
+func (v *RunVisitor) setupUpstream(host string, b *config.KubernetesBackend, clusterName string, opts k8s.PortForwardOptions) (envoy.Upstream, error) {
+	clientset, restConfig, err := v.getOrCreateClient(b.Cluster)
+	if err != nil {
+		return envoy.Upstream{}, fmt.Errorf("failed to create kubernetes client: %w", err)
+	}
+
+	remotePort, err := k8s.ResolveServicePort(v.ctx, clientset, b.Namespace, b.Service, b.PortName, b.Port)
+	if err != nil {
+		return envoy.Upstream{}, err
+	}
+
+	localPort, err := port.FreeLocalPort()
+	if err != nil {
+		return envoy.Upstream{}, err
+	}
+
+	v.logger.Debugf(
+		"pf: %-30s -> %s/%s:%d via 127.0.0.1:%d (%s)",
+		host,
+		b.Namespace,
+		b.Service,
+		remotePort,
+		localPort,
+		clusterName,
+	)
+
+	v.startPortForward(b.Namespace, b.Service, b.Cluster, localPort, remotePort, restConfig, clientset, opts)
+
+	return envoy.Upstream{
+		ClusterName:        clusterName,
+		LocalPort:          localPort,
+		ResolvedRemotePort: remotePort,
+		Namespace:          b.Namespace,
+		ServiceName:        b.Service,
+		PortName:           b.PortName,
+		Port:               b.Port,
+		Cluster:            b.Cluster,
+	}, nil
+}
+
+// formatUpstream はサマリー表示用にバックエンドを整形する
+// 親サービスと異なるclusterの場合はcluster名も表示する
+func formatUpstream(u envoy.Upstream, parentCluster string) string {
+	s := fmt.Sprintf("%s/%s:%d", u.Namespace, u.ServiceName, u.ResolvedRemotePort)
+	if u.Cluster != parentCluster {
+		s += fmt.Sprintf(" @ %s", u.Cluster)
+	}
+	return s
+}
+
+// formatHeaderMatch はサマリー表示用にヘッダー一致条件を整形する
+func formatHeaderMatch(header, value string) string {
+	if value == "" {
+		return header + " (present)"
+	}
+	return header + ": " + value
+}
+
+// startPortForward はport-forwardをgoroutineで起動する
+// 接続状態はclusterを付けた接続先（clusterが空の場合は省略）としてダッシュボードに表示する
+func (v *RunVisitor) startPortForward(ns, svc, cluster string, local port.LocalPort, remote port.ServicePort, rc *rest.Config, cs *kubernetes.Clientset, opts k8s.PortForwardOptions) {
+	target := fmt.Sprintf("%s/%s:%d", ns, svc, remote)
+	if cluster != "" {
+		target += " @ " + cluster
+	}
+	status := newTunnelStatus(target)
+	v.tunnels = append(v.tunnels, status)
+	onStateChange := opts.OnStateChange
+	opts.OnStateChange = func(ready bool, podName string) {
+		status.set(ready, podName)
+		if onStateChange != nil {
+			onStateChange(ready, podName)
+		}
+	}
+
+	go func(logger *log.Logger) {
+		if err := k8s.StartPortForwardLoopWithOptions(
 			v.ctx,
 			rc,
 			cs,
@@ -149,21 +451,13 @@ func (v *RunVisitor) VisitKubernetes(s *config.KubernetesService) error {
 			local,
 			remote,
 			logger,
+			opts,
 		); err != nil {
 			if v.ctx.Err() == nil {
 				fmt.Fprintf(os.Stderr, "port-forward error for %s/%s: %v\n", ns, svc, err)
 			}
 		}
-	}(s.Namespace, s.Service, localPort, remotePort, restConfig, clientset, v.logger)
-
-	// ServiceConfig を保存
-	v.serviceConfigs = append(v.serviceConfigs, envoy.ServiceConfig{
-		Builder:     builder,
-		ClusterName: clusterName,
-		LocalPort:   localPort,
-	})
-
-	return nil
+	}(v.logger)
 }
 
 // VisitTCP は TCP Service の処理
@@ -182,17 +476,21 @@ func (v *RunVisitor) VisitTCP(s *config.TCPService) error {
 	clusterName := sanitize(fmt.Sprintf("tcp_%s_%s_%d", s.SSHBastion, s.TargetHost, s.TargetPort))
 
 	// loopback IP割り当て（同一ポート重複を回避）
-	listenAddr, err := v.ipAllocator.Allocate()
-	if err != nil {
-		return fmt.Errorf("failed to allocate loopback IP for service '%s': %w", s.Host, err)
-	}
+	// SNIで振り分けるサービスはエイリアスを使わず、127.0.0.1のリスナーを共有する
+	listenAddr := "127.0.0.1"
+	if !s.SNI {
+		listenAddr, err = v.ipAllocator.Allocate()
+		if err != nil {
+			return fmt.Errorf("failed to allocate loopback IP for service '%s': %w", s.Host, err)
+		}
 
-	// ポート競合チェック（IP:port の組み合わせでチェック）
-	listenPort := s.ListenPort
-	if listenPort == 0 {
-		listenPort = s.TargetPort
+		// ポート競合チェック（IP:port の組み合わせでチェック）
+		listenPort := s.ListenPort
+		if listenPort == 0 {
+			listenPort = s.TargetPort
+		}
+		v.portChecker.RegisterWithAddr(listenAddr, int(listenPort), s.Host)
 	}
-	v.portChecker.RegisterWithAddr(listenAddr, int(listenPort), s.Host)
 
 	// ビルダー構築
 	builder := envoy.NewTCPServiceBuilder(s.Host, s.ListenPort, listenAddr, s.SSHBastion, s.TargetHost, s.TargetPort)
@@ -217,16 +515,58 @@ func (v *RunVisitor) VisitTCP(s *config.TCPService) error {
 		Backend:     fmt.Sprintf("%s @ %s:%d", s.SSHBastion, s.TargetHost, s.TargetPort),
 		ListenPort:  port.ListenerPort(s.ListenPort),
 	})
+	if s.AccessLog != nil {
+		builder.AccessLog = toEnvoyAccessLog(s.AccessLog)
+		summary := &v.serviceSummaries[len(v.serviceSummaries)-1]
+		summary.Details = append(summary.Details, fmt.Sprintf("access log -> %s (%s)", s.AccessLog.Path, s.AccessLog.Format))
+	}
+
+	if s.SNI {
+		builder.ServerNames = s.EffectiveServerNames()
+		summary := &v.serviceSummaries[len(v.serviceSummaries)-1]
+		summary.Details = append(summary.Details, fmt.Sprintf("sni: %s (shared %s:%d)",
+			strings.Join(builder.ServerNames, ", "), listenAddr, s.ListenPort))
+	}
+
+	if aliases := s.HostAliases(); len(aliases) > 0 {
+		builder.Aliases = aliases
+		summary := &v.serviceSummaries[len(v.serviceSummaries)-1]
+		summary.Details = append(summary.Details, "aliases: "+strings.Join(aliases, ", "))
+	}
+
+	if s.Protocol != "" {
+		builder.Protocol = s.Protocol
+		summary := &v.serviceSummaries[len(v.serviceSummaries)-1]
+		summary.Details = append(summary.Details, fmt.Sprintf("query stats: %s.%s.*", s.Protocol, envoy.DatabaseStatPrefix(s.Host)))
+	}
+
+	var tunnelOpts gcp.SSHTunnelOptions
+	if s.HealthCheck != nil {
+		builder.HealthCheck = toEnvoyHealthCheck(s.HealthCheck)
+		summary := &v.serviceSummaries[len(v.serviceSummaries)-1]
+		summary.Details = append(summary.Details, formatHealthCheck(s.HealthCheck))
+
+		reconnect := make(chan struct{}, 1)
+		tunnelOpts.Reconnect = reconnect
+		v.monitorHealth(s.Host, localPort, s.HealthCheck, "tcp", reconnect)
+	}
+
+	status := newTunnelStatus(fmt.Sprintf("%s @ %s:%d", s.SSHBastion, s.TargetHost, s.TargetPort))
+	v.tunnels = append(v.tunnels, status)
+	tunnelOpts.OnStateChange = func(ready bool) {
+		status.set(ready, "")
+	}
 
 	// GCP SSH tunnelをgoroutineで起動
 	go func(b *config.SSHBastion, local port.LocalPort, target string, targetPort port.TCPPort, logger *log.Logger) {
-		if err := gcp.StartGCPSSHTunnel(
+		if err := gcp.StartGCPSSHTunnelWithOptions(
 			v.ctx,
 			b,
 			local,
 			target,
 			targetPort,
 			logger,
+			tunnelOpts,
 		); err != nil {
 			if v.ctx.Err() == nil {
 				fmt.Fprintf(os.Stderr, "gcp-ssh tunnel error for %s: %v\n", b.Instance, err)
@@ -250,11 +590,161 @@ func (v *RunVisitor) GetServiceConfigs() []envoy.ServiceConfig {
 }
 
 // GetServiceSummaries は収集した ServiceSummary を返す
+// クラスタ間フェイルオーバーするサービスには呼び出し時点のアクティブなclusterを付与する
 func (v *RunVisitor) GetServiceSummaries() []log.ServiceSummary {
-	return v.serviceSummaries
+	if len(v.failoverGroups) == 0 {
+		return v.serviceSummaries
+	}
+
+	summaries := make([]log.ServiceSummary, len(v.serviceSummaries))
+	copy(summaries, v.serviceSummaries)
+	for idx, group := range v.failoverGroups {
+		details := append([]string{}, summaries[idx].Details...)
+		summaries[idx].Details = append(details, group.details()...)
+	}
+	return summaries
+}
+
+// WaitFailover はフェイルオーバーする各サービスの最優先clusterのport-forward確立をtimeoutまで待つ
+func (v *RunVisitor) WaitFailover(ctx context.Context, timeout time.Duration) {
+	deadline := time.Now().Add(timeout)
+	for _, group := range v.failoverGroups {
+		group.waitPrimary(ctx, time.Until(deadline))
+	}
 }
 
 // GetIPAllocator はIPアロケータを返す（エイリアス管理用）
 func (v *RunVisitor) GetIPAllocator() *loopback.IPAllocator {
 	return v.ipAllocator
 }
+
+// toEnvoyRateLimit はレート制限設定をEnvoy用に変換（未設定の場合はnil）
+func toEnvoyRateLimit(r *config.RateLimit) *envoy.RateLimit {
+	if r == nil {
+		return nil
+	}
+	return &envoy.RateLimit{
+		Requests:     r.Requests,
+		Burst:        r.EffectiveBurst(),
+		FillInterval: r.EffectiveFillInterval(),
+	}
+}
+
+// formatRateLimit はレート制限設定を表示用に整形（例: 10 req/1s, burst 20）
+func formatRateLimit(r *config.RateLimit) string {
+	return fmt.Sprintf("%d req/%s, burst %d", r.Requests, r.EffectiveFillInterval(), r.EffectiveBurst())
+}
+
+// toEnvoyAccessLog はアクセスログ設定をEnvoy用に変換（未設定の場合はnil）
+func toEnvoyAccessLog(a *config.AccessLog) *envoy.AccessLog {
+	if a == nil {
+		return nil
+	}
+	return &envoy.AccessLog{Path: a.Path, Format: a.Format}
+}
+
+// toEnvoyTracing はトレーシング設定をEnvoy用に変換（未設定の場合はnil）
+func toEnvoyTracing(t *config.Tracing) *envoy.Tracing {
+	if t == nil {
+		return nil
+	}
+	address, p := t.Collector()
+	return &envoy.Tracing{
+		Address:         address,
+		Port:            p,
+		SamplingPercent: t.EffectiveSampling(),
+		ServiceName:     t.ServiceName,
+	}
+}
+
+// toEnvoyListenerAuth はリスナー認証の設定をEnvoy用に変換（未設定の場合はnil）
+func toEnvoyListenerAuth(a *config.ListenerAuth) *envoy.ListenerAuth {
+	if a == nil {
+		return nil
+	}
+	auth := &envoy.ListenerAuth{AllowedCIDRs: a.AllowedCIDRs}
+	if a.Basic != nil {
+		auth.BasicUsername = a.Basic.Username
+		auth.BasicPassword = a.Basic.Password.Value()
+	}
+	if a.BearerToken != nil {
+		auth.BearerToken = a.BearerToken.Value()
+	}
+	return auth
+}
+
+// formatListenerAuth はリスナー認証の設定を表示用に整形（秘密の値は表示しない）
+// 例: basic (alice), bearer token; allowed sources: loopback, 192.168.1.0/24
+func formatListenerAuth(a *config.ListenerAuth) string {
+	var methods []string
+	if a.Basic != nil {
+		methods = append(methods, fmt.Sprintf("basic (%s)", a.Basic.Username))
+	}
+	if a.BearerToken != nil {
+		methods = append(methods, "bearer token")
+	}
+	sources := "any"
+	if len(a.AllowedCIDRs) > 0 {
+		sources = strings.Join(append([]string{"loopback"}, a.AllowedCIDRs...), ", ")
+	}
+	if len(methods) == 0 {
+		return "allowed sources: " + sources
+	}
+	return strings.Join(methods, ", ") + " (not required from loopback); allowed sources: " + sources
+}
+
+// toEnvoyOverrides はenvoy_overridesをEnvoy用に変換
+func toEnvoyOverrides(overrides []*config.EnvoyOverride) []envoy.Override {
+	var result []envoy.Override
+	for _, o := range overrides {
+		result = append(result, envoy.Override{Listener: o.Listener, Cluster: o.Cluster, Patch: o.PatchJSON()})
+	}
+	return result
+}
+
+// sharedListeners はサービスが接続する名前付きリスナーをEnvoyの共有HTTPリスナーに変換する
+// defaultはlistener_portの共通HTTPリスナー（名前なし）とする
+func (v *RunVisitor) sharedListeners(names []string) []envoy.NamedListener {
+	listeners := make([]envoy.NamedListener, 0, len(names))
+	for _, name := range names {
+		if name == config.DefaultListenerName {
+			listeners = append(listeners, envoy.NamedListener{})
+			continue
+		}
+		listeners = append(listeners, envoy.NamedListener{Name: name, Port: v.cfg.Listeners[name]})
+	}
+	return listeners
+}
+
+// listenPorts はKubernetesサービスのホストを提供するリスナーのポートを返す
+func listenPorts(b *envoy.KubernetesServiceBuilder, listenerPort port.ListenerPort) []port.ListenerPort {
+	if b.OverwriteListenPort != 0 {
+		return []port.ListenerPort{b.OverwriteListenPort}
+	}
+	if len(b.Listeners) == 0 {
+		return []port.ListenerPort{listenerPort}
+	}
+	ports := make([]port.ListenerPort, len(b.Listeners))
+	for i, l := range b.Listeners {
+		ports[i] = l.EffectivePort(listenerPort)
+	}
+	return ports
+}
+
+// formatPorts はポートを":<port>"形式のカンマ区切りで整形
+func formatPorts(ports []port.ListenerPort) string {
+	formatted := make([]string, len(ports))
+	for i, p := range ports {
+		formatted[i] = fmt.Sprintf(":%d", p)
+	}
+	return strings.Join(formatted, ", ")
+}
+
+// formatListeners はサマリー表示用に接続するリスナーの名前とポートを整形する（例: web (:80), api (:8080)）
+func formatListeners(names []string, listeners []envoy.NamedListener, listenerPort port.ListenerPort) string {
+	formatted := make([]string, len(names))
+	for i, name := range names {
+		formatted[i] = fmt.Sprintf("%s (:%d)", name, listeners[i].EffectivePort(listenerPort))
+	}
+	return strings.Join(formatted, ", ")
+}
@@ -2,10 +2,13 @@ package run
 
 import (
 	"context"
+	"slices"
 	"testing"
 
 	"github.com/usadamasa/kubectl-localmesh/internal/config"
+	"github.com/usadamasa/kubectl-localmesh/internal/envoy"
 	"github.com/usadamasa/kubectl-localmesh/internal/log"
+	"github.com/usadamasa/kubectl-localmesh/internal/port"
 )
 
 func TestRunVisitor_Creation(t *testing.T) {
@@ -27,3 +30,74 @@ func TestRunVisitor_Creation(t *testing.T) {
 		t.Errorf("expected 0 summaries, got %d", len(visitor.GetServiceSummaries()))
 	}
 }
+
+func TestFormatUpstream(t *testing.T) {
+	u := envoy.Upstream{Namespace: "users", ServiceName: "users-api", ResolvedRemotePort: 8080, Cluster: "dev"}
+
+	if got := formatUpstream(u, "dev"); got != "users/users-api:8080" {
+		t.Errorf("expected 'users/users-api:8080', got %q", got)
+	}
+	// 親サービスと異なるclusterの場合はcluster名を表示
+	if got := formatUpstream(u, "prod"); got != "users/users-api:8080 @ dev" {
+		t.Errorf("expected 'users/users-api:8080 @ dev', got %q", got)
+	}
+}
+
+func TestFormatHealthCheck(t *testing.T) {
+	tests := []struct {
+		hc   config.HealthCheck
+		want string
+	}{
+		{hc: config.HealthCheck{Type: "http", Path: "/healthz"}, want: "health check: http /healthz every 5s (reconnect after 3 failures)"},
+		{hc: config.HealthCheck{Type: "grpc", Service: "users.v1.UserService", Interval: "10s", UnhealthyThreshold: 2}, want: "health check: grpc users.v1.UserService every 10s (reconnect after 2 failures)"},
+		{hc: config.HealthCheck{Type: "tcp"}, want: "health check: tcp every 5s (reconnect after 3 failures)"},
+	}
+
+	for _, tt := range tests {
+		if got := formatHealthCheck(&tt.hc); got != tt.want {
+			t.Errorf("expected %q, got %q", tt.want, got)
+		}
+	}
+}
+
+func TestFormatListenerAuth(t *testing.T) {
+	tests := []struct {
+		auth config.ListenerAuth
+		want string
+	}{
+		{
+			auth: config.ListenerAuth{Basic: &config.BasicAuth{Username: "alice"}, BearerToken: &config.Secret{Env: "TOKEN"}},
+			want: "basic (alice), bearer token (not required from loopback); allowed sources: any",
+		},
+		{
+			auth: config.ListenerAuth{AllowedCIDRs: []string{"192.168.1.0/24"}},
+			want: "allowed sources: loopback, 192.168.1.0/24",
+		},
+	}
+
+	for _, tt := range tests {
+		if got := formatListenerAuth(&tt.auth); got != tt.want {
+			t.Errorf("expected %q, got %q", tt.want, got)
+		}
+	}
+}
+
+func TestListenPorts(t *testing.T) {
+	b := envoy.NewKubernetesServiceBuilder("users.localhost", "http", "users", "users-api", "", 8080, 0, "")
+	if got := listenPorts(b, 80); !slices.Equal(got, []port.ListenerPort{80}) {
+		t.Errorf("expected [80], got %v", got)
+	}
+
+	b.Listeners = []envoy.NamedListener{{}, {Name: "api", Port: 8080}}
+	if got := listenPorts(b, 80); !slices.Equal(got, []port.ListenerPort{80, 8080}) {
+		t.Errorf("expected [80 8080], got %v", got)
+	}
+	if got := formatPorts(listenPorts(b, 80)); got != ":80, :8080" {
+		t.Errorf("unexpected format: %q", got)
+	}
+
+	b = envoy.NewKubernetesServiceBuilder("admin.localhost", "http", "admin", "admin", "", 8080, 8081, "")
+	if got := listenPorts(b, 80); !slices.Equal(got, []port.ListenerPort{8081}) {
+		t.Errorf("expected [8081], got %v", got)
+	}
+}
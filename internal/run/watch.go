@@ -0,0 +1,71 @@
+package run
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/usadamasa/kubectl-localmesh/internal/config"
+)
+
+// configPollInterval は設定ファイルの変更を確認する間隔
+const configPollInterval = time.Second
+
+// watchConfig は設定ファイルと設定が参照するファイル（files）を定期的に読み込み、内容が変わった場合にonChangeを呼ぶ
+// 参照するファイルは読み込みに成功した設定ごとに更新する（失敗した設定が新たに参照するファイルは監視しない）
+// エディタの保存途中などで読み込み・検証に失敗した変更はログに出力してスキップする
+// contextのキャンセルで終了する
+func watchConfig(ctx context.Context, path string, files []string, interval time.Duration, onChange func(*config.Config)) {
+	last, _ := fingerprint(path, files)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		fp, err := fingerprint(path, files)
+		if err != nil || fp == last {
+			continue
+		}
+		last = fp
+
+		cfg, err := config.Load(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "warning: ignoring invalid config change in %s: %v\n", path, err)
+			continue
+		}
+		files = cfg.ReferencedFiles()
+		last, _ = fingerprint(path, files)
+		onChange(cfg)
+	}
+}
+
+// fingerprint は設定ファイルと参照するファイルの内容のハッシュを返す
+// 参照するファイルが読めない場合は、読めないこと自体を内容として扱う
+func fingerprint(path string, files []string) ([sha256.Size]byte, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return [sha256.Size]byte{}, err
+	}
+	h := sha256.New()
+	fmt.Fprintf(h, "%d\n", len(b))
+	h.Write(b)
+	for _, f := range files {
+		content, err := os.ReadFile(f)
+		if err != nil {
+			fmt.Fprintf(h, "%s\x00missing\n", f)
+			continue
+		}
+		fmt.Fprintf(h, "%s\x00%d\n", f, len(content))
+		h.Write(content)
+	}
+	var sum [sha256.Size]byte
+	copy(sum[:], h.Sum(nil))
+	return sum, nil
+}
@@ -0,0 +1,132 @@
+package run
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/usadamasa/kubectl-localmesh/internal/config"
+)
+
+func TestWatchConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "services.yaml")
+	if err := os.WriteFile(path, []byte(meshBaseConfig), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	changes := make(chan *config.Config, 1)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		watchConfig(ctx, path, nil, 10*time.Millisecond, func(cfg *config.Config) { changes <- cfg })
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	// 検証に失敗する変更はスキップする
+	if err := os.WriteFile(path, []byte("services: []\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case cfg := <-changes:
+		t.Fatalf("expected invalid config to be skipped, got %+v", cfg)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// 有効な変更は読み込んだ設定で通知する
+	if err := os.WriteFile(path, []byte("listener_port: 8080\n"+meshBaseConfig), 0644); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case cfg := <-changes:
+		if cfg.ListenerPort != 8080 {
+			t.Errorf("expected listener_port 8080, got %d", cfg.ListenerPort)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected config change to be reported")
+	}
+}
+
+func TestWatchConfig_ReferencedFiles(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "services.yaml")
+	luaPath := filepath.Join(dir, "users.lua")
+	content := meshBaseConfig + `
+  - kind: kubernetes
+    host: lua.localhost
+    namespace: lua
+    service: lua-api
+    protocol: http
+    lua_file: users.lua
+`
+	if err := os.WriteFile(luaPath, []byte("function envoy_on_request(handle) end -- v1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := config.Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	changes := make(chan *config.Config, 1)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		watchConfig(ctx, path, cfg.ReferencedFiles(), 10*time.Millisecond, func(cfg *config.Config) { changes <- cfg })
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	luaScript := func(cfg *config.Config) string {
+		for _, s := range cfg.Services {
+			if k, ok := s.Get().(*config.KubernetesService); ok && k.Host == "lua.localhost" {
+				return k.LuaScript()
+			}
+		}
+		return ""
+	}
+	expectLua := func(want string) {
+		t.Helper()
+		select {
+		case cfg := <-changes:
+			if got := luaScript(cfg); got != want {
+				t.Errorf("expected lua %q, got %q", want, got)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatal("expected config change to be reported")
+		}
+	}
+
+	// 監視を始めるまで待ってから変更する
+	time.Sleep(50 * time.Millisecond)
+
+	// 設定ファイルが変わらなくても、参照するファイルの変更で読み込み直す
+	if err := os.WriteFile(luaPath, []byte("function envoy_on_request(handle) end -- v2\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	expectLua("function envoy_on_request(handle) end -- v2\n")
+
+	// 参照するファイルがない間は読み込みに失敗し、作り直した時点で読み込む
+	if err := os.Remove(luaPath); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case cfg := <-changes:
+		t.Fatalf("expected missing lua_file to be skipped, got %+v", cfg)
+	case <-time.After(100 * time.Millisecond):
+	}
+	if err := os.WriteFile(luaPath, []byte("function envoy_on_request(handle) end -- v3\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	expectLua("function envoy_on_request(handle) end -- v3\n")
+}
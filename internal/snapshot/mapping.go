@@ -1,12 +1,19 @@
 package snapshot
 
-import "github.com/usadamasa/kubectl-localmesh/internal/envoy"
+import (
+	"net"
+	"strconv"
+
+	"github.com/usadamasa/kubectl-localmesh/internal/config"
+	"github.com/usadamasa/kubectl-localmesh/internal/envoy"
+)
 
 // PortForwardMapping はポート割り当て結果を記録
 type PortForwardMapping struct {
-	Kind     string `yaml:"kind"`
-	Host     string `yaml:"host"`
-	Protocol string `yaml:"protocol,omitempty"`
+	Kind     string   `yaml:"kind"`
+	Host     string   `yaml:"host"`
+	Aliases  []string `yaml:"aliases,omitempty"`
+	Protocol string   `yaml:"protocol,omitempty"`
 
 	// Kubernetes service fields
 	Namespace          string `yaml:"namespace,omitempty"`
@@ -25,8 +32,54 @@ type PortForwardMapping struct {
 	AssignedListenAddr   string `yaml:"assigned_listen_addr,omitempty"`   // TCPサービス用（loopback IP）
 	AssignedListenerPort int    `yaml:"assigned_listener_port,omitempty"` // TCPサービス用 / 個別リスナーポート
 
+	// 接続する名前付きの共有HTTPリスナー
+	Listeners []ListenerMapping `yaml:"listeners,omitempty"`
+
 	// Envoy cluster reference
 	EnvoyClusterName string `yaml:"envoy_cluster_name"`
+
+	// grpc_aggregate fields
+	GRPCRoutes []GRPCRouteMapping `yaml:"grpc_routes,omitempty"`
+
+	// ローカルプロセスを優先する場合のアドレス（port-forwardはフォールバック）
+	LocalOverrideAddress string `yaml:"local_override_address,omitempty"`
+
+	// 重み付き分散時のメインのバックエンドの重み
+	Weight *int `yaml:"weight,omitempty"`
+
+	// フェイルオーバー先・重み付き分散・ミラー先など追加のバックエンド
+	Upstreams []UpstreamMapping `yaml:"upstreams,omitempty"`
+}
+
+// UpstreamMapping はメイン以外のバックエンドのポート割り当て結果を記録
+type UpstreamMapping struct {
+	Role               string  `yaml:"role"` // failover|split|mirror|header_route
+	Namespace          string  `yaml:"namespace,omitempty"`
+	Service            string  `yaml:"service,omitempty"`
+	PortName           string  `yaml:"port_name,omitempty"`
+	Cluster            string  `yaml:"cluster,omitempty"`
+	ResolvedRemotePort int     `yaml:"resolved_remote_port,omitempty"`
+	AssignedLocalPort  int     `yaml:"assigned_local_port,omitempty"`
+	EnvoyClusterName   string  `yaml:"envoy_cluster_name"`
+	Priority           int     `yaml:"priority,omitempty"`
+	Weight             int     `yaml:"weight,omitempty"`
+	Percent            float64 `yaml:"percent,omitempty"`
+	Header             string  `yaml:"header,omitempty"`
+	HeaderValue        string  `yaml:"header_value,omitempty"`
+	Address            string  `yaml:"address,omitempty"` // ローカルプロセスに直接接続する場合のアドレス
+}
+
+// ListenerMapping はサービスを接続した共有HTTPリスナーを記録
+// listener_portの共通HTTPリスナーはdefaultとして記録する（ポートは省略）
+type ListenerMapping struct {
+	Name string `yaml:"name"`
+	Port int    `yaml:"port,omitempty"`
+}
+
+// GRPCRouteMapping は集約gRPCホストのサービス名とクラスタの対応を記録
+type GRPCRouteMapping struct {
+	Service          string `yaml:"service"`
+	EnvoyClusterName string `yaml:"envoy_cluster_name"`
 }
 
 // PortForwardMappingSet はマッピングのセット
@@ -44,6 +97,7 @@ func BuildMappings(configs []envoy.ServiceConfig) PortForwardMappingSet {
 			mapping := PortForwardMapping{
 				Kind:               "kubernetes",
 				Host:               builder.Host,
+				Aliases:            builder.Aliases,
 				Protocol:           builder.Protocol,
 				Namespace:          builder.Namespace,
 				Service:            builder.ServiceName,
@@ -59,12 +113,59 @@ func BuildMappings(configs []envoy.ServiceConfig) PortForwardMappingSet {
 				mapping.AssignedListenerPort = int(builder.OverwriteListenPort)
 			}
 
+			for _, l := range builder.Listeners {
+				if l.Name == "" {
+					mapping.Listeners = append(mapping.Listeners, ListenerMapping{Name: config.DefaultListenerName})
+					continue
+				}
+				mapping.Listeners = append(mapping.Listeners, ListenerMapping{Name: l.Name, Port: int(l.Port)})
+			}
+
+			if builder.LocalOverride != nil {
+				mapping.LocalOverrideAddress = net.JoinHostPort(builder.LocalOverride.Address, strconv.Itoa(builder.LocalOverride.Port))
+			}
+
+			for i, failover := range builder.Failover {
+				u := newUpstreamMapping("failover", failover)
+				u.Priority = i + 1
+				mapping.Upstreams = append(mapping.Upstreams, u)
+			}
+
+			if len(builder.Splits) > 0 {
+				weight := builder.Weight
+				mapping.Weight = &weight
+				for _, split := range builder.Splits {
+					u := newUpstreamMapping("split", split.Upstream)
+					u.Weight = split.Weight
+					mapping.Upstreams = append(mapping.Upstreams, u)
+				}
+			}
+
+			for _, r := range builder.HeaderRoutes {
+				u := newUpstreamMapping("header_route", r.Upstream)
+				u.Header = r.Header
+				u.HeaderValue = r.Value
+				if r.Address != "" {
+					// ローカルプロセスはport-forwardしないため、ローカルポートではなくアドレスとして記録
+					u.Address = net.JoinHostPort(r.Address, strconv.Itoa(int(r.LocalPort)))
+					u.AssignedLocalPort = 0
+				}
+				mapping.Upstreams = append(mapping.Upstreams, u)
+			}
+
+			if builder.Mirror != nil {
+				u := newUpstreamMapping("mirror", builder.Mirror.Upstream)
+				u.Percent = builder.Mirror.Percent
+				mapping.Upstreams = append(mapping.Upstreams, u)
+			}
+
 			mappings = append(mappings, mapping)
 
 		case *envoy.TCPServiceBuilder:
 			mapping := PortForwardMapping{
 				Kind:                 "tcp",
 				Host:                 builder.Host,
+				Aliases:              builder.Aliases,
 				SSHBastion:           builder.SSHBastion,
 				TargetHost:           builder.TargetHost,
 				TargetPort:           int(builder.TargetPort),
@@ -74,6 +175,31 @@ func BuildMappings(configs []envoy.ServiceConfig) PortForwardMappingSet {
 				EnvoyClusterName:     cfg.ClusterName,
 			}
 			mappings = append(mappings, mapping)
+
+		case *envoy.GRPCAggregateBuilder:
+			mapping := PortForwardMapping{
+				Kind:              "grpc_aggregate",
+				Host:              builder.Host,
+				Protocol:          "grpc",
+				AssignedLocalPort: int(cfg.LocalPort),
+				EnvoyClusterName:  cfg.ClusterName,
+			}
+			for _, r := range builder.Routes {
+				mapping.GRPCRoutes = append(mapping.GRPCRoutes, GRPCRouteMapping{
+					Service:          r.Service,
+					EnvoyClusterName: r.ClusterName,
+				})
+			}
+			mappings = append(mappings, mapping)
+
+		case *envoy.DashboardBuilder:
+			mappings = append(mappings, PortForwardMapping{
+				Kind:              "dashboard",
+				Host:              builder.Host,
+				Protocol:          "http",
+				AssignedLocalPort: int(cfg.LocalPort),
+				EnvoyClusterName:  cfg.ClusterName,
+			})
 		}
 	}
 
@@ -81,3 +207,17 @@ func BuildMappings(configs []envoy.ServiceConfig) PortForwardMappingSet {
 		Services: mappings,
 	}
 }
+
+// newUpstreamMapping はUpstreamからUpstreamMappingを生成
+func newUpstreamMapping(role string, u envoy.Upstream) UpstreamMapping {
+	return UpstreamMapping{
+		Role:               role,
+		Namespace:          u.Namespace,
+		Service:            u.ServiceName,
+		PortName:           u.PortName,
+		Cluster:            u.Cluster,
+		ResolvedRemotePort: int(u.ResolvedRemotePort),
+		AssignedLocalPort:  int(u.LocalPort),
+		EnvoyClusterName:   u.ClusterName,
+	}
+}
@@ -1,6 +1,7 @@
 package snapshot_test
 
 import (
+	"reflect"
 	"testing"
 
 	"gopkg.in/yaml.v3"
@@ -201,6 +202,24 @@ func TestBuildMappings(t *testing.T) {
 		assertEqual(t, 8081, m.AssignedListenerPort)
 	})
 
+	t.Run("kubernetes services with shared listeners", func(t *testing.T) {
+		builder := envoy.NewKubernetesServiceBuilder(
+			"users.localhost", "http", "users", "users-api", "", 8080, 0, "",
+		)
+		builder.Listeners = []envoy.NamedListener{{}, {Name: "web", Port: 8081}}
+		configs := []envoy.ServiceConfig{
+			{Builder: builder, ClusterName: "users_users_api_8080", LocalPort: 10002, ResolvedRemotePort: 8080},
+		}
+
+		m := snapshot.BuildMappings(configs).Services[0]
+		// 共通HTTPリスナーはdefaultとして記録
+		want := []snapshot.ListenerMapping{{Name: "default"}, {Name: "web", Port: 8081}}
+		if !reflect.DeepEqual(m.Listeners, want) {
+			t.Errorf("expected listeners %v, got %v", want, m.Listeners)
+		}
+		assertEqual(t, 0, m.AssignedListenerPort)
+	})
+
 	t.Run("tcp services", func(t *testing.T) {
 		builder := envoy.NewTCPServiceBuilder(
 			"db.localhost", 5432, "127.0.0.2", "primary", "10.0.0.1", 5432,
@@ -256,6 +275,250 @@ func TestBuildMappings(t *testing.T) {
 		assertEqual(t, "gke_myproject_asia-northeast1_staging", m.Cluster)
 	})
 
+	t.Run("kubernetes service with mirror", func(t *testing.T) {
+		builder := envoy.NewKubernetesServiceBuilder(
+			"users.localhost", "http", "users", "users-api", "http", 0, 0, "",
+		)
+		builder.Mirror = &envoy.MirrorUpstream{
+			Upstream: envoy.Upstream{
+				ClusterName:        "users_users_api_8080_mirror",
+				LocalPort:          20000,
+				ResolvedRemotePort: 8080,
+				Namespace:          "users-canary",
+				ServiceName:        "users-api",
+				PortName:           "http",
+			},
+			Percent: 10,
+		}
+		configs := []envoy.ServiceConfig{
+			{
+				Builder:            builder,
+				ClusterName:        "users_users_api_8080",
+				LocalPort:          10000,
+				ResolvedRemotePort: 8080,
+			},
+		}
+
+		mappings := snapshot.BuildMappings(configs)
+
+		m := mappings.Services[0]
+		if len(m.Upstreams) != 1 {
+			t.Fatalf("expected 1 upstream, got %d", len(m.Upstreams))
+		}
+		u := m.Upstreams[0]
+		assertEqual(t, "mirror", u.Role)
+		assertEqual(t, "users-canary", u.Namespace)
+		assertEqual(t, "users-api", u.Service)
+		assertEqual(t, 8080, u.ResolvedRemotePort)
+		assertEqual(t, 20000, u.AssignedLocalPort)
+		assertEqual(t, "users_users_api_8080_mirror", u.EnvoyClusterName)
+		assertEqual(t, 10.0, u.Percent)
+	})
+
+	t.Run("kubernetes service with weighted split", func(t *testing.T) {
+		builder := envoy.NewKubernetesServiceBuilder(
+			"users.localhost", "http", "users", "users-api", "http", 0, 0, "",
+		)
+		builder.Weight = 0
+		builder.Splits = []envoy.WeightedUpstream{
+			{
+				Upstream: envoy.Upstream{
+					ClusterName:        "users_users_api_8080_split_1",
+					LocalPort:          20000,
+					ResolvedRemotePort: 8080,
+					Namespace:          "users",
+					ServiceName:        "users-api",
+					Cluster:            "other-cluster",
+				},
+				Weight: 100,
+			},
+		}
+		configs := []envoy.ServiceConfig{
+			{
+				Builder:            builder,
+				ClusterName:        "users_users_api_8080",
+				LocalPort:          10000,
+				ResolvedRemotePort: 8080,
+			},
+		}
+
+		mappings := snapshot.BuildMappings(configs)
+
+		m := mappings.Services[0]
+		// 重み0でもメインのバックエンドの重みを記録する
+		if m.Weight == nil {
+			t.Fatal("expected weight to be recorded")
+		}
+		assertEqual(t, 0, *m.Weight)
+		if len(m.Upstreams) != 1 {
+			t.Fatalf("expected 1 upstream, got %d", len(m.Upstreams))
+		}
+		u := m.Upstreams[0]
+		assertEqual(t, "split", u.Role)
+		assertEqual(t, "other-cluster", u.Cluster)
+		assertEqual(t, 100, u.Weight)
+		assertEqual(t, "users_users_api_8080_split_1", u.EnvoyClusterName)
+	})
+
+	t.Run("kubernetes service with failover clusters", func(t *testing.T) {
+		builder := envoy.NewKubernetesServiceBuilder(
+			"users.localhost", "http", "users", "users-api", "http", 0, 0, "tokyo",
+		)
+		builder.Failover = []envoy.Upstream{
+			{
+				ClusterName:        "users_users_api_8080",
+				LocalPort:          20000,
+				ResolvedRemotePort: 8080,
+				Namespace:          "users",
+				ServiceName:        "users-api",
+				PortName:           "http",
+				Cluster:            "osaka",
+			},
+		}
+		configs := []envoy.ServiceConfig{
+			{
+				Builder:            builder,
+				ClusterName:        "users_users_api_8080",
+				LocalPort:          10000,
+				ResolvedRemotePort: 8080,
+			},
+		}
+
+		mappings := snapshot.BuildMappings(configs)
+
+		m := mappings.Services[0]
+		assertEqual(t, "tokyo", m.Cluster)
+		if len(m.Upstreams) != 1 {
+			t.Fatalf("expected 1 upstream, got %d", len(m.Upstreams))
+		}
+		u := m.Upstreams[0]
+		assertEqual(t, "failover", u.Role)
+		assertEqual(t, "osaka", u.Cluster)
+		assertEqual(t, 1, u.Priority)
+		assertEqual(t, 20000, u.AssignedLocalPort)
+		assertEqual(t, "users_users_api_8080", u.EnvoyClusterName)
+	})
+
+	t.Run("kubernetes service with local override", func(t *testing.T) {
+		builder := envoy.NewKubernetesServiceBuilder(
+			"users.localhost", "http", "users", "users-api", "http", 0, 0, "",
+		)
+		builder.LocalOverride = &envoy.LocalOverride{Address: "127.0.0.1", Port: 8080, HealthPath: "/healthz"}
+		configs := []envoy.ServiceConfig{
+			{
+				Builder:            builder,
+				ClusterName:        "users_users_api_8080",
+				LocalPort:          10000,
+				ResolvedRemotePort: 8080,
+			},
+		}
+
+		mappings := snapshot.BuildMappings(configs)
+
+		assertEqual(t, "127.0.0.1:8080", mappings.Services[0].LocalOverrideAddress)
+	})
+
+	t.Run("kubernetes service with header routes", func(t *testing.T) {
+		builder := envoy.NewKubernetesServiceBuilder(
+			"users.localhost", "http", "users", "users-api", "http", 0, 0, "",
+		)
+		builder.HeaderRoutes = []envoy.HeaderRoute{
+			{
+				Header: "x-localmesh-route",
+				Value:  "alice",
+				Upstream: envoy.Upstream{
+					ClusterName:        "users_users_api_8080_header_1",
+					LocalPort:          20000,
+					ResolvedRemotePort: 8080,
+					Namespace:          "alice-dev",
+					ServiceName:        "users-api",
+				},
+			},
+			{
+				Header:   "x-localmesh-route",
+				Value:    "bob",
+				Upstream: envoy.Upstream{ClusterName: "users_users_api_8080_header_2", LocalPort: 8081},
+				Address:  "127.0.0.1",
+			},
+		}
+		configs := []envoy.ServiceConfig{
+			{
+				Builder:            builder,
+				ClusterName:        "users_users_api_8080",
+				LocalPort:          10000,
+				ResolvedRemotePort: 8080,
+			},
+		}
+
+		mappings := snapshot.BuildMappings(configs)
+
+		m := mappings.Services[0]
+		if len(m.Upstreams) != 2 {
+			t.Fatalf("expected 2 upstreams, got %d", len(m.Upstreams))
+		}
+		assertEqual(t, "header_route", m.Upstreams[0].Role)
+		assertEqual(t, "alice", m.Upstreams[0].HeaderValue)
+		assertEqual(t, "alice-dev", m.Upstreams[0].Namespace)
+		assertEqual(t, 20000, m.Upstreams[0].AssignedLocalPort)
+		// ローカルプロセスはアドレスとして記録
+		assertEqual(t, "bob", m.Upstreams[1].HeaderValue)
+		assertEqual(t, "127.0.0.1:8081", m.Upstreams[1].Address)
+		assertEqual(t, 0, m.Upstreams[1].AssignedLocalPort)
+	})
+
+	t.Run("grpc aggregate", func(t *testing.T) {
+		builder := envoy.NewGRPCAggregateBuilder("grpc.localhost", []envoy.GRPCServiceRoute{
+			{Service: "users.v1.UserService", ClusterName: "users_users_api_50051"},
+		})
+		configs := []envoy.ServiceConfig{
+			{
+				Builder:     builder,
+				ClusterName: envoy.GRPCReflectionClusterName,
+				LocalPort:   10003,
+			},
+		}
+
+		mappings := snapshot.BuildMappings(configs)
+
+		if len(mappings.Services) != 1 {
+			t.Fatalf("expected 1 service, got %d", len(mappings.Services))
+		}
+
+		m := mappings.Services[0]
+		assertEqual(t, "grpc_aggregate", m.Kind)
+		assertEqual(t, "grpc.localhost", m.Host)
+		assertEqual(t, 10003, m.AssignedLocalPort)
+		assertEqual(t, envoy.GRPCReflectionClusterName, m.EnvoyClusterName)
+		if len(m.GRPCRoutes) != 1 {
+			t.Fatalf("expected 1 grpc route, got %d", len(m.GRPCRoutes))
+		}
+		assertEqual(t, "users.v1.UserService", m.GRPCRoutes[0].Service)
+		assertEqual(t, "users_users_api_50051", m.GRPCRoutes[0].EnvoyClusterName)
+	})
+
+	t.Run("dashboard", func(t *testing.T) {
+		configs := []envoy.ServiceConfig{
+			{
+				Builder:     envoy.NewDashboardBuilder("localmesh.localhost"),
+				ClusterName: envoy.DashboardClusterName,
+				LocalPort:   10004,
+			},
+		}
+
+		mappings := snapshot.BuildMappings(configs)
+
+		if len(mappings.Services) != 1 {
+			t.Fatalf("expected 1 service, got %d", len(mappings.Services))
+		}
+
+		m := mappings.Services[0]
+		assertEqual(t, "dashboard", m.Kind)
+		assertEqual(t, "localmesh.localhost", m.Host)
+		assertEqual(t, "http", m.Protocol)
+		assertEqual(t, 10004, m.AssignedLocalPort)
+		assertEqual(t, envoy.DashboardClusterName, m.EnvoyClusterName)
+	})
+
 	t.Run("mixed services", func(t *testing.T) {
 		k8sBuilder := envoy.NewKubernetesServiceBuilder(
 			"api.localhost", "http", "default", "api", "http", 0, 0, "",
@@ -141,6 +141,144 @@ services:
 	}
 }
 
+func TestValidateSchema_Features(t *testing.T) {
+	// global・http・tcpはfeatureConfigの最上位・HTTPサービス・TCPサービスに追加するフィールド
+	tests := []struct {
+		name   string
+		global string
+		http   string
+		tcp    string
+		wantOK bool
+	}{
+		{name: "基本の設定", wantOK: true},
+
+		{name: "sni/sniのみ", tcp: "sni: true", wantOK: true},
+		{name: "sni/server_names指定", tcp: "sni: true\nserver_names: [db.localhost, '*.db.example.com']", wantOK: true},
+		{name: "sni/sniなしでserver_names", tcp: "server_names: [db.localhost]", wantOK: false},
+		{name: "sni/先頭以外のワイルドカード", tcp: "sni: true\nserver_names: ['db.*.example.com']", wantOK: false},
+
+		{name: "tcp_protocol/postgres", tcp: "protocol: postgres", wantOK: true},
+		{name: "tcp_protocol/mysql", tcp: "protocol: mysql", wantOK: true},
+		{name: "tcp_protocol/未対応のプロトコル", tcp: "protocol: redis", wantOK: false},
+		{name: "tcp_protocol/sniとの併用", tcp: "protocol: postgres\nsni: true", wantOK: false},
+
+		{name: "bind_address/グローバル", global: "bind_address: 0.0.0.0", wantOK: true},
+		{name: "bind_address/個別リスナー", http: "listener_port: 8081\nbind_address: 0.0.0.0", wantOK: true},
+		{name: "bind_address/listener_portなし", http: "bind_address: 0.0.0.0", wantOK: false},
+
+		{
+			name: "listener_auth/すべて指定",
+			global: `listener_auth:
+  basic:
+    username: alice
+    password:
+      env: LOCALMESH_PASSWORD
+  bearer_token:
+    file: ./token
+  allowed_cidrs: [192.168.1.0/24]`,
+			wantOK: true,
+		},
+		{name: "listener_auth/allowed_cidrsのみ", global: "listener_auth:\n  allowed_cidrs: [10.0.0.5]", wantOK: true},
+		{name: "listener_auth/設定なし", global: "listener_auth: {}", wantOK: false},
+		{name: "listener_auth/インラインの秘密", global: "listener_auth:\n  bearer_token: s3cret", wantOK: false},
+		{name: "listener_auth/envとfileの両方", global: "listener_auth:\n  bearer_token:\n    env: A\n    file: b", wantOK: false},
+		{name: "listener_auth/passwordなし", global: "listener_auth:\n  basic:\n    username: alice", wantOK: false},
+
+		{name: "dashboard/ホスト指定", global: "dashboard:\n  host: mesh.localhost", wantOK: true},
+		{name: "dashboard/無効化", global: "dashboard:\n  enabled: false", wantOK: true},
+		{name: "dashboard/未知のフィールド", global: "dashboard:\n  port: 8080", wantOK: false},
+
+		{name: "aliases/aliasesとcluster_dns_aliases", http: "aliases: [users.internal]\ncluster_dns_aliases: true", wantOK: true},
+		{name: "aliases/aliasesが文字列", http: "aliases: users.internal", wantOK: false},
+		{name: "aliases/cluster_dns_aliasesが文字列", http: `cluster_dns_aliases: "yes"`, wantOK: false},
+
+		{name: "listeners/名前付きリスナーに接続", global: "listeners:\n  web: 8081\n  api: 8082", http: "listeners: [default, web]", wantOK: true},
+		{name: "listeners/defaultは定義できない", global: "listeners:\n  default: 8081", wantOK: false},
+		{name: "listeners/名前に大文字", global: "listeners:\n  Web: 8081", wantOK: false},
+		{name: "listeners/ポートが範囲外", global: "listeners:\n  web: 70000", wantOK: false},
+		{name: "listeners/listener_portと併用", global: "listeners:\n  web: 8081", http: "listeners: [web]\nlistener_port: 9000", wantOK: false},
+		{name: "listeners/listenersが文字列", global: "listeners:\n  web: 8081", http: "listeners: web", wantOK: false},
+
+		{name: "mirror/全フィールド指定", http: "mirror: {namespace: users-canary, service: users-api, percent: 12.5}", wantOK: true},
+		{name: "mirror/service未指定", http: "mirror: {namespace: users-canary}", wantOK: false},
+		{name: "mirror/percentが範囲外", http: "mirror: {service: users-api, percent: 0}", wantOK: false},
+		{name: "mirror/未知のフィールド", http: "mirror: {service: users-api, weight: 10}", wantOK: false},
+
+		{name: "split/重み付き", http: "weight: 90\nsplit:\n  - namespace: users-canary\n    service: users-api\n    weight: 10", wantOK: true},
+		{name: "split/weight未指定", http: "weight: 90\nsplit: [{service: users-api}]", wantOK: false},
+		{name: "split/weightが0", http: "weight: 90\nsplit: [{service: users-api, weight: 0}]", wantOK: false},
+		{name: "split/未知のフィールド", http: "weight: 90\nsplit: [{service: users-api, weight: 10, percent: 10}]", wantOK: false},
+
+		{name: "clusters/優先順に2つ", http: "clusters: [tokyo, osaka]", wantOK: true},
+		{name: "clusters/1つのみ", http: "clusters: [tokyo]", wantOK: false},
+		{name: "clusters/重複", http: "clusters: [tokyo, tokyo]", wantOK: false},
+
+		{name: "local_override/addressとhealth_path", http: "local_override: {address: 'localhost:8080', health_path: /healthz}", wantOK: true},
+		{name: "local_override/addressのみ", http: "local_override: {address: '127.0.0.1:8080'}", wantOK: true},
+		{name: "local_override/address未指定", http: "local_override: {health_path: /healthz}", wantOK: false},
+		{name: "local_override/health_pathが/で始まらない", http: "local_override: {address: 'localhost:8080', health_path: healthz}", wantOK: false},
+
+		{name: "header_routes/Kubernetes Service", http: "header_routes: [{header: x-localmesh-route, value: alice, namespace: alice-dev, service: users-api}]", wantOK: true},
+		{name: "header_routes/ローカルプロセス", http: "header_routes: [{header: x-localmesh-route, value: bob, address: 'localhost:8081'}]", wantOK: true},
+		{name: "header_routes/header未指定", http: "header_routes: [{value: alice, service: users-api}]", wantOK: false},
+		{name: "header_routes/バックエンド未指定", http: "header_routes: [{header: x-localmesh-route, value: alice}]", wantOK: false},
+		{name: "header_routes/serviceとaddressを両方指定", http: "header_routes: [{header: x-localmesh-route, service: users-api, address: 'localhost:8081'}]", wantOK: false},
+
+		{name: "rate_limit/ホスト単位", http: "rate_limit: {requests: 10, fill_interval: 500ms, burst: 20}", wantOK: true},
+		{name: "rate_limit/グローバル", global: "rate_limit: {requests: 100}", http: "rate_limit: {requests: 10}", wantOK: true},
+		{name: "rate_limit/requests未指定", http: "rate_limit: {burst: 10}", wantOK: false},
+		{name: "rate_limit/不正なfill_interval", http: "rate_limit: {requests: 10, fill_interval: soon}", wantOK: false},
+		{name: "rate_limit/未知のフィールド", http: "rate_limit: {requests: 10, per: second}", wantOK: false},
+
+		{name: "access_log/ファイル・json", http: "access_log: {path: /tmp/users.log, format: json}", wantOK: true},
+		{name: "access_log/stdout・形式省略", http: "access_log: {path: stdout}", wantOK: true},
+		{name: "access_log/path未指定", http: "access_log: {format: json}", wantOK: false},
+		{name: "access_log/未知の形式", http: "access_log: {path: stdout, format: xml}", wantOK: false},
+
+		{name: "health_check/http・全フィールド指定", http: "health_check: {type: http, path: /healthz, interval: 10s, unhealthy_threshold: 2}", wantOK: true},
+		{name: "health_check/tcp", http: "health_check: {type: tcp}", wantOK: true},
+		{name: "health_check/type未指定", http: "health_check: {path: /healthz}", wantOK: false},
+		{name: "health_check/未知のtype", http: "health_check: {type: redis}", wantOK: false},
+		{name: "health_check/pathが/で始まらない", http: "health_check: {type: http, path: healthz}", wantOK: false},
+		{name: "health_check/unhealthy_thresholdが0", http: "health_check: {type: tcp, unhealthy_threshold: 0}", wantOK: false},
+
+		{name: "tracing/全フィールド指定", global: "tracing: {endpoint: 'localhost:4317', sampling: 12.5, service_name: my-mesh}", wantOK: true},
+		{name: "tracing/endpointのみ", global: "tracing: {endpoint: 'jaeger:4317'}", wantOK: true},
+		{name: "tracing/endpoint未指定", global: "tracing: {sampling: 10}", wantOK: false},
+		{name: "tracing/samplingが範囲外", global: "tracing: {endpoint: 'jaeger:4317', sampling: 101}", wantOK: false},
+
+		{name: "envoy_overrides/Merge Patch", global: "envoy_overrides:\n  - patch: {stats_flush_interval: 10s}", wantOK: true},
+		{name: "envoy_overrides/JSON Patch", global: "envoy_overrides:\n  - listener: listener_http\n    patch: [{op: remove, path: /access_log}]", wantOK: true},
+		{name: "envoy_overrides/ファイル", global: "envoy_overrides:\n  - cluster: users\n    file: envoy/cluster.yaml", wantOK: true},
+		{name: "envoy_overrides/patchとfileを両方指定", global: "envoy_overrides:\n  - patch: {}\n    file: envoy/cluster.yaml", wantOK: false},
+		{name: "envoy_overrides/patchとfileが未指定", global: "envoy_overrides:\n  - listener: listener_http", wantOK: false},
+		{name: "envoy_overrides/listenerとclusterを両方指定", global: "envoy_overrides:\n  - listener: a\n    cluster: b\n    patch: {}", wantOK: false},
+		{name: "envoy_overrides/patchがスカラー", global: "envoy_overrides:\n  - patch: stats", wantOK: false},
+
+		{name: "lua/インライン", http: "lua: 'function envoy_on_request(h) end'", wantOK: true},
+		{name: "lua/ファイル", http: "lua_file: filters/strip.lua", wantOK: true},
+		{name: "lua/両方指定", http: "lua: 'function envoy_on_request(h) end'\nlua_file: filters/strip.lua", wantOK: false},
+
+		{name: "host_rewrite/真偽値", http: "rewrite_redirects: true", wantOK: true},
+		{name: "host_rewrite/ホスト名のリスト", http: "cookie_domain_rewrite: [auth.example.com]", wantOK: true},
+		{name: "host_rewrite/文字列", http: "rewrite_redirects: auth.example.com", wantOK: false},
+		{name: "host_rewrite/空のリスト", http: "cookie_domain_rewrite: []", wantOK: false},
+
+		{name: "grpc_aggregate/host指定", global: "grpc_aggregate:\n  host: grpc.localhost", wantOK: true},
+		{name: "grpc_aggregate/host未指定", global: "grpc_aggregate: {}", wantOK: false},
+		{name: "grpc_aggregate/未知のフィールド", global: "grpc_aggregate:\n  host: grpc.localhost\n  timeout: 10s", wantOK: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := validateYAMLContent(t, featureConfig(tt.global, tt.http, tt.tcp))
+			if result.OK() != tt.wantOK {
+				t.Errorf("expected OK=%v, got errors: %v", tt.wantOK, result.Errors)
+			}
+		})
+	}
+}
+
 func TestValidateSchema_GlobalCluster(t *testing.T) {
 	content := `
 cluster: gke_myproject_asia-northeast1_staging
@@ -676,3 +814,37 @@ func assertContainsError(t *testing.T, result *ValidationResult, substr string)
 	}
 	t.Errorf("expected error containing %q, got: %v", substr, result.Errors)
 }
+
+// featureConfig はHTTPサービスとTCPサービスを1つずつ持つ設定に、最上位・各サービスのフィールドを追加して返す
+// フィールドは行ごとに、最上位はそのまま、サービスはエントリの深さにインデントして追加する
+func featureConfig(global, http, tcp string) string {
+	return global + `
+ssh_bastions:
+  primary:
+    instance: bastion-1
+    zone: asia-northeast1-a
+services:
+  - kind: kubernetes
+    host: users.localhost
+    namespace: users
+    service: users-api
+    protocol: http
+` + indentFields(http) + `  - kind: tcp
+    host: db.localhost
+    ssh_bastion: primary
+    target_host: 10.0.0.1
+    target_port: 5432
+` + indentFields(tcp)
+}
+
+// indentFields はフィールドの各行をサービスのエントリの深さにインデントする
+func indentFields(fields string) string {
+	if fields == "" {
+		return ""
+	}
+	var b strings.Builder
+	for _, line := range strings.Split(fields, "\n") {
+		b.WriteString("    " + line + "\n")
+	}
+	return b.String()
+}
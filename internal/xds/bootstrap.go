@@ -0,0 +1,97 @@
+package xds
+
+import (
+	"time"
+
+	bootstrapv3 "github.com/envoyproxy/go-control-plane/envoy/config/bootstrap/v3"
+	clusterv3 "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
+	corev3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	endpointv3 "github.com/envoyproxy/go-control-plane/envoy/config/endpoint/v3"
+	upstreamhttpv3 "github.com/envoyproxy/go-control-plane/envoy/extensions/upstreams/http/v3"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// ClusterName はADSサーバーへ接続するための静的クラスタ名
+const ClusterName = "localmesh_xds"
+
+// Bootstrap はADSサーバーからリスナー・クラスタを取得するEnvoyのbootstrapを生成
+// overload_managerやenvoy_overridesで追加した設定など、動的に配信しない設定は静的な設定から引き継ぐ
+func Bootstrap(cfg *bootstrapv3.Bootstrap, socketPath string) (*bootstrapv3.Bootstrap, error) {
+	cluster, err := xdsCluster(socketPath)
+	if err != nil {
+		return nil, err
+	}
+
+	bootstrap := proto.Clone(cfg).(*bootstrapv3.Bootstrap)
+	bootstrap.Node = &corev3.Node{
+		Id:      NodeID,
+		Cluster: NodeID,
+	}
+	bootstrap.DynamicResources = &bootstrapv3.Bootstrap_DynamicResources{
+		AdsConfig: &corev3.ApiConfigSource{
+			ApiType:             corev3.ApiConfigSource_GRPC,
+			TransportApiVersion: corev3.ApiVersion_V3,
+			GrpcServices: []*corev3.GrpcService{
+				{
+					TargetSpecifier: &corev3.GrpcService_EnvoyGrpc_{
+						EnvoyGrpc: &corev3.GrpcService_EnvoyGrpc{ClusterName: ClusterName},
+					},
+				},
+			},
+			SetNodeOnFirstMessageOnly: true,
+		},
+		LdsConfig: adsConfigSource(),
+		CdsConfig: adsConfigSource(),
+	}
+	bootstrap.StaticResources = &bootstrapv3.Bootstrap_StaticResources{
+		Clusters: []*clusterv3.Cluster{cluster},
+	}
+	return bootstrap, nil
+}
+
+// xdsCluster はunixソケット上のADSサーバーへHTTP/2で接続するクラスタを生成
+func xdsCluster(socketPath string) (*clusterv3.Cluster, error) {
+	protocolOptions, err := anypb.New(&upstreamhttpv3.HttpProtocolOptions{
+		UpstreamProtocolOptions: &upstreamhttpv3.HttpProtocolOptions_ExplicitHttpConfig_{
+			ExplicitHttpConfig: &upstreamhttpv3.HttpProtocolOptions_ExplicitHttpConfig{
+				ProtocolConfig: &upstreamhttpv3.HttpProtocolOptions_ExplicitHttpConfig_Http2ProtocolOptions{
+					Http2ProtocolOptions: &corev3.Http2ProtocolOptions{},
+				},
+			},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &clusterv3.Cluster{
+		Name:                 ClusterName,
+		ClusterDiscoveryType: &clusterv3.Cluster_Type{Type: clusterv3.Cluster_STATIC},
+		ConnectTimeout:       durationpb.New(time.Second),
+		LoadAssignment: &endpointv3.ClusterLoadAssignment{
+			ClusterName: ClusterName,
+			Endpoints: []*endpointv3.LocalityLbEndpoints{
+				{
+					LbEndpoints: []*endpointv3.LbEndpoint{
+						{
+							HostIdentifier: &endpointv3.LbEndpoint_Endpoint{
+								Endpoint: &endpointv3.Endpoint{
+									Address: &corev3.Address{
+										Address: &corev3.Address_Pipe{
+											Pipe: &corev3.Pipe{Path: socketPath},
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		TypedExtensionProtocolOptions: map[string]*anypb.Any{
+			"envoy.extensions.upstreams.http.v3.HttpProtocolOptions": protocolOptions,
+		},
+	}, nil
+}
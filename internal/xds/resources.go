@@ -0,0 +1,85 @@
+package xds
+
+import (
+	"fmt"
+
+	bootstrapv3 "github.com/envoyproxy/go-control-plane/envoy/config/bootstrap/v3"
+	corev3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	listenerv3 "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
+	hcmv3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/cache/types"
+	resourcev3 "github.com/envoyproxy/go-control-plane/pkg/resource/v3"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+// Resources は静的なEnvoy設定をxDSのリソース（LDS・RDS・CDS）に分解
+// HTTP connection managerのroute_configはRDSへ切り出し、
+// ホストの追加・変更でリスナーを作り直さない（既存の接続を切らない）ようにする
+func Resources(cfg *bootstrapv3.Bootstrap) (map[resourcev3.Type][]types.Resource, error) {
+	static := cfg.GetStaticResources()
+
+	var listeners, routes, clusters []types.Resource
+	for _, l := range static.GetListeners() {
+		listener := proto.Clone(l).(*listenerv3.Listener)
+		for _, chain := range listener.GetFilterChains() {
+			for _, filter := range chain.GetFilters() {
+				route, err := extractRouteConfig(filter)
+				if err != nil {
+					return nil, fmt.Errorf("listener %s: %w", listener.GetName(), err)
+				}
+				if route != nil {
+					routes = append(routes, route)
+				}
+			}
+		}
+		listeners = append(listeners, listener)
+	}
+	for _, c := range static.GetClusters() {
+		clusters = append(clusters, c)
+	}
+
+	return map[resourcev3.Type][]types.Resource{
+		resourcev3.ListenerType: listeners,
+		resourcev3.RouteType:    routes,
+		resourcev3.ClusterType:  clusters,
+	}, nil
+}
+
+// extractRouteConfig はHTTP connection managerのroute_configを取り出し、ADS経由のRDSに置き換える
+// HTTP connection manager以外のフィルタ（tcp_proxyなど）の場合はnilを返す
+func extractRouteConfig(filter *listenerv3.Filter) (types.Resource, error) {
+	typed := filter.GetTypedConfig()
+	if typed == nil || !typed.MessageIs(&hcmv3.HttpConnectionManager{}) {
+		return nil, nil
+	}
+	hcm := &hcmv3.HttpConnectionManager{}
+	if err := typed.UnmarshalTo(hcm); err != nil {
+		return nil, fmt.Errorf("failed to unpack http connection manager: %w", err)
+	}
+	route := hcm.GetRouteConfig()
+	if route == nil {
+		return nil, nil
+	}
+
+	hcm.RouteSpecifier = &hcmv3.HttpConnectionManager_Rds{
+		Rds: &hcmv3.Rds{
+			RouteConfigName: route.GetName(),
+			ConfigSource:    adsConfigSource(),
+		},
+	}
+	repacked, err := anypb.New(hcm)
+	if err != nil {
+		return nil, fmt.Errorf("failed to pack http connection manager: %w", err)
+	}
+	filter.ConfigType = &listenerv3.Filter_TypedConfig{TypedConfig: repacked}
+	return route, nil
+}
+
+// adsConfigSource はADSストリームから取得するリソースの設定元
+func adsConfigSource() *corev3.ConfigSource {
+	return &corev3.ConfigSource{
+		ConfigSourceSpecifier: &corev3.ConfigSource_Ads{Ads: &corev3.AggregatedConfigSource{}},
+		ResourceApiVersion:    corev3.ApiVersion_V3,
+	}
+}
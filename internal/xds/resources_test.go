@@ -0,0 +1,151 @@
+package xds
+
+import (
+	"testing"
+	"time"
+
+	bootstrapv3 "github.com/envoyproxy/go-control-plane/envoy/config/bootstrap/v3"
+	listenerv3 "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
+	routev3 "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	hcmv3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
+	resourcev3 "github.com/envoyproxy/go-control-plane/pkg/resource/v3"
+	"google.golang.org/protobuf/types/known/durationpb"
+
+	"github.com/usadamasa/kubectl-localmesh/internal/envoy"
+	"github.com/usadamasa/kubectl-localmesh/internal/port"
+)
+
+// buildConfig はEnvoy設定を生成し、エラーの場合はテストを失敗させる
+func buildConfig(t *testing.T, configs []envoy.ServiceConfig) *bootstrapv3.Bootstrap {
+	t.Helper()
+	cfg, err := envoy.BuildConfig(80, configs)
+	if err != nil {
+		t.Fatalf("failed to build config: %v", err)
+	}
+	return cfg
+}
+
+// testConfig は共通HTTPリスナー・個別リスナー・TCPリスナーを持つEnvoy設定を生成
+func testConfig(hosts ...string) []envoy.ServiceConfig {
+	var configs []envoy.ServiceConfig
+	for i, host := range hosts {
+		configs = append(configs, envoy.ServiceConfig{
+			Builder:     envoy.NewKubernetesServiceBuilder(host, "http", "default", host, "http", 8080, 0, ""),
+			ClusterName: host + "_cluster",
+			LocalPort:   port.LocalPort(10001 + i),
+		})
+	}
+	configs = append(configs,
+		envoy.ServiceConfig{
+			Builder:     envoy.NewKubernetesServiceBuilder("grpc.localhost", "grpc", "default", "grpc", "grpc", 50051, 50051, ""),
+			ClusterName: "grpc_cluster",
+			LocalPort:   10100,
+		},
+		envoy.ServiceConfig{
+			Builder:     envoy.NewTCPServiceBuilder("db.localhost", 5432, "127.0.0.2", "bastion", "10.0.0.1", 5432),
+			ClusterName: "db_cluster",
+			LocalPort:   10200,
+		},
+	)
+	return configs
+}
+
+func TestResources(t *testing.T) {
+	cfg := buildConfig(t, testConfig("users.localhost", "billing.localhost"))
+
+	resources, err := Resources(cfg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	listeners := resources[resourcev3.ListenerType]
+	routes := resources[resourcev3.RouteType]
+	clusters := resources[resourcev3.ClusterType]
+	if len(listeners) != 3 {
+		t.Fatalf("expected 3 listeners, got %d", len(listeners))
+	}
+	if len(clusters) != 4 {
+		t.Errorf("expected 4 clusters, got %d", len(clusters))
+	}
+
+	// HTTPリスナーのroute_configはRDSに切り出す（TCPリスナーにはルートがない）
+	wantRoutes := []string{"local_route", "route_grpc_cluster_50051"}
+	if len(routes) != len(wantRoutes) {
+		t.Fatalf("expected %d routes, got %d", len(wantRoutes), len(routes))
+	}
+	for i, name := range wantRoutes {
+		if got := routes[i].(*routev3.RouteConfiguration).GetName(); got != name {
+			t.Errorf("route[%d]: expected %s, got %s", i, name, got)
+		}
+	}
+	shared := routes[0].(*routev3.RouteConfiguration)
+	if len(shared.GetVirtualHosts()) != 2 {
+		t.Errorf("expected 2 virtual hosts on shared route, got %d", len(shared.GetVirtualHosts()))
+	}
+
+	for _, l := range listeners[:2] {
+		hcm := &hcmv3.HttpConnectionManager{}
+		if err := l.(*listenerv3.Listener).GetFilterChains()[0].GetFilters()[0].GetTypedConfig().UnmarshalTo(hcm); err != nil {
+			t.Fatal(err)
+		}
+		if hcm.GetRouteConfig() != nil {
+			t.Error("expected inline route_config to be removed")
+		}
+		if hcm.GetRds().GetConfigSource().GetAds() == nil {
+			t.Errorf("expected rds over ads, got %v", hcm.GetRouteSpecifier())
+		}
+	}
+
+	// 元の設定（dump-envoy-configと共通）は変更しない
+	original := &hcmv3.HttpConnectionManager{}
+	if err := cfg.GetStaticResources().GetListeners()[0].GetFilterChains()[0].GetFilters()[0].GetTypedConfig().UnmarshalTo(original); err != nil {
+		t.Fatal(err)
+	}
+	if original.GetRouteConfig() == nil {
+		t.Error("expected static config to keep inline route_config")
+	}
+}
+
+func TestBootstrap(t *testing.T) {
+	cfg := buildConfig(t, testConfig("users.localhost"))
+	// envoy_overridesで追加したbootstrapの設定
+	cfg.StatsFlushInterval = durationpb.New(10 * time.Second)
+
+	bootstrap, err := Bootstrap(cfg, "/tmp/localmesh/xds.sock")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := envoy.Validate(bootstrap); err != nil {
+		t.Fatalf("expected valid bootstrap, got %v", err)
+	}
+
+	if bootstrap.GetNode().GetId() != NodeID {
+		t.Errorf("expected node id %s, got %s", NodeID, bootstrap.GetNode().GetId())
+	}
+	dynamic := bootstrap.GetDynamicResources()
+	if dynamic.GetLdsConfig().GetAds() == nil || dynamic.GetCdsConfig().GetAds() == nil {
+		t.Error("expected lds/cds over ads")
+	}
+	if got := dynamic.GetAdsConfig().GetGrpcServices()[0].GetEnvoyGrpc().GetClusterName(); got != ClusterName {
+		t.Errorf("expected ads cluster %s, got %s", ClusterName, got)
+	}
+
+	// リスナー・クラスタはbootstrapに含めず、ADSサーバーへの静的クラスタのみを持つ
+	static := bootstrap.GetStaticResources()
+	if len(static.GetListeners()) != 0 || len(static.GetClusters()) != 1 {
+		t.Fatalf("expected only the xds cluster, got %d listeners and %d clusters", len(static.GetListeners()), len(static.GetClusters()))
+	}
+	pipe := static.GetClusters()[0].GetLoadAssignment().GetEndpoints()[0].GetLbEndpoints()[0].GetEndpoint().GetAddress().GetPipe()
+	if pipe.GetPath() != "/tmp/localmesh/xds.sock" {
+		t.Errorf("expected pipe address, got %v", pipe)
+	}
+	if bootstrap.GetOverloadManager() == nil {
+		t.Error("expected overload_manager to be carried over")
+	}
+	if bootstrap.GetStatsFlushInterval().AsDuration() != 10*time.Second {
+		t.Errorf("expected stats_flush_interval to be carried over, got %v", bootstrap.GetStatsFlushInterval())
+	}
+	if len(cfg.GetStaticResources().GetListeners()) == 0 {
+		t.Error("expected static config to be unchanged")
+	}
+}
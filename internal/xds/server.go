@@ -0,0 +1,113 @@
+package xds
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"sync"
+
+	bootstrapv3 "github.com/envoyproxy/go-control-plane/envoy/config/bootstrap/v3"
+	discoveryv3 "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	cachev3 "github.com/envoyproxy/go-control-plane/pkg/cache/v3"
+	serverv3 "github.com/envoyproxy/go-control-plane/pkg/server/v3"
+	"google.golang.org/grpc"
+
+	"github.com/usadamasa/kubectl-localmesh/internal/envoy"
+	"github.com/usadamasa/kubectl-localmesh/internal/log"
+)
+
+// NodeID はEnvoyのbootstrapとスナップショットで共有するノードID
+const NodeID = "kubectl-localmesh"
+
+// Server はEnvoyにリスナー・ルート・クラスタを配信するプロセス内のADSサーバー
+// Updateでスナップショットを差し替えると、接続中のEnvoyへ差分として反映される
+type Server struct {
+	cache  cachev3.SnapshotCache
+	logger *log.Logger
+
+	mu      sync.Mutex
+	version int
+}
+
+// NewServer はServerを生成
+func NewServer(logger *log.Logger) *Server {
+	return &Server{
+		cache:  cachev3.NewSnapshotCache(true, cachev3.IDHash{}, nil),
+		logger: logger,
+	}
+}
+
+// Start はunixソケットでADSの提供を開始
+// ソケットの作成までは同期的に行うため、戻った時点でEnvoyを起動できる
+// contextのキャンセルで停止し、ソケットを削除する
+func (s *Server) Start(ctx context.Context, socketPath string) error {
+	lis, err := net.Listen("unix", socketPath)
+	if err != nil {
+		return fmt.Errorf("failed to listen on xds socket %s: %w", socketPath, err)
+	}
+
+	grpcServer := grpc.NewServer()
+	xdsServer := serverv3.NewServer(ctx, s.cache, serverv3.CallbackFuncs{
+		StreamRequestFunc: s.onStreamRequest,
+	})
+	discoveryv3.RegisterAggregatedDiscoveryServiceServer(grpcServer, xdsServer)
+
+	go func() {
+		<-ctx.Done()
+		// ADSのストリームはEnvoyが終了するまで閉じないため、GracefulStopは使わない
+		grpcServer.Stop()
+		_ = os.Remove(socketPath)
+	}()
+	go func() {
+		if err := grpcServer.Serve(lis); err != nil {
+			fmt.Fprintf(os.Stderr, "warning: xds server stopped: %v\n", err)
+		}
+	}()
+	return nil
+}
+
+// Update はEnvoy設定を検証し、新しいバージョンのスナップショットとして配信
+func (s *Server) Update(ctx context.Context, cfg *bootstrapv3.Bootstrap) error {
+	if err := envoy.Validate(cfg); err != nil {
+		return err
+	}
+	resources, err := Resources(cfg)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	version := strconv.Itoa(s.version + 1)
+	snapshot, err := cachev3.NewSnapshot(version, resources)
+	if err != nil {
+		return fmt.Errorf("failed to create xds snapshot: %w", err)
+	}
+	if err := snapshot.Consistent(); err != nil {
+		return fmt.Errorf("inconsistent xds snapshot: %w", err)
+	}
+	if err := s.cache.SetSnapshot(ctx, NodeID, snapshot); err != nil {
+		return fmt.Errorf("failed to set xds snapshot: %w", err)
+	}
+	s.version++
+	s.logger.Debugf("xds snapshot version %s pushed", version)
+	return nil
+}
+
+// Version は最後に配信したスナップショットのバージョン（未配信の場合は0）
+func (s *Server) Version() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.version
+}
+
+// onStreamRequest はEnvoyからのリクエストを監視し、設定の拒否（NACK）をログ出力する
+func (s *Server) onStreamRequest(_ int64, req *discoveryv3.DiscoveryRequest) error {
+	if detail := req.GetErrorDetail(); detail != nil {
+		fmt.Fprintf(os.Stderr, "warning: envoy rejected %s (version %s): %s\n", req.GetTypeUrl(), req.GetVersionInfo(), detail.GetMessage())
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+package xds
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	corev3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	routev3 "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	discoveryv3 "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	resourcev3 "github.com/envoyproxy/go-control-plane/pkg/resource/v3"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+
+	"github.com/usadamasa/kubectl-localmesh/internal/envoy"
+	"github.com/usadamasa/kubectl-localmesh/internal/log"
+)
+
+func TestServer_Update(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	server := NewServer(log.New("error"))
+	socketPath := filepath.Join(t.TempDir(), "xds.sock")
+	if err := server.Start(ctx, socketPath); err != nil {
+		t.Fatalf("failed to start: %v", err)
+	}
+	if err := server.Update(ctx, buildConfig(t, testConfig("users.localhost"))); err != nil {
+		t.Fatalf("failed to update: %v", err)
+	}
+
+	conn, err := grpc.NewClient("unix://"+socketPath, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = conn.Close() }()
+	stream, err := discoveryv3.NewAggregatedDiscoveryServiceClient(conn).StreamAggregatedResources(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Envoyと同様にLDSで参照される全てのルートを購読し、初回のスナップショットを受け取る
+	// ADSモードのキャッシュは、スナップショット内の全リソースが要求されるまで応答しない
+	names := []string{"local_route", "route_grpc_cluster_50051"}
+	if err := stream.Send(&discoveryv3.DiscoveryRequest{
+		Node:          &corev3.Node{Id: NodeID},
+		TypeUrl:       resourcev3.RouteType,
+		ResourceNames: names,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	first := recvRoute(t, stream)
+	if first.version != "1" || first.virtualHosts != 1 {
+		t.Fatalf("expected version 1 with 1 virtual host, got %+v", first)
+	}
+
+	// ACK後にホストを追加すると、同じストリームで次のバージョンが配信される
+	if err := stream.Send(&discoveryv3.DiscoveryRequest{
+		Node:          &corev3.Node{Id: NodeID},
+		TypeUrl:       resourcev3.RouteType,
+		ResourceNames: names,
+		VersionInfo:   first.version,
+		ResponseNonce: first.nonce,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := server.Update(ctx, buildConfig(t, testConfig("users.localhost", "billing.localhost"))); err != nil {
+		t.Fatalf("failed to update: %v", err)
+	}
+	second := recvRoute(t, stream)
+	if second.version != "2" || second.virtualHosts != 2 {
+		t.Errorf("expected version 2 with 2 virtual hosts, got %+v", second)
+	}
+	if server.Version() != 2 {
+		t.Errorf("expected server version 2, got %d", server.Version())
+	}
+}
+
+func TestServer_Update_Invalid(t *testing.T) {
+	server := NewServer(log.New("error"))
+	builder := envoy.NewKubernetesServiceBuilder("api.localhost", "http", "default", "api", "http", 8080, 0, "")
+	builder.RateLimit = &envoy.RateLimit{Requests: 1}
+
+	err := server.Update(context.Background(), buildConfig(t, []envoy.ServiceConfig{{Builder: builder, ClusterName: "api_cluster", LocalPort: 10001}}))
+	if err == nil {
+		t.Fatal("expected error, got nil")
+	}
+	if server.Version() != 0 {
+		t.Errorf("expected no snapshot to be pushed, got version %d", server.Version())
+	}
+}
+
+// routeResponse はRDSレスポンスの要約（virtualHostsは共通HTTPリスナーのもの）
+type routeResponse struct {
+	version      string
+	nonce        string
+	virtualHosts int
+}
+
+func recvRoute(t *testing.T, stream discoveryv3.AggregatedDiscoveryService_StreamAggregatedResourcesClient) routeResponse {
+	t.Helper()
+	resp, err := stream.Recv()
+	if err != nil {
+		t.Fatalf("failed to receive: %v", err)
+	}
+	if len(resp.GetResources()) != 2 {
+		t.Fatalf("expected 2 route configurations, got %d", len(resp.GetResources()))
+	}
+	got := routeResponse{
+		version: resp.GetVersionInfo(),
+		nonce:   resp.GetNonce(),
+	}
+	for _, res := range resp.GetResources() {
+		route := &routev3.RouteConfiguration{}
+		if err := res.UnmarshalTo(route); err != nil {
+			t.Fatal(err)
+		}
+		if route.GetName() == "local_route" {
+			got.virtualHosts = len(route.GetVirtualHosts())
+		}
+	}
+	return got
+}
@@ -3,6 +3,7 @@ set -euo pipefail
 
 LOCALMESH_HOST="${LOCALMESH_HOST:-localmesh}"
 LOCALMESH_PORT="${LOCALMESH_PORT:-18080}"
+JAEGER_URL="${JAEGER_URL:-http://jaeger:16686}"
 
 echo "=== E2E Test Suite ==="
 echo "Target: ${LOCALMESH_HOST}:${LOCALMESH_PORT}"
@@ -59,5 +60,27 @@ else
     exit 1
 fi
 
+echo ""
+
+# トレーシングテスト
+echo "--- Test: Tracing ---"
+
+# EnvoyはスパンをバッチでエクスポートするためJaegerに届くまで待つ
+traces=0
+for _ in $(seq 1 15); do
+    traces=$(curl -s "${JAEGER_URL}/api/traces?service=kubectl-localmesh&limit=20" | jq '.data | length' 2>/dev/null || echo 0)
+    if [ "${traces:-0}" -gt 0 ]; then
+        break
+    fi
+    sleep 2
+done
+
+if [ "${traces:-0}" -gt 0 ]; then
+    echo "PASSED: Tracing (${traces} traces in Jaeger)"
+else
+    echo "FAILED: Tracing (no traces for service kubectl-localmesh)"
+    exit 1
+fi
+
 echo ""
 echo "=== All tests passed ==="
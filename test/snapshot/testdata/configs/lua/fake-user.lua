@@ -0,0 +1,8 @@
+-- 開発用のユーザーIDを付与し、内部ヘッダーを取り除く
+function envoy_on_request(request_handle)
+  request_handle:headers():replace("x-user-id", "dev-user-1")
+end
+
+function envoy_on_response(response_handle)
+  response_handle:headers():remove("x-internal-trace")
+end